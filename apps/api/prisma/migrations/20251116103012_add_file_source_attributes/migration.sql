@@ -0,0 +1,9 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "is3D" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isRemux" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "sourceType" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "is3D" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isRemux" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "sourceType" TEXT;
@@ -0,0 +1,32 @@
+-- CreateTable
+CREATE TABLE "ScanAddition" (
+    "id" TEXT NOT NULL,
+    "libraryId" TEXT NOT NULL,
+    "scanJobId" TEXT,
+    "mediaId" TEXT NOT NULL,
+    "episodeId" TEXT,
+    "addedAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "ScanAddition_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "ScanAddition_libraryId_addedAt_idx" ON "ScanAddition"("libraryId", "addedAt");
+
+-- CreateIndex
+CREATE INDEX "ScanAddition_scanJobId_idx" ON "ScanAddition"("scanJobId");
+
+-- CreateIndex
+CREATE INDEX "ScanAddition_mediaId_idx" ON "ScanAddition"("mediaId");
+
+-- AddForeignKey
+ALTER TABLE "ScanAddition" ADD CONSTRAINT "ScanAddition_libraryId_fkey" FOREIGN KEY ("libraryId") REFERENCES "Library"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "ScanAddition" ADD CONSTRAINT "ScanAddition_scanJobId_fkey" FOREIGN KEY ("scanJobId") REFERENCES "ScanJob"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "ScanAddition" ADD CONSTRAINT "ScanAddition_mediaId_fkey" FOREIGN KEY ("mediaId") REFERENCES "Media"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "ScanAddition" ADD CONSTRAINT "ScanAddition_episodeId_fkey" FOREIGN KEY ("episodeId") REFERENCES "Episode"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "scannerVersion" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "scannerVersion" TEXT;
@@ -0,0 +1,9 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "isInternal" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isProper" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isRepack" BOOLEAN NOT NULL DEFAULT false;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "isInternal" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isProper" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "isRepack" BOOLEAN NOT NULL DEFAULT false;
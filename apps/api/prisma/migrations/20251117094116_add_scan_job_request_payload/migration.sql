@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE "ScanJob" ADD COLUMN     "requestPayload" TEXT,
+ADD COLUMN     "resumedAfterRestartAt" TIMESTAMP(3);
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "resolution" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "resolution" TEXT;
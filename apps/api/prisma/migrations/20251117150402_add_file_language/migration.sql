@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "language" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "language" TEXT;
@@ -0,0 +1,23 @@
+-- CreateTable
+CREATE TABLE "PathOverride" (
+    "id" TEXT NOT NULL,
+    "libraryId" TEXT NOT NULL,
+    "path" TEXT NOT NULL,
+    "title" TEXT,
+    "year" INTEGER,
+    "season" INTEGER,
+    "episode" INTEGER,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "PathOverride_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "PathOverride_libraryId_idx" ON "PathOverride"("libraryId");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "PathOverride_libraryId_path_key" ON "PathOverride"("libraryId", "path");
+
+-- AddForeignKey
+ALTER TABLE "PathOverride" ADD CONSTRAINT "PathOverride_libraryId_fkey" FOREIGN KEY ("libraryId") REFERENCES "Library"("id") ON DELETE CASCADE ON UPDATE CASCADE;
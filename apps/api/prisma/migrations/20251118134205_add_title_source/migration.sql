@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "titleSource" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "titleSource" TEXT;
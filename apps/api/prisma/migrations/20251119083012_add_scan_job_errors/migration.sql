@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE "ScanJob" ADD COLUMN     "errorCount" INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN     "errorDirectories" TEXT NOT NULL DEFAULT '[]';
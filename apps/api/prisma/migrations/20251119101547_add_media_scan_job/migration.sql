@@ -0,0 +1,17 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "scanJobId" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "scanJobId" TEXT;
+
+-- CreateIndex
+CREATE INDEX "Episode_scanJobId_idx" ON "Episode"("scanJobId");
+
+-- CreateIndex
+CREATE INDEX "Movie_scanJobId_idx" ON "Movie"("scanJobId");
+
+-- AddForeignKey
+ALTER TABLE "Movie" ADD CONSTRAINT "Movie_scanJobId_fkey" FOREIGN KEY ("scanJobId") REFERENCES "ScanJob"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "Episode" ADD CONSTRAINT "Episode_scanJobId_fkey" FOREIGN KEY ("scanJobId") REFERENCES "ScanJob"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,23 @@
+-- CreateTable
+CREATE TABLE "MovieExtra" (
+    "id" TEXT NOT NULL,
+    "movieId" TEXT NOT NULL,
+    "extraType" TEXT NOT NULL,
+    "title" TEXT NOT NULL,
+    "filePath" TEXT NOT NULL,
+    "fileSize" BIGINT,
+    "fileModifiedAt" TIMESTAMP(3),
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "MovieExtra_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "MovieExtra_filePath_key" ON "MovieExtra"("filePath");
+
+-- CreateIndex
+CREATE INDEX "MovieExtra_movieId_idx" ON "MovieExtra"("movieId");
+
+-- AddForeignKey
+ALTER TABLE "MovieExtra" ADD CONSTRAINT "MovieExtra_movieId_fkey" FOREIGN KEY ("movieId") REFERENCES "Movie"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,8 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "missingSince" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "missingSince" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "MovieExtra" ADD COLUMN     "missingSince" TIMESTAMP(3);
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Episode" ADD COLUMN     "parseHash" TEXT;
+
+-- AlterTable
+ALTER TABLE "Movie" ADD COLUMN     "parseHash" TEXT;
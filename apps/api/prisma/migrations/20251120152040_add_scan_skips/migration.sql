@@ -0,0 +1,17 @@
+-- CreateTable
+CREATE TABLE "ScanSkip" (
+    "id" TEXT NOT NULL,
+    "scanJobId" TEXT NOT NULL,
+    "path" TEXT NOT NULL,
+    "reason" TEXT NOT NULL,
+    "detail" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "ScanSkip_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "ScanSkip_scanJobId_reason_idx" ON "ScanSkip"("scanJobId", "reason");
+
+-- AddForeignKey
+ALTER TABLE "ScanSkip" ADD CONSTRAINT "ScanSkip_scanJobId_fkey" FOREIGN KEY ("scanJobId") REFERENCES "ScanJob"("id") ON DELETE CASCADE ON UPDATE CASCADE;
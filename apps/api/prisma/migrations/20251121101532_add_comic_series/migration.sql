@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "Comic" ADD COLUMN     "series" TEXT;
+
+-- CreateIndex
+CREATE INDEX "Comic_series_idx" ON "Comic"("series");
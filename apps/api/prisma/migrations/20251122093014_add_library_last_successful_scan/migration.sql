@@ -0,0 +1,8 @@
+-- AlterTable
+ALTER TABLE "Library" ADD COLUMN     "lastSuccessfulScanAt" TIMESTAMP(3);
+
+-- Backfill from the last completed scan job of each library
+UPDATE "Library" SET "lastSuccessfulScanAt" = (
+  SELECT MAX("completedAt") FROM "ScanJob"
+  WHERE "ScanJob"."libraryId" = "Library"."id" AND "ScanJob"."status" = 'COMPLETED'
+);
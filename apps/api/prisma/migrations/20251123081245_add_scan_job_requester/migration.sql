@@ -0,0 +1,4 @@
+-- AlterTable
+ALTER TABLE "ScanJob" ADD COLUMN     "origin" TEXT,
+ADD COLUMN     "remoteAddress" TEXT,
+ADD COLUMN     "userAgent" TEXT;
@@ -7,3 +7,4 @@ export { streamRoutes } from "./stream";
 export { settingsRoutes } from "./settings";
 export { logsRoutes } from "./logs";
 export { default as searchRoutes } from "./search/search.routes";
+export { default as statsRoutes } from "./stats/stats.routes";
@@ -1,16 +1,41 @@
 import { Request, Response } from "express";
+import { createInterface } from "readline";
 import { libraryServices } from "./library.services";
 import {
+  clearLibraryMediaSchema,
   deleteLibrarySchema,
   updateLibrarySchema,
   getLibrariesSchema,
+  getRecentlyAddedSchema,
+  getMergeSuggestionsSchema,
+  verifyLibrarySchema,
+  reparseLibrarySchema,
+  exportLibrarySchema,
+  importLibrarySchema,
+  setPathOverrideSchema,
+  deletePathOverrideSchema,
 } from "./library.schema";
 import { z } from "zod";
-import { sendSuccess, asyncHandler } from "@/lib/utils";
+import {
+  sendSuccess,
+  asyncHandler,
+  createPaginationMeta,
+  logger,
+  ValidationError,
+} from "@/lib/utils";
 
 type DeleteLibraryRequest = z.infer<typeof deleteLibrarySchema>;
+type ClearLibraryMediaRequest = z.infer<typeof clearLibraryMediaSchema>;
 type UpdateLibraryRequest = z.infer<typeof updateLibrarySchema>;
 type GetLibrariesRequest = z.infer<typeof getLibrariesSchema>;
+type GetRecentlyAddedRequest = z.infer<typeof getRecentlyAddedSchema>;
+type GetMergeSuggestionsRequest = z.infer<typeof getMergeSuggestionsSchema>;
+type VerifyLibraryRequest = z.infer<typeof verifyLibrarySchema>;
+type ReparseLibraryRequest = z.infer<typeof reparseLibrarySchema>;
+type ExportLibraryRequest = z.infer<typeof exportLibrarySchema>;
+type ImportLibraryRequest = z.infer<typeof importLibrarySchema>;
+type SetPathOverrideRequest = z.infer<typeof setPathOverrideSchema>;
+type DeletePathOverrideRequest = z.infer<typeof deletePathOverrideSchema>;
 
 export const libraryControllers = {
   /**
@@ -23,6 +48,16 @@ export const libraryControllers = {
     return sendSuccess(res, result, 200, result.message);
   }),
 
+  /**
+   * Remove all media from a library, keeping the library itself
+   */
+  clearMedia: asyncHandler(async (req: Request, res: Response) => {
+    const { id } = req.validatedData as ClearLibraryMediaRequest;
+    const result = await libraryServices.clearMedia(id);
+
+    return sendSuccess(res, result, 200, result.message);
+  }),
+
   /**
    * Get all libraries with optional filtering
    */
@@ -51,4 +86,211 @@ export const libraryControllers = {
 
     return sendSuccess(res, result, 200, result.message);
   }),
+
+  /**
+   * Get items added to a library by recent scans, newest first
+   */
+  getRecentlyAdded: asyncHandler(async (req: Request, res: Response) => {
+    const { since, page, limit } = req.validatedData as GetRecentlyAddedRequest;
+    const result = await libraryServices.getRecentlyAdded(req.params.id, {
+      since,
+      skip: (page - 1) * limit,
+      take: limit,
+    });
+
+    return sendSuccess(
+      res,
+      result.items,
+      200,
+      undefined,
+      createPaginationMeta(page, limit, result.total),
+    );
+  }),
+
+  /**
+   * List groups of movies that look like duplicates, for a person to confirm
+   */
+  getMergeSuggestions: asyncHandler(async (req: Request, res: Response) => {
+    const { maxDistance } = req.validatedData as GetMergeSuggestionsRequest;
+    const result = await libraryServices.getMergeSuggestions(
+      req.params.id,
+      maxDistance,
+    );
+
+    return sendSuccess(res, result);
+  }),
+
+  /**
+   * Queue a check that a library's stored files still exist
+   */
+  verify: asyncHandler(async (req: Request, res: Response) => {
+    const options = req.validatedData as VerifyLibraryRequest;
+    const { job, queuePosition, estimatedStartAt } =
+      await libraryServices.startVerify(req.params.id, options);
+
+    return sendSuccess(
+      res,
+      { ...job, queuePosition, estimatedStartAt },
+      202,
+      queuePosition > 0
+        ? `Verify queued at position ${queuePosition}`
+        : "Verify started",
+    );
+  }),
+
+  /**
+   * Get the status, and once finished the result, of a verify job
+   */
+  getVerifyJob: asyncHandler(async (req: Request, res: Response) => {
+    const job = libraryServices.getVerifyJob(req.params.id, req.params.jobId);
+
+    return sendSuccess(res, job, 200, job.result?.message);
+  }),
+
+  /**
+   * Re-run filename parsing on a library's stored files
+   */
+  reparse: asyncHandler(async (req: Request, res: Response) => {
+    const options = req.validatedData as ReparseLibraryRequest;
+    const result = await libraryServices.reparse(req.params.id, options);
+
+    return sendSuccess(res, result, 200, result.message);
+  }),
+
+  /**
+   * Stream a snapshot of a library as NDJSON (one record per line) or JSON
+   */
+  exportLibrary: asyncHandler(async (req: Request, res: Response) => {
+    const { format } = req.validatedData as ExportLibraryRequest;
+    const library = await libraryServices.getExportLibrary(req.params.id);
+
+    res.setHeader(
+      "Content-Type",
+      format === "json" ? "application/json" : "application/x-ndjson",
+    );
+    res.setHeader(
+      "Content-Disposition",
+      `attachment; filename="${library.slug}.${format}"`,
+    );
+
+    let closed = false;
+    res.on("close", () => {
+      closed = true;
+    });
+
+    // Wait until the client has taken what was written, or went away
+    const waitForDrain = () =>
+      new Promise<void>((resolve) => {
+        const done = () => {
+          res.off("drain", done);
+          res.off("close", done);
+          resolve();
+        };
+        res.on("drain", done);
+        res.on("close", done);
+      });
+
+    if (format === "json") {
+      res.write('{"records":[');
+    }
+
+    try {
+      let first = true;
+      for await (const record of libraryServices.exportRecords(library)) {
+        if (closed) return;
+
+        const line = JSON.stringify(record);
+        const chunk =
+          format === "json" ? `${first ? "" : ","}\n${line}` : `${line}\n`;
+        first = false;
+
+        if (!res.write(chunk)) {
+          await waitForDrain();
+        }
+      }
+    } catch (error) {
+      // Headers are already sent, so the only way to signal failure is to
+      // cut the response short
+      logger.error(
+        `Export of library ${library.id} failed: ${error instanceof Error ? error.message : error}`,
+      );
+      res.destroy();
+      return;
+    }
+
+    if (format === "json") {
+      res.write("\n]}\n");
+    }
+    res.end();
+  }),
+
+  /**
+   * Restore a library from an export (NDJSON body, or JSON with a records array)
+   */
+  importLibrary: asyncHandler(async (req: Request, res: Response) => {
+    const { id } = req.validatedData as ImportLibraryRequest;
+
+    let records: AsyncIterable<unknown> | Iterable<unknown>;
+    if (req.is("application/json")) {
+      // Parsed by the global JSON body parser
+      const body = req.body as unknown;
+      const jsonRecords = Array.isArray(body)
+        ? body
+        : (body as { records?: unknown } | undefined)?.records;
+
+      if (!Array.isArray(jsonRecords)) {
+        throw new ValidationError(
+          'JSON imports must be an array of records or an object with a "records" array',
+        );
+      }
+      records = jsonRecords;
+    } else {
+      // NDJSON is read line by line straight from the request stream
+      records = createInterface({ input: req, crlfDelay: Infinity });
+    }
+
+    const result = await libraryServices.importRecords(id, records);
+
+    return sendSuccess(res, result, 200, result.message);
+  }),
+
+  /**
+   * List a library's path overrides
+   */
+  getPathOverrides: asyncHandler(async (req: Request, res: Response) => {
+    const overrides = await libraryServices.getPathOverrides(req.params.id);
+
+    return sendSuccess(res, overrides);
+  }),
+
+  /**
+   * Create or replace a path override
+   */
+  setPathOverride: asyncHandler(async (req: Request, res: Response) => {
+    const data = req.validatedData as SetPathOverrideRequest;
+    const override = await libraryServices.setPathOverride(
+      req.params.id,
+      data,
+    );
+
+    return sendSuccess(
+      res,
+      override,
+      200,
+      "Path override saved. It applies from the next scan.",
+    );
+  }),
+
+  /**
+   * Remove a path override
+   */
+  deletePathOverride: asyncHandler(async (req: Request, res: Response) => {
+    const { path } = req.validatedData as DeletePathOverrideRequest;
+    const result = await libraryServices.deletePathOverride(
+      req.params.id,
+      path,
+    );
+
+    return sendSuccess(res, result, 200, result.message);
+  }),
 };
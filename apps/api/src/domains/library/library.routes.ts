@@ -1,10 +1,23 @@
 import express, { Router } from "express";
 import { libraryControllers } from "./library.controller";
-import { validateBody, validateQuery } from "../../lib/middleware";
 import {
+  validateBody,
+  validateParams,
+  validateQuery,
+} from "../../lib/middleware";
+import {
+  clearLibraryMediaSchema,
   deleteLibrarySchema,
   updateLibrarySchema,
   getLibrariesSchema,
+  getRecentlyAddedSchema,
+  getMergeSuggestionsSchema,
+  verifyLibrarySchema,
+  reparseLibrarySchema,
+  exportLibrarySchema,
+  importLibrarySchema,
+  setPathOverrideSchema,
+  deletePathOverrideSchema,
 } from "./library.schema";
 
 const router: Router = express.Router();
@@ -272,4 +285,882 @@ router.delete(
   libraryControllers.delete,
 );
 
+/**
+ * @swagger
+ * /api/v1/library/{id}/media:
+ *   delete:
+ *     summary: Remove all media from a library
+ *     description: |
+ *       Empties a library without deleting the library itself:
+ *       - Deletes media entries that ONLY belong to this library, along with
+ *         their movie, TV show, season and episode records
+ *       - Unlinks media that also belongs to other libraries
+ *       - Does NOT delete actual files on disk
+ *       - Runs in a single transaction
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     responses:
+ *       200:
+ *         description: Library media removed
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     libraryId:
+ *                       type: string
+ *                       example: "clx123abc456def789"
+ *                     libraryName:
+ *                       type: string
+ *                       example: "My Anime Library"
+ *                     mediaDeleted:
+ *                       type: number
+ *                       example: 42
+ *                     moviesDeleted:
+ *                       type: number
+ *                       example: 0
+ *                     tvShowsDeleted:
+ *                       type: number
+ *                       example: 42
+ *                     seasonsDeleted:
+ *                       type: number
+ *                       example: 97
+ *                     episodesDeleted:
+ *                       type: number
+ *                       example: 1204
+ *                     linksRemoved:
+ *                       type: number
+ *                       description: Library links removed for media kept because other libraries use it
+ *                       example: 3
+ *                     message:
+ *                       type: string
+ *                 message:
+ *                   type: string
+ *       404:
+ *         description: Library not found
+ */
+router.delete(
+  "/:id/media",
+  validateParams(clearLibraryMediaSchema),
+  libraryControllers.clearMedia,
+);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/recent:
+ *   get:
+ *     summary: List items recently added to a library
+ *     description: |
+ *       Returns files that scans added to the library for the first time,
+ *       newest first. Rescans of files already in the library are not listed.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *       - in: query
+ *         name: since
+ *         schema:
+ *           type: string
+ *           format: date-time
+ *         description: Only include items added at or after this time
+ *       - in: query
+ *         name: page
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           default: 1
+ *       - in: query
+ *         name: limit
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           maximum: 100
+ *           default: 20
+ *     responses:
+ *       200:
+ *         description: Recently added items
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: array
+ *                   items:
+ *                     type: object
+ *                     properties:
+ *                       id:
+ *                         type: string
+ *                       addedAt:
+ *                         type: string
+ *                         format: date-time
+ *                       scanJobId:
+ *                         type: string
+ *                         nullable: true
+ *                       media:
+ *                         type: object
+ *                         properties:
+ *                           id:
+ *                             type: string
+ *                           title:
+ *                             type: string
+ *                           type:
+ *                             type: string
+ *                             enum: [MOVIE, TV_SHOW, MUSIC, COMIC]
+ *                           posterUrl:
+ *                             type: string
+ *                             nullable: true
+ *                           backdropUrl:
+ *                             type: string
+ *                             nullable: true
+ *                       episode:
+ *                         type: object
+ *                         nullable: true
+ *                         properties:
+ *                           id:
+ *                             type: string
+ *                           title:
+ *                             type: string
+ *                           seasonNumber:
+ *                             type: integer
+ *                           episodeNumber:
+ *                             type: integer
+ *                 meta:
+ *                   type: object
+ *                   properties:
+ *                     page:
+ *                       type: integer
+ *                     limit:
+ *                       type: integer
+ *                     total:
+ *                       type: integer
+ *                     totalPages:
+ *                       type: integer
+ *                     hasNext:
+ *                       type: boolean
+ *                     hasPrev:
+ *                       type: boolean
+ *       400:
+ *         description: Invalid query parameters
+ *       404:
+ *         description: Library not found
+ */
+router.get(
+  "/:id/recent",
+  validateQuery(getRecentlyAddedSchema),
+  libraryControllers.getRecentlyAdded,
+);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/merge-suggestions:
+ *   get:
+ *     summary: Suggest movies that may be duplicates
+ *     description: |
+ *       Groups movies from the same release year whose titles differ by at
+ *       most `maxDistance` edits, after lowercasing and dropping spaces and
+ *       punctuation. "Spider Man", "Spider-Man" and "SpiderMan" land in one
+ *       group. Nothing is merged; each group is a suggestion to confirm.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *       - in: query
+ *         name: maxDistance
+ *         schema:
+ *           type: integer
+ *           minimum: 0
+ *           maximum: 10
+ *           default: 2
+ *         description: Largest edit distance between normalized titles
+ *     responses:
+ *       200:
+ *         description: Suggested groups, most similar first
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     libraryId:
+ *                       type: string
+ *                     maxDistance:
+ *                       type: integer
+ *                     groups:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           year:
+ *                             type: integer
+ *                             nullable: true
+ *                           score:
+ *                             type: number
+ *                             description: Lowest title similarity in the group, 0 to 1
+ *                             example: 1
+ *                           movies:
+ *                             type: array
+ *                             items:
+ *                               type: object
+ *                               properties:
+ *                                 movieId:
+ *                                   type: string
+ *                                 mediaId:
+ *                                   type: string
+ *                                 title:
+ *                                   type: string
+ *                                   example: "Spider-Man"
+ *                                 filePath:
+ *                                   type: string
+ *                                   nullable: true
+ *       400:
+ *         description: Invalid query parameters
+ *       404:
+ *         description: Library not found
+ */
+router.get(
+  "/:id/merge-suggestions",
+  validateQuery(getMergeSuggestionsSchema),
+  libraryControllers.getMergeSuggestions,
+);
+
+/**
+ * @swagger
+ * components:
+ *   schemas:
+ *     LibraryVerifyJob:
+ *       type: object
+ *       properties:
+ *         jobId:
+ *           type: string
+ *         libraryId:
+ *           type: string
+ *         dryRun:
+ *           type: boolean
+ *         status:
+ *           type: string
+ *           enum: [queued, running, completed, failed]
+ *         queuedAt:
+ *           type: string
+ *           format: date-time
+ *         startedAt:
+ *           type: string
+ *           format: date-time
+ *           nullable: true
+ *         finishedAt:
+ *           type: string
+ *           format: date-time
+ *           nullable: true
+ *         checked:
+ *           type: integer
+ *           description: Files checked so far
+ *         error:
+ *           type: string
+ *           nullable: true
+ *           description: Why the job failed
+ *         result:
+ *           type: object
+ *           nullable: true
+ *           description: Set once the job has completed
+ *           properties:
+ *             libraryId:
+ *               type: string
+ *             dryRun:
+ *               type: boolean
+ *             checked:
+ *               type: integer
+ *             ok:
+ *               type: integer
+ *             missing:
+ *               type: integer
+ *             permissionDenied:
+ *               type: integer
+ *             failed:
+ *               type: integer
+ *               description: Timed out or failed for another reason
+ *             marked:
+ *               type: integer
+ *               description: Newly marked missing (or that would be, in a dry run)
+ *             restored:
+ *               type: integer
+ *               description: Marked missing before and found again
+ *             missingFiles:
+ *               type: array
+ *               description: The first 100 missing files
+ *               items:
+ *                 type: object
+ *                 properties:
+ *                   kind:
+ *                     type: string
+ *                     enum: [movie, episode, extra]
+ *                   id:
+ *                     type: string
+ *                   filePath:
+ *                     type: string
+ *             message:
+ *               type: string
+ */
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/verify:
+ *   post:
+ *     summary: Check that a library's files still exist
+ *     description: |
+ *       Queues a background job that stats every movie, episode and extra
+ *       file stored for the library, without probing or parsing anything.
+ *       Files that are gone get `missingSince` set, and files marked
+ *       missing earlier that are back have it cleared. Nothing is deleted.
+ *       With `dryRun`, only reports.
+ *
+ *       The job waits in the scan queue, so it never runs alongside a
+ *       scan. Answers right away with the job; poll
+ *       `GET /api/v1/library/{id}/verify/{jobId}` for its progress and
+ *       result.
+ *
+ *       Only a path that does not exist counts as missing. Stats that are
+ *       denied, or get no answer within SCANNER_FILE_DEADLINE_SECONDS, are
+ *       counted separately and leave the file as it was. The next scan
+ *       that finds a file also clears `missingSince`.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     requestBody:
+ *       content:
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             properties:
+ *               dryRun:
+ *                 type: boolean
+ *                 default: false
+ *               concurrency:
+ *                 type: integer
+ *                 minimum: 1
+ *                 maximum: 32
+ *                 default: 8
+ *                 description: Stats in flight at once. Lower it for slow network mounts.
+ *     responses:
+ *       202:
+ *         description: Verify queued or started
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   allOf:
+ *                     - $ref: '#/components/schemas/LibraryVerifyJob'
+ *                     - type: object
+ *                       properties:
+ *                         queuePosition:
+ *                           type: integer
+ *                           description: Place in the scan queue (0 = started right away)
+ *                         estimatedStartAt:
+ *                           type: string
+ *                           format: date-time
+ *                           nullable: true
+ *       400:
+ *         description: Invalid body
+ *       404:
+ *         description: Library not found
+ */
+router.post(
+  "/:id/verify",
+  validateBody(verifyLibrarySchema),
+  libraryControllers.verify,
+);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/verify/{jobId}:
+ *   get:
+ *     summary: Get a library verify job
+ *     description: |
+ *       Returns the status of a verify job and the files checked so far.
+ *       Once the job has completed, `result` holds the summary. Finished
+ *       jobs are kept in memory for the 50 most recent verifies and are
+ *       lost on restart.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *       - in: path
+ *         name: jobId
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The job ID returned when the verify was queued
+ *     responses:
+ *       200:
+ *         description: The verify job
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   $ref: '#/components/schemas/LibraryVerifyJob'
+ *       404:
+ *         description: No such verify job for this library
+ */
+router.get("/:id/verify/:jobId", libraryControllers.getVerifyJob);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/reparse:
+ *   post:
+ *     summary: Re-run filename parsing on a library's files
+ *     description: |
+ *       Parses the stored path of every movie and episode in the library
+ *       again, without touching the filesystem, and updates the release
+ *       attributes parsed from file names (3D, remux, source, resolution,
+ *       language, proper/repack/internal) where they changed. Lets parser
+ *       fixes reach existing items without a full rescan. With `dryRun`,
+ *       only reports.
+ *
+ *       Titles are not changed: they come from TMDB, and a different
+ *       parsed title needs a rescan to match again.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     requestBody:
+ *       content:
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             properties:
+ *               dryRun:
+ *                 type: boolean
+ *                 default: false
+ *     responses:
+ *       200:
+ *         description: Reparse finished
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     libraryId:
+ *                       type: string
+ *                     dryRun:
+ *                       type: boolean
+ *                     checked:
+ *                       type: integer
+ *                     changed:
+ *                       type: integer
+ *                       description: Files updated (or that would be, in a dry run)
+ *                     changedFiles:
+ *                       type: array
+ *                       description: The first 100 changed files
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           kind:
+ *                             type: string
+ *                             enum: [movie, episode]
+ *                           id:
+ *                             type: string
+ *                           filePath:
+ *                             type: string
+ *                           changes:
+ *                             type: object
+ *                             description: Changed attributes, as { from, to }
+ *                     message:
+ *                       type: string
+ *       400:
+ *         description: Invalid body
+ *       404:
+ *         description: Library not found
+ */
+router.post(
+  "/:id/reparse",
+  validateBody(reparseLibrarySchema),
+  libraryControllers.reparse,
+);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/export:
+ *   get:
+ *     summary: Export a library snapshot
+ *     description: |
+ *       Streams everything the scanner knows about a library, for backups and
+ *       migrations. The first record is the library itself, followed by each
+ *       movie, and each TV show followed by its seasons and episodes. Files
+ *       carry their path, size, modification time and the release attributes
+ *       parsed from the filename.
+ *
+ *       Every record has a `type` field: `library`, `movie`, `tvShow`,
+ *       `season` or `episode`. Seasons and episodes name their show by
+ *       `title` and `year`. File sizes are strings because they can exceed
+ *       the safe integer range.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *       - in: query
+ *         name: format
+ *         schema:
+ *           type: string
+ *           enum: [ndjson, json]
+ *           default: ndjson
+ *         description: |
+ *           `ndjson` writes one record per line. `json` writes an object
+ *           with a `records` array.
+ *     responses:
+ *       200:
+ *         description: The export, sent as a file download
+ *         content:
+ *           application/x-ndjson:
+ *             schema:
+ *               type: string
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 records:
+ *                   type: array
+ *                   items:
+ *                     type: object
+ *       400:
+ *         description: Invalid format
+ *       404:
+ *         description: Library not found
+ */
+router.get(
+  "/:id/export",
+  validateQuery(exportLibrarySchema),
+  libraryControllers.exportLibrary,
+);
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/import:
+ *   post:
+ *     summary: Import a library snapshot
+ *     description: |
+ *       Restores records written by the export endpoint. Movies and shows
+ *       are matched on their TMDB ID, then on their file path, then on type,
+ *       title and year, so importing the same export twice updates rows
+ *       instead of duplicating them. Episodes are matched on their path.
+ *
+ *       A movie that already has another file on disk (not marked missing)
+ *       keeps it, and a file that belongs to another movie stays with that
+ *       movie; both are counted as `movieFilesKept`.
+ *
+ *       If no library has this ID, it is created from the export's library
+ *       record. Records that fail are counted and reported, and the rest
+ *       are still imported.
+ *
+ *       Send NDJSON (`application/x-ndjson`) for large libraries. It is read
+ *       line by line. JSON bodies are limited to 10MB.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID to import into
+ *     requestBody:
+ *       required: true
+ *       content:
+ *         application/x-ndjson:
+ *           schema:
+ *             type: string
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             properties:
+ *               records:
+ *                 type: array
+ *                 items:
+ *                   type: object
+ *     responses:
+ *       200:
+ *         description: Import finished
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     libraryId:
+ *                       type: string
+ *                     libraryName:
+ *                       type: string
+ *                     libraryCreated:
+ *                       type: boolean
+ *                     moviesImported:
+ *                       type: integer
+ *                     tvShowsImported:
+ *                       type: integer
+ *                     seasonsImported:
+ *                       type: integer
+ *                     episodesImported:
+ *                       type: integer
+ *                     movieFilesKept:
+ *                       type: integer
+ *                       description: Imported movies whose stored file was kept instead of the export's
+ *                     recordsFailed:
+ *                       type: integer
+ *                     errors:
+ *                       type: array
+ *                       description: The first 20 failures
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           record:
+ *                             type: integer
+ *                             description: 1-based position of the record
+ *                           message:
+ *                             type: string
+ *                     message:
+ *                       type: string
+ *                 message:
+ *                   type: string
+ *       400:
+ *         description: Invalid body or unsupported export version
+ *       404:
+ *         description: Library not found and the import has no library record
+ *       409:
+ *         description: The export's library slug belongs to another library
+ */
+router.post(
+  "/:id/import",
+  validateParams(importLibrarySchema),
+  libraryControllers.importLibrary,
+);
+
+/**
+ * @swagger
+ * components:
+ *   schemas:
+ *     PathOverride:
+ *       type: object
+ *       properties:
+ *         id:
+ *           type: string
+ *         libraryId:
+ *           type: string
+ *         path:
+ *           type: string
+ *           description: Normalized absolute file or folder path
+ *           example: "/media/tv/Some Show/weird-name-03.mkv"
+ *         title:
+ *           type: string
+ *           nullable: true
+ *           description: Movie title, or show title for episodes
+ *         year:
+ *           type: integer
+ *           nullable: true
+ *         season:
+ *           type: integer
+ *           nullable: true
+ *         episode:
+ *           type: integer
+ *           nullable: true
+ *         createdAt:
+ *           type: string
+ *           format: date-time
+ *         updatedAt:
+ *           type: string
+ *           format: date-time
+ */
+
+/**
+ * @swagger
+ * /api/v1/library/{id}/overrides:
+ *   get:
+ *     summary: List path overrides
+ *     description: |
+ *       Path overrides force the title, year, season or episode of files the
+ *       filename parser gets wrong. An override on a folder applies to every
+ *       file below it, and a file's own override wins over its folders'.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     responses:
+ *       200:
+ *         description: The library's overrides, sorted by path
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: array
+ *                   items:
+ *                     $ref: '#/components/schemas/PathOverride'
+ *       404:
+ *         description: Library not found
+ *   put:
+ *     summary: Create or replace a path override
+ *     description: |
+ *       Sets the override for a file or folder. The path is matched as stored
+ *       on media rows (the host path when running in Docker), after
+ *       normalizing it to an absolute path. Fields left out are cleared.
+ *       Overrides apply from the next scan.
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     requestBody:
+ *       required: true
+ *       content:
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             required:
+ *               - path
+ *             properties:
+ *               path:
+ *                 type: string
+ *                 example: "/media/tv/Some Show/weird-name-03.mkv"
+ *               title:
+ *                 type: string
+ *                 example: "Some Show"
+ *               year:
+ *                 type: integer
+ *                 example: 2019
+ *               season:
+ *                 type: integer
+ *                 example: 1
+ *               episode:
+ *                 type: integer
+ *                 example: 3
+ *     responses:
+ *       200:
+ *         description: Override saved
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   $ref: '#/components/schemas/PathOverride'
+ *                 message:
+ *                   type: string
+ *       400:
+ *         description: Invalid body, or no field to override
+ *       404:
+ *         description: Library not found
+ *   delete:
+ *     summary: Remove a path override
+ *     tags: [Library]
+ *     parameters:
+ *       - in: path
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The library ID
+ *     requestBody:
+ *       required: true
+ *       content:
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             required:
+ *               - path
+ *             properties:
+ *               path:
+ *                 type: string
+ *     responses:
+ *       200:
+ *         description: Override removed
+ *       404:
+ *         description: No override for this path
+ */
+router.get("/:id/overrides", libraryControllers.getPathOverrides);
+router.put(
+  "/:id/overrides",
+  validateBody(setPathOverrideSchema),
+  libraryControllers.setPathOverride,
+);
+router.delete(
+  "/:id/overrides",
+  validateBody(deletePathOverrideSchema),
+  libraryControllers.deletePathOverride,
+);
+
 export default router;
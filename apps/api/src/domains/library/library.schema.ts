@@ -1,5 +1,5 @@
 import { z } from "zod";
-import { MediaType } from "@/lib/database";
+import { MediaType, RoleType } from "@/lib/database";
 
 /**
  * Schema for deleting a library
@@ -8,6 +8,13 @@ export const deleteLibrarySchema = z.object({
   id: z.string().min(1, "Library ID is required"),
 });
 
+/**
+ * Schema for removing all media from a library
+ */
+export const clearLibraryMediaSchema = z.object({
+  id: z.string().min(1, "Library ID is required"),
+});
+
 /**
  * Schema for updating a library
  */
@@ -35,3 +42,196 @@ export const getLibrariesSchema = z.object({
     }),
   libraryType: z.nativeEnum(MediaType).optional(),
 });
+
+/**
+ * Schema for listing recently added items in a library
+ */
+export const getRecentlyAddedSchema = z.object({
+  since: z
+    .string()
+    .refine((val) => !Number.isNaN(Date.parse(val)), {
+      message: "since must be a valid date",
+    })
+    .transform((val) => new Date(val))
+    .optional(),
+  page: z.coerce.number().int().min(1).default(1),
+  limit: z.coerce.number().int().min(1).max(100).default(20),
+});
+
+/**
+ * Schema for listing suggested movie merges in a library
+ */
+export const getMergeSuggestionsSchema = z.object({
+  maxDistance: z.coerce.number().int().min(0).max(10).default(2),
+});
+
+/**
+ * Schema for checking that a library's files still exist
+ */
+export const verifyLibrarySchema = z.object({
+  dryRun: z.boolean().default(false),
+  concurrency: z.number().int().min(1).max(32).default(8),
+});
+
+/**
+ * Schema for re-parsing a library's stored file names
+ */
+export const reparseLibrarySchema = z.object({
+  dryRun: z.boolean().default(false),
+});
+
+/**
+ * Schema for exporting a library snapshot
+ */
+export const exportLibrarySchema = z.object({
+  format: z.enum(["ndjson", "json"]).default("ndjson"),
+});
+
+/**
+ * Schema for the library ID of an import
+ */
+export const importLibrarySchema = z.object({
+  id: z.string().min(1, "Library ID is required"),
+});
+
+// ────────────────────────────
+// Import records (one per NDJSON line)
+// ────────────────────────────
+
+const nullableString = z.string().nullable().default(null);
+const nullableInt = z.number().int().nullable().default(null);
+const nullableDate = z
+  .string()
+  .refine((val) => !Number.isNaN(Date.parse(val)), {
+    message: "must be a valid date",
+  })
+  .nullable()
+  .default(null);
+
+const exportFileSchema = z.object({
+  filePath: z.string().min(1),
+  fileSize: z
+    .string()
+    .regex(/^\d+$/, "fileSize must be a whole number of bytes")
+    .nullable()
+    .default(null),
+  fileModifiedAt: nullableDate,
+  is3D: z.boolean().default(false),
+  isRemux: z.boolean().default(false),
+  sourceType: nullableString,
+  resolution: nullableString,
+  language: nullableString,
+  isProper: z.boolean().default(false),
+  isRepack: z.boolean().default(false),
+  isInternal: z.boolean().default(false),
+  titleSource: nullableString,
+  scannerVersion: nullableString,
+});
+
+const showRefSchema = z.object({
+  title: z.string().min(1),
+  year: nullableInt,
+  tmdbId: nullableString,
+});
+
+const exportContentShape = {
+  title: z.string().min(1),
+  year: nullableInt,
+  description: nullableString,
+  posterUrl: nullableString,
+  backdropUrl: nullableString,
+  meshGradientColors: z.array(z.string()).default([]),
+  releaseDate: nullableDate,
+  rating: z.number().nullable().default(null),
+  externalIds: z.record(z.string(), z.string()).default({}),
+  genres: z.array(z.string()).default([]),
+  people: z
+    .array(
+      z.object({
+        id: z.string().min(1),
+        name: z.string().min(1),
+        role: z.nativeEnum(RoleType),
+        character: nullableString,
+      }),
+    )
+    .default([]),
+};
+
+/**
+ * Schema for a single record of a library export
+ */
+export const libraryImportRecordSchema = z.discriminatedUnion("type", [
+  z.object({
+    type: z.literal("library"),
+    version: z.number().int(),
+    exportedAt: z.string().optional(),
+    library: z.object({
+      id: z.string().optional(),
+      name: z.string().min(1),
+      slug: z.string().min(1),
+      description: nullableString,
+      posterUrl: nullableString,
+      backdropUrl: nullableString,
+      libraryPath: nullableString,
+      libraryType: z.nativeEnum(MediaType).nullable().default(null),
+    }),
+  }),
+  z.object({
+    type: z.literal("movie"),
+    ...exportContentShape,
+    duration: nullableInt,
+    trailerUrl: nullableString,
+    file: exportFileSchema.nullable().default(null),
+  }),
+  z.object({
+    type: z.literal("tvShow"),
+    ...exportContentShape,
+    creator: nullableString,
+    network: nullableString,
+  }),
+  z.object({
+    type: z.literal("season"),
+    show: showRefSchema,
+    number: z.number().int().min(0),
+    posterUrl: nullableString,
+  }),
+  z.object({
+    type: z.literal("episode"),
+    show: showRefSchema,
+    seasonNumber: z.number().int().min(0),
+    number: z.number().int().min(0),
+    title: z.string(),
+    fileTitle: nullableString,
+    duration: nullableInt,
+    airDate: nullableDate,
+    stillPath: nullableString,
+    file: exportFileSchema.nullable().default(null),
+  }),
+]);
+
+/**
+ * Schema for creating or replacing a path override
+ */
+export const setPathOverrideSchema = z
+  .object({
+    path: z.string().min(1, "Path is required").max(5000, "Path is too long"),
+    title: z.string().min(1).max(500).optional(),
+    year: z.number().int().min(1800).max(2200).optional(),
+    season: z.number().int().min(0).optional(),
+    episode: z.number().int().min(0).optional(),
+  })
+  .refine(
+    (data) =>
+      data.title !== undefined ||
+      data.year !== undefined ||
+      data.season !== undefined ||
+      data.episode !== undefined,
+    { message: "Set at least one of title, year, season or episode" },
+  );
+
+/**
+ * Schema for removing a path override
+ */
+export const deletePathOverrideSchema = z.object({
+  path: z.string().min(1, "Path is required").max(5000, "Path is too long"),
+});
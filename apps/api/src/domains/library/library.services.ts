@@ -1,14 +1,676 @@
 import prisma from "@/lib/database/prisma";
-import { logger, NotFoundError } from "@/lib/utils";
 import {
+  logger,
+  ConflictError,
+  NotFoundError,
+  ValidationError,
+  getTitleSimilarity,
+  levenshteinDistance,
+  mapHostToContainerPath,
+  normalizeTitleForComparison,
+  extractIds,
+} from "@/lib/utils";
+import { randomUUID } from "crypto";
+import { stat } from "fs/promises";
+import { basename } from "path";
+import { assignGenresToMedia } from "../../core/services/genre.service";
+import {
+  OperationTimeoutError,
+  enqueueScan,
+  getDiscTitleName,
+  getFileDeadlineMs,
+  getReleaseAttributes,
+  getLibraryStaleAfterMs,
+  getScannerVersionData,
+  isDiscStructureDetectionEnabled,
+  isLibraryStale,
+  mapWithConcurrency,
+  normalizeOverridePath,
+  withTimeout,
+} from "../scan/helpers";
+import {
+  LibraryClearMediaResult,
   LibraryDeleteResult,
+  LibraryExportContent,
+  LibraryExportFile,
+  LibraryExportRecord,
+  LibraryExportShowRef,
+  LibraryImportResult,
+  LibraryReparseResult,
   LibraryUpdateResult,
+  LibraryVerifyJob,
+  LibraryVerifyResult,
   LibraryWithMetadata,
   LibraryWithMediaRelations,
   MediaLibraryWithRelations,
+  MergeSuggestionGroup,
+  MergeSuggestionMovie,
+  MergeSuggestionsResult,
+  PathOverrideDeleteResult,
   PrismaTransactionClient,
+  RecentlyAddedResult,
+  ReparsedFile,
+  ReparsedFileKind,
+  VerifiedFileKind,
 } from "./library.types";
-import { Prisma, MediaType } from "@prisma/client";
+import { libraryImportRecordSchema } from "./library.schema";
+import {
+  Prisma,
+  MediaType,
+  ExternalIdSource,
+  Library,
+  Movie,
+  PathOverride,
+} from "@prisma/client";
+import { z } from "zod";
+
+/**
+ * Version of the export format, written in the header record
+ * Imports refuse files written by a newer version
+ */
+export const LIBRARY_EXPORT_VERSION = 1;
+
+// Media rows read per query while exporting
+const EXPORT_BATCH_SIZE = 200;
+
+// Import errors included in the result (the rest are only counted)
+const MAX_IMPORT_ERRORS = 20;
+
+// Library links read per query while verifying or reparsing
+const FILE_LIST_BATCH_SIZE = 100;
+
+// Missing files listed in a verify result (the rest are only counted)
+const MAX_REPORTED_MISSING_FILES = 100;
+
+// Finished verify jobs kept for status requests (the oldest are dropped)
+const MAX_FINISHED_VERIFY_JOBS = 50;
+
+// Changed files listed in a reparse result (the rest are only counted)
+const MAX_REPORTED_REPARSED_FILES = 100;
+
+type LibraryImportRecord = z.infer<typeof libraryImportRecordSchema>;
+type ImportedContentRecord = Extract<
+  LibraryImportRecord,
+  { type: "movie" | "tvShow" }
+>;
+type ImportedFile = NonNullable<
+  Extract<LibraryImportRecord, { type: "movie" }>["file"]
+>;
+
+const exportMediaInclude = {
+  movie: true,
+  tvShow: true,
+  externalIds: true,
+  genres: { include: { genre: true } },
+  people: { include: { person: true } },
+} satisfies Prisma.MediaInclude;
+
+type ExportMedia = Prisma.MediaGetPayload<{
+  include: typeof exportMediaInclude;
+}>;
+
+// File columns shared by Movie and Episode rows
+type FileColumns = Pick<
+  Movie,
+  | "filePath"
+  | "fileSize"
+  | "fileModifiedAt"
+  | "is3D"
+  | "isRemux"
+  | "sourceType"
+  | "resolution"
+  | "language"
+  | "isProper"
+  | "isRepack"
+  | "isInternal"
+  | "titleSource"
+  | "scannerVersion"
+>;
+
+function toIsoString(date: Date | null): string | null {
+  return date ? date.toISOString() : null;
+}
+
+function toDate(value: string | null): Date | null {
+  return value ? new Date(value) : null;
+}
+
+function getYearRange(year: number) {
+  return {
+    gte: new Date(Date.UTC(year, 0, 1)),
+    lt: new Date(Date.UTC(year + 1, 0, 1)),
+  };
+}
+
+// Shows are keyed by TMDB ID when the export has one, since title and
+// year alone collide for remakes and shows without a year
+function getShowKey(show: LibraryExportShowRef): string {
+  return show.tmdbId
+    ? `tmdb:${show.tmdbId}`
+    : JSON.stringify([show.title, show.year]);
+}
+
+/**
+ * Convert a Movie or Episode row's file columns to an export file record
+ */
+function toExportFile(row: FileColumns): LibraryExportFile | null {
+  if (!row.filePath) {
+    return null;
+  }
+
+  return {
+    filePath: row.filePath,
+    fileSize: row.fileSize !== null ? row.fileSize.toString() : null,
+    fileModifiedAt: toIsoString(row.fileModifiedAt),
+    is3D: row.is3D,
+    isRemux: row.isRemux,
+    sourceType: row.sourceType,
+    resolution: row.resolution,
+    language: row.language,
+    isProper: row.isProper,
+    isRepack: row.isRepack,
+    isInternal: row.isInternal,
+    titleSource: row.titleSource,
+    scannerVersion: row.scannerVersion,
+  };
+}
+
+/**
+ * Convert an imported file record back to Movie/Episode columns
+ * Records without a file leave the existing file columns untouched
+ */
+function toFileColumns(file: ImportedFile | null) {
+  if (!file) {
+    return {};
+  }
+
+  return {
+    filePath: file.filePath,
+    fileSize: file.fileSize !== null ? BigInt(file.fileSize) : null,
+    fileModifiedAt: toDate(file.fileModifiedAt),
+    is3D: file.is3D,
+    isRemux: file.isRemux,
+    sourceType: file.sourceType,
+    resolution: file.resolution,
+    language: file.language,
+    isProper: file.isProper,
+    isRepack: file.isRepack,
+    isInternal: file.isInternal,
+    titleSource: file.titleSource,
+    scannerVersion: file.scannerVersion,
+  };
+}
+
+/**
+ * Convert a Media row to the fields shared by movie and TV show records
+ */
+function toExportContent(media: ExportMedia): LibraryExportContent {
+  const externalIds: LibraryExportContent["externalIds"] = {};
+  for (const externalId of media.externalIds) {
+    externalIds[externalId.source] = externalId.externalId;
+  }
+
+  return {
+    title: media.title,
+    year: media.releaseDate ? media.releaseDate.getUTCFullYear() : null,
+    description: media.description,
+    posterUrl: media.posterUrl,
+    backdropUrl: media.backdropUrl,
+    meshGradientColors: media.meshGradientColors,
+    releaseDate: toIsoString(media.releaseDate),
+    rating: media.rating,
+    externalIds,
+    genres: media.genres.map((mediaGenre) => mediaGenre.genre.name),
+    people: media.people.map((mediaPerson) => ({
+      id: mediaPerson.person.id,
+      name: mediaPerson.person.name,
+      role: mediaPerson.role,
+      character: mediaPerson.character,
+    })),
+  };
+}
+
+/**
+ * Export records for a TV show's seasons and episodes
+ */
+async function* exportShowChildren(
+  tvShowId: string,
+  show: LibraryExportShowRef,
+): AsyncGenerator<LibraryExportRecord> {
+  const seasons = await prisma.season.findMany({
+    where: { tvShowId },
+    orderBy: { number: "asc" },
+  });
+
+  for (const season of seasons) {
+    yield {
+      type: "season",
+      show,
+      number: season.number,
+      posterUrl: season.posterUrl,
+    };
+
+    // Read one season at a time so long-running shows stay out of memory
+    const episodes = await prisma.episode.findMany({
+      where: { seasonId: season.id },
+      orderBy: { number: "asc" },
+    });
+
+    for (const episode of episodes) {
+      yield {
+        type: "episode",
+        show,
+        seasonNumber: season.number,
+        number: episode.number,
+        title: episode.title,
+        fileTitle: episode.fileTitle,
+        duration: episode.duration,
+        airDate: toIsoString(episode.airDate),
+        stillPath: episode.stillPath,
+        file: toExportFile(episode),
+      };
+    }
+  }
+}
+
+interface StoredFile {
+  kind: VerifiedFileKind;
+  id: string;
+  filePath: string;
+  missingSince: Date | null;
+}
+
+type StoredFileStatus = "ok" | "missing" | "permissionDenied" | "failed";
+
+/**
+ * Page through every stored file of a library: movies with their extras,
+ * and the episodes of each show. Yields one batch of library links at a
+ * time so large libraries are never loaded at once.
+ */
+async function* listLibraryFiles(
+  libraryId: string,
+): AsyncGenerator<StoredFile[]> {
+  const fileSelect = { id: true, filePath: true, missingSince: true };
+  let cursor: string | undefined;
+
+  while (true) {
+    const links = await prisma.mediaLibrary.findMany({
+      where: { libraryId },
+      orderBy: { id: "asc" },
+      take: FILE_LIST_BATCH_SIZE,
+      ...(cursor ? { cursor: { id: cursor }, skip: 1 } : {}),
+      select: {
+        id: true,
+        media: {
+          select: {
+            movie: {
+              select: { ...fileSelect, extras: { select: fileSelect } },
+            },
+            tvShow: {
+              select: {
+                seasons: { select: { episodes: { select: fileSelect } } },
+              },
+            },
+          },
+        },
+      },
+    });
+
+    const files: StoredFile[] = [];
+    const add = (
+      kind: VerifiedFileKind,
+      row: { id: string; filePath: string | null; missingSince: Date | null },
+    ) => {
+      if (row.filePath) {
+        files.push({
+          kind,
+          id: row.id,
+          filePath: row.filePath,
+          missingSince: row.missingSince,
+        });
+      }
+    };
+
+    for (const { media } of links) {
+      if (media.movie) {
+        add("movie", media.movie);
+        media.movie.extras.forEach((extra) => add("extra", extra));
+      }
+      for (const season of media.tvShow?.seasons ?? []) {
+        season.episodes.forEach((episode) => add("episode", episode));
+      }
+    }
+
+    yield files;
+
+    const lastLink = links[links.length - 1];
+    if (!lastLink || links.length < FILE_LIST_BATCH_SIZE) {
+      break;
+    }
+    cursor = lastLink.id;
+  }
+}
+
+type ReleaseAttributes = ReturnType<typeof getReleaseAttributes>;
+
+interface ParsedFile {
+  kind: ReparsedFileKind;
+  id: string;
+  filePath: string;
+  attributes: ReleaseAttributes; // As stored
+}
+
+/**
+ * Page through the movies and episodes of a library with the release
+ * attributes stored for them, one batch of library links at a time
+ */
+async function* listParsedFiles(
+  libraryId: string,
+): AsyncGenerator<ParsedFile[]> {
+  const fileSelect = {
+    id: true,
+    filePath: true,
+    is3D: true,
+    isRemux: true,
+    sourceType: true,
+    resolution: true,
+    language: true,
+    isProper: true,
+    isRepack: true,
+    isInternal: true,
+  };
+  let cursor: string | undefined;
+
+  while (true) {
+    const links = await prisma.mediaLibrary.findMany({
+      where: { libraryId },
+      orderBy: { id: "asc" },
+      take: FILE_LIST_BATCH_SIZE,
+      ...(cursor ? { cursor: { id: cursor }, skip: 1 } : {}),
+      select: {
+        id: true,
+        media: {
+          select: {
+            movie: { select: fileSelect },
+            tvShow: {
+              select: {
+                seasons: { select: { episodes: { select: fileSelect } } },
+              },
+            },
+          },
+        },
+      },
+    });
+
+    const files: ParsedFile[] = [];
+    const add = (
+      kind: ReparsedFileKind,
+      row: ReleaseAttributes & { id: string; filePath: string | null },
+    ) => {
+      const { id, filePath, ...attributes } = row;
+      if (filePath) {
+        files.push({ kind, id, filePath, attributes });
+      }
+    };
+
+    for (const { media } of links) {
+      if (media.movie) {
+        add("movie", media.movie);
+      }
+      for (const season of media.tvShow?.seasons ?? []) {
+        season.episodes.forEach((episode) => add("episode", episode));
+      }
+    }
+
+    yield files;
+
+    const lastLink = links[links.length - 1];
+    if (!lastLink || links.length < FILE_LIST_BATCH_SIZE) {
+      break;
+    }
+    cursor = lastLink.id;
+  }
+}
+
+/**
+ * Compare stored release attributes with freshly parsed ones
+ * Returns the attributes that differ, stored value first
+ */
+function diffReleaseAttributes(
+  stored: ReleaseAttributes,
+  parsed: ReleaseAttributes,
+): ReparsedFile["changes"] {
+  const changes: ReparsedFile["changes"] = {};
+
+  for (const field of Object.keys(parsed) as Array<keyof ReleaseAttributes>) {
+    if (stored[field] !== parsed[field]) {
+      changes[field] = { from: stored[field], to: parsed[field] };
+    }
+  }
+
+  return changes;
+}
+
+/**
+ * Stat a stored path (host paths are mapped into the container first)
+ * Only a missing file or directory counts as missing; a stat that is
+ * denied, times out or fails otherwise leaves the file as it was
+ */
+async function checkStoredFile(
+  filePath: string,
+  deadlineMs: number,
+): Promise<StoredFileStatus> {
+  const mappedPath = mapHostToContainerPath(filePath);
+
+  try {
+    await withTimeout(stat(mappedPath), deadlineMs, `Stat ${mappedPath}`);
+    return "ok";
+  } catch (error) {
+    if (error instanceof OperationTimeoutError) {
+      logger.warn(`Verify: no response from the drive for ${filePath}`);
+      return "failed";
+    }
+
+    const code = (error as NodeJS.ErrnoException).code;
+    if (code === "ENOENT" || code === "ENOTDIR") {
+      return "missing";
+    }
+    if (code === "EACCES" || code === "EPERM") {
+      return "permissionDenied";
+    }
+
+    logger.warn(
+      `Verify: could not stat ${filePath}: ${error instanceof Error ? error.message : error}`,
+    );
+    return "failed";
+  }
+}
+
+/**
+ * Set or clear missingSince on the given rows of one kind
+ */
+async function setMissingSince(
+  kind: VerifiedFileKind,
+  ids: string[],
+  missingSince: Date | null,
+): Promise<void> {
+  if (ids.length === 0) {
+    return;
+  }
+
+  const where = { id: { in: ids } };
+  const data = { missingSince };
+
+  if (kind === "movie") {
+    await prisma.movie.updateMany({ where, data });
+  } else if (kind === "episode") {
+    await prisma.episode.updateMany({ where, data });
+  } else {
+    await prisma.movieExtra.updateMany({ where, data });
+  }
+}
+
+/**
+ * Find content by type, title and release year
+ * With withoutTmdbId, content that has a TMDB ID is left out: it is a
+ * different title of the same name than a record with another TMDB ID
+ */
+async function findContentMedia(
+  type: MediaType,
+  title: string,
+  year: number | null,
+  withoutTmdbId = false,
+) {
+  return prisma.media.findFirst({
+    where: {
+      type,
+      title,
+      releaseDate: year === null ? null : getYearRange(year),
+      ...(withoutTmdbId
+        ? { externalIds: { none: { source: ExternalIdSource.TMDB } } }
+        : {}),
+    },
+    orderBy: { createdAt: "asc" },
+    include: { tvShow: { select: { id: true } } },
+  });
+}
+
+/**
+ * Find content by type and TMDB ID
+ */
+async function findContentMediaByTmdbId(type: MediaType, tmdbId: string) {
+  return prisma.media.findFirst({
+    where: {
+      type,
+      externalIds: {
+        some: { source: ExternalIdSource.TMDB, externalId: tmdbId },
+      },
+    },
+    orderBy: { createdAt: "asc" },
+    include: { tvShow: { select: { id: true } } },
+  });
+}
+
+/**
+ * Find the content an imported record belongs to: by TMDB ID when the
+ * record has one, otherwise by type, title and release year
+ */
+async function findImportedContentMedia(
+  type: MediaType,
+  record: { title: string; year: number | null; tmdbId?: string | null },
+) {
+  if (record.tmdbId) {
+    const byTmdbId = await findContentMediaByTmdbId(type, record.tmdbId);
+    if (byTmdbId) {
+      return byTmdbId;
+    }
+  }
+
+  return findContentMedia(type, record.title, record.year, !!record.tmdbId);
+}
+
+/**
+ * Create or update the Media row for an imported movie or TV show,
+ * with its external IDs, genres and people, and link it to the library
+ */
+async function importContentMedia(
+  libraryId: string,
+  type: MediaType,
+  record: ImportedContentRecord,
+  existingMediaId?: string,
+): Promise<string> {
+  const data = {
+    title: record.title,
+    description: record.description,
+    posterUrl: record.posterUrl,
+    backdropUrl: record.backdropUrl,
+    meshGradientColors: record.meshGradientColors,
+    releaseDate: toDate(record.releaseDate),
+    rating: record.rating,
+  };
+
+  const mediaId =
+    existingMediaId ??
+    (
+      await findImportedContentMedia(type, {
+        title: record.title,
+        year: record.year,
+        tmdbId: record.externalIds.TMDB,
+      })
+    )?.id;
+
+  const media = mediaId
+    ? await prisma.media.update({ where: { id: mediaId }, data })
+    : await prisma.media.create({ data: { ...data, type } });
+
+  // IDs already owned by other media are skipped rather than moved
+  const externalIds = Object.entries(record.externalIds)
+    .filter(([source]) =>
+      (Object.values(ExternalIdSource) as string[]).includes(source),
+    )
+    .map(([source, externalId]) => ({
+      source: source as ExternalIdSource,
+      externalId,
+      mediaId: media.id,
+    }));
+  if (externalIds.length > 0) {
+    await prisma.externalId.createMany({
+      data: externalIds,
+      skipDuplicates: true,
+    });
+  }
+
+  await assignGenresToMedia(
+    media.id,
+    record.genres.map((name) => ({ id: name, name })),
+  );
+
+  for (const person of record.people) {
+    await prisma.person.upsert({
+      where: { id: person.id },
+      update: { name: person.name },
+      create: { id: person.id, name: person.name },
+    });
+    await prisma.mediaPerson.upsert({
+      where: {
+        mediaId_personId_role: {
+          mediaId: media.id,
+          personId: person.id,
+          role: person.role,
+        },
+      },
+      update: { character: person.character },
+      create: {
+        mediaId: media.id,
+        personId: person.id,
+        role: person.role,
+        character: person.character,
+      },
+    });
+  }
+
+  await prisma.mediaLibrary.upsert({
+    where: { mediaId_libraryId: { mediaId: media.id, libraryId } },
+    update: {},
+    create: { mediaId: media.id, libraryId },
+  });
+
+  return media.id;
+}
+
+// Verify jobs by job ID, oldest first
+const verifyJobs = new Map<string, LibraryVerifyJob>();
+
+// Drop the oldest finished verify jobs past the cap
+function pruneVerifyJobs() {
+  const finished = [...verifyJobs.values()].filter(
+    (job) => job.status === "completed" || job.status === "failed",
+  );
+  const excess = finished.length - MAX_FINISHED_VERIFY_JOBS;
+  for (const job of finished.slice(0, Math.max(0, excess))) {
+    verifyJobs.delete(job.jobId);
+  }
+}
 
 export const libraryServices = {
   delete: async (libraryId: string): Promise<LibraryDeleteResult> => {
@@ -96,6 +758,80 @@ export const libraryServices = {
     return result;
   },
 
+  clearMedia: async (libraryId: string): Promise<LibraryClearMediaResult> => {
+    logger.info(`🗑️  Removing all media from library: ${libraryId}`);
+
+    const library = (await prisma.library.findUnique({
+      where: { id: libraryId },
+      include: {
+        media: {
+          include: {
+            media: {
+              include: {
+                libraries: true,
+              },
+            },
+          },
+        },
+      },
+    })) as LibraryWithMediaRelations | null;
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    // Media shared with other libraries is only unlinked, never deleted
+    const mediaToDelete = library.media
+      .filter(
+        (ml: MediaLibraryWithRelations) => ml.media.libraries.length === 1,
+      )
+      .map((ml: MediaLibraryWithRelations) => ml.mediaId);
+
+    const result = await prisma.$transaction(
+      async (tx: PrismaTransactionClient) => {
+        const mediaFilter = { mediaId: { in: mediaToDelete } };
+
+        // Count the rows the media cascade will remove, for the response
+        const [moviesDeleted, tvShowsDeleted, seasonsDeleted, episodesDeleted] =
+          await Promise.all([
+            tx.movie.count({ where: mediaFilter }),
+            tx.tVShow.count({ where: mediaFilter }),
+            tx.season.count({ where: { tvShow: mediaFilter } }),
+            tx.episode.count({ where: { season: { tvShow: mediaFilter } } }),
+          ]);
+
+        // Deleting media cascades to Movie/TVShow/Season/Episode rows,
+        // genres, people, external IDs and library links
+        const { count: mediaDeleted } = await tx.media.deleteMany({
+          where: { id: { in: mediaToDelete } },
+        });
+
+        // Unlink media that also belongs to other libraries
+        const { count: linksRemoved } = await tx.mediaLibrary.deleteMany({
+          where: { libraryId },
+        });
+
+        // Recently added entries for unlinked media no longer apply here
+        await tx.scanAddition.deleteMany({ where: { libraryId } });
+
+        return {
+          libraryId: library.id,
+          libraryName: library.name,
+          mediaDeleted,
+          moviesDeleted,
+          tvShowsDeleted,
+          seasonsDeleted,
+          episodesDeleted,
+          linksRemoved,
+          message: `Removed ${mediaDeleted} media entries from library "${library.name}" (${linksRemoved} shared entries unlinked)`,
+        };
+      },
+    );
+
+    logger.info(`✅ ${result.message}\n`);
+    return result;
+  },
+
   getLibraries: async (filters?: {
     isLibrary?: boolean;
     libraryType?: string;
@@ -120,6 +856,7 @@ export const libraryServices = {
       orderBy: [{ isLibrary: "desc" }, { name: "asc" }],
     });
 
+    const staleAfterMs = getLibraryStaleAfterMs();
     const librariesWithMetadata: LibraryWithMetadata[] = libraries.map(
       (library) => {
         // Destructure to exclude the media array from the response
@@ -129,6 +866,10 @@ export const libraryServices = {
           createdAt: library.createdAt.toISOString(),
           updatedAt: library.updatedAt.toISOString(),
           mediaCount: media.length,
+          isStale:
+            library.isLibrary &&
+            library.libraryPath !== null &&
+            isLibraryStale(library, staleAfterMs),
         };
       },
     );
@@ -195,4 +936,918 @@ export const libraryServices = {
       message: `Successfully updated library "${updatedLibrary.name}"`,
     };
   },
+
+  getRecentlyAdded: async (
+    libraryId: string,
+    options: { since?: Date; skip: number; take: number },
+  ): Promise<RecentlyAddedResult> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const where: Prisma.ScanAdditionWhereInput = { libraryId };
+    if (options.since) {
+      where.addedAt = { gte: options.since };
+    }
+
+    const [total, additions] = await Promise.all([
+      prisma.scanAddition.count({ where }),
+      prisma.scanAddition.findMany({
+        where,
+        orderBy: { addedAt: "desc" },
+        skip: options.skip,
+        take: options.take,
+        include: {
+          media: {
+            select: {
+              id: true,
+              title: true,
+              type: true,
+              posterUrl: true,
+              backdropUrl: true,
+            },
+          },
+          episode: {
+            select: {
+              id: true,
+              title: true,
+              number: true,
+              season: { select: { number: true } },
+            },
+          },
+        },
+      }),
+    ]);
+
+    const items = additions.map((addition) => ({
+      id: addition.id,
+      addedAt: addition.addedAt.toISOString(),
+      scanJobId: addition.scanJobId,
+      media: addition.media,
+      episode: addition.episode
+        ? {
+            id: addition.episode.id,
+            title: addition.episode.title,
+            seasonNumber: addition.episode.season.number,
+            episodeNumber: addition.episode.number,
+          }
+        : null,
+    }));
+
+    return { items, total };
+  },
+
+  /**
+   * Suggest movies that may be duplicates of each other: same release year
+   * and normalized titles within maxDistance edits. Read-only, nothing is
+   * merged; a person confirms each group.
+   */
+  getMergeSuggestions: async (
+    libraryId: string,
+    maxDistance: number,
+  ): Promise<MergeSuggestionsResult> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const movies = await prisma.movie.findMany({
+      where: { media: { libraries: { some: { libraryId } } } },
+      select: {
+        id: true,
+        filePath: true,
+        media: { select: { id: true, title: true, releaseDate: true } },
+      },
+      orderBy: { media: { title: "asc" } },
+    });
+
+    // Only movies from the same year are compared; movies without a
+    // release date are compared with each other
+    const byYear = new Map<number | null, typeof movies>();
+    for (const movie of movies) {
+      const year = movie.media.releaseDate?.getUTCFullYear() ?? null;
+      const bucket = byYear.get(year) ?? [];
+      bucket.push(movie);
+      byYear.set(year, bucket);
+    }
+
+    const groups: MergeSuggestionGroup[] = [];
+
+    for (const [year, bucket] of byYear) {
+      const titles = bucket.map((movie) =>
+        normalizeTitleForComparison(movie.media.title),
+      );
+
+      // Union-find over every pair within the threshold
+      const parent = bucket.map((_, i) => i);
+      const find = (i: number): number => {
+        while (parent[i] !== i) {
+          parent[i] = parent[parent[i]!]!;
+          i = parent[i]!;
+        }
+        return i;
+      };
+      const lowestScore = new Map<number, number>();
+      const linkScores: Array<[number, number]> = [];
+
+      for (let i = 0; i < bucket.length; i++) {
+        const a = titles[i]!;
+        if (a === "") continue;
+
+        for (let j = i + 1; j < bucket.length; j++) {
+          const b = titles[j]!;
+          if (b === "") continue;
+
+          const distance = levenshteinDistance(a, b, maxDistance);
+          if (distance > maxDistance) continue;
+
+          parent[find(j)] = find(i);
+          linkScores.push([i, getTitleSimilarity(distance, a, b)]);
+        }
+      }
+
+      for (const [i, score] of linkScores) {
+        const root = find(i);
+        lowestScore.set(root, Math.min(lowestScore.get(root) ?? 1, score));
+      }
+
+      const members = new Map<number, MergeSuggestionMovie[]>();
+      bucket.forEach((movie, i) => {
+        const root = find(i);
+        if (!lowestScore.has(root)) return;
+
+        const list = members.get(root) ?? [];
+        list.push({
+          movieId: movie.id,
+          mediaId: movie.media.id,
+          title: movie.media.title,
+          filePath: movie.filePath,
+        });
+        members.set(root, list);
+      });
+
+      for (const [root, list] of members) {
+        groups.push({
+          year,
+          score: Math.round(lowestScore.get(root)! * 100) / 100,
+          movies: list,
+        });
+      }
+    }
+
+    groups.sort((a, b) => b.score - a.score);
+
+    logger.info(
+      `🔍 Found ${groups.length} suggested merge group(s) in library ${libraryId}`,
+    );
+
+    return { libraryId, maxDistance, groups };
+  },
+
+  /**
+   * Check that every file stored for a library still exists, without
+   * probing or parsing anything. Missing files get missingSince set, and
+   * files marked missing before that are back have it cleared; a dry run
+   * only reports. Stats use the scanner's per-file deadline
+   * (SCANNER_FILE_DEADLINE_SECONDS). onProgress gets the number of files
+   * checked so far after each page.
+   */
+  verify: async (
+    libraryId: string,
+    options: { dryRun: boolean; concurrency: number },
+    onProgress?: (checked: number) => void,
+  ): Promise<LibraryVerifyResult> => {
+    const { dryRun, concurrency } = options;
+
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true, name: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const deadlineMs = getFileDeadlineMs();
+    const result: LibraryVerifyResult = {
+      libraryId,
+      dryRun,
+      checked: 0,
+      ok: 0,
+      missing: 0,
+      permissionDenied: 0,
+      failed: 0,
+      marked: 0,
+      restored: 0,
+      missingFiles: [],
+      message: "",
+    };
+
+    for await (const files of listLibraryFiles(libraryId)) {
+      const statuses = await mapWithConcurrency(files, concurrency, (file) =>
+        checkStoredFile(file.filePath, deadlineMs),
+      );
+
+      const toMark = new Map<VerifiedFileKind, string[]>();
+      const toRestore = new Map<VerifiedFileKind, string[]>();
+      const push = (
+        target: Map<VerifiedFileKind, string[]>,
+        file: StoredFile,
+      ) => {
+        const ids = target.get(file.kind) ?? [];
+        ids.push(file.id);
+        target.set(file.kind, ids);
+      };
+
+      files.forEach((file, index) => {
+        const status = statuses[index]!;
+        result.checked++;
+        result[status]++;
+
+        if (status === "missing") {
+          if (result.missingFiles.length < MAX_REPORTED_MISSING_FILES) {
+            result.missingFiles.push({
+              kind: file.kind,
+              id: file.id,
+              filePath: file.filePath,
+            });
+          }
+          if (!file.missingSince) {
+            result.marked++;
+            push(toMark, file);
+          }
+        } else if (status === "ok" && file.missingSince) {
+          result.restored++;
+          push(toRestore, file);
+        }
+      });
+
+      if (!dryRun) {
+        const now = new Date();
+        for (const [kind, ids] of toMark) {
+          await setMissingSince(kind, ids, now);
+        }
+        for (const [kind, ids] of toRestore) {
+          await setMissingSince(kind, ids, null);
+        }
+      }
+      onProgress?.(result.checked);
+    }
+
+    const action = dryRun ? "would be marked" : "marked";
+    result.message = `Checked ${result.checked} file(s) in "${library.name}": ${result.missing} missing (${result.marked} newly ${action}), ${result.permissionDenied} permission denied, ${result.failed} failed`;
+    logger.info(`🔎 ${result.message}`);
+
+    // Denied or failed stats say nothing about whether a file is gone
+    const unchecked = result.permissionDenied + result.failed;
+    if (unchecked > 0) {
+      logger.warn(
+        `⚠️  Verify could not check ${unchecked} file(s) in "${library.name}" (${result.permissionDenied} permission denied, ${result.failed} failed or timed out) - they were left as they were`,
+      );
+    }
+
+    return result;
+  },
+
+  /**
+   * Queue a verify of a library as a background job and return it
+   * The job waits in the scan queue, so it never stats a mount while a
+   * scan is reading it; poll getVerifyJob for its status and result.
+   */
+  startVerify: async (
+    libraryId: string,
+    options: { dryRun: boolean; concurrency: number },
+  ): Promise<{
+    job: LibraryVerifyJob;
+    queuePosition: number;
+    estimatedStartAt: Date | null;
+  }> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const job: LibraryVerifyJob = {
+      jobId: randomUUID(),
+      libraryId,
+      dryRun: options.dryRun,
+      status: "queued",
+      queuedAt: new Date(),
+      startedAt: null,
+      finishedAt: null,
+      checked: 0,
+      result: null,
+      error: null,
+    };
+    verifyJobs.set(job.jobId, job);
+
+    const { queuePosition, estimatedStartAt } = enqueueScan(async () => {
+      job.status = "running";
+      job.startedAt = new Date();
+      try {
+        job.result = await libraryServices.verify(
+          libraryId,
+          options,
+          (checked) => {
+            job.checked = checked;
+          },
+        );
+        job.status = "completed";
+      } catch (error) {
+        job.status = "failed";
+        job.error = error instanceof Error ? error.message : String(error);
+        logger.error(`Verify of library ${libraryId} failed: ${job.error}`);
+      } finally {
+        job.finishedAt = new Date();
+        pruneVerifyJobs();
+      }
+    });
+
+    return { job, queuePosition, estimatedStartAt };
+  },
+
+  /**
+   * Get a verify job of a library by its ID
+   * Finished jobs are kept until MAX_FINISHED_VERIFY_JOBS newer ones have
+   * finished, or the server restarts
+   */
+  getVerifyJob: (libraryId: string, jobId: string): LibraryVerifyJob => {
+    const job = verifyJobs.get(jobId);
+    if (!job || job.libraryId !== libraryId) {
+      throw new NotFoundError("Verify job", jobId);
+    }
+    return job;
+  },
+
+  /**
+   * Parse the stored file name of every movie and episode in a library
+   * again, without filesystem access, and update the release attributes
+   * that changed; a dry run only reports. Titles are left alone since they
+   * come from TMDB. The main title of a disc folder is parsed by the name
+   * of the folder the disc is in, as the scanner does.
+   */
+  reparse: async (
+    libraryId: string,
+    options: { dryRun: boolean },
+  ): Promise<LibraryReparseResult> => {
+    const { dryRun } = options;
+
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true, name: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const result: LibraryReparseResult = {
+      libraryId,
+      dryRun,
+      checked: 0,
+      changed: 0,
+      changedFiles: [],
+      message: "",
+    };
+
+    const detectDiscs = isDiscStructureDetectionEnabled();
+
+    for await (const files of listParsedFiles(libraryId)) {
+      for (const file of files) {
+        result.checked++;
+
+        const name =
+          (detectDiscs && getDiscTitleName(file.filePath)) ||
+          basename(file.filePath);
+        const parsed = getReleaseAttributes(
+          extractIds(name, {
+            mediaType: file.kind === "episode" ? "tv" : "movie",
+          }),
+        );
+        const changes = diffReleaseAttributes(file.attributes, parsed);
+        if (Object.keys(changes).length === 0) {
+          continue;
+        }
+
+        result.changed++;
+        if (result.changedFiles.length < MAX_REPORTED_REPARSED_FILES) {
+          result.changedFiles.push({
+            kind: file.kind,
+            id: file.id,
+            filePath: file.filePath,
+            changes,
+          });
+        }
+
+        if (dryRun) {
+          continue;
+        }
+
+        const data = { ...parsed, ...getScannerVersionData() };
+        if (file.kind === "movie") {
+          await prisma.movie.update({ where: { id: file.id }, data });
+        } else {
+          await prisma.episode.update({ where: { id: file.id }, data });
+        }
+      }
+    }
+
+    const action = dryRun ? "would change" : "changed";
+    result.message = `Re-parsed ${result.checked} file(s) in "${library.name}": ${result.changed} ${action}`;
+    logger.info(`🔤 ${result.message}`);
+
+    return result;
+  },
+
+  /**
+   * Get a library for export, failing before any output is written
+   */
+  getExportLibrary: async (libraryId: string): Promise<Library> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    return library;
+  },
+
+  /**
+   * Stream every record of a library export: the library header, then each
+   * movie, and each TV show followed by its seasons and episodes.
+   * Media is read in cursor-paginated batches so large libraries are never
+   * loaded into memory at once.
+   */
+  exportRecords: async function* (
+    library: Library,
+  ): AsyncGenerator<LibraryExportRecord> {
+    yield {
+      type: "library",
+      version: LIBRARY_EXPORT_VERSION,
+      exportedAt: new Date().toISOString(),
+      library: {
+        id: library.id,
+        name: library.name,
+        slug: library.slug,
+        description: library.description,
+        posterUrl: library.posterUrl,
+        backdropUrl: library.backdropUrl,
+        libraryPath: library.libraryPath,
+        libraryType: library.libraryType,
+      },
+    };
+
+    let cursor: string | undefined;
+
+    while (true) {
+      const links = await prisma.mediaLibrary.findMany({
+        where: { libraryId: library.id },
+        orderBy: { id: "asc" },
+        take: EXPORT_BATCH_SIZE,
+        ...(cursor ? { cursor: { id: cursor }, skip: 1 } : {}),
+        include: { media: { include: exportMediaInclude } },
+      });
+
+      for (const { media } of links) {
+        const content = toExportContent(media);
+
+        if (media.movie) {
+          yield {
+            type: "movie",
+            ...content,
+            duration: media.movie.duration,
+            trailerUrl: media.movie.trailerUrl,
+            file: toExportFile(media.movie),
+          };
+        } else if (media.tvShow) {
+          yield {
+            type: "tvShow",
+            ...content,
+            creator: media.tvShow.creator,
+            network: media.tvShow.network,
+          };
+          yield* exportShowChildren(media.tvShow.id, {
+            title: content.title,
+            year: content.year,
+            tmdbId: content.externalIds.TMDB ?? null,
+          });
+        }
+      }
+
+      const lastLink = links[links.length - 1];
+      if (!lastLink || links.length < EXPORT_BATCH_SIZE) {
+        break;
+      }
+      cursor = lastLink.id;
+    }
+  },
+
+  /**
+   * Import records produced by exportRecords into a library.
+   * Records may be objects or NDJSON lines (strings).
+   * Content is matched on its TMDB ID, then on filePath, then on type,
+   * title and year, so importing the same export twice updates rows instead
+   * of duplicating them. A movie keeps a stored file that is on disk rather
+   * than take a different one from the export, and a file another movie
+   * holds is left with it.
+   * A missing library is created from the export's header record.
+   */
+  importRecords: async (
+    libraryId: string,
+    records: AsyncIterable<unknown> | Iterable<unknown>,
+  ): Promise<LibraryImportResult> => {
+    let library = await prisma.library.findUnique({
+      where: { id: libraryId },
+    });
+    let libraryCreated = false;
+
+    const result = {
+      moviesImported: 0,
+      tvShowsImported: 0,
+      seasonsImported: 0,
+      episodesImported: 0,
+      movieFilesKept: 0,
+      recordsFailed: 0,
+    };
+    const errors: LibraryImportResult["errors"] = [];
+    const recordError = (record: number, message: string) => {
+      result.recordsFailed++;
+      if (errors.length < MAX_IMPORT_ERRORS) {
+        errors.push({ record, message });
+      }
+    };
+
+    // Show and season IDs resolved so far, to avoid a lookup per episode
+    const tvShowIds = new Map<string, string>();
+    const seasonIds = new Map<string, string>();
+
+    const resolveTvShowId = async (
+      show: LibraryExportShowRef,
+    ): Promise<string> => {
+      const key = getShowKey(show);
+      const cached = tvShowIds.get(key);
+      if (cached) {
+        return cached;
+      }
+
+      const media = await findImportedContentMedia(MediaType.TV_SHOW, show);
+      if (!media?.tvShow) {
+        throw new Error(
+          `TV show "${show.title}"${show.year ? ` (${show.year})` : ""} not found in the import or the database`,
+        );
+      }
+
+      tvShowIds.set(key, media.tvShow.id);
+      return media.tvShow.id;
+    };
+
+    const upsertSeason = async (
+      tvShowId: string,
+      number: number,
+      posterUrl?: string | null,
+    ): Promise<string> => {
+      const season = await prisma.season.upsert({
+        where: { tvShowId_number: { tvShowId, number } },
+        update: posterUrl !== undefined ? { posterUrl } : {},
+        create: { tvShowId, number, posterUrl: posterUrl ?? null },
+      });
+      seasonIds.set(`${tvShowId}:${number}`, season.id);
+      return season.id;
+    };
+
+    let recordNumber = 0;
+
+    for await (const entry of records) {
+      // Strings are NDJSON lines; blank lines are not records
+      if (typeof entry === "string" && entry.trim() === "") {
+        continue;
+      }
+
+      recordNumber++;
+
+      let raw: unknown = entry;
+      if (typeof entry === "string") {
+        try {
+          raw = JSON.parse(entry);
+        } catch {
+          recordError(recordNumber, "Line is not valid JSON");
+          continue;
+        }
+      }
+
+      const parsed = libraryImportRecordSchema.safeParse(raw);
+      if (!parsed.success) {
+        const issue = parsed.error.issues[0];
+        recordError(
+          recordNumber,
+          issue
+            ? `${issue.path.join(".") || "record"}: ${issue.message}`
+            : "Invalid record",
+        );
+        continue;
+      }
+
+      const record = parsed.data;
+
+      if (record.type === "library") {
+        if (record.version > LIBRARY_EXPORT_VERSION) {
+          throw new ValidationError(
+            `Unsupported export version ${record.version} (this server reads up to version ${LIBRARY_EXPORT_VERSION})`,
+          );
+        }
+
+        if (!library) {
+          const slugOwner = await prisma.library.findUnique({
+            where: { slug: record.library.slug },
+            select: { id: true },
+          });
+          if (slugOwner) {
+            throw new ConflictError(
+              `Library slug "${record.library.slug}" is already used by library ${slugOwner.id}`,
+            );
+          }
+
+          library = await prisma.library.create({
+            data: {
+              id: libraryId,
+              name: record.library.name,
+              slug: record.library.slug,
+              description: record.library.description,
+              posterUrl: record.library.posterUrl,
+              backdropUrl: record.library.backdropUrl,
+              libraryPath: record.library.libraryPath,
+              libraryType: record.library.libraryType,
+              isLibrary: true,
+            },
+          });
+          libraryCreated = true;
+          logger.info(`📚 Created library from export: ${library.name}`);
+        }
+        continue;
+      }
+
+      if (!library) {
+        throw new NotFoundError("Library", libraryId);
+      }
+
+      try {
+        switch (record.type) {
+          case "movie": {
+            const fileOwner = record.file
+              ? await prisma.movie.findUnique({
+                  where: { filePath: record.file.filePath },
+                  select: { mediaId: true },
+                })
+              : null;
+            // A TMDB match wins over the movie that holds the file, so one
+            // movie's metadata is never written over another's
+            const tmdbId = record.externalIds.TMDB;
+            const byTmdbId = tmdbId
+              ? await findContentMediaByTmdbId(MediaType.MOVIE, tmdbId)
+              : null;
+            const mediaId = await importContentMedia(
+              library.id,
+              MediaType.MOVIE,
+              record,
+              byTmdbId?.id ?? fileOwner?.mediaId,
+            );
+
+            // The file is only taken when no other movie holds it and the
+            // movie has no other file on disk (one not marked missing)
+            const stored = await prisma.movie.findUnique({
+              where: { mediaId },
+              select: { filePath: true, missingSince: true },
+            });
+            const keepStoredFile =
+              record.file !== null &&
+              ((fileOwner !== null && fileOwner.mediaId !== mediaId) ||
+                (!!stored?.filePath &&
+                  stored.filePath !== record.file.filePath &&
+                  stored.missingSince === null));
+            if (keepStoredFile) {
+              result.movieFilesKept++;
+              logger.warn(
+                `📥 Import of "${record.title}": kept the stored file instead of ${record.file!.filePath}`,
+              );
+            }
+
+            const movieData = {
+              duration: record.duration,
+              trailerUrl: record.trailerUrl,
+              ...toFileColumns(keepStoredFile ? null : record.file),
+            };
+            await prisma.movie.upsert({
+              where: { mediaId },
+              update: movieData,
+              create: { mediaId, ...movieData },
+            });
+            result.moviesImported++;
+            break;
+          }
+
+          case "tvShow": {
+            const mediaId = await importContentMedia(
+              library.id,
+              MediaType.TV_SHOW,
+              record,
+            );
+            const showData = {
+              creator: record.creator,
+              network: record.network,
+            };
+            const tvShow = await prisma.tVShow.upsert({
+              where: { mediaId },
+              update: showData,
+              create: { mediaId, ...showData },
+            });
+            tvShowIds.set(
+              getShowKey({
+                title: record.title,
+                year: record.year,
+                tmdbId: record.externalIds.TMDB ?? null,
+              }),
+              tvShow.id,
+            );
+            result.tvShowsImported++;
+            break;
+          }
+
+          case "season": {
+            const tvShowId = await resolveTvShowId(record.show);
+            await upsertSeason(tvShowId, record.number, record.posterUrl);
+            result.seasonsImported++;
+            break;
+          }
+
+          case "episode": {
+            const tvShowId = await resolveTvShowId(record.show);
+            const seasonId =
+              seasonIds.get(`${tvShowId}:${record.seasonNumber}`) ??
+              (await upsertSeason(tvShowId, record.seasonNumber));
+
+            const episodeData = {
+              seasonId,
+              number: record.number,
+              title: record.title,
+              fileTitle: record.fileTitle,
+              duration: record.duration,
+              airDate: toDate(record.airDate),
+              stillPath: record.stillPath,
+              ...toFileColumns(record.file),
+            };
+
+            const existingEpisode = record.file
+              ? await prisma.episode.findUnique({
+                  where: { filePath: record.file.filePath },
+                  select: { id: true },
+                })
+              : null;
+
+            if (existingEpisode) {
+              await prisma.episode.update({
+                where: { id: existingEpisode.id },
+                data: episodeData,
+              });
+            } else {
+              await prisma.episode.upsert({
+                where: {
+                  seasonId_number: { seasonId, number: record.number },
+                },
+                update: episodeData,
+                create: episodeData,
+              });
+            }
+            result.episodesImported++;
+            break;
+          }
+        }
+      } catch (error) {
+        recordError(
+          recordNumber,
+          error instanceof Error ? error.message : String(error),
+        );
+      }
+    }
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const imported =
+      result.moviesImported +
+      result.tvShowsImported +
+      result.seasonsImported +
+      result.episodesImported;
+
+    logger.info(
+      `📥 Imported ${imported} records into library "${library.name}" (${result.recordsFailed} failed)`,
+    );
+
+    return {
+      libraryId: library.id,
+      libraryName: library.name,
+      libraryCreated,
+      ...result,
+      errors,
+      message: `Imported ${imported} records into library "${library.name}"${result.recordsFailed > 0 ? ` (${result.recordsFailed} failed)` : ""}`,
+    };
+  },
+
+  getPathOverrides: async (libraryId: string): Promise<PathOverride[]> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    return prisma.pathOverride.findMany({
+      where: { libraryId },
+      orderBy: { path: "asc" },
+    });
+  },
+
+  /**
+   * Create or replace the override for a file or folder path.
+   * Fields left out are cleared, so the override always matches the request.
+   */
+  setPathOverride: async (
+    libraryId: string,
+    data: {
+      path: string;
+      title?: string;
+      year?: number;
+      season?: number;
+      episode?: number;
+    },
+  ): Promise<PathOverride> => {
+    const library = await prisma.library.findUnique({
+      where: { id: libraryId },
+      select: { id: true },
+    });
+
+    if (!library) {
+      throw new NotFoundError("Library", libraryId);
+    }
+
+    const path = normalizeOverridePath(data.path);
+    const values = {
+      title: data.title ?? null,
+      year: data.year ?? null,
+      season: data.season ?? null,
+      episode: data.episode ?? null,
+    };
+
+    const override = await prisma.pathOverride.upsert({
+      where: { libraryId_path: { libraryId, path } },
+      update: values,
+      create: { libraryId, path, ...values },
+    });
+
+    logger.info(`📌 Set path override for ${path}`);
+
+    return override;
+  },
+
+  deletePathOverride: async (
+    libraryId: string,
+    rawPath: string,
+  ): Promise<PathOverrideDeleteResult> => {
+    const path = normalizeOverridePath(rawPath);
+
+    const override = await prisma.pathOverride.findUnique({
+      where: { libraryId_path: { libraryId, path } },
+      select: { id: true },
+    });
+
+    if (!override) {
+      throw new NotFoundError("Path override", path);
+    }
+
+    await prisma.pathOverride.delete({ where: { id: override.id } });
+
+    logger.info(`📌 Removed path override for ${path}`);
+
+    return {
+      path,
+      message: `Removed path override for ${path}`,
+    };
+  },
 };
@@ -1,4 +1,10 @@
-import { Library, Prisma } from "@prisma/client";
+import {
+  ExternalIdSource,
+  Library,
+  MediaType,
+  Prisma,
+  RoleType,
+} from "@prisma/client";
 
 /**
  * Library types and interfaces
@@ -11,17 +17,269 @@ export interface LibraryDeleteResult {
   message: string;
 }
 
+export interface LibraryClearMediaResult {
+  libraryId: string;
+  libraryName: string;
+  mediaDeleted: number;
+  moviesDeleted: number;
+  tvShowsDeleted: number;
+  seasonsDeleted: number;
+  episodesDeleted: number;
+  linksRemoved: number;
+  message: string;
+}
+
 export interface LibraryUpdateResult {
   library: Library;
   message: string;
 }
 
+// A file added to the library for the first time during a scan
+export interface RecentlyAddedItem {
+  id: string;
+  addedAt: string;
+  scanJobId: string | null;
+  media: {
+    id: string;
+    title: string;
+    type: string;
+    posterUrl: string | null;
+    backdropUrl: string | null;
+  };
+  episode: {
+    id: string;
+    title: string;
+    seasonNumber: number;
+    episodeNumber: number;
+  } | null;
+}
+
+export interface RecentlyAddedResult {
+  items: RecentlyAddedItem[];
+  total: number;
+}
+
+export interface MergeSuggestionMovie {
+  movieId: string;
+  mediaId: string;
+  title: string;
+  filePath: string | null;
+}
+
+// Movies that look like the same title. Score is the lowest similarity
+// (0-1) between any two linked titles in the group.
+export interface MergeSuggestionGroup {
+  year: number | null;
+  score: number;
+  movies: MergeSuggestionMovie[];
+}
+
+export interface MergeSuggestionsResult {
+  libraryId: string;
+  maxDistance: number;
+  groups: MergeSuggestionGroup[];
+}
+
+// ────────────────────────────
+// Library verify
+// ────────────────────────────
+
+export type VerifiedFileKind = "movie" | "episode" | "extra";
+
+export interface MissingFile {
+  kind: VerifiedFileKind;
+  id: string;
+  filePath: string;
+}
+
+export interface LibraryVerifyResult {
+  libraryId: string;
+  dryRun: boolean;
+  checked: number;
+  ok: number;
+  missing: number;
+  permissionDenied: number;
+  failed: number; // Timed out or failed for another reason; left unchanged
+  marked: number; // Newly marked missing
+  restored: number; // Marked missing before, found again
+  missingFiles: MissingFile[]; // The first missing files
+  message: string;
+}
+
+export type LibraryVerifyJobStatus =
+  | "queued"
+  | "running"
+  | "completed"
+  | "failed";
+
+// A verify run in the background, queued behind scans
+export interface LibraryVerifyJob {
+  jobId: string;
+  libraryId: string;
+  dryRun: boolean;
+  status: LibraryVerifyJobStatus;
+  queuedAt: Date;
+  startedAt: Date | null;
+  finishedAt: Date | null;
+  checked: number; // Files checked so far
+  result: LibraryVerifyResult | null; // Set once completed
+  error: string | null; // Set once failed
+}
+
+// ────────────────────────────
+// Library reparse
+// ────────────────────────────
+
+export type ReparsedFileKind = "movie" | "episode";
+
+export interface ReparsedFile {
+  kind: ReparsedFileKind;
+  id: string;
+  filePath: string;
+  // Changed release attributes, stored value first
+  changes: Record<string, { from: unknown; to: unknown }>;
+}
+
+export interface LibraryReparseResult {
+  libraryId: string;
+  dryRun: boolean;
+  checked: number;
+  changed: number; // Updated, or that would be in a dry run
+  changedFiles: ReparsedFile[]; // The first changed files
+  message: string;
+}
+
+// ────────────────────────────
+// Library export / import
+// ────────────────────────────
+
+// A file on disk and what the scanner parsed from its name
+export interface LibraryExportFile {
+  filePath: string;
+  fileSize: string | null; // Bytes, as a string because sizes are BigInt
+  fileModifiedAt: string | null;
+  is3D: boolean;
+  isRemux: boolean;
+  sourceType: string | null;
+  resolution: string | null;
+  language: string | null;
+  isProper: boolean;
+  isRepack: boolean;
+  isInternal: boolean;
+  titleSource: string | null; // filename, folder, override or override-file
+  scannerVersion: string | null;
+}
+
+// Fields shared by movie and TV show records (the Media row)
+export interface LibraryExportContent {
+  title: string;
+  year: number | null;
+  description: string | null;
+  posterUrl: string | null;
+  backdropUrl: string | null;
+  meshGradientColors: string[];
+  releaseDate: string | null;
+  rating: number | null;
+  externalIds: Partial<Record<ExternalIdSource, string>>;
+  genres: string[];
+  people: Array<{
+    id: string;
+    name: string;
+    role: RoleType;
+    character: string | null;
+  }>;
+}
+
+// Identifies the show a season or episode record belongs to
+export interface LibraryExportShowRef {
+  title: string;
+  year: number | null;
+  tmdbId: string | null; // Tells apart shows of the same title and year
+}
+
+export interface LibraryExportHeaderRecord {
+  type: "library";
+  version: number;
+  exportedAt: string;
+  library: {
+    id: string;
+    name: string;
+    slug: string;
+    description: string | null;
+    posterUrl: string | null;
+    backdropUrl: string | null;
+    libraryPath: string | null;
+    libraryType: MediaType | null;
+  };
+}
+
+export interface LibraryExportMovieRecord extends LibraryExportContent {
+  type: "movie";
+  duration: number | null;
+  trailerUrl: string | null;
+  file: LibraryExportFile | null;
+}
+
+export interface LibraryExportTVShowRecord extends LibraryExportContent {
+  type: "tvShow";
+  creator: string | null;
+  network: string | null;
+}
+
+export interface LibraryExportSeasonRecord {
+  type: "season";
+  show: LibraryExportShowRef;
+  number: number;
+  posterUrl: string | null;
+}
+
+export interface LibraryExportEpisodeRecord {
+  type: "episode";
+  show: LibraryExportShowRef;
+  seasonNumber: number;
+  number: number;
+  title: string;
+  fileTitle: string | null;
+  duration: number | null;
+  airDate: string | null;
+  stillPath: string | null;
+  file: LibraryExportFile | null;
+}
+
+// One line of an NDJSON export (or one element of a JSON export's records)
+export type LibraryExportRecord =
+  | LibraryExportHeaderRecord
+  | LibraryExportMovieRecord
+  | LibraryExportTVShowRecord
+  | LibraryExportSeasonRecord
+  | LibraryExportEpisodeRecord;
+
+export interface LibraryImportResult {
+  libraryId: string;
+  libraryName: string;
+  libraryCreated: boolean;
+  moviesImported: number;
+  tvShowsImported: number;
+  seasonsImported: number;
+  episodesImported: number;
+  movieFilesKept: number; // Imported movies whose stored file was kept
+  recordsFailed: number;
+  errors: Array<{ record: number; message: string }>; // Capped sample
+  message: string;
+}
+
+export interface PathOverrideDeleteResult {
+  path: string;
+  message: string;
+}
+
 // Extended library type with media count
 export interface LibraryWithMetadata
   extends Omit<Library, "createdAt" | "updatedAt"> {
   createdAt: string;
   updatedAt: string;
   mediaCount: number;
+  isStale: boolean; // No full scan within LIBRARY_STALE_AFTER
 }
 
 // Prisma payload types for type-safe queries
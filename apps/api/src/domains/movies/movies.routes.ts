@@ -174,6 +174,22 @@ router.get("/", moviesControllers.getMovies);
  *                       type: string
  *                       description: URL to stream the movie
  *                       example: "/api/v1/stream/clx123abc456def789"
+ *                     extras:
+ *                       type: array
+ *                       description: Trailers, featurettes and other extras found next to the movie
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           id:
+ *                             type: string
+ *                           extraType:
+ *                             type: string
+ *                             example: "trailer"
+ *                           title:
+ *                             type: string
+ *                             example: "Inception (2010)-trailer"
+ *                           filePath:
+ *                             type: string
  *                     mediaId:
  *                       type: string
  *                       example: "clx987zyx654wvu321"
@@ -10,7 +10,9 @@ export const moviesServices = {
   getMovies: async (): Promise<MoviesListResponse> => {
     logger.info("📽️  Fetching movies list...");
 
+    // A movie known only from its extras has no file to list
     const movies = await prisma.movie.findMany({
+      where: { filePath: { not: null } },
       include: {
         media: true,
       },
@@ -47,6 +49,9 @@ export const moviesServices = {
       where: { id },
       include: {
         media: true,
+        extras: {
+          orderBy: [{ extraType: "asc" }, { title: "asc" }],
+        },
       },
     });
     if (!movie) {
@@ -2,13 +2,14 @@
  * Movie types and interfaces
  */
 
-import { Movie, Media } from "@prisma/client";
+import { Movie, Media, MovieExtra } from "@prisma/client";
 
 /**
  * Movie with its associated media information
  */
 export interface MovieWithMedia extends Movie {
   media: Media;
+  extras?: MovieExtra[]; // Trailers, featurettes, ... (single movie only)
 }
 
 /**
@@ -3,42 +3,85 @@
  * Handles scanning large directories in manageable batches
  */
 
-import { readdir } from "fs/promises";
 import { join } from "path";
 import { logger } from "@/lib/utils";
 import { MediaType, ScanJobStatus } from "@/lib/database";
 import prisma from "@/lib/database/prisma";
 import { collectMediaEntries } from "./file-scanner.helper";
+import {
+  recordScanThroughput,
+  setScanActivity,
+} from "./scan-activity.helper";
+import { createPathOverrideResolver } from "./path-override.helper";
+import { recordSuccessfulScan } from "./library-staleness.helper";
+import { listFolder, peekListing } from "./listing-cache.helper";
+import {
+  SKIP_SENTINEL_FILE,
+  describeSkipSentinel,
+  getActiveSkipSentinel,
+} from "./skip-sentinel.helper";
+import type { PathOverrideIndex } from "./path-override.helper";
+import type { ScanErrorCollector } from "./scan-errors.helper";
+import type { ScanSkipRecorder } from "./scan-skips.helper";
+import type { ScanResultsWriter } from "./scan-results.helper";
 import {
   fetchExistingMetadata,
   fetchMetadataForEntries,
   fetchSeasonMetadata,
   saveMediaToDatabase,
+  applyUnchangedMatches,
   createRateLimiter,
   withTimeoutAndRetry,
+  OperationTimeoutError,
+  getFileDeadlineMs,
+  getTrailerFolders,
+  saveThroughOutages,
+  DatabaseOutageError,
 } from "./index";
 import { wsManager } from "@/lib/websocket";
-import type { TmdbMetadata } from "../scan.types";
+import type {
+  ScanRequestPayload,
+  ScanRequester,
+  TmdbMetadata,
+} from "../scan.types";
 import type { TmdbSeasonMetadata } from "@/lib/providers/tmdb/tmdb.types";
 
 /**
  * Discover top-level folders to batch process
  * For TV shows: Returns show folders
  * For movies: Returns movie folders or files depending on structure
+ * A root skipped by a sentinel file has no folders; onSentinelSkip tells it
+ * apart from an empty root.
  */
 export async function discoverFoldersToScan(
   rootPath: string,
   mediaType: "movie" | "tv",
+  options: { onSentinelSkip?: () => void } = {},
 ): Promise<string[]> {
   return withTimeoutAndRetry(
     async () => {
       logger.info(
         `🔍 Listing directory: ${rootPath} (this may take a while on slow mounts)...`,
       );
-      const entries = await readdir(rootPath, { withFileTypes: true });
+      // A movie pass and a TV pass over the same root list it once
+      const directory = await peekListing(await listFolder(rootPath));
+
+      const sentinel =
+        directory.hasFile(SKIP_SENTINEL_FILE) === false
+          ? null
+          : await getActiveSkipSentinel(rootPath);
+      if (sentinel) {
+        await directory.close();
+        logger.info(
+          `⏸️  Skipping ${rootPath}: ${describeSkipSentinel(sentinel)}`,
+        );
+        options.onSentinelSkip?.();
+        return [];
+      }
+
       const folders: string[] = [];
 
-      for (const entry of entries) {
+      for await (const entry of directory.entries) {
         // Skip hidden files and system files
         if (entry.name.startsWith(".") || entry.name.startsWith("@")) {
           continue;
@@ -52,6 +95,18 @@ export async function discoverFoldersToScan(
       logger.info(
         `📂 Discovered ${folders.length} ${mediaType === "tv" ? "show" : "movie"} folders to scan`,
       );
+
+      // Central trailer folders go last so the movies they link to are
+      // saved before them
+      if (mediaType === "movie") {
+        const trailerFolders = getTrailerFolders();
+        const isTrailerFolder = (name: string) =>
+          trailerFolders.has(name.toLowerCase());
+        return [
+          ...folders.filter((name) => !isTrailerFolder(name)),
+          ...folders.filter(isTrailerFolder),
+        ];
+      }
       return folders;
     },
     {
@@ -70,6 +125,8 @@ export async function createScanJob(
   scanPath: string,
   mediaType: MediaType,
   folders: string[],
+  requestPayload?: ScanRequestPayload,
+  requester?: ScanRequester,
 ): Promise<string> {
   // Determine batch size based on media type
   const batchSize = mediaType === MediaType.TV_SHOW ? 5 : 25;
@@ -88,6 +145,10 @@ export async function createScanJob(
       totalBatches,
       currentBatch: 0,
       pendingFolders: JSON.stringify(folders),
+      requestPayload: requestPayload ? JSON.stringify(requestPayload) : null,
+      origin: requester?.origin ?? null,
+      userAgent: requester?.userAgent ?? null,
+      remoteAddress: requester?.remoteAddress ?? null,
       startedAt: new Date(),
     },
   });
@@ -98,6 +159,27 @@ export async function createScanJob(
   return scanJob.id;
 }
 
+/**
+ * Read the request options stored on a scan job
+ * Returns null for jobs created before payloads were stored or with unreadable JSON
+ */
+export function parseScanRequestPayload(
+  requestPayload: string | null,
+): ScanRequestPayload | null {
+  if (!requestPayload) {
+    return null;
+  }
+
+  try {
+    return JSON.parse(requestPayload) as ScanRequestPayload;
+  } catch (error) {
+    logger.warn(
+      `Ignoring unreadable scan request payload: ${error instanceof Error ? error.message : error}`,
+    );
+    return null;
+  }
+}
+
 /**
  * Get the next batch of folders to process
  */
@@ -180,6 +262,14 @@ export async function markBatchProcessed(
     },
   });
 
+  // Only a scan of the whole library counts toward its last full scan
+  if (
+    isComplete &&
+    !parseScanRequestPayload(scanJob.requestPayload)?.targeted
+  ) {
+    await recordSuccessfulScan(scanJob.libraryId);
+  }
+
   logger.info(
     `✅ Batch ${newCurrentBatch}/${scanJob.totalBatches} processed: ${processedFolderNames.length} success, ${failedFolderNames.length} failed (${totalProcessed}/${scanJob.totalFolders} folders, ${newTotalItemsSaved} items saved)`,
   );
@@ -200,11 +290,25 @@ export async function processFolderBatch(
     fileExtensions: string[];
     rescan?: boolean;
     originalPath?: string;
+    includeExtras?: boolean;
+    maxFiles?: number; // Remaining file budget for the whole scan
+    pathOverrides?: PathOverrideIndex;
+    errorCollector?: ScanErrorCollector;
+    skipRecorder?: ScanSkipRecorder; // Set when the scan records skipped files
+    resultsWriter?: ScanResultsWriter | null; // Per-file results (SCANNER_RESULTS_FILE)
   },
 ): Promise<{
   processedFolders: string[];
   failedFolders: string[];
   totalSaved: number;
+  extrasSaved: number;
+  filesFound: number;
+  fileLimitReached: boolean;
+  filesTimedOut: number;
+  lowConfidenceTitles: number;
+  depthLimitedFolders: number;
+  sentinelSkippedFolders: number;
+  unmatchedTrailers: number;
 }> {
   const {
     rootPath,
@@ -215,8 +319,17 @@ export async function processFolderBatch(
     fileExtensions,
     rescan = false,
     originalPath,
+    includeExtras = false,
+    maxFiles = Infinity,
+    pathOverrides,
+    errorCollector,
+    skipRecorder,
+    resultsWriter,
   } = options;
 
+  const resolveOverride = pathOverrides
+    ? createPathOverrideResolver(pathOverrides, originalPath)
+    : undefined;
   const rateLimiter = createRateLimiter();
   const metadataCache = new Map<string, TmdbMetadata>();
   const episodeMetadataCache = new Map<string, TmdbSeasonMetadata>();
@@ -224,6 +337,17 @@ export async function processFolderBatch(
   const processedFolders: string[] = [];
   const failedFolders: string[] = [];
   let totalSaved = 0;
+  let extrasSaved = 0;
+  let filesFound = 0;
+  let fileLimitReached = false;
+  let filesTimedOut = 0;
+  let lowConfidenceTitles = 0;
+  let depthLimitedFolders = 0;
+  let sentinelSkippedFolders = 0;
+  let unmatchedTrailers = 0;
+  const fileDeadlineMs = getFileDeadlineMs();
+  const trailerFolders =
+    mediaType === "movie" ? getTrailerFolders() : new Set<string>();
 
   // Get scan job for total folder count
   const scanJob = await prisma.scanJob.findUnique({
@@ -236,6 +360,9 @@ export async function processFolderBatch(
     : 0;
 
   for (const folderName of folderNames) {
+    // Leave the remaining folders pending once the scan hit its file limit
+    if (fileLimitReached) break;
+
     const folderPath = join(rootPath, folderName);
 
     try {
@@ -257,12 +384,32 @@ export async function processFolderBatch(
       });
 
       // Step 1: Collect media entries for this folder (with timeout and retry for slow mounts)
+      setScanActivity(scanJobId, "walking", folderName);
       const mediaEntries = await withTimeoutAndRetry(
         () =>
           collectMediaEntries(folderPath, {
             maxDepth,
             mediaType,
             fileExtensions,
+            includeExtras,
+            maxFiles: maxFiles - filesFound,
+            resolveOverride,
+            onLimitReached: () => {
+              fileLimitReached = true;
+            },
+            fileDeadlineMs,
+            errorCollector,
+            onFileTimeout: () => {
+              filesTimedOut++;
+            },
+            onDepthLimit: () => {
+              depthLimitedFolders++;
+            },
+            onSentinelSkip: () => {
+              sentinelSkippedFolders++;
+            },
+            onSkip: skipRecorder?.record,
+            isTrailerFolder: trailerFolders.has(folderName.toLowerCase()),
           }),
         {
           timeoutMs: 300000, // 5 minutes timeout per folder for very slow mounts
@@ -271,9 +418,19 @@ export async function processFolderBatch(
         },
       );
 
+      filesFound += mediaEntries.filter((e) => !e.isDirectory).length;
+      lowConfidenceTitles += mediaEntries.filter(
+        (e) => e.extractedIds.titleFallback,
+      ).length;
+
+      if (fileLimitReached && mediaEntries.length === 0) {
+        break;
+      }
+
       if (mediaEntries.length === 0) {
         logger.warn(`⚠️  No media found in ${folderName}, skipping`);
         processedFolders.push(folderName);
+        recordScanThroughput(scanJobId, 1, 0);
         continue;
       }
 
@@ -282,6 +439,8 @@ export async function processFolderBatch(
       // Step 2: Fetch existing metadata if not rescanning
       let existingMetadataMap = new Map<string, TmdbMetadata>();
       if (!rescan) {
+        await applyUnchangedMatches(mediaEntries, mediaType, originalPath);
+
         const tmdbIdsToCheck = mediaEntries
           .filter((e) => e.extractedIds.tmdbId)
           .map((e) => e.extractedIds.tmdbId!);
@@ -308,6 +467,7 @@ export async function processFolderBatch(
         libraryId,
         scanJobId,
       });
+      setScanActivity(scanJobId, "fetching-metadata", folderName);
 
       await fetchMetadataForEntries(mediaEntries, {
         mediaType,
@@ -345,25 +505,99 @@ export async function processFolderBatch(
       let savedCount = 0;
       for (const mediaEntry of mediaEntries) {
         if (!mediaEntry.isDirectory) {
+          setScanActivity(scanJobId, "saving", mediaEntry.path);
           try {
-            await saveMediaToDatabase(
-              mediaEntry,
-              mediaType,
-              tmdbApiKey,
-              episodeMetadataCache,
-              libraryId,
-              originalPath,
+            const saved = await saveThroughOutages(
+              () =>
+                saveMediaToDatabase(
+                  mediaEntry,
+                  mediaType,
+                  tmdbApiKey,
+                  episodeMetadataCache,
+                  libraryId,
+                  originalPath,
+                  scanJobId,
+                ),
+              mediaEntry.name,
             );
             savedCount++;
-          } catch (error) {
-            logger.error(
-              `Failed to save ${mediaEntry.name}: ${error instanceof Error ? error.message : error}`,
+            if (saved?.isExtra) {
+              extrasSaved++;
+            }
+            if (!saved && mediaEntry.fromTrailerFolder) {
+              unmatchedTrailers++;
+            }
+            if (!saved) {
+              skipRecorder?.record(
+                mediaEntry.path,
+                mediaEntry.fromTrailerFolder ? "not-in-library" : "no-match",
+              );
+            }
+            if (saved?.inferiorTo) {
+              skipRecorder?.record(
+                mediaEntry.path,
+                "inferior-copy",
+                `Better copy: ${saved.inferiorTo}`,
+              );
+            }
+            resultsWriter?.write(
+              mediaEntry,
+              saved
+                ? {
+                    outcome: saved.inferiorTo ? "inferior-copy" : "saved",
+                    title: saved.title,
+                    isNew: saved.isNew,
+                  }
+                : {
+                    outcome: mediaEntry.fromTrailerFolder
+                      ? "not-in-library"
+                      : "no-match",
+                  },
             );
+          } catch (error) {
+            // The database stayed down: stop the scan rather than drop files
+            if (error instanceof DatabaseOutageError) {
+              throw error;
+            }
+
+            const message =
+              error instanceof Error ? error.message : String(error);
+            if (error instanceof OperationTimeoutError) {
+              filesTimedOut++;
+              logger.warn(
+                `⏱️  Gave up on ${mediaEntry.name} after ${error.timeoutMs / 1000}s`,
+              );
+              skipRecorder?.record(mediaEntry.path, "timeout", message);
+              resultsWriter?.write(mediaEntry, {
+                outcome: "timeout",
+                error: message,
+              });
+            } else {
+              logger.error(`Failed to save ${mediaEntry.name}: ${message}`);
+              skipRecorder?.record(mediaEntry.path, "save-failed", message);
+              resultsWriter?.write(mediaEntry, {
+                outcome: "failed",
+                error: message,
+              });
+            }
+            errorCollector?.record(mediaEntry.path, error);
           }
         }
       }
 
       totalSaved += savedCount;
+      // A folder cut short by the file limit is not finished yet
+      recordScanThroughput(scanJobId, fileLimitReached ? 0 : 1, savedCount);
+
+      // A folder cut short by the file limit stays pending so a resumed scan
+      // walks it again in full
+      if (fileLimitReached) {
+        logger.warn(
+          `⚠️  ${folderName}: File limit reached, saved ${savedCount}/${mediaEntries.length} items so far`,
+        );
+        break;
+      }
+
       processedFolders.push(folderName);
 
       logger.info(
@@ -391,10 +625,17 @@ export async function processFolderBatch(
         },
       });
     } catch (error) {
+      // The folder stays pending for a resumed scan
+      if (error instanceof DatabaseOutageError) {
+        throw error;
+      }
+
       logger.error(
         `❌ Failed to process ${folderName}: ${error instanceof Error ? error.message : error}`,
       );
       failedFolders.push(folderName);
+      errorCollector?.record(folderPath, error, true);
+      recordScanThroughput(scanJobId, 1, 0);
 
       wsManager.sendScanError({
         error: `Failed to process ${folderName}: ${error instanceof Error ? error.message : String(error)}`,
@@ -407,5 +648,13 @@ export async function processFolderBatch(
     processedFolders,
     failedFolders,
     totalSaved,
+    extrasSaved,
+    filesFound,
+    fileLimitReached,
+    filesTimedOut,
+    lowConfidenceTitles,
+    depthLimitedFolders,
+    sentinelSkippedFolders,
+    unmatchedTrailers,
   };
 }
@@ -0,0 +1,165 @@
+/**
+ * Best copy of a movie
+ * A movie has one main file. When a library holds several copies of the
+ * same movie (a 720p and a 2160p release, say), the last one saved wins by
+ * default. With SCANNER_KEEP_BEST_COPY, the copy already saved is only
+ * replaced by a better one, and worse copies are skipped.
+ */
+
+import prisma from "@/lib/database/prisma";
+import { logger, mapHostToContainerPath } from "@/lib/utils";
+import { scanFs } from "./scan-fs.helper";
+import { getFileDeadlineMs } from "./scan-limits.helper";
+import { withTimeout } from "./timeout-helper";
+
+/**
+ * How copies of the same movie are compared
+ * - off: no comparison, the last copy saved wins
+ * - size: the larger file wins
+ * - resolution: the higher resolution parsed from the name wins, then the
+ *   larger file
+ */
+export type BestCopyPreference = "off" | "size" | "resolution";
+
+const BEST_COPY_PREFERENCES: readonly BestCopyPreference[] = [
+  "off",
+  "size",
+  "resolution",
+];
+
+/**
+ * Get how copies of the same movie are compared
+ * Read from SCANNER_KEEP_BEST_COPY, falling back to "off"
+ */
+export function getBestCopyPreference(
+  value: string | undefined = process.env.SCANNER_KEEP_BEST_COPY,
+): BestCopyPreference {
+  if (!value || value.trim() === "") {
+    return "off";
+  }
+
+  const preference = value.trim().toLowerCase() as BestCopyPreference;
+  if (!BEST_COPY_PREFERENCES.includes(preference)) {
+    logger.warn(
+      `Invalid SCANNER_KEEP_BEST_COPY "${value}" (use off, size or resolution), using off`,
+    );
+    return "off";
+  }
+
+  return preference;
+}
+
+interface MovieCopy {
+  size: number;
+  resolution: string | null | undefined;
+}
+
+// Vertical lines of a stored resolution ("1080p" -> 1080), 0 when unknown
+function getResolutionLines(resolution: string | null | undefined): number {
+  return resolution ? parseInt(resolution, 10) || 0 : 0;
+}
+
+/**
+ * Compare two copies of a movie: positive when `a` is better, negative when
+ * `b` is, 0 when neither is
+ */
+export function compareMovieCopies(
+  a: MovieCopy,
+  b: MovieCopy,
+  preference: Exclude<BestCopyPreference, "off">,
+): number {
+  if (preference === "resolution") {
+    const byResolution =
+      getResolutionLines(a.resolution) - getResolutionLines(b.resolution);
+    if (byResolution !== 0) {
+      return byResolution;
+    }
+  }
+
+  return a.size - b.size;
+}
+
+/**
+ * Check whether a stored copy is still on disk
+ * Only a path that does not exist counts as gone. A stat that is denied or
+ * gets no answer within the file deadline says nothing about the file, so
+ * the copy is kept rather than replaced while its drive is slow.
+ */
+export async function isStoredCopyPresent(
+  filePath: string,
+  fileDeadlineMs: number,
+): Promise<boolean> {
+  try {
+    await withTimeout(
+      scanFs.stat(filePath),
+      fileDeadlineMs,
+      `Stat ${filePath}`,
+    );
+    return true;
+  } catch (error) {
+    if ((error as NodeJS.ErrnoException).code === "ENOENT") {
+      return false;
+    }
+    logger.warn(
+      `Could not check stored copy ${filePath}, keeping it: ${error instanceof Error ? error.message : error}`,
+    );
+    return true;
+  }
+}
+
+/**
+ * Find the stored main file of a movie that is at least as good as a new
+ * copy, so the new copy can be skipped. Returns the stored path, or null
+ * when the new copy should be saved: there is no other stored file, it is
+ * missing from disk, or the new copy is better.
+ */
+export async function findBetterStoredCopy(
+  tmdbId: string,
+  filePath: string,
+  copy: MovieCopy,
+  preference: BestCopyPreference = getBestCopyPreference(),
+  fileDeadlineMs: number = getFileDeadlineMs(),
+): Promise<string | null> {
+  if (preference === "off") {
+    return null;
+  }
+
+  const stored = await prisma.movie.findFirst({
+    where: {
+      filePath: { not: null },
+      missingSince: null,
+      media: {
+        externalIds: { some: { source: "TMDB", externalId: tmdbId } },
+      },
+    },
+    select: { filePath: true, fileSize: true, resolution: true },
+  });
+
+  if (!stored?.filePath || stored.filePath === filePath) {
+    return null;
+  }
+
+  // A copy deleted since the last scan cannot win
+  if (
+    !(await isStoredCopyPresent(
+      mapHostToContainerPath(stored.filePath),
+      fileDeadlineMs,
+    ))
+  ) {
+    return null;
+  }
+
+  const comparison = compareMovieCopies(
+    copy,
+    { size: Number(stored.fileSize ?? 0), resolution: stored.resolution },
+    preference,
+  );
+  if (comparison > 0) {
+    logger.info(
+      `🏆 ${filePath} is a better copy than ${stored.filePath} (by ${preference}), replacing it`,
+    );
+    return null;
+  }
+
+  return stored.filePath;
+}
@@ -0,0 +1,464 @@
+/**
+ * Comic archive scanning
+ * Walks a folder of .cbz archives, reads each archive's page count from its
+ * zip directory and saves one comic per archive. The folder an archive is in
+ * is its series; the series and issue number are otherwise parsed from the
+ * filename. Comics have no TMDB lookup.
+ */
+
+import { open, opendir, stat } from "fs/promises";
+import { basename, extname, join, resolve } from "path";
+import prisma from "@/lib/database/prisma";
+import { MediaType } from "@/lib/database";
+import { logger, mapContainerToHostPath, sanitizeTitle } from "@/lib/utils";
+import { getEntrySkipReason } from "./file-filter.helper";
+import {
+  DIRECTORY_READ_BATCH_SIZE,
+  peekListing,
+} from "./listing-cache.helper";
+import { linkMediaToLibrary, recordScanAddition } from "./database.helper";
+import { OperationTimeoutError, withTimeout } from "./timeout-helper";
+import {
+  SKIP_SENTINEL_FILE,
+  describeSkipSentinel,
+  getActiveSkipSentinel,
+} from "./skip-sentinel.helper";
+import type { ScanErrorCollector } from "./scan-errors.helper";
+import type { SkipReason } from "./scan-skips.helper";
+
+/**
+ * Comic archive extensions that are scanned. .cbr (RAR) and .pdf are not
+ * read yet and are skipped as not-media.
+ */
+export const COMIC_EXTENSIONS = [".cbz"];
+
+// Archive entries counted as pages
+const PAGE_EXTENSIONS = [
+  ".jpg",
+  ".jpeg",
+  ".png",
+  ".gif",
+  ".webp",
+  ".bmp",
+  ".avif",
+  ".jxl",
+];
+
+// Zip record signatures
+const END_OF_CENTRAL_DIRECTORY = 0x06054b50;
+const ZIP64_END_LOCATOR = 0x07064b50;
+const ZIP64_END_OF_CENTRAL_DIRECTORY = 0x06064b50;
+const CENTRAL_DIRECTORY_HEADER = 0x02014b50;
+
+// End record (22 bytes) plus the longest possible archive comment
+const MAX_END_RECORD_SEARCH = 22 + 0xffff;
+
+// Largest central directory read into memory (a few hundred thousand entries)
+const MAX_CENTRAL_DIRECTORY_SIZE = 64 * 1024 * 1024;
+
+/**
+ * A comic archive found by the walk
+ */
+export interface ComicEntry {
+  path: string;
+  name: string;
+  series: string;
+  issue?: number;
+  volume?: string;
+  fileSize: number;
+  fileModifiedAt: Date;
+}
+
+export interface ParsedComicName {
+  series: string;
+  issue?: number;
+  volume?: string;
+}
+
+/**
+ * Count the pages (image entries) of a .cbz from its zip central directory
+ * Only the end of the file and the directory are read, not the images.
+ * Returns null if the file is not a readable zip.
+ */
+export async function countZipPages(filePath: string): Promise<number | null> {
+  const file = await open(filePath, "r");
+
+  try {
+    const { size } = await file.stat();
+    if (size < 22) {
+      return null;
+    }
+
+    // The end record sits at the very end, after an optional comment
+    const tailLength = Math.min(size, MAX_END_RECORD_SEARCH);
+    const tail = Buffer.alloc(tailLength);
+    await file.read(tail, 0, tailLength, size - tailLength);
+
+    let endOffset = -1;
+    for (let i = tailLength - 22; i >= 0; i--) {
+      if (tail.readUInt32LE(i) === END_OF_CENTRAL_DIRECTORY) {
+        endOffset = i;
+        break;
+      }
+    }
+    if (endOffset === -1) {
+      return null;
+    }
+
+    let directorySize = tail.readUInt32LE(endOffset + 12);
+    let directoryOffset = tail.readUInt32LE(endOffset + 16);
+
+    // Archives over 4GB or 65535 entries keep the real values in a zip64 record
+    if (directorySize === 0xffffffff || directoryOffset === 0xffffffff) {
+      const locatorOffset = endOffset - 20;
+      if (
+        locatorOffset < 0 ||
+        tail.readUInt32LE(locatorOffset) !== ZIP64_END_LOCATOR
+      ) {
+        return null;
+      }
+
+      const zip64 = Buffer.alloc(56);
+      await file.read(
+        zip64,
+        0,
+        56,
+        Number(tail.readBigUInt64LE(locatorOffset + 8)),
+      );
+      if (zip64.readUInt32LE(0) !== ZIP64_END_OF_CENTRAL_DIRECTORY) {
+        return null;
+      }
+      directorySize = Number(zip64.readBigUInt64LE(40));
+      directoryOffset = Number(zip64.readBigUInt64LE(48));
+    }
+
+    if (
+      directorySize > MAX_CENTRAL_DIRECTORY_SIZE ||
+      directoryOffset + directorySize > size
+    ) {
+      return null;
+    }
+
+    const directory = Buffer.alloc(directorySize);
+    await file.read(directory, 0, directorySize, directoryOffset);
+
+    let pages = 0;
+    let offset = 0;
+    while (offset + 46 <= directorySize) {
+      if (directory.readUInt32LE(offset) !== CENTRAL_DIRECTORY_HEADER) {
+        break;
+      }
+
+      const nameLength = directory.readUInt16LE(offset + 28);
+      const extraLength = directory.readUInt16LE(offset + 30);
+      const commentLength = directory.readUInt16LE(offset + 32);
+      const name = directory.toString(
+        "utf8",
+        offset + 46,
+        offset + 46 + nameLength,
+      );
+
+      // Skip folders and macOS resource forks
+      const fileName = name.split("/").pop() || "";
+      if (
+        !name.endsWith("/") &&
+        !name.startsWith("__MACOSX/") &&
+        !fileName.startsWith(".") &&
+        PAGE_EXTENSIONS.includes(extname(fileName).toLowerCase())
+      ) {
+        pages++;
+      }
+
+      offset += 46 + nameLength + extraLength + commentLength;
+    }
+
+    return pages;
+  } finally {
+    await file.close();
+  }
+}
+
+/**
+ * Drop release tags and separators from a series name
+ * "Saga_(2012)_(Digital)" -> "Saga"
+ */
+function cleanSeriesName(name: string): string {
+  return sanitizeTitle(
+    name
+      .replace(/\([^)]*\)|\[[^\]]*\]|\{[^}]*\}/g, " ")
+      .replace(/[._]+/g, " ")
+      .replace(/[\s-]+$/, ""),
+  ).title;
+}
+
+/**
+ * Parse series, issue and volume from a comic file name
+ *
+ * Handles common names like "Saga #012 (2012).cbz", "Saga 012 (2012)
+ * (Digital).cbz", "Saga v02 012.cbz" and "Saga - Issue 12.cbz". When
+ * `folderName` is given the archive is in a series folder, and the folder
+ * name is the series.
+ */
+export function parseComicName(
+  fileName: string,
+  folderName?: string,
+): ParsedComicName {
+  let name = basename(fileName, extname(fileName));
+
+  name = name
+    .replace(/\([^)]*\)|\[[^\]]*\]|\{[^}]*\}/g, " ")
+    .replace(/[._]+/g, " ");
+
+  let volume: string | undefined;
+  const volumeMatch = name.match(/\b(?:v|vol\.?\s*|volume\s*)(\d{1,3})\b/i);
+  if (volumeMatch) {
+    volume = String(parseInt(volumeMatch[1]!, 10));
+    name = name.replace(volumeMatch[0], " ");
+  }
+
+  // "#12", "Issue 12" / "No. 12", or a trailing number ("Saga 012", "Saga 12 of 54")
+  const issueMatch =
+    name.match(/#\s*(\d{1,4})\b/) ||
+    name.match(/\b(?:issue|no\.?)\s*(\d{1,4})\b/i) ||
+    name.match(/(?:^|\s)(\d{1,4})(?:\s+of\s+\d+)?\s*$/i);
+  const issue = issueMatch ? parseInt(issueMatch[1]!, 10) : undefined;
+
+  const seriesFromName =
+    issueMatch && issueMatch.index !== undefined
+      ? name.slice(0, issueMatch.index)
+      : name;
+
+  const fromFolder = folderName ? cleanSeriesName(folderName) : "";
+  const series =
+    fromFolder ||
+    cleanSeriesName(seriesFromName) ||
+    cleanSeriesName(basename(fileName, extname(fileName)));
+
+  return { series, issue, volume };
+}
+
+/**
+ * Display title of a comic, e.g. "Saga #12"
+ */
+export function getComicTitle(comic: ParsedComicName): string {
+  return comic.issue !== undefined
+    ? `${comic.series} #${comic.issue}`
+    : comic.series;
+}
+
+/**
+ * Recursively collect comic archives from a directory
+ * Archives in a subfolder take that folder as their series; archives
+ * directly in rootPath get the series from their filename.
+ */
+export async function collectComicEntries(
+  rootPath: string,
+  options: {
+    maxDepth?: number;
+    maxFiles?: number;
+    fileDeadlineMs?: number;
+    errorCollector?: ScanErrorCollector;
+    onProgress?: (count: number) => void;
+    onLimitReached?: () => void;
+    onFileTimeout?: (filePath: string) => void;
+    onSkip?: (path: string, reason: SkipReason, detail?: string) => void;
+  } = {},
+): Promise<ComicEntry[]> {
+  const {
+    maxDepth = Infinity,
+    maxFiles = Infinity,
+    fileDeadlineMs,
+    errorCollector,
+    onProgress,
+    onLimitReached,
+    onFileTimeout,
+    onSkip,
+  } = options;
+  const comics: ComicEntry[] = [];
+  const root = resolve(rootPath);
+  let limitReached = false;
+
+  async function walk(currentPath: string, depth: number): Promise<void> {
+    if (depth > maxDepth || limitReached) return;
+
+    try {
+      const directory = await peekListing(
+        await opendir(currentPath, {
+          bufferSize: DIRECTORY_READ_BATCH_SIZE,
+        }),
+      );
+
+      const sentinel =
+        directory.hasFile(SKIP_SENTINEL_FILE) === false
+          ? null
+          : await getActiveSkipSentinel(currentPath, fileDeadlineMs);
+      if (sentinel) {
+        await directory.close();
+        const detail = describeSkipSentinel(sentinel);
+        logger.info(`⏸️  Skipping ${currentPath}: ${detail}`);
+        onSkip?.(currentPath, "skip-sentinel", detail);
+        return;
+      }
+
+      for await (const entry of directory.entries) {
+        if (limitReached) break;
+
+        const fullPath = join(currentPath, entry.name);
+        const filterReason = getEntrySkipReason(
+          entry.name,
+          entry.isDirectory(),
+        );
+        if (filterReason) {
+          onSkip?.(fullPath, filterReason);
+          continue;
+        }
+
+        if (entry.isDirectory()) {
+          if (depth + 1 > maxDepth) {
+            onSkip?.(fullPath, "too-deep");
+            continue;
+          }
+          await walk(fullPath, depth + 1);
+          continue;
+        }
+
+        if (!COMIC_EXTENSIONS.includes(extname(entry.name).toLowerCase())) {
+          onSkip?.(fullPath, "not-media");
+          continue;
+        }
+
+        try {
+          const stats = fileDeadlineMs
+            ? await withTimeout(
+                stat(fullPath),
+                fileDeadlineMs,
+                `Stat ${fullPath}`,
+              )
+            : await stat(fullPath);
+
+          const folderName =
+            resolve(currentPath) === root ? undefined : basename(currentPath);
+          comics.push({
+            path: fullPath,
+            name: entry.name,
+            ...parseComicName(entry.name, folderName),
+            fileSize: stats.size,
+            fileModifiedAt: stats.mtime,
+          });
+          onProgress?.(comics.length);
+
+          if (comics.length >= maxFiles) {
+            limitReached = true;
+            onLimitReached?.();
+          }
+        } catch (error) {
+          if (error instanceof OperationTimeoutError) {
+            logger.warn(
+              `⏱️  Skipping ${fullPath}: no response after ${error.timeoutMs / 1000}s`,
+            );
+            onFileTimeout?.(fullPath);
+            onSkip?.(fullPath, "timeout");
+          } else {
+            logger.warn(
+              `Cannot access ${fullPath}: ${error instanceof Error ? error.message : error}`,
+            );
+            onSkip?.(
+              fullPath,
+              "inaccessible",
+              error instanceof Error ? error.message : undefined,
+            );
+          }
+          errorCollector?.record(fullPath, error);
+        }
+      }
+    } catch (error) {
+      logger.error(
+        `Error reading directory ${currentPath}: ${error instanceof Error ? error.message : error}`,
+      );
+      onSkip?.(
+        currentPath,
+        "inaccessible",
+        error instanceof Error ? error.message : undefined,
+      );
+      errorCollector?.record(currentPath, error, true);
+    }
+  }
+
+  await walk(rootPath, 0);
+  return comics;
+}
+
+/**
+ * Save a comic archive as a COMIC media item in a library
+ * An archive saved before (same path) is updated in place. An archive that
+ * cannot be read within fileDeadlineMs is not saved.
+ */
+export async function saveComicToDatabase(
+  comic: ComicEntry,
+  libraryId: string,
+  originalPath?: string,
+  fileDeadlineMs?: number,
+): Promise<{ isNew: boolean; title: string; pages: number | null }> {
+  const filePath = mapContainerToHostPath(comic.path, originalPath);
+  const title = getComicTitle(comic);
+
+  // Only reading the archive races the deadline: a database write cut
+  // short would go on in the background
+  let pages: number | null = null;
+  try {
+    pages = fileDeadlineMs
+      ? await withTimeout(
+          countZipPages(comic.path),
+          fileDeadlineMs,
+          `Read ${comic.path}`,
+        )
+      : await countZipPages(comic.path);
+    if (pages === null) {
+      logger.warn(`⚠️  ${comic.name} is not a readable zip archive`);
+    }
+  } catch (error) {
+    if (error instanceof OperationTimeoutError) {
+      throw error;
+    }
+    logger.warn(
+      `⚠️  Could not read pages of ${comic.name}: ${error instanceof Error ? error.message : error}`,
+    );
+  }
+
+  const comicData = {
+    series: comic.series,
+    issue: comic.issue ?? null,
+    volume: comic.volume ?? null,
+    pages,
+    fileSize: BigInt(comic.fileSize),
+    fileModifiedAt: comic.fileModifiedAt,
+  };
+
+  const existing = await prisma.comic.findUnique({
+    where: { filePath },
+    select: { id: true, mediaId: true },
+  });
+
+  if (existing) {
+    await prisma.comic.update({ where: { id: existing.id }, data: comicData });
+    await prisma.media.update({
+      where: { id: existing.mediaId },
+      data: { title },
+    });
+    await linkMediaToLibrary(existing.mediaId, libraryId);
+    return { isNew: false, title, pages };
+  }
+
+  const media = await prisma.media.create({
+    data: {
+      title,
+      type: MediaType.COMIC,
+      comic: { create: { ...comicData, filePath } },
+    },
+  });
+  await linkMediaToLibrary(media.id, libraryId);
+  await recordScanAddition(libraryId, media.id);
+
+  logger.debug(`📚 Saved comic: ${title} (${pages ?? "?"} pages)`);
+  return { isNew: true, title, pages };
+}
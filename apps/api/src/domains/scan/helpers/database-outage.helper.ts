@@ -0,0 +1,179 @@
+/**
+ * Database outages during a scan
+ * When the database restarts mid-scan, saves fail until it is back. Instead
+ * of dropping every file found in the meantime, the scan pauses on the file
+ * it was saving, waits for the database, and saves it again. Files are
+ * saved one at a time, so nothing else piles up while it waits.
+ */
+
+import { Prisma } from "@prisma/client";
+import prisma from "@/lib/database/prisma";
+import { ScanJobStatus } from "@/lib/database";
+import { logger } from "@/lib/utils";
+
+/**
+ * Default time a scan waits for the database to come back, in seconds
+ */
+export const DEFAULT_DB_OUTAGE_MAX_SECONDS = 300;
+
+// Ping backoff while waiting: 1s, 2s, 4s, ... up to 30s
+const DB_PING_INITIAL_DELAY_MS = 1000;
+const DB_PING_MAX_DELAY_MS = 30000;
+
+// Outages waited out for a single save before it counts as failed, so a
+// save that fails for another reason after every reconnect cannot loop
+const MAX_OUTAGES_PER_SAVE = 3;
+
+// Prisma error codes for an unreachable database or a lost connection
+const CONNECTION_ERROR_CODES = new Set([
+  "P1001", // Can't reach database server
+  "P1002", // Database server timed out
+  "P1008", // Operations timed out
+  "P1017", // Server has closed the connection
+  "P2024", // Timed out fetching a connection from the pool
+]);
+
+/**
+ * Error thrown when the database stays unreachable longer than
+ * SCANNER_DB_OUTAGE_MAX_SECONDS; the scan stops instead of skipping files
+ */
+export class DatabaseOutageError extends Error {
+  constructor(public outageMs: number) {
+    super(
+      `Database unreachable for ${Math.round(outageMs / 1000)}s (SCANNER_DB_OUTAGE_MAX_SECONDS), scan stopped. Resume the scan once the database is back.`,
+    );
+    this.name = "DatabaseOutageError";
+  }
+}
+
+/**
+ * Get how long a scan waits for a lost database, in milliseconds
+ * Read from SCANNER_DB_OUTAGE_MAX_SECONDS, falling back to the default
+ */
+export function getDbOutageMaxMs(
+  value: string | undefined = process.env.SCANNER_DB_OUTAGE_MAX_SECONDS,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_DB_OUTAGE_MAX_SECONDS * 1000;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isFinite(parsed) || parsed <= 0) {
+    logger.warn(
+      `Invalid SCANNER_DB_OUTAGE_MAX_SECONDS "${value}", using default of ${DEFAULT_DB_OUTAGE_MAX_SECONDS}`,
+    );
+    return DEFAULT_DB_OUTAGE_MAX_SECONDS * 1000;
+  }
+
+  return parsed * 1000;
+}
+
+/**
+ * Whether an error means the database connection is gone, rather than the
+ * query itself failing
+ */
+export function isDatabaseConnectionError(error: unknown): boolean {
+  if (error instanceof Prisma.PrismaClientInitializationError) {
+    return true;
+  }
+  if (error instanceof Prisma.PrismaClientKnownRequestError) {
+    return CONNECTION_ERROR_CODES.has(error.code);
+  }
+  // Dropped connections sometimes surface without a code
+  if (error instanceof Prisma.PrismaClientUnknownRequestError) {
+    return /closed the connection|connection (?:reset|refused|terminated)/i.test(
+      error.message,
+    );
+  }
+  return false;
+}
+
+/**
+ * Ping the database with backoff until it answers
+ * Throws DatabaseOutageError once it has been down for maxOutageMs
+ */
+export async function waitForDatabase(
+  maxOutageMs: number = getDbOutageMaxMs(),
+): Promise<void> {
+  const startedAt = Date.now();
+  let delay = DB_PING_INITIAL_DELAY_MS;
+
+  while (true) {
+    const elapsed = Date.now() - startedAt;
+    if (elapsed >= maxOutageMs) {
+      throw new DatabaseOutageError(elapsed);
+    }
+
+    await new Promise((resolve) =>
+      setTimeout(resolve, Math.min(delay, maxOutageMs - elapsed)),
+    );
+    delay = Math.min(delay * 2, DB_PING_MAX_DELAY_MS);
+
+    try {
+      await prisma.$queryRaw`SELECT 1`;
+      return;
+    } catch (error) {
+      if (!isDatabaseConnectionError(error)) {
+        throw error;
+      }
+    }
+  }
+}
+
+/**
+ * Run a save, waiting out database outages and saving again once the
+ * database is back
+ * Other errors, and a save still failing after several outages, are thrown
+ * as they are
+ */
+export async function saveThroughOutages<T>(
+  save: () => Promise<T>,
+  itemName: string,
+  maxOutageMs: number = getDbOutageMaxMs(),
+): Promise<T> {
+  for (let outages = 0; ; outages++) {
+    try {
+      return await save();
+    } catch (error) {
+      if (
+        !isDatabaseConnectionError(error) ||
+        outages >= MAX_OUTAGES_PER_SAVE
+      ) {
+        throw error;
+      }
+
+      logger.warn(
+        `⏸️  Database connection lost while saving ${itemName}, pausing the scan for up to ${Math.round(maxOutageMs / 1000)}s`,
+      );
+      await waitForDatabase(maxOutageMs);
+      logger.info(`▶️  Database is back, resuming with ${itemName}`);
+    }
+  }
+}
+
+/**
+ * Mark a batch scan job as stopped by a database outage
+ * Folders it had not finished stay pending, so resuming the job picks them
+ * up. The database may still be down, in which case the job is left for the
+ * stale job cleanup.
+ */
+export async function markScanJobDatabaseOutage(
+  scanJobId: string,
+  error: DatabaseOutageError,
+): Promise<void> {
+  logger.error(`❌ Scan job ${scanJobId}: ${error.message}`);
+
+  try {
+    await prisma.scanJob.update({
+      where: { id: scanJobId },
+      data: {
+        status: ScanJobStatus.FAILED,
+        errorMessage: error.message,
+      },
+    });
+  } catch (updateError) {
+    logger.warn(
+      `Could not mark scan job ${scanJobId} as failed: ${updateError instanceof Error ? updateError.message : updateError}`,
+    );
+  }
+}
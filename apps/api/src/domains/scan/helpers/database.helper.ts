@@ -4,10 +4,19 @@
  */
 
 import prisma from "@/lib/database/prisma";
-import { logger, mapContainerToHostPath } from "@/lib/utils";
+import { logger, mapContainerToHostPath, sanitizeTitle } from "@/lib/utils";
+import type { ExtractedIds } from "@/lib/utils";
 import { MediaType } from "@/lib/database";
 import { assignGenresToMedia } from "../../../core/services/genre.service";
 import { getTmdbImageUrl } from "./tmdb-image.helper";
+import { getScannerVersionData } from "./scanner-version.helper";
+import { sanitizeDuration } from "./duration-validator.helper";
+import {
+  releaseEpisodeFile,
+  releaseReclassifiedFile,
+} from "./media-type-change.helper";
+import { getParseHash } from "./parse-hash.helper";
+import { findBetterStoredCopy } from "./best-copy.helper";
 import type {
   TmdbEpisodeMetadata,
   TmdbSeasonMetadata,
@@ -29,6 +38,39 @@ type ExtendedMetadata = TmdbMetadata & {
   };
 };
 
+/**
+ * Release attributes parsed from a filename, in the shape stored on Movie
+ * and Episode rows
+ */
+export function getReleaseAttributes(extractedIds: ExtractedIds) {
+  return {
+    is3D: extractedIds.is3D ?? false,
+    isRemux: extractedIds.isRemux ?? false,
+    sourceType: extractedIds.sourceType ?? null,
+    resolution: extractedIds.resolution ?? null,
+    language: extractedIds.language ?? null,
+    isProper: extractedIds.isProper ?? false,
+    isRepack: extractedIds.isRepack ?? false,
+    isInternal: extractedIds.isInternal ?? false,
+  };
+}
+
+/**
+ * Release attributes, title source and parse hash of a file, plus the
+ * scanner version when stamping is enabled
+ */
+function getSourceAttributes(
+  mediaEntry: MediaEntry,
+  mediaType: "movie" | "tv",
+) {
+  return {
+    ...getReleaseAttributes(mediaEntry.extractedIds),
+    titleSource: mediaEntry.extractedIds.titleSource ?? null,
+    parseHash: getParseHash(mediaEntry.extractedIds, mediaType),
+    ...getScannerVersionData(),
+  };
+}
+
 /**
  * Create or update media record in database
  */
@@ -61,6 +103,9 @@ export async function upsertMedia(
     `[${metadata.title || metadata.name}] backdrop_path: ${metadata.backdrop_path} → backdropUrl: ${backdropUrl}`,
   );
 
+  const mediaTitle =
+    sanitizeTitle(metadata.title || metadata.name || "").title || "Unknown";
+
   let media;
 
   if (existingExternalId) {
@@ -68,7 +113,7 @@ export async function upsertMedia(
     media = await prisma.media.update({
       where: { id: existingExternalId.mediaId },
       data: {
-        title: metadata.title || metadata.name || "Unknown",
+        title: mediaTitle,
         description: metadata.overview,
         posterUrl,
         backdropUrl,
@@ -84,7 +129,7 @@ export async function upsertMedia(
     // Create new media
     media = await prisma.media.create({
       data: {
-        title: metadata.title || metadata.name || "Unknown",
+        title: mediaTitle,
         type: mediaType === "tv" ? MediaType.TV_SHOW : MediaType.MOVIE,
         description: metadata.overview,
         posterUrl,
@@ -188,27 +233,45 @@ export async function saveGenres(
 
 /**
  * Save movie record to database
+ * The scan job is stamped on new rows only, so it records where a file came from
  */
 export async function saveMovie(
   mediaId: string,
   mediaEntry: MediaEntry,
   extendedMetadata: ExtendedMetadata,
   filePathForStorage: string,
+  scanJobId?: string,
 ) {
+  const sourceAttributes = getSourceAttributes(mediaEntry, "movie");
+  const duration = sanitizeDuration(
+    extendedMetadata.runtime,
+    mediaEntry.path,
+  );
+
+  const existingMovie = await prisma.movie.findUnique({
+    where: { mediaId: mediaId },
+    select: { id: true },
+  });
+
   await prisma.movie.upsert({
     where: { mediaId: mediaId },
     update: {
-      duration: extendedMetadata.runtime,
+      // Metadata reused from the database has no runtime; keep the stored one
+      ...(extendedMetadata.runtime !== undefined ? { duration } : {}),
       filePath: filePathForStorage,
       fileSize: BigInt(mediaEntry.size),
       fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      missingSince: null, // Found by this scan
     },
     create: {
       mediaId: mediaId,
-      duration: extendedMetadata.runtime,
+      duration,
       filePath: filePathForStorage,
       fileSize: BigInt(mediaEntry.size),
       fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      scanJobId,
     },
   });
 
@@ -244,16 +307,179 @@ export async function saveMovie(
       },
     });
   }
+
+  return { isNew: !existingMovie };
+}
+
+/**
+ * Check whether a library already has the main file of a movie
+ */
+async function hasLibraryMovie(
+  tmdbId: string,
+  libraryId: string,
+): Promise<boolean> {
+  const movie = await prisma.movie.findFirst({
+    where: {
+      filePath: { not: null },
+      media: {
+        externalIds: { some: { source: "TMDB", externalId: tmdbId } },
+        libraries: { some: { libraryId } },
+      },
+    },
+    select: { id: true },
+  });
+
+  return movie !== null;
+}
+
+/**
+ * Save a movie extra (trailer, featurette, ...) against its movie
+ * The main file may not be saved yet, so the movie row is created without
+ * a file if needed and gets its file when the main video is saved
+ */
+async function saveMovieExtra(
+  mediaId: string,
+  mediaEntry: MediaEntry,
+  filePathForStorage: string,
+  scanJobId?: string,
+) {
+  const movie = await prisma.movie.upsert({
+    where: { mediaId: mediaId },
+    update: {},
+    create: { mediaId: mediaId, scanJobId },
+    select: { id: true },
+  });
+
+  const extraType = mediaEntry.extraType || "other";
+  const title = sanitizeTitle(mediaEntry.extraTitle || mediaEntry.name).title;
+
+  await prisma.movieExtra.upsert({
+    where: { filePath: filePathForStorage },
+    update: {
+      movieId: movie.id,
+      extraType,
+      title,
+      fileSize: BigInt(mediaEntry.size),
+      fileModifiedAt: mediaEntry.modified,
+      missingSince: null,
+    },
+    create: {
+      movieId: movie.id,
+      extraType,
+      title,
+      filePath: filePathForStorage,
+      fileSize: BigInt(mediaEntry.size),
+      fileModifiedAt: mediaEntry.modified,
+    },
+  });
+
+  return { extraType, title };
+}
+
+/**
+ * Save a TV extra as a season 0 episode
+ * Extras have no episode number of their own, so each file keeps the number
+ * it was first given and new files take the next free one
+ */
+async function saveTVExtra(
+  tvShowId: string,
+  mediaEntry: MediaEntry,
+  filePathForStorage: string,
+  scanJobId?: string,
+) {
+  const season = await prisma.season.upsert({
+    where: {
+      tvShowId_number: {
+        tvShowId,
+        number: 0,
+      },
+    },
+    update: {},
+    create: {
+      tvShowId,
+      number: 0,
+    },
+  });
+
+  let existingEpisode = await prisma.episode.findUnique({
+    where: { filePath: filePathForStorage },
+    select: { id: true, seasonId: true, number: true },
+  });
+
+  // A regular episode, or an extra of another show, gives up the file
+  // first, since a file belongs to one episode only
+  if (existingEpisode && existingEpisode.seasonId !== season.id) {
+    await releaseEpisodeFile(filePathForStorage, "is now saved as an extra");
+    existingEpisode = null;
+  }
+
+  let episodeNumber: number;
+  if (existingEpisode) {
+    episodeNumber = existingEpisode.number;
+  } else {
+    const lastEpisode = await prisma.episode.findFirst({
+      where: { seasonId: season.id },
+      orderBy: { number: "desc" },
+      select: { number: true },
+    });
+    episodeNumber = (lastEpisode?.number ?? 0) + 1;
+  }
+
+  const episodeTitle = sanitizeTitle(
+    mediaEntry.extraTitle || mediaEntry.name,
+  ).title;
+  const sourceAttributes = getSourceAttributes(mediaEntry, "tv");
+
+  const savedEpisode = await prisma.episode.upsert({
+    where: {
+      seasonId_number: {
+        seasonId: season.id,
+        number: episodeNumber,
+      },
+    },
+    update: {
+      title: episodeTitle,
+      fileTitle: episodeTitle,
+      filePath: filePathForStorage,
+      fileSize: BigInt(mediaEntry.size),
+      fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      missingSince: null,
+    },
+    create: {
+      seasonId: season.id,
+      number: episodeNumber,
+      title: episodeTitle,
+      fileTitle: episodeTitle,
+      filePath: filePathForStorage,
+      fileSize: BigInt(mediaEntry.size),
+      fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      scanJobId,
+    },
+  });
+
+  return {
+    seasonNumber: 0,
+    episodeNumber,
+    episodeTitle,
+    fileTitleExtracted: undefined,
+    episodeId: savedEpisode.id,
+    isNew: !existingEpisode,
+    isExtra: true,
+  };
 }
 
 /**
  * Save TV show and episode data to database
+ * The scan job is stamped on new episodes only, like saveMovie
  */
 export async function saveTVShow(
   mediaId: string,
   mediaEntry: MediaEntry,
   episodeCache: Map<string, TmdbSeasonMetadata>,
   filePathForStorage: string,
+  scanJobId?: string,
 ) {
   // Create TV show record
   const tvShow = await prisma.tVShow.upsert({
@@ -264,6 +490,10 @@ export async function saveTVShow(
     },
   });
 
+  if (mediaEntry.isExtra) {
+    return saveTVExtra(tvShow.id, mediaEntry, filePathForStorage, scanJobId);
+  }
+
   // Handle seasons and episodes if we have that info
   if (!mediaEntry.extractedIds.season) {
     return;
@@ -294,6 +524,15 @@ export async function saveTVShow(
   const episodeNumber = mediaEntry.extractedIds.episode;
   const fileTitleExtracted = mediaEntry.extractedIds.title;
 
+  // Episodes belong to a single season, so a file spanning two seasons is
+  // stored against its first episode only
+  const rangeEnd = mediaEntry.extractedIds.rangeEnd;
+  if (rangeEnd) {
+    logger.warn(
+      `Cross-season range S${seasonNumber}E${episodeNumber}-S${rangeEnd.season}E${rangeEnd.episode} detected in ${mediaEntry.name} - linking to S${seasonNumber}E${episodeNumber} only`,
+    );
+  }
+
   // Extract episode-specific metadata from cached season data
   let episodeTitle = `Episode ${episodeNumber}`;
   let episodeDuration: number | null = null;
@@ -311,8 +550,12 @@ export async function saveTVShow(
           (ep: TmdbEpisodeMetadata) => ep.episode_number === episodeNumber,
         );
         if (episode) {
-          episodeTitle = episode.name || episodeTitle;
-          episodeDuration = episode.runtime || null;
+          episodeTitle =
+            sanitizeTitle(episode.name || "").title || episodeTitle;
+          episodeDuration = sanitizeDuration(
+            episode.runtime,
+            mediaEntry.path,
+          );
           episodeAirDate = episode.air_date ? new Date(episode.air_date) : null;
           episodeStillPath = getTmdbImageUrl(episode.still_path);
           logger.debug(
@@ -323,7 +566,19 @@ export async function saveTVShow(
     }
   }
 
-  await prisma.episode.upsert({
+  const sourceAttributes = getSourceAttributes(mediaEntry, "tv");
+
+  const existingEpisode = await prisma.episode.findUnique({
+    where: {
+      seasonId_number: {
+        seasonId: season.id,
+        number: episodeNumber,
+      },
+    },
+    select: { id: true },
+  });
+
+  const savedEpisode = await prisma.episode.upsert({
     where: {
       seasonId_number: {
         seasonId: season.id,
@@ -339,6 +594,8 @@ export async function saveTVShow(
       filePath: filePathForStorage,
       fileSize: BigInt(mediaEntry.size),
       fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      missingSince: null,
     },
     create: {
       seasonId: season.id,
@@ -351,10 +608,20 @@ export async function saveTVShow(
       filePath: filePathForStorage,
       fileSize: BigInt(mediaEntry.size),
       fileModifiedAt: mediaEntry.modified,
+      ...sourceAttributes,
+      scanJobId,
     },
   });
 
-  return { seasonNumber, episodeNumber, episodeTitle, fileTitleExtracted };
+  return {
+    seasonNumber,
+    episodeNumber,
+    episodeTitle,
+    fileTitleExtracted,
+    episodeId: savedEpisode.id,
+    isNew: !existingEpisode,
+    isExtra: false,
+  };
 }
 
 /**
@@ -376,9 +643,61 @@ export async function linkMediaToLibrary(mediaId: string, libraryId: string) {
   });
 }
 
+/**
+ * Record a file that was added to a library for the first time
+ * Feeds the per-library "recently added" list
+ */
+export async function recordScanAddition(
+  libraryId: string,
+  mediaId: string,
+  scanJobId?: string,
+  episodeId?: string,
+) {
+  await prisma.scanAddition.create({
+    data: {
+      libraryId,
+      mediaId,
+      scanJobId: scanJobId ?? null,
+      episodeId: episodeId ?? null,
+    },
+  });
+}
+
+/**
+ * Summarize the files a scan job added for the first time
+ * Returns the total count and up to `limit` titles, newest first
+ */
+export async function getScanAdditionSummary(scanJobId: string, limit: number) {
+  const [newItemsCount, additions] = await Promise.all([
+    prisma.scanAddition.count({ where: { scanJobId } }),
+    prisma.scanAddition.findMany({
+      where: { scanJobId },
+      orderBy: { addedAt: "desc" },
+      take: limit,
+      include: {
+        media: { select: { title: true } },
+        episode: {
+          select: { number: true, season: { select: { number: true } } },
+        },
+      },
+    }),
+  ]);
+
+  const newItemTitles = additions.map((addition) =>
+    addition.episode
+      ? `${addition.media.title} - S${addition.episode.season.number}E${addition.episode.number}`
+      : addition.media.title,
+  );
+
+  return { newItemsCount, newItemTitles };
+}
+
 /**
  * Main function to save media and file data to database
  * Orchestrates all database operations for a single media entry
+ * Returns whether the file was new to the library and whether it was saved
+ * as an extra, or null if it was skipped (including trailer folder files
+ * whose movie is not in the library)
  */
 export async function saveMediaToDatabase(
   mediaEntry: MediaEntry,
@@ -387,23 +706,82 @@ export async function saveMediaToDatabase(
   episodeCache: Map<string, TmdbSeasonMetadata>,
   libraryId: string,
   originalPath?: string,
-): Promise<void> {
+  scanJobId?: string,
+): Promise<{
+  isNew: boolean;
+  isExtra: boolean;
+  title: string;
+  // Set when the file was skipped for a better copy of the same movie
+  inferiorTo?: string;
+} | null> {
   try {
     // Only process if we have metadata and a TMDB ID
     if (!mediaEntry.metadata || !mediaEntry.extractedIds.tmdbId) {
-      logger.debug(`Skipping ${mediaEntry.path} - no metadata or TMDB ID`);
-      return;
+      if (mediaEntry.fromTrailerFolder) {
+        logger.warn(
+          `🎞️  Skipping trailer ${mediaEntry.path}: no movie found for "${mediaEntry.extractedIds.title}"`,
+        );
+      } else {
+        logger.debug(`Skipping ${mediaEntry.path} - no metadata or TMDB ID`);
+      }
+      return null;
     }
 
     const metadata = mediaEntry.metadata;
     const tmdbId = mediaEntry.extractedIds.tmdbId.toString();
 
+    // A central trailer folder holds trailers for movies that may not be in
+    // the library; those are skipped rather than added as empty movies
+    if (
+      mediaEntry.fromTrailerFolder &&
+      !(await hasLibraryMovie(tmdbId, libraryId))
+    ) {
+      const year = mediaEntry.extractedIds.year;
+      logger.warn(
+        `🎞️  Skipping trailer ${mediaEntry.path}: "${metadata.title || mediaEntry.extractedIds.title}"${year ? ` (${year})` : ""} is not in this library`,
+      );
+      return null;
+    }
+
     // Map container path back to host path for database storage
     const filePathForStorage = mapContainerToHostPath(
       mediaEntry.path,
       originalPath,
     );
 
+    // A file saved before as the other media type gives it up first, so it
+    // is not listed as both a movie and an episode
+    const savesMainFile =
+      mediaType === "movie"
+        ? !mediaEntry.isExtra
+        : mediaEntry.isExtra ||
+          Boolean(
+            mediaEntry.extractedIds.season && mediaEntry.extractedIds.episode,
+          );
+    if (savesMainFile) {
+      await releaseReclassifiedFile(filePathForStorage, mediaType);
+    }
+
+    // With SCANNER_KEEP_BEST_COPY, a worse copy of a movie already saved
+    // from another file is left out
+    if (mediaType === "movie" && savesMainFile) {
+      const betterCopy = await findBetterStoredCopy(
+        tmdbId,
+        filePathForStorage,
+        {
+          size: mediaEntry.size,
+          resolution: mediaEntry.extractedIds.resolution,
+        },
+      );
+      if (betterCopy) {
+        const title = metadata.title || mediaEntry.extractedIds.title || "";
+        logger.info(
+          `🗂️  Skipping ${mediaEntry.path}: ${betterCopy} is a better copy of "${title}"`,
+        );
+        return { isNew: false, isExtra: false, title, inferiorTo: betterCopy };
+      }
+    }
+
     const extendedMetadata = metadata as ExtendedMetadata;
 
     // 1. Create or update media record
@@ -419,13 +797,31 @@ export async function saveMediaToDatabase(
     await saveGenres(media.id, extendedMetadata.genres, media.title);
 
     // 4. Save type-specific records
-    if (mediaType === "movie") {
-      await saveMovie(
+    let isNew = false;
+    let isExtra = false;
+    let addedTitle = media.title;
+    let episodeId: string | undefined;
+
+    if (mediaType === "movie" && mediaEntry.isExtra) {
+      // Extras are not listed as additions; they belong to their movie
+      const extra = await saveMovieExtra(
         media.id,
         mediaEntry,
-        extendedMetadata,
         filePathForStorage,
+        scanJobId,
+      );
+      isExtra = true;
+      logger.info(
+        `✓ Saved ${extra.extraType} for ${media.title}: ${extra.title}`,
       );
+    } else if (mediaType === "movie") {
+      ({ isNew } = await saveMovie(
+        media.id,
+        mediaEntry,
+        extendedMetadata,
+        filePathForStorage,
+        scanJobId,
+      ));
       logger.info(`✓ Saved ${media.title}`);
     } else {
       const result = await saveTVShow(
@@ -433,6 +829,7 @@ export async function saveMediaToDatabase(
         mediaEntry,
         episodeCache,
         filePathForStorage,
+        scanJobId,
       );
       if (result) {
         const {
@@ -441,6 +838,10 @@ export async function saveMediaToDatabase(
           episodeTitle,
           fileTitleExtracted,
         } = result;
+        isNew = result.isNew;
+        isExtra = result.isExtra;
+        episodeId = result.episodeId;
+        addedTitle = `${media.title} - S${seasonNumber}E${episodeNumber}`;
         logger.info(
           `✓ Saved ${media.title} - S${seasonNumber}E${episodeNumber}: ${episodeTitle}${fileTitleExtracted ? ` (file: ${fileTitleExtracted})` : ""}`,
         );
@@ -457,6 +858,13 @@ export async function saveMediaToDatabase(
 
     // 5. Link media to library
     await linkMediaToLibrary(media.id, libraryId);
+
+    // 6. Remember files seen for the first time
+    if (isNew) {
+      await recordScanAddition(libraryId, media.id, scanJobId, episodeId);
+    }
+
+    return { isNew, isExtra, title: addedTitle };
   } catch (error) {
     logger.error(
       `Error saving media to database for ${mediaEntry.path}: ${error instanceof Error ? error.message : error}`,
@@ -0,0 +1,176 @@
+/**
+ * Disc structures
+ * A ripped Blu-ray (BDMV) or DVD (VIDEO_TS) folder holds the movie as one
+ * large stream next to dozens of menus, trailers and fragments with the same
+ * extensions. Movie scans take only the main title of a disc folder; TV
+ * scans leave disc folders out, since a disc holds several episodes.
+ * Standalone .m2ts/.ts recordings outside disc folders are scanned as usual.
+ */
+
+import { basename, extname, join } from "path";
+import { scanFs } from "./scan-fs.helper";
+import { withTimeout } from "./timeout-helper";
+
+export type DiscStructureType = "bdmv" | "video_ts";
+
+// DVD title set parts: VTS_01_1.VOB, VTS_01_2.VOB, ... (VTS_01_0 is the menu)
+const DVD_TITLE_PART_PATTERN = /^VTS_(\d{2})_[1-9]\.VOB$/i;
+
+/**
+ * Whether disc folders are detected
+ * Read from SCANNER_DISC_STRUCTURES; on unless set to "false". When off,
+ * disc folders are walked like any other folder and .m2ts/.ts are not
+ * default video extensions.
+ */
+export function isDiscStructureDetectionEnabled(
+  value: string | undefined = process.env.SCANNER_DISC_STRUCTURES,
+): boolean {
+  return value?.trim().toLowerCase() !== "false";
+}
+
+/**
+ * Get the disc structure a folder name stands for, if any
+ */
+export function getDiscStructureType(name: string): DiscStructureType | null {
+  const upperName = name.toUpperCase();
+  if (upperName === "BDMV") return "bdmv";
+  if (upperName === "VIDEO_TS") return "video_ts";
+  return null;
+}
+
+/**
+ * Get the name a disc title is parsed from: the folder the disc is in,
+ * with the extension of the title ("Alien (1979) Remux/BDMV/STREAM/
+ * 00800.m2ts" is parsed as "Alien (1979) Remux.m2ts")
+ * Returns null for a path that is not inside a disc folder.
+ */
+export function getDiscTitleName(titlePath: string): string | null {
+  const parts = titlePath.split(/[\\/]/);
+  for (let i = parts.length - 2; i > 0; i--) {
+    if (getDiscStructureType(parts[i]!)) {
+      return `${parts[i - 1]}${extname(titlePath).toLowerCase()}`;
+    }
+  }
+  return null;
+}
+
+export interface DiscMainTitle {
+  path: string;
+  size: number; // The whole title, all parts of a DVD title set
+  modified: Date;
+}
+
+// Files directly in a folder, with their sizes
+async function listFiles(
+  folderPath: string,
+  fileDeadlineMs?: number,
+): Promise<Array<{ name: string; path: string; size: number; mtime: Date }>> {
+  const files: Array<{
+    name: string;
+    path: string;
+    size: number;
+    mtime: Date;
+  }> = [];
+  const directory = await scanFs.opendir(folderPath);
+
+  for await (const entry of directory) {
+    if (!entry.isFile()) continue;
+
+    const filePath = join(folderPath, entry.name);
+    const stats = fileDeadlineMs
+      ? await withTimeout(
+          scanFs.stat(filePath),
+          fileDeadlineMs,
+          `Stat ${filePath}`,
+        )
+      : await scanFs.stat(filePath);
+    files.push({
+      name: entry.name,
+      path: filePath,
+      size: stats.size,
+      mtime: stats.mtime,
+    });
+  }
+
+  return files;
+}
+
+// Subfolder of a folder matched without regard to case (BDMV/STREAM)
+async function findSubfolder(
+  folderPath: string,
+  name: string,
+): Promise<string | null> {
+  const directory = await scanFs.opendir(folderPath);
+  for await (const entry of directory) {
+    if (entry.isDirectory() && entry.name.toUpperCase() === name) {
+      return join(folderPath, entry.name);
+    }
+  }
+  return null;
+}
+
+/**
+ * Find the main title of a disc folder: the largest stream of a Blu-ray
+ * (BDMV/STREAM/*.m2ts), or the largest title set of a DVD (VTS_xx_1.VOB
+ * onward). Blu-ray streams count only with one of the scan's extensions;
+ * DVD title parts are known by name, since .vob is not a video extension
+ * scanned elsewhere. Returns null when there is none.
+ */
+export async function findDiscMainTitle(
+  discPath: string,
+  type: DiscStructureType,
+  fileExtensions: string[],
+  fileDeadlineMs?: number,
+): Promise<DiscMainTitle | null> {
+  const extensions = fileExtensions.map((ext) => ext.toLowerCase());
+  const hasExtension = (name: string) =>
+    extensions.includes(extname(name).toLowerCase());
+
+  if (type === "bdmv") {
+    const streamPath = await findSubfolder(discPath, "STREAM");
+    if (!streamPath) return null;
+
+    let largest: DiscMainTitle | null = null;
+    for (const file of await listFiles(streamPath, fileDeadlineMs)) {
+      if (hasExtension(file.name) && (!largest || file.size > largest.size)) {
+        largest = { path: file.path, size: file.size, modified: file.mtime };
+      }
+    }
+    return largest;
+  }
+
+  // A DVD title is split into 1GB parts; the title set with the most data
+  // is the movie
+  const titleSets = new Map<string, DiscMainTitle>();
+  for (const file of await listFiles(discPath, fileDeadlineMs)) {
+    const match = file.name.match(DVD_TITLE_PART_PATTERN);
+    if (!match) continue;
+
+    const titleSet = titleSets.get(match[1]!);
+    if (!titleSet) {
+      titleSets.set(match[1]!, {
+        path: file.path,
+        size: file.size,
+        modified: file.mtime,
+      });
+      continue;
+    }
+
+    titleSet.size += file.size;
+    // The first part names the title
+    if (file.name.toUpperCase() < basename(titleSet.path).toUpperCase()) {
+      titleSet.path = file.path;
+    }
+    if (file.mtime > titleSet.modified) {
+      titleSet.modified = file.mtime;
+    }
+  }
+
+  let largest: DiscMainTitle | null = null;
+  for (const titleSet of titleSets.values()) {
+    if (!largest || titleSet.size > largest.size) {
+      largest = titleSet;
+    }
+  }
+  return largest;
+}
@@ -0,0 +1,64 @@
+/**
+ * Duration sanity checks
+ * Keeps absurd runtimes (zero, negative or days long) out of the library
+ */
+
+import { logger } from "@/lib/utils";
+
+/**
+ * Default longest duration accepted, in minutes (24 hours)
+ */
+export const DEFAULT_MAX_DURATION_MINUTES = 24 * 60;
+
+/**
+ * Get the longest duration, in minutes, that is saved for a movie or episode
+ * Read from SCANNER_MAX_DURATION_MINUTES, falling back to the default
+ */
+export function getMaxDurationMinutes(
+  value: string | undefined = process.env.SCANNER_MAX_DURATION_MINUTES,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_MAX_DURATION_MINUTES;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isFinite(parsed) || parsed < 1) {
+    logger.warn(
+      `Invalid SCANNER_MAX_DURATION_MINUTES "${value}", using default of ${DEFAULT_MAX_DURATION_MINUTES}`,
+    );
+    return DEFAULT_MAX_DURATION_MINUTES;
+  }
+
+  return parsed;
+}
+
+/**
+ * Return a duration that is safe to save, or null if it is missing or
+ * outside the accepted range of 1 minute to SCANNER_MAX_DURATION_MINUTES.
+ * The range is checked after rounding to whole minutes, so a fraction of a
+ * minute is never saved as 0. Rejected durations are logged with the file
+ * so suspect files can be found.
+ *
+ * @param minutes - Duration in minutes (TMDB runtimes are whole minutes)
+ * @param filePath - File the duration belongs to, for the log message
+ */
+export function sanitizeDuration(
+  minutes: number | null | undefined,
+  filePath: string,
+): number | null {
+  // TMDB reports unknown runtimes as 0
+  if (minutes === null || minutes === undefined || minutes === 0) {
+    return null;
+  }
+
+  const maxMinutes = getMaxDurationMinutes();
+  const rounded = Math.round(minutes);
+  if (!Number.isFinite(rounded) || rounded < 1 || rounded > maxMinutes) {
+    logger.warn(
+      `⚠️  Suspect duration ${minutes} min for ${filePath} (allowed: 1-${maxMinutes}), saving it without a duration`,
+    );
+    return null;
+  }
+
+  return rounded;
+}
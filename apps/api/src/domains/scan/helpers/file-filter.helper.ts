@@ -3,6 +3,25 @@
  * Determines which files and directories to skip during scanning
  */
 
+import { isDiscStructureDetectionEnabled } from "./disc-structure.helper";
+import type { SkipReason } from "./scan-skips.helper";
+
+/**
+ * Bonus-content directories inside a title's folder
+ * Skipped by default; TV scans can ingest them as extras (season 0)
+ */
+const EXTRAS_DIRECTORIES = [
+  "Extras",
+  "Behind The Scenes",
+  "Deleted Scenes",
+  "Featurettes",
+  "Interviews",
+  "Scenes",
+  "Shorts",
+  "Trailers",
+  "Other",
+];
+
 /**
  * List of directory patterns to skip during scanning
  */
@@ -29,83 +48,113 @@ const SKIP_DIRECTORIES = [
   "@eaDir", // Synology
   "#recycle",
   ".@__thumb",
-  "Extras",
-  "Behind The Scenes",
-  "Deleted Scenes",
-  "Featurettes",
-  "Interviews",
-  "Scenes",
-  "Shorts",
-  "Trailers",
-  "Other",
   ".AppleDouble",
 ];
 
 /**
- * File patterns to skip during scanning
+ * File patterns to skip during scanning, with the reason each is skipped
  */
-const SKIP_FILE_PATTERNS = [
+const SKIP_FILE_PATTERNS: Array<[RegExp, SkipReason]> = [
   // System files
-  /^\./, // Hidden files
-  /^~\$/, // Temp files
-  /^Thumbs\.db$/i,
-  /^\.DS_Store$/,
-  /^desktop\.ini$/i,
+  [/^\./, "hidden"], // Hidden files
+  [/^~\$/, "system"], // Temp files
+  [/^Thumbs\.db$/i, "system"],
+  [/^\.DS_Store$/, "hidden"],
+  [/^desktop\.ini$/i, "system"],
 
   // Media-specific
-  /\.(nfo|txt|srt|sub|idx|ass|ssa|vtt)$/i, // Metadata/subtitles
-  /\.(jpg|jpeg|png|gif|bmp)$/i, // Images
-  /^sample\./i, // Sample files
-  /-sample\./i,
-  /\bsample\b/i,
+  [/\.(nfo|txt|srt|sub|idx|ass|ssa|vtt)$/i, "sidecar"], // Metadata/subtitles
+  [/\.(jpg|jpeg|png|gif|bmp)$/i, "sidecar"], // Images
+  [/^sample\./i, "sample"], // Sample files
+  [/-sample\./i, "sample"],
+  [/\bsample\b/i, "sample"],
 ];
 
 /**
- * Checks if a directory or file should be skipped during scanning
+ * Checks if a directory holds bonus content (Extras, Featurettes, etc.)
+ */
+export function isExtrasDirectory(name: string): boolean {
+  const lowerName = name.toLowerCase();
+  return EXTRAS_DIRECTORIES.some((dir) => dir.toLowerCase() === lowerName);
+}
+
+/**
+ * Get why a directory or file is skipped during scanning
  *
  * @param name - File or directory name
  * @param isDirectory - Whether this is a directory
- * @returns True if should skip, false otherwise
+ * @param options - includeExtras keeps bonus-content directories
+ * @returns The skip reason, or null if the entry is scanned
  */
-export function shouldSkipEntry(name: string, isDirectory: boolean): boolean {
+export function getEntrySkipReason(
+  name: string,
+  isDirectory: boolean,
+  options: { includeExtras?: boolean } = {},
+): SkipReason | null {
   // Skip hidden/system files and directories
   if (name.startsWith(".")) {
     // Allow specific media directories that start with dot but aren't system files
     const allowedDotDirs = [".media", ".movies", ".tv"];
     if (!isDirectory || !allowedDotDirs.includes(name.toLowerCase())) {
-      return true;
+      return "hidden";
     }
   }
 
   // Skip system and unwanted directories
   if (isDirectory) {
+    if (options.includeExtras && isExtrasDirectory(name)) {
+      return null;
+    }
+
+    if (EXTRAS_DIRECTORIES.includes(name)) {
+      return "extras-folder";
+    }
+
     if (SKIP_DIRECTORIES.includes(name)) {
-      return true;
+      return "system";
     }
 
     // Skip directories matching patterns
     if (name.toLowerCase().includes("@eadir")) {
-      return true;
+      return "system";
     }
   }
 
   // Skip files matching patterns
   if (!isDirectory) {
-    for (const pattern of SKIP_FILE_PATTERNS) {
+    for (const [pattern, reason] of SKIP_FILE_PATTERNS) {
       if (pattern.test(name)) {
-        return true;
+        return reason;
       }
     }
   }
 
-  return false;
+  return null;
+}
+
+/**
+ * Checks if a directory or file should be skipped during scanning
+ *
+ * @param name - File or directory name
+ * @param isDirectory - Whether this is a directory
+ * @param options - includeExtras keeps bonus-content directories
+ * @returns True if should skip, false otherwise
+ */
+export function shouldSkipEntry(
+  name: string,
+  isDirectory: boolean,
+  options: { includeExtras?: boolean } = {},
+): boolean {
+  return getEntrySkipReason(name, isDirectory, options) !== null;
 }
 
 /**
  * Get default video file extensions for scanning
+ * .m2ts and .ts are only included while disc folders are detected, since
+ * without it every fragment of a Blu-ray rip would be scanned
  */
 export function getDefaultVideoExtensions(): string[] {
-  return [
+  const extensions = [
     ".mp4",
     ".mkv",
     ".avi",
@@ -116,9 +165,11 @@ export function getDefaultVideoExtensions(): string[] {
     ".m4v",
     ".mpg",
     ".mpeg",
-    ".m2ts",
-    ".ts",
   ];
+
+  return isDiscStructureDetectionEnabled()
+    ? [...extensions, ".m2ts", ".ts"]
+    : extensions;
 }
 
 /**
@@ -3,13 +3,75 @@
  * Handles recursive directory traversal and file collection
  */
 
-import { readdir, stat } from "fs/promises";
-import { join } from "path";
-import { logger, extractIds } from "@/lib/utils";
-import { shouldSkipEntry } from "./file-filter.helper";
+import { basename, dirname, extname, join, resolve } from "path";
+import { logger, extractIds, MAX_TITLE_LENGTH } from "@/lib/utils";
+import type { ExtractedIds } from "@/lib/utils";
+import { getEntrySkipReason, isExtrasDirectory } from "./file-filter.helper";
+import { applyPathOverride } from "./path-override.helper";
+import {
+  MEDIA_OVERRIDE_FILE,
+  applyMediaOverrideFile,
+  checkMediaOverrideFile,
+  getMediaOverrideForFile,
+  loadMediaOverrideFile,
+} from "./media-override-file.helper";
+import type { MediaOverrideFile } from "./media-override-file.helper";
+import {
+  findDiscMainTitle,
+  getDiscStructureType,
+  isDiscStructureDetectionEnabled,
+} from "./disc-structure.helper";
+import type { DiscStructureType } from "./disc-structure.helper";
+import { OperationTimeoutError, withTimeout } from "./timeout-helper";
+import { listFolder, peekListing } from "./listing-cache.helper";
+import type { ListedEntry } from "./listing-cache.helper";
+import { scanFs } from "./scan-fs.helper";
+import type { PathOverrideResolver } from "./path-override.helper";
+import type { ScanErrorCollector } from "./scan-errors.helper";
+import type { SkipReason } from "./scan-skips.helper";
+import { getExtraSuffixes, matchExtraSuffix } from "./movie-extras.helper";
+import {
+  SKIP_SENTINEL_FILE,
+  describeSkipSentinel,
+  getActiveSkipSentinel,
+} from "./skip-sentinel.helper";
 import { validateMediaPath, logValidationStats } from "./path-validator.helper";
 import type { MediaEntry } from "../scan.types";
 
+/**
+ * Score how much of a title a name gave: IDs beat a year, a year beats a
+ * bare title
+ */
+function getTitleConfidence(ids: ExtractedIds): number {
+  let score = 0;
+  if (ids.tmdbId || ids.imdbId || ids.tvdbId) score += 3;
+  if (ids.year) score += 2;
+  if (ids.title) score += 1;
+  return score;
+}
+
+/**
+ * Take the title and year of a folder's only video from the folder name
+ * when the folder parses with more confidence than the filename
+ */
+function preferFolderTitle(entry: MediaEntry, folderName: string): void {
+  const fromFolder = extractIds(folderName);
+  const fromName = extractIds(entry.name);
+
+  if (!fromFolder.title || !fromFolder.year) return;
+  if (getTitleConfidence(fromFolder) <= getTitleConfidence(fromName)) return;
+
+  entry.extractedIds = {
+    ...entry.extractedIds,
+    title: fromFolder.title,
+    year: fromFolder.year,
+    titleSource: "folder",
+  };
+  logger.debug(
+    `Using folder title for ${entry.name}: ${fromFolder.title} (${fromFolder.year})`,
+  );
+}
+
 /**
  * Recursively collect media entries from a directory
  *
@@ -23,23 +85,79 @@ export async function collectMediaEntries(
     maxDepth?: number;
     mediaType: "movie" | "tv";
     fileExtensions: string[];
+    includeExtras?: boolean; // TV only: ingest Extras/Featurettes/... folders
+    maxFiles?: number; // Stop the walk once this many media files are found
+    resolveOverride?: PathOverrideResolver; // Manual values for stubborn files
+    fileDeadlineMs?: number; // Skip a file whose stat hangs longer than this
+    errorCollector?: ScanErrorCollector; // Groups access failures by directory
     onProgress?: (count: number) => void;
+    onLimitReached?: () => void;
+    onFileTimeout?: (filePath: string) => void;
+    // A file or folder was left out of the scan, and why
+    onSkip?: (path: string, reason: SkipReason, detail?: string) => void;
+    // A folder held only subfolders and the walk stopped above them at
+    // maxDepth, so any media below was never seen
+    onDepthLimit?: (folderPath: string) => void;
+    // A folder was left out, with everything below it, because it holds an
+    // active .dester-skip-until file
+    onSentinelSkip?: (folderPath: string) => void;
+    // Movies only: lowercased names of central trailer folders directly
+    // under rootPath, when rootPath is the library root
+    trailerFolders?: Set<string>;
+    // Movies only: rootPath is itself a central trailer folder (batch scans
+    // walk each library folder on its own)
+    isTrailerFolder?: boolean;
+    // Absolute paths of the only files to collect. Just the folders leading
+    // to them are read, instead of the whole tree.
+    onlyFiles?: string[];
   },
 ): Promise<MediaEntry[]> {
   const {
     maxDepth = Infinity,
     mediaType,
     fileExtensions,
+    maxFiles = Infinity,
+    resolveOverride,
+    fileDeadlineMs,
+    errorCollector,
     onProgress,
+    onLimitReached,
+    onFileTimeout,
+    onDepthLimit,
+    onSentinelSkip,
+    onSkip,
   } = options;
+  const includeExtras = mediaType === "tv" && !!options.includeExtras;
+  const extraSuffixes = mediaType === "movie" ? getExtraSuffixes() : undefined;
+  const trailerFolders =
+    mediaType === "movie" ? options.trailerFolders : undefined;
   const mediaEntries: MediaEntry[] = [];
   let totalScanned = 0;
   let totalSkipped = 0;
   let depthViolations = 0;
   let structureViolations = 0;
+  let timedOut = 0;
+  let depthLimited = 0;
+  let fileCount = 0;
+  let limitReached = false;
   const sampleFiles: string[] = [];
   const maxSamples = 10;
 
+  // Explicit file list: the listed files and the folders between them and
+  // the root
+  const listedFiles = options.onlyFiles
+    ? new Set(options.onlyFiles.map((file) => resolve(file)))
+    : undefined;
+  const listedFolders = new Set<string>();
+  const resolvedRoot = resolve(rootPath);
+  for (const file of listedFiles ?? []) {
+    let folder = dirname(file);
+    while (folder !== resolvedRoot && folder.startsWith(resolvedRoot)) {
+      listedFolders.add(folder);
+      folder = dirname(folder);
+    }
+  }
+
   // Track unique show folders for TV shows (for optimization)
   const tvShowFolders = new Map<string, Set<number>>(); // showFolder -> Set<seasons>
 
@@ -48,21 +166,192 @@ export async function collectMediaEntries(
   );
   logger.debug(`Media type: ${mediaType}, Max depth: ${maxDepth}`);
 
+  const detectDiscs = isDiscStructureDetectionEnabled();
+
+  // Videos a folder holds: main video files and disc folders, not extras,
+  // samples or other skipped files
+  function countFolderVideos(entries: ListedEntry[]): number {
+    const fileNames = entries
+      .filter((entry) => !entry.isDirectory())
+      .map((entry) => entry.name);
+    return entries.filter((entry) => {
+      if (entry.isDirectory()) {
+        return detectDiscs && getDiscStructureType(entry.name) !== null;
+      }
+      return (
+        fileExtensions.some((ext) =>
+          entry.name.toLowerCase().endsWith(ext.toLowerCase()),
+        ) &&
+        !getEntrySkipReason(entry.name, false, { includeExtras }) &&
+        !(
+          extraSuffixes &&
+          matchExtraSuffix(entry.name, extraSuffixes, fileNames)
+        )
+      );
+    }).length;
+  }
+
+  /**
+   * Add the main title of a BDMV or VIDEO_TS folder as the video of the
+   * folder the disc is in, named after that folder
+   * Returns whether it was added
+   */
+  async function addDiscMainTitle(
+    folderPath: string,
+    discPath: string,
+    discType: DiscStructureType,
+    overrideFile: MediaOverrideFile | null,
+  ): Promise<boolean> {
+    if (mediaType === "tv") {
+      totalSkipped++;
+      onSkip?.(discPath, "disc-structure", "TV scans leave disc folders out");
+      return false;
+    }
+
+    let mainTitle;
+    try {
+      mainTitle = await findDiscMainTitle(
+        discPath,
+        discType,
+        fileExtensions,
+        fileDeadlineMs,
+      );
+    } catch (err) {
+      const message = err instanceof Error ? err.message : String(err);
+      logger.warn(`Cannot read disc folder ${discPath}: ${message}`);
+      errorCollector?.record(discPath, err);
+      onSkip?.(
+        discPath,
+        err instanceof OperationTimeoutError ? "timeout" : "inaccessible",
+        message,
+      );
+      return false;
+    }
+
+    if (!mainTitle) {
+      totalSkipped++;
+      onSkip?.(discPath, "disc-structure", "no main title found");
+      return false;
+    }
+    onSkip?.(discPath, "disc-structure", `main title ${mainTitle.path}`);
+
+    const name = `${basename(folderPath)}${extname(mainTitle.path).toLowerCase()}`;
+    let extractedIds: ExtractedIds = {
+      ...extractIds(name),
+      titleSource: "folder",
+    };
+    if (overrideFile) {
+      extractedIds = applyMediaOverrideFile(
+        extractedIds,
+        getMediaOverrideForFile(overrideFile, basename(discPath)),
+      );
+    }
+    const override = resolveOverride?.(mainTitle.path);
+    if (override) {
+      extractedIds = applyPathOverride(extractedIds, override);
+    }
+
+    // Validated as if the title were a file in the disc's folder
+    const validation = validateMediaPath(
+      rootPath,
+      join(folderPath, name),
+      mediaType,
+      extractedIds,
+    );
+    if (!validation.valid) {
+      totalSkipped++;
+      structureViolations++;
+      logger.info(`⏭️  Skipping disc ${discPath}: ${validation.reason}`);
+      onSkip?.(discPath, "bad-structure", validation.reason);
+      return false;
+    }
+
+    if (fileCount >= maxFiles) {
+      limitReached = true;
+      logger.warn(
+        `⚠️  File limit reached: stopped scanning ${rootPath} after ${maxFiles} media files`,
+      );
+      onLimitReached?.();
+      return false;
+    }
+    fileCount++;
+
+    mediaEntries.push({
+      path: mainTitle.path,
+      name,
+      isDirectory: false,
+      size: mainTitle.size,
+      modified: mainTitle.modified,
+      extractedIds,
+    });
+    onProgress?.(mediaEntries.length);
+    logger.debug(`💿 Using ${mainTitle.path} as the main title of ${discPath}`);
+    return true;
+  }
+
   async function collectEntries(
     currentPath: string,
     depth: number = 0,
+    inTrailerFolder: boolean = mediaType === "movie" &&
+      !!options.isTrailerFolder,
   ): Promise<void> {
-    if (depth > maxDepth) return;
+    if (depth > maxDepth || limitReached) return;
 
     try {
-      const entries = await readdir(currentPath, { withFileTypes: true });
+      // An unchanged folder listed by a recent scan is not listed again.
+      // Marker files are looked for in the first batch of the listing, and
+      // read only when they are there (or the folder is too large to tell).
+      const directory = await peekListing(
+        await listFolder(currentPath, fileDeadlineMs),
+      );
 
-      if (depth === 0 && entries.length === 0) {
-        logger.warn(`Directory is empty: ${currentPath}`);
+      const sentinel =
+        directory.hasFile(SKIP_SENTINEL_FILE) === false
+          ? null
+          : await getActiveSkipSentinel(currentPath, fileDeadlineMs);
+      if (sentinel) {
+        await directory.close();
+        const detail = describeSkipSentinel(sentinel);
+        totalSkipped++;
+        logger.info(`⏸️  Skipping ${currentPath}: ${detail}`);
+        onSkip?.(currentPath, "skip-sentinel", detail);
+        onSentinelSkip?.(currentPath);
         return;
       }
 
-      for (const entry of entries) {
+      // dester.json pins parse results for the videos in this folder
+      const loadedOverrideFile =
+        directory.hasFile(MEDIA_OVERRIDE_FILE) === false
+          ? null
+          : await loadMediaOverrideFile(currentPath, fileDeadlineMs);
+      const overrideFile =
+        loadedOverrideFile &&
+        checkMediaOverrideFile(
+          loadedOverrideFile,
+          currentPath,
+          directory.complete && countFolderVideos(directory.complete),
+        );
+      // Names of the files here, when the folder fit in one batch
+      const folderFileNames =
+        directory.complete
+          ?.filter((entry) => !entry.isDirectory())
+          .map((entry) => entry.name) ?? null;
+
+      let entryCount = 0;
+      let fileEntries = 0;
+      let subfoldersBeyondDepth = 0;
+      // The only video file directly in this folder, if there is just one
+      let videoFileCount = 0;
+      let folderVideoEntry: MediaEntry | undefined;
+      let folderVideoOverridden = false;
+      // Walked after the rest of the folder so their movies are saved first
+      const trailerSubfolders: string[] = [];
+
+      // The directory handle closes itself when the loop ends or breaks
+      for await (const entry of directory.entries) {
+        if (limitReached) break;
+
+        entryCount++;
         totalScanned++;
 
         // Collect sample file names for debugging (first few files only)
@@ -70,20 +359,88 @@ export async function collectMediaEntries(
           sampleFiles.push(entry.name);
         }
 
+        const isTrailerSubfolder =
+          depth === 0 &&
+          !inTrailerFolder &&
+          entry.isDirectory() &&
+          !!trailerFolders?.has(entry.name.toLowerCase());
+
+        const fullPath = join(currentPath, entry.name);
+
         // Skip system files and unwanted entries
-        if (shouldSkipEntry(entry.name, entry.isDirectory())) {
+        const filterReason = isTrailerSubfolder
+          ? null
+          : getEntrySkipReason(entry.name, entry.isDirectory(), {
+              includeExtras,
+            });
+        if (filterReason) {
           totalSkipped++;
           logger.debug(`Skipping filtered entry: ${entry.name}`);
+          onSkip?.(fullPath, filterReason);
           continue;
         }
 
-        const fullPath = join(currentPath, entry.name);
+        if (
+          listedFiles &&
+          !(entry.isDirectory() ? listedFolders : listedFiles).has(
+            resolve(fullPath),
+          )
+        ) {
+          continue;
+        }
+
+        if (!entry.isDirectory()) {
+          fileEntries++;
+        }
+        if (isTrailerSubfolder) {
+          trailerSubfolders.push(fullPath);
+          continue;
+        }
+
+        const discType =
+          detectDiscs && entry.isDirectory()
+            ? getDiscStructureType(entry.name)
+            : null;
+        if (discType) {
+          // The disc stands for the video of this folder
+          fileEntries++;
+          if (
+            await addDiscMainTitle(
+              currentPath,
+              fullPath,
+              discType,
+              overrideFile,
+            )
+          ) {
+            videoFileCount++;
+          }
+          continue;
+        }
 
         try {
-          const stats = await stat(fullPath);
+          // stat has no timeout of its own and can hang on a failing mount.
+          // A timed-out call cannot be cancelled, but its late result is
+          // ignored and the walk moves on.
+          const stats = fileDeadlineMs
+            ? await withTimeout(
+                scanFs.stat(fullPath),
+                fileDeadlineMs,
+                `Stat ${fullPath}`,
+              )
+            : await scanFs.stat(fullPath);
+
+          // Movie extras ("Movie (2010)-trailer.mkv") parse as the movie
+          // they belong to
+          const movieExtra =
+            extraSuffixes && !entry.isDirectory()
+              ? matchExtraSuffix(entry.name, extraSuffixes, folderFileNames)
+              : null;
 
           // Extract IDs from the filename
-          const extractedFromName = extractIds(entry.name);
+          const extractedFromName = extractIds(
+            movieExtra ? movieExtra.baseName : entry.name,
+            { mediaType },
+          );
 
           // For TV shows, try to extract from parent folders
           // Structure: "Show Name (2020)/Season 1/episode.mkv"
@@ -99,37 +456,103 @@ export async function collectMediaEntries(
             extractedFromName.season && extractedFromName.episode
           );
 
+          // TV extras: files without episode info in an Extras-style folder.
+          // The show folder is the one above it, skipping a Season folder
+          // (Show/Extras/x.mkv or Show/Season 1/Extras/x.mkv)
+          const isExtra =
+            includeExtras &&
+            !entry.isDirectory() &&
+            !hasEpisodeInfo &&
+            isExtrasDirectory(parentFolderName);
+          const extrasShowFolderName = /^(season\s*\d+|specials)$/i.test(
+            grandparentFolderName,
+          )
+            ? pathParts[pathParts.length - 3] || ""
+            : grandparentFolderName;
+
           // For episode files, prefer show info from grandparent folder (show folder)
           // For other files, use parent folder or filename
-          const showInfo = hasEpisodeInfo
-            ? extractedFromGrandparent
-            : extractedFromParent;
+          const showInfo = isExtra
+            ? extractIds(extrasShowFolderName)
+            : hasEpisodeInfo
+              ? extractedFromGrandparent
+              : extractedFromParent;
 
           // Merge IDs, prioritizing: filename > grandparent (for episodes) > parent
-          const extractedIds = {
+          let extractedIds: ExtractedIds = {
             tmdbId: extractedFromName.tmdbId || showInfo.tmdbId,
             imdbId: extractedFromName.imdbId || showInfo.imdbId,
             tvdbId: extractedFromName.tvdbId || showInfo.tvdbId,
             year: extractedFromName.year || showInfo.year,
-            // For title, use show folder name for episodes, filename for others
-            title: hasEpisodeInfo
-              ? showInfo.title || extractedFromName.title
-              : extractedFromName.title,
-            season: extractedFromName.season || extractedFromParent.season,
+            // For title, use show folder name for episodes and extras, filename for others
+            title:
+              hasEpisodeInfo || isExtra
+                ? showInfo.title || extractedFromName.title
+                : extractedFromName.title,
+            titleSource:
+              (hasEpisodeInfo || isExtra) && showInfo.title
+                ? "folder"
+                : "filename",
+            season: isExtra
+              ? 0
+              : extractedFromName.season || extractedFromParent.season,
             episode: extractedFromName.episode,
+            rangeEnd: extractedFromName.rangeEnd,
+            // Release attributes only ever come from the file itself
+            is3D: extractedFromName.is3D,
+            isRemux: extractedFromName.isRemux,
+            sourceType: extractedFromName.sourceType,
+            resolution: extractedFromName.resolution,
+            language: extractedFromName.language,
+            isProper: extractedFromName.isProper,
+            isRepack: extractedFromName.isRepack,
+            isInternal: extractedFromName.isInternal,
           };
 
+          const isMediaFile =
+            !entry.isDirectory() &&
+            fileExtensions.some((ext) =>
+              entry.name.toLowerCase().endsWith(ext.toLowerCase()),
+            );
+
+          // An override file replaces parsing for the videos next to it,
+          // unless it says they are the other media type
+          if (overrideFile && isMediaFile) {
+            if (
+              overrideFile.mediaType &&
+              overrideFile.mediaType !== mediaType
+            ) {
+              totalSkipped++;
+              const reason = `${MEDIA_OVERRIDE_FILE} marks this folder as ${overrideFile.mediaType === "tv" ? "TV" : "movies"}`;
+              logger.info(`⏭️  Skipping ${entry.name}: ${reason}`);
+              onSkip?.(fullPath, "bad-structure", reason);
+              continue;
+            }
+            extractedIds = applyMediaOverrideFile(
+              extractedIds,
+              getMediaOverrideForFile(overrideFile, entry.name),
+            );
+          }
+
+          // A path override replaces whatever parsing produced
+          const override =
+            resolveOverride && !entry.isDirectory()
+              ? resolveOverride(fullPath)
+              : undefined;
+          if (override) {
+            extractedIds = applyPathOverride(extractedIds, override);
+            logger.debug(`📌 Using path override for ${fullPath}`);
+          }
+
           // Check if it's a media file or folder with IDs
           const hasIds = !!(
             extractedIds.tmdbId ||
             extractedIds.imdbId ||
             extractedIds.tvdbId
           );
-          const isMediaFile =
-            !entry.isDirectory() &&
-            fileExtensions.some((ext) =>
-              entry.name.toLowerCase().endsWith(ext.toLowerCase()),
-            );
+          if (isMediaFile && !movieExtra && !inTrailerFolder) {
+            videoFileCount++;
+          }
 
           // Debug log for first few files to see why they're not matching
           if (!entry.isDirectory() && mediaEntries.length < 3) {
@@ -144,18 +567,24 @@ export async function collectMediaEntries(
               rootPath,
               fullPath,
               mediaType,
-              extractedIds,
+              { ...extractedIds, isExtra },
             );
 
             if (!validation.valid) {
               totalSkipped++;
 
               // Track violation type for statistics
-              if (validation.reason?.includes("too deeply nested")) {
+              const skipReason: SkipReason = validation.reason?.includes(
+                "too deeply nested",
+              )
+                ? "too-deep"
+                : "bad-structure";
+              if (skipReason === "too-deep") {
                 depthViolations++;
               } else {
                 structureViolations++;
               }
+              onSkip?.(fullPath, skipReason, validation.reason);
 
               // Log first few violations at info level, rest at debug
               const logLevel =
@@ -181,6 +610,17 @@ export async function collectMediaEntries(
               tvShowFolders.get(showFolder)!.add(extractedIds.season);
             }
 
+            if (extractedIds.titleFallback) {
+              logger.warn(
+                `Low-confidence title for ${fullPath}: nothing was left after cleaning the name, using "${extractedIds.title}"`,
+              );
+            }
+            if (extractedIds.titleTruncated) {
+              logger.warn(
+                `Title parsed from ${fullPath} exceeded ${MAX_TITLE_LENGTH} characters and was truncated`,
+              );
+            }
+
             const mediaEntry: MediaEntry = {
               path: fullPath,
               name: entry.name,
@@ -190,8 +630,44 @@ export async function collectMediaEntries(
               extractedIds,
             };
 
+            const trailerFile = inTrailerFolder && !entry.isDirectory();
+            if (isExtra || movieExtra || trailerFile) {
+              mediaEntry.isExtra = true;
+              mediaEntry.extraTitle = entry.name
+                .replace(/\.[^.]+$/, "")
+                .replace(/[._]+/g, " ")
+                .trim();
+            }
+            if (movieExtra) {
+              mediaEntry.extraType = movieExtra.extraType;
+            }
+            if (trailerFile) {
+              mediaEntry.extraType ??= "trailer";
+              mediaEntry.fromTrailerFolder = true;
+            }
+
+            // Safety limit: stop the walk instead of collecting another file
+            if (!entry.isDirectory()) {
+              if (fileCount >= maxFiles) {
+                limitReached = true;
+                logger.warn(
+                  `⚠️  File limit reached: stopped scanning ${rootPath} after ${maxFiles} media files`,
+                );
+                if (onLimitReached) {
+                  onLimitReached();
+                }
+                break;
+              }
+              fileCount++;
+            }
+
             mediaEntries.push(mediaEntry);
 
+            if (isMediaFile && !movieExtra && !inTrailerFolder) {
+              folderVideoEntry = mediaEntry;
+              folderVideoOverridden = !!override || !!overrideFile;
+            }
+
             if (onProgress) {
               onProgress(mediaEntries.length);
             }
@@ -209,32 +685,97 @@ export async function collectMediaEntries(
               logger.debug(
                 `Not a media file: ${entry.name} (ext: ${ext}, expected: ${fileExtensions.join(", ")})`,
               );
+              onSkip?.(fullPath, "not-media", `extension ${ext}`);
             }
           }
 
           if (entry.isDirectory()) {
-            await collectEntries(fullPath, depth + 1);
+            if (depth + 1 > maxDepth) {
+              subfoldersBeyondDepth++;
+              onSkip?.(fullPath, "too-deep", `below maxDepth ${maxDepth}`);
+            } else {
+              await collectEntries(fullPath, depth + 1, inTrailerFolder);
+            }
           }
         } catch (err) {
-          logger.warn(
-            `Cannot access: ${fullPath} - ${err instanceof Error ? err.message : err}`,
-          );
+          if (err instanceof OperationTimeoutError) {
+            timedOut++;
+            logger.warn(
+              `⏱️  Skipping ${fullPath}: no response from the drive after ${err.timeoutMs / 1000}s`,
+            );
+            errorCollector?.record(fullPath, err);
+            onSkip?.(fullPath, "timeout", err.message);
+            if (onFileTimeout) {
+              onFileTimeout(fullPath);
+            }
+            continue;
+          }
+
+          const message = err instanceof Error ? err.message : String(err);
+          logger.warn(`Cannot access: ${fullPath} - ${message}`);
+          errorCollector?.record(fullPath, err);
+          onSkip?.(fullPath, "inaccessible", message);
+        }
+      }
+
+      for (const trailerFolderPath of trailerSubfolders) {
+        if (limitReached) break;
+        logger.debug(`🎞️  Scanning trailer folder: ${trailerFolderPath}`);
+        await collectEntries(trailerFolderPath, depth + 1, true);
+      }
+
+      if (depth === 0 && entryCount === 0) {
+        logger.warn(`Directory is empty: ${currentPath}`);
+      }
+
+      // Nothing but folders at the depth limit: files are likely below it
+      if (subfoldersBeyondDepth > 0 && fileEntries === 0) {
+        depthLimited++;
+        logger.warn(
+          `📏 Depth limit reached at ${currentPath}: it holds only folders (${subfoldersBeyondDepth}) below maxDepth ${maxDepth}, so their media was not scanned. Increase maxDepth if files are missing.`,
+        );
+        if (onDepthLimit) {
+          onDepthLimit(currentPath);
         }
       }
+
+      // "Movie Title (2010)/some.scene.name-GROUP.mkv": the folder names
+      // the movie better than the file does. Library roots rarely carry a
+      // year, so they do not qualify.
+      if (
+        mediaType === "movie" &&
+        videoFileCount === 1 &&
+        folderVideoEntry &&
+        !folderVideoOverridden
+      ) {
+        preferFolderTitle(folderVideoEntry, basename(currentPath));
+      }
     } catch (err) {
-      logger.error(
-        `Error scanning ${currentPath}: ${err instanceof Error ? err.message : err}`,
-      );
+      const message = err instanceof Error ? err.message : String(err);
+      logger.error(`Error scanning ${currentPath}: ${message}`);
+      errorCollector?.record(currentPath, err, true);
+      onSkip?.(currentPath, "inaccessible", message);
     }
   }
 
   await collectEntries(rootPath);
 
-  const notRecognized = totalScanned - totalSkipped - mediaEntries.length;
+  const notRecognized =
+    totalScanned - totalSkipped - timedOut - mediaEntries.length;
   logger.info(
     `Scan statistics: Scanned ${totalScanned} items, Skipped ${totalSkipped} filtered items, Not recognized as media: ${notRecognized}, Found ${mediaEntries.length} media items`,
   );
 
+  if (timedOut > 0) {
+    logger.warn(`⏱️  ${timedOut} file(s) skipped after timing out`);
+  }
+
+  if (depthLimited > 0) {
+    logger.warn(
+      `📏 ${depthLimited} folder(s) not fully scanned because of the depth limit (maxDepth ${maxDepth})`,
+    );
+  }
+
   // Log validation statistics
   if (depthViolations > 0 || structureViolations > 0) {
     logValidationStats({
@@ -12,6 +12,29 @@ export * from "./path-validator.helper";
 export * from "./batch-scanner.helper";
 export * from "./timeout-helper";
 export * from "./scan-job-cleanup.helper";
+export * from "./scan-activity.helper";
+export * from "./scan-queue.helper";
+export * from "./scan-limits.helper";
+export * from "./scan-requester.helper";
+export * from "./database-outage.helper";
+export * from "./scan-errors.helper";
+export * from "./scan-skips.helper";
+export * from "./scan-results.helper";
+export * from "./duration-validator.helper";
+export * from "./path-override.helper";
+export * from "./media-override-file.helper";
+export * from "./skip-sentinel.helper";
+export * from "./disc-structure.helper";
+export * from "./listing-cache.helper";
+export * from "./scan-fs.helper";
+export * from "./scanner-version.helper";
 export * from "./media-type-detector.helper";
+export * from "./movie-extras.helper";
+export * from "./media-type-change.helper";
+export * from "./parse-hash.helper";
+export * from "./best-copy.helper";
+export * from "./comic-scanner.helper";
+export * from "./library-analysis.helper";
+export * from "./library-staleness.helper";
 export * from "./color-extraction.helper";
 export * from "./color-extraction-middleware.helper";
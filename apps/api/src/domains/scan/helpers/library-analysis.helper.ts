@@ -0,0 +1,228 @@
+/**
+ * Directory analysis
+ * Walks a directory the way a scan would, but only counts what it finds:
+ * extensions (video or not), file sizes, folder depths and the largest
+ * files. Nothing is parsed, matched or saved, so it is safe to run on any
+ * folder before choosing extensions and size settings for a library.
+ */
+
+import { extname, join } from "path";
+import { logger, mapContainerToHostPath } from "@/lib/utils";
+import { getEntrySkipReason, isVideoFile } from "./file-filter.helper";
+import { DIRECTORY_READ_BATCH_SIZE } from "./listing-cache.helper";
+import { scanFs } from "./scan-fs.helper";
+import { OperationTimeoutError, withTimeout } from "./timeout-helper";
+import type { DirectoryAnalysis } from "../scan.types";
+
+export const DEFAULT_ANALYSIS_MAX_FILES = 100000;
+export const DEFAULT_ANALYSIS_TIME_LIMIT_SECONDS = 30;
+
+// Largest files listed in an analysis
+const LARGEST_FILES_COUNT = 20;
+
+const MB = 1024 * 1024;
+const GB = 1024 * MB;
+
+// Upper bounds of the size buckets; the last bucket has no bound
+const SIZE_BUCKETS: Array<{ label: string; maxBytes: number }> = [
+  { label: "< 1 MB", maxBytes: MB },
+  { label: "1-10 MB", maxBytes: 10 * MB },
+  { label: "10-100 MB", maxBytes: 100 * MB },
+  { label: "100 MB - 1 GB", maxBytes: GB },
+  { label: "1-4 GB", maxBytes: 4 * GB },
+  { label: "4-10 GB", maxBytes: 10 * GB },
+  { label: "10 GB+", maxBytes: Infinity },
+];
+
+/**
+ * Analyze the files under rootPath
+ * Hidden and system entries are left out like in a scan; everything else is
+ * counted, including files a scan would skip as sidecars. The walk stops at
+ * maxFiles files or after timeLimitMs, and the result says so. No stat may
+ * outlast the time left, and a listing that hangs does not hold the result
+ * past the time limit either.
+ */
+export async function analyzeDirectory(
+  rootPath: string,
+  options: {
+    maxDepth?: number;
+    maxFiles?: number;
+    timeLimitMs?: number;
+    fileDeadlineMs?: number;
+    originalPath?: string;
+  } = {},
+): Promise<DirectoryAnalysis> {
+  const {
+    maxDepth = Infinity,
+    maxFiles = DEFAULT_ANALYSIS_MAX_FILES,
+    timeLimitMs = DEFAULT_ANALYSIS_TIME_LIMIT_SECONDS * 1000,
+    fileDeadlineMs,
+    originalPath,
+  } = options;
+  const startedAt = Date.now();
+
+  const extensions = new Map<
+    string,
+    { count: number; totalBytes: number; isVideo: boolean }
+  >();
+  const sizeCounts = SIZE_BUCKETS.map(() => ({ count: 0, totalBytes: 0 }));
+  const depthCounts = new Map<number, number>();
+  // Kept sorted, largest first
+  const largestFiles: Array<{ path: string; size: number }> = [];
+
+  let totalFiles = 0;
+  let totalDirectories = 0;
+  let totalBytes = 0;
+  let skippedEntries = 0;
+  let unreadable = 0;
+  let stoppedBy = null as DirectoryAnalysis["stoppedBy"];
+
+  function addFile(
+    filePath: string,
+    name: string,
+    size: number,
+    depth: number,
+  ) {
+    totalFiles++;
+    totalBytes += size;
+
+    const extension = extname(name).toLowerCase() || "(none)";
+    const extensionStats = extensions.get(extension) ?? {
+      count: 0,
+      totalBytes: 0,
+      isVideo: isVideoFile(name),
+    };
+    extensionStats.count++;
+    extensionStats.totalBytes += size;
+    extensions.set(extension, extensionStats);
+
+    const bucket = SIZE_BUCKETS.findIndex((b) => size < b.maxBytes);
+    sizeCounts[bucket]!.count++;
+    sizeCounts[bucket]!.totalBytes += size;
+
+    depthCounts.set(depth, (depthCounts.get(depth) ?? 0) + 1);
+
+    const smallest = largestFiles[largestFiles.length - 1];
+    if (largestFiles.length < LARGEST_FILES_COUNT || size > smallest!.size) {
+      const index = largestFiles.findIndex((f) => size > f.size);
+      largestFiles.splice(index === -1 ? largestFiles.length : index, 0, {
+        path: mapContainerToHostPath(filePath, originalPath),
+        size,
+      });
+      largestFiles.length = Math.min(largestFiles.length, LARGEST_FILES_COUNT);
+    }
+  }
+
+  async function walk(currentPath: string, depth: number): Promise<void> {
+    if (stoppedBy) return;
+
+    try {
+      const directory = await scanFs.opendir(currentPath, {
+        bufferSize: DIRECTORY_READ_BATCH_SIZE,
+      });
+
+      for await (const entry of directory) {
+        if (totalFiles >= maxFiles) {
+          stoppedBy = "file-limit";
+        } else if (Date.now() - startedAt >= timeLimitMs) {
+          stoppedBy = "time-limit";
+        }
+        if (stoppedBy) break;
+
+        const reason = getEntrySkipReason(entry.name, entry.isDirectory());
+        if (reason === "hidden" || reason === "system") {
+          skippedEntries++;
+          continue;
+        }
+
+        const fullPath = join(currentPath, entry.name);
+
+        if (entry.isDirectory()) {
+          totalDirectories++;
+          if (depth + 1 <= maxDepth) {
+            await walk(fullPath, depth + 1);
+          }
+          continue;
+        }
+
+        if (!entry.isFile()) {
+          continue;
+        }
+
+        // A stat gets its own deadline, but never more than the time left
+        const remainingMs = timeLimitMs - (Date.now() - startedAt);
+        const statDeadlineMs = Math.min(
+          fileDeadlineMs ?? Infinity,
+          remainingMs,
+        );
+        try {
+          const stats = await withTimeout(
+            scanFs.stat(fullPath),
+            statDeadlineMs,
+            `Stat ${fullPath}`,
+          );
+          if (stoppedBy) break;
+          addFile(fullPath, entry.name, stats.size, depth);
+        } catch (error) {
+          if (stoppedBy) break;
+          if (
+            error instanceof OperationTimeoutError &&
+            statDeadlineMs === remainingMs
+          ) {
+            stoppedBy = "time-limit";
+            break;
+          }
+          unreadable++;
+          if (error instanceof OperationTimeoutError) {
+            logger.debug(`Analysis: no response for ${fullPath}`);
+          }
+        }
+      }
+    } catch (error) {
+      if (stoppedBy) return;
+      unreadable++;
+      logger.warn(
+        `Analysis: cannot read ${currentPath}: ${error instanceof Error ? error.message : error}`,
+      );
+    }
+  }
+
+  // A listing that never answers is cut off at the time limit; the walk
+  // notices stoppedBy and ends on its own once the drive answers
+  let timer: NodeJS.Timeout | undefined;
+  const timeLimitReached = new Promise<void>((resolve) => {
+    timer = setTimeout(() => {
+      stoppedBy ??= "time-limit";
+      resolve();
+    }, timeLimitMs);
+  });
+  await Promise.race([walk(rootPath, 0), timeLimitReached]).finally(() =>
+    clearTimeout(timer),
+  );
+
+  return {
+    totalFiles,
+    totalDirectories,
+    totalBytes,
+    skippedEntries,
+    unreadable,
+    truncated: stoppedBy !== null,
+    stoppedBy,
+    durationMs: Date.now() - startedAt,
+    extensions: Array.from(extensions, ([extension, stats]) => ({
+      extension,
+      ...stats,
+    })).sort((a, b) => b.count - a.count || b.totalBytes - a.totalBytes),
+    sizeBuckets: SIZE_BUCKETS.map((bucket, i) => ({
+      label: bucket.label,
+      minBytes: i === 0 ? 0 : SIZE_BUCKETS[i - 1]!.maxBytes,
+      maxBytes: Number.isFinite(bucket.maxBytes) ? bucket.maxBytes : null,
+      ...sizeCounts[i]!,
+    })),
+    depths: Array.from(depthCounts, ([depth, files]) => ({
+      depth,
+      files,
+    })).sort((a, b) => a.depth - b.depth),
+    largestFiles,
+  };
+}
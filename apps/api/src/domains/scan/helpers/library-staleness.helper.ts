@@ -0,0 +1,187 @@
+/**
+ * Library staleness
+ * Every library records when a full scan of it last finished. When
+ * LIBRARY_STALE_AFTER is set, a periodic check warns about libraries that
+ * have not been scanned for longer than that, so a broken SCAN_SCHEDULE or a
+ * scan that keeps failing does not go unnoticed.
+ */
+
+import prisma from "@/lib/database/prisma";
+import { logger } from "@/lib/utils";
+import { wsManager } from "@/lib/websocket";
+
+const DURATION_UNITS_MS: Record<string, number> = {
+  s: 1000,
+  m: 60 * 1000,
+  h: 60 * 60 * 1000,
+  d: 24 * 60 * 60 * 1000,
+};
+
+/**
+ * Parse a duration such as "30m", "6h", "7d" or "1h30m" into milliseconds
+ * Returns null if the value is not a duration
+ */
+export function parseDurationMs(value: string): number | null {
+  const duration = value.trim().toLowerCase();
+
+  if (!/^(?:\d+[smhd])+$/.test(duration)) {
+    return null;
+  }
+
+  let ms = 0;
+  for (const [, amount, unit] of duration.matchAll(/(\d+)([smhd])/g)) {
+    ms += parseInt(amount!, 10) * DURATION_UNITS_MS[unit!]!;
+  }
+
+  return ms;
+}
+
+/**
+ * Get how long a library may go without a full scan before it is stale
+ * Read from LIBRARY_STALE_AFTER; null (no staleness check) when unset or
+ * invalid
+ */
+export function getLibraryStaleAfterMs(
+  value: string | undefined = process.env.LIBRARY_STALE_AFTER,
+): number | null {
+  if (!value || value.trim() === "") {
+    return null;
+  }
+
+  const staleAfterMs = parseDurationMs(value);
+  if (!staleAfterMs) {
+    logger.warn(
+      `Invalid LIBRARY_STALE_AFTER "${value}" (use a duration such as "7d"), staleness check disabled`,
+    );
+    return null;
+  }
+
+  return staleAfterMs;
+}
+
+export interface StaleLibrary {
+  id: string;
+  name: string;
+  libraryPath: string | null;
+  lastSuccessfulScanAt: string | null;
+}
+
+// Result of the last check, reported by GET /health
+let lastCheck: { checkedAt: string; staleLibraries: StaleLibrary[] } | null =
+  null;
+
+// Libraries already reported stale, so each is announced once per stale spell
+const staleLibraryIds = new Set<string>();
+
+/**
+ * Whether a library is stale
+ * A library that never finished a full scan is measured from its creation.
+ */
+export function isLibraryStale(
+  library: { lastSuccessfulScanAt: Date | null; createdAt: Date },
+  staleAfterMs: number | null = getLibraryStaleAfterMs(),
+  now: Date = new Date(),
+): boolean {
+  if (staleAfterMs === null) {
+    return false;
+  }
+
+  const since = library.lastSuccessfulScanAt ?? library.createdAt;
+  return now.getTime() - since.getTime() > staleAfterMs;
+}
+
+/**
+ * Record that a full scan of a library finished
+ * Scans of listed files or subpaths, and scans stopped at the file limit,
+ * do not count.
+ */
+export async function recordSuccessfulScan(
+  libraryId: string,
+  completedAt: Date = new Date(),
+): Promise<void> {
+  await prisma.library.update({
+    where: { id: libraryId },
+    data: { lastSuccessfulScanAt: completedAt },
+  });
+
+  staleLibraryIds.delete(libraryId);
+  if (lastCheck) {
+    lastCheck.staleLibraries = lastCheck.staleLibraries.filter(
+      (library) => library.id !== libraryId,
+    );
+  }
+}
+
+/**
+ * Find the stale libraries, log a warning and send a library:stale event for
+ * each one that was not stale at the previous check
+ */
+export async function checkStaleLibraries(
+  staleAfterMs: number | null = getLibraryStaleAfterMs(),
+  now: Date = new Date(),
+): Promise<StaleLibrary[]> {
+  if (staleAfterMs === null) {
+    return [];
+  }
+
+  const libraries = await prisma.library.findMany({
+    where: { isLibrary: true, libraryPath: { not: null } },
+    select: {
+      id: true,
+      name: true,
+      libraryPath: true,
+      lastSuccessfulScanAt: true,
+      createdAt: true,
+    },
+    orderBy: { name: "asc" },
+  });
+
+  const stale = libraries.filter((library) =>
+    isLibraryStale(library, staleAfterMs, now),
+  );
+
+  for (const library of stale) {
+    if (staleLibraryIds.has(library.id)) {
+      continue;
+    }
+    staleLibraryIds.add(library.id);
+
+    const lastScan = library.lastSuccessfulScanAt
+      ? `last full scan finished ${library.lastSuccessfulScanAt.toISOString()}`
+      : "never fully scanned";
+    logger.warn(`⚠️  Library "${library.name}" is stale: ${lastScan}`);
+
+    wsManager.sendLibraryStale({
+      libraryId: library.id,
+      libraryName: library.name,
+      lastSuccessfulScanAt:
+        library.lastSuccessfulScanAt?.toISOString() ?? null,
+      staleAfterMs,
+    });
+  }
+
+  // Libraries that were scanned or deleted since can be reported again
+  const staleIds = new Set(stale.map((library) => library.id));
+  for (const id of staleLibraryIds) {
+    if (!staleIds.has(id)) {
+      staleLibraryIds.delete(id);
+    }
+  }
+
+  const staleLibraries = stale.map((library) => ({
+    id: library.id,
+    name: library.name,
+    libraryPath: library.libraryPath,
+    lastSuccessfulScanAt: library.lastSuccessfulScanAt?.toISOString() ?? null,
+  }));
+  lastCheck = { checkedAt: now.toISOString(), staleLibraries };
+
+  return staleLibraries;
+}
+
+/**
+ * Get the result of the last staleness check, or null before the first one
+ */
+export function getLastStaleLibraryCheck() {
+  return lastCheck;
+}
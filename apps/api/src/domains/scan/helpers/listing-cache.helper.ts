@@ -0,0 +1,297 @@
+/**
+ * Folder listings and the listing cache
+ * On cloud and network mounts, listing folders is a large part of the cost
+ * of a scan. With SCANNER_LISTING_CACHE on, the walker keeps the names and
+ * types of what it listed for a short while, so a scan soon after another
+ * over the same folders (a movie pass, then a TV pass) lists each unchanged
+ * folder once. A folder whose modification time changed (a file added,
+ * removed or renamed) is listed again.
+ */
+
+import { logger } from "@/lib/utils";
+import { parseDurationMs } from "./library-staleness.helper";
+import { scanFs } from "./scan-fs.helper";
+import { withTimeout } from "./timeout-helper";
+
+/**
+ * Default time a listing stays usable
+ */
+export const DEFAULT_LISTING_CACHE_TTL_MS = 10 * 60 * 1000;
+
+/**
+ * Default maximum number of entries kept, across all cached folders
+ */
+export const DEFAULT_LISTING_CACHE_MAX_ENTRIES = 200000;
+
+// Entries read from disk at a time. Very large flat folders are streamed
+// in chunks instead of being listed in one call.
+export const DIRECTORY_READ_BATCH_SIZE = 1000;
+
+/**
+ * A cached folder entry
+ * Only names and types are kept. Files are statted on every scan, since a
+ * file changed in place does not change the modification time of its
+ * folder.
+ */
+export interface CachedEntry {
+  name: string;
+  directory: boolean;
+}
+
+interface CachedListing {
+  folderMtimeMs: number;
+  cachedAt: number;
+  entries: CachedEntry[];
+}
+
+/**
+ * An entry of a folder, from the cache or freshly listed
+ */
+export interface ListedEntry {
+  name: string;
+  isDirectory(): boolean;
+}
+
+// Oldest first, so the first entries are the ones evicted
+const listings = new Map<string, CachedListing>();
+let cachedEntryCount = 0;
+
+/**
+ * Whether the listing cache is on
+ * Read from SCANNER_LISTING_CACHE; off unless set to "true"
+ */
+export function isListingCacheEnabled(
+  value: string | undefined = process.env.SCANNER_LISTING_CACHE,
+): boolean {
+  return value?.trim().toLowerCase() === "true";
+}
+
+/**
+ * Get how long a cached listing is used, in milliseconds
+ * Read from SCANNER_LISTING_CACHE_TTL as a duration ("10m"), falling back
+ * to the default
+ */
+export function getListingCacheTtlMs(
+  value: string | undefined = process.env.SCANNER_LISTING_CACHE_TTL,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_LISTING_CACHE_TTL_MS;
+  }
+
+  const ttlMs = parseDurationMs(value);
+  if (!ttlMs) {
+    logger.warn(
+      `Invalid SCANNER_LISTING_CACHE_TTL "${value}" (use a duration such as "10m"), using default of 10m`,
+    );
+    return DEFAULT_LISTING_CACHE_TTL_MS;
+  }
+
+  return ttlMs;
+}
+
+/**
+ * Get the maximum number of entries the listing cache holds
+ * Read from SCANNER_LISTING_CACHE_MAX_ENTRIES, falling back to the default
+ */
+export function getListingCacheMaxEntries(
+  value: string | undefined = process.env.SCANNER_LISTING_CACHE_MAX_ENTRIES,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_LISTING_CACHE_MAX_ENTRIES;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isInteger(parsed) || parsed <= 0) {
+    logger.warn(
+      `Invalid SCANNER_LISTING_CACHE_MAX_ENTRIES "${value}", using default of ${DEFAULT_LISTING_CACHE_MAX_ENTRIES}`,
+    );
+    return DEFAULT_LISTING_CACHE_MAX_ENTRIES;
+  }
+
+  return parsed;
+}
+
+function removeListing(folderPath: string) {
+  const listing = listings.get(folderPath);
+  if (listing) {
+    cachedEntryCount -= listing.entries.length;
+    listings.delete(folderPath);
+  }
+}
+
+// Modification time of a folder, or null if it cannot be read
+async function getFolderMtimeMs(
+  folderPath: string,
+  fileDeadlineMs?: number,
+): Promise<number | null> {
+  try {
+    const stats = fileDeadlineMs
+      ? await withTimeout(
+          scanFs.stat(folderPath),
+          fileDeadlineMs,
+          `Stat ${folderPath}`,
+        )
+      : await scanFs.stat(folderPath);
+    return stats.mtimeMs;
+  } catch {
+    return null;
+  }
+}
+
+// Cached entries of a folder, or null when it is not cached, has expired
+// or changed since
+function getCachedEntries(
+  folderPath: string,
+  folderMtimeMs: number,
+  now: number,
+): ListedEntry[] | null {
+  const listing = listings.get(folderPath);
+  if (!listing) {
+    return null;
+  }
+
+  if (
+    now - listing.cachedAt > getListingCacheTtlMs() ||
+    folderMtimeMs !== listing.folderMtimeMs
+  ) {
+    removeListing(folderPath);
+    return null;
+  }
+
+  logger.debug(`📋 Using cached listing of ${folderPath}`);
+  return listing.entries.map((entry) => ({
+    name: entry.name,
+    isDirectory: () => entry.directory,
+  }));
+}
+
+function cacheListing(
+  folderPath: string,
+  folderMtimeMs: number,
+  entries: CachedEntry[],
+  now: number,
+): void {
+  const maxEntries = getListingCacheMaxEntries();
+  if (entries.length > maxEntries) {
+    return;
+  }
+
+  removeListing(folderPath);
+  listings.set(folderPath, { folderMtimeMs, cachedAt: now, entries });
+  cachedEntryCount += entries.length;
+
+  // Evict the oldest listings until the cache fits again
+  for (const oldestPath of listings.keys()) {
+    if (cachedEntryCount <= maxEntries) break;
+    removeListing(oldestPath);
+  }
+}
+
+/**
+ * List the entries of a folder, from the cache when it is on and holds
+ * the folder unchanged
+ * A fresh listing is streamed in batches and cached once it has been read
+ * to the end; a walk that stops early (at the file limit) leaves the folder
+ * uncached.
+ */
+export async function listFolder(
+  folderPath: string,
+  fileDeadlineMs?: number,
+  now: number = Date.now(),
+): Promise<AsyncIterable<ListedEntry> | ListedEntry[]> {
+  // Read before listing, so a change made while listing is seen next time
+  const folderMtimeMs = isListingCacheEnabled()
+    ? await getFolderMtimeMs(folderPath, fileDeadlineMs)
+    : null;
+
+  const cached =
+    folderMtimeMs === null
+      ? null
+      : getCachedEntries(folderPath, folderMtimeMs, now);
+  if (cached) {
+    return cached;
+  }
+
+  const directory = await scanFs.opendir(folderPath, {
+    bufferSize: DIRECTORY_READ_BATCH_SIZE,
+  });
+  if (folderMtimeMs === null) {
+    return directory;
+  }
+
+  return (async function* () {
+    const entries: CachedEntry[] = [];
+    // The directory handle closes itself when the loop ends or breaks
+    for await (const entry of directory) {
+      entries.push({ name: entry.name, directory: entry.isDirectory() });
+      yield entry;
+    }
+    cacheListing(folderPath, folderMtimeMs, entries, now);
+  })();
+}
+
+/**
+ * A folder listing with its first batch read ahead
+ */
+export interface PeekedListing {
+  entries: AsyncIterable<ListedEntry>;
+  /**
+   * Whether the folder holds a file of this name; null when the folder is
+   * larger than one batch and the name may come later
+   */
+  hasFile(name: string): boolean | null;
+  // Every entry of a folder that fit in one batch, otherwise null
+  complete: ListedEntry[] | null;
+  // Stop listing without walking the entries
+  close(): Promise<void>;
+}
+
+/**
+ * Read the first batch of a listing ahead
+ * Lets the walker find marker files (.dester-skip-until, dester.json) in
+ * what it lists anyway instead of trying to read them in every folder.
+ * Later batches are still streamed.
+ */
+export async function peekListing(
+  listing: AsyncIterable<ListedEntry> | ListedEntry[],
+  batchSize: number = DIRECTORY_READ_BATCH_SIZE,
+): Promise<PeekedListing> {
+  const iterator = (async function* () {
+    yield* listing;
+  })();
+
+  const head: ListedEntry[] = [];
+  let complete = false;
+  while (head.length < batchSize) {
+    const next = await iterator.next();
+    if (next.done) {
+      complete = true;
+      break;
+    }
+    head.push(next.value);
+  }
+
+  const fileNames = new Set(
+    head.filter((entry) => !entry.isDirectory()).map((entry) => entry.name),
+  );
+  const close = async () => {
+    await iterator.return(undefined);
+  };
+
+  return {
+    entries: (async function* () {
+      try {
+        yield* head;
+        if (!complete) {
+          yield* iterator;
+        }
+      } finally {
+        // A walk that breaks early must still release the folder handle
+        await close();
+      }
+    })(),
+    hasFile: (name) => (fileNames.has(name) ? true : complete ? false : null),
+    complete: complete ? head : null,
+    close,
+  };
+}
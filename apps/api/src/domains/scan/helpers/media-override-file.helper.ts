@@ -0,0 +1,161 @@
+/**
+ * Media override files
+ * A dester.json file in a folder pins what the scanner would otherwise parse
+ * from the names of the videos in it: title, year, TMDB ID, season and
+ * episode. It lives with the files, so it survives moves and needs no API
+ * call, unlike a path override.
+ *
+ * Values at the top level are for the video of the folder. Values under
+ * "files" are for the video of that file name, so a season folder can
+ * number each of its episodes.
+ */
+
+import { join } from "path";
+import { z } from "zod";
+import { logger } from "@/lib/utils";
+import type { ExtractedIds } from "@/lib/utils";
+import { scanFs } from "./scan-fs.helper";
+import { withTimeout } from "./timeout-helper";
+
+export const MEDIA_OVERRIDE_FILE = "dester.json";
+
+const mediaOverrideSchema = z
+  .object({
+    title: z.string().trim().min(1).max(500).optional(),
+    year: z.number().int().min(1800).max(2200).optional(),
+    tmdbId: z
+      .union([z.number().int().positive(), z.string().regex(/^\d+$/)])
+      .optional(),
+    season: z.number().int().min(0).optional(),
+    episode: z.number().int().min(0).optional(),
+  })
+  .strict();
+
+const mediaOverrideFileSchema = mediaOverrideSchema
+  .extend({
+    mediaType: z.enum(["movie", "tv"]).optional(),
+    files: z.record(z.string().min(1), mediaOverrideSchema).optional(),
+  })
+  .strict();
+
+export type MediaOverride = z.infer<typeof mediaOverrideSchema>;
+export type MediaOverrideFile = z.infer<typeof mediaOverrideFileSchema>;
+
+// Top-level values that would give every video of a folder the same identity
+const SINGLE_VIDEO_FIELDS = ["tmdbId", "season", "episode"] as const;
+
+/**
+ * Load the override file of a folder
+ * Returns null when there is none. A file that is not valid JSON or does
+ * not match the schema is ignored with a warning, and the folder is parsed
+ * as usual. Walkers call this only for folders whose listing shows the file.
+ */
+export async function loadMediaOverrideFile(
+  folderPath: string,
+  fileDeadlineMs?: number,
+): Promise<MediaOverrideFile | null> {
+  const filePath = join(folderPath, MEDIA_OVERRIDE_FILE);
+
+  let contents: string;
+  try {
+    contents = fileDeadlineMs
+      ? await withTimeout(
+          scanFs.readFile(filePath),
+          fileDeadlineMs,
+          `Read ${filePath}`,
+        )
+      : await scanFs.readFile(filePath);
+  } catch (error) {
+    if ((error as NodeJS.ErrnoException).code !== "ENOENT") {
+      logger.warn(
+        `Cannot read ${filePath}: ${error instanceof Error ? error.message : error}`,
+      );
+    }
+    return null;
+  }
+
+  let raw: unknown;
+  try {
+    raw = JSON.parse(contents);
+  } catch {
+    logger.warn(`Ignoring ${filePath}: not valid JSON`);
+    return null;
+  }
+
+  const parsed = mediaOverrideFileSchema.safeParse(raw);
+  if (!parsed.success) {
+    const issue = parsed.error.issues[0];
+    logger.warn(
+      `Ignoring ${filePath}: ${issue ? `${issue.path.join(".") || "file"}: ${issue.message}` : "invalid override file"}`,
+    );
+    return null;
+  }
+
+  logger.debug(`📌 Using ${filePath}`);
+  return parsed.data;
+}
+
+/**
+ * Drop top-level values of an override file that do not fit its folder
+ * The TMDB ID, season and episode at the top level name one video. In a
+ * folder of several videos (videoCount is null when the folder is too large
+ * to count) they are ignored with a warning, and only "files" entries set
+ * them.
+ */
+export function checkMediaOverrideFile(
+  overrideFile: MediaOverrideFile,
+  folderPath: string,
+  videoCount: number | null,
+): MediaOverrideFile {
+  if (videoCount !== null && videoCount <= 1) {
+    return overrideFile;
+  }
+
+  const ignored = SINGLE_VIDEO_FIELDS.filter(
+    (field) => overrideFile[field] !== undefined,
+  );
+  if (ignored.length === 0) {
+    return overrideFile;
+  }
+
+  logger.warn(
+    `Ignoring ${ignored.join(", ")} in ${join(folderPath, MEDIA_OVERRIDE_FILE)}: the folder holds more than one video (set them under "files" instead)`,
+  );
+  const checked = { ...overrideFile };
+  for (const field of ignored) {
+    delete checked[field];
+  }
+  return checked;
+}
+
+/**
+ * Get the values an override file sets for one video of its folder
+ * An entry under "files" wins over the top level.
+ */
+export function getMediaOverrideForFile(
+  overrideFile: MediaOverrideFile,
+  fileName: string,
+): MediaOverride {
+  // eslint-disable-next-line @typescript-eslint/no-unused-vars
+  const { mediaType: _, files, ...folderOverride } = overrideFile;
+  return { ...folderOverride, ...files?.[fileName] };
+}
+
+/**
+ * Replace parsed values with the ones an override file sets
+ */
+export function applyMediaOverrideFile(
+  extractedIds: ExtractedIds,
+  override: MediaOverride,
+): ExtractedIds {
+  const result = { ...extractedIds };
+  if (override.title !== undefined) {
+    result.title = override.title;
+    result.titleSource = "override-file";
+  }
+  if (override.year !== undefined) result.year = String(override.year);
+  if (override.tmdbId !== undefined) result.tmdbId = String(override.tmdbId);
+  if (override.season !== undefined) result.season = override.season;
+  if (override.episode !== undefined) result.episode = override.episode;
+  return result;
+}
@@ -0,0 +1,127 @@
+/**
+ * Media type changes on rescan
+ * A file first saved as a movie can later be scanned as an episode (or the
+ * other way round), after a library type change or a better AUTO guess.
+ * The row of the old type gives up the file so it is not listed twice.
+ */
+
+import prisma from "@/lib/database/prisma";
+import { logger } from "@/lib/utils";
+
+/**
+ * What happens to the row that held a reclassified file
+ * - unlink: the row is kept without a file, like a movie known only from
+ *   its extras
+ * - delete: the row is deleted; for a movie, its media item goes with it
+ */
+export type TypeChangeMode = "unlink" | "delete";
+
+const TYPE_CHANGE_MODES: readonly TypeChangeMode[] = ["unlink", "delete"];
+
+/**
+ * Get what happens to the old row of a reclassified file
+ * Read from SCANNER_TYPE_CHANGE, falling back to "unlink"
+ */
+export function getTypeChangeMode(
+  value: string | undefined = process.env.SCANNER_TYPE_CHANGE,
+): TypeChangeMode {
+  if (!value || value.trim() === "") {
+    return "unlink";
+  }
+
+  const mode = value.trim().toLowerCase() as TypeChangeMode;
+  if (!TYPE_CHANGE_MODES.includes(mode)) {
+    logger.warn(
+      `Invalid SCANNER_TYPE_CHANGE "${value}" (use unlink or delete), using unlink`,
+    );
+    return "unlink";
+  }
+
+  return mode;
+}
+
+// File fields cleared when a row gives up its file
+const NO_FILE = {
+  filePath: null,
+  fileSize: null,
+  fileModifiedAt: null,
+  missingSince: null,
+};
+
+/**
+ * Take a file away from the row of the other media type before it is saved
+ * as `mediaType`. Returns true if a row held the file.
+ *
+ * A movie's media item only has the one file, so in delete mode the whole
+ * media item is removed (with its extras and library links). An episode is
+ * deleted on its own; its show and season stay.
+ */
+export async function releaseReclassifiedFile(
+  filePath: string,
+  mediaType: "movie" | "tv",
+  mode: TypeChangeMode = getTypeChangeMode(),
+): Promise<boolean> {
+  if (mediaType === "tv") {
+    const movie = await prisma.movie.findUnique({
+      where: { filePath },
+      select: { id: true, mediaId: true, media: { select: { title: true } } },
+    });
+    if (!movie) {
+      return false;
+    }
+
+    if (mode === "delete") {
+      await prisma.media.delete({ where: { id: movie.mediaId } });
+    } else {
+      await prisma.movie.update({ where: { id: movie.id }, data: NO_FILE });
+    }
+
+    logger.warn(
+      `🔀 ${filePath} was saved as the movie "${movie.media.title}" and is now scanned as an episode - ${mode === "delete" ? "deleted the movie" : "unlinked it from the movie"}`,
+    );
+    return true;
+  }
+
+  return releaseEpisodeFile(filePath, "is now scanned as a movie", mode);
+}
+
+/**
+ * Take a file away from the episode that holds it, before it is saved as
+ * something else: a movie, or an extra of a show. Returns true if an
+ * episode held the file. `change` says what became of the file, for the
+ * log.
+ */
+export async function releaseEpisodeFile(
+  filePath: string,
+  change: string,
+  mode: TypeChangeMode = getTypeChangeMode(),
+): Promise<boolean> {
+  const episode = await prisma.episode.findUnique({
+    where: { filePath },
+    select: {
+      id: true,
+      number: true,
+      season: {
+        select: {
+          number: true,
+          tvShow: { select: { media: { select: { title: true } } } },
+        },
+      },
+    },
+  });
+  if (!episode) {
+    return false;
+  }
+
+  if (mode === "delete") {
+    await prisma.episode.delete({ where: { id: episode.id } });
+  } else {
+    await prisma.episode.update({ where: { id: episode.id }, data: NO_FILE });
+  }
+
+  const label = `${episode.season.tvShow.media.title} - S${episode.season.number}E${episode.number}`;
+  logger.warn(
+    `🔀 ${filePath} was saved as the episode ${label} and ${change} - ${mode === "delete" ? "deleted the episode" : "unlinked it from the episode"}`,
+  );
+  return true;
+}
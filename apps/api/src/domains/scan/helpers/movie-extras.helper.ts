@@ -0,0 +1,146 @@
+/**
+ * Movie extras
+ * Recognises bonus videos named with a Plex/Kodi style suffix, such as
+ * "Inception (2010)-trailer.mkv", so they are attached to their movie
+ * instead of being scanned as movies of their own, and finds the central
+ * trailer folders whose files are linked back to their movies by title
+ */
+
+import { extname } from "path";
+import { logger } from "@/lib/utils";
+
+/**
+ * Filename suffix (without the dash) to extra type
+ */
+export type ExtraSuffixMap = Map<string, string>;
+
+/**
+ * Suffixes recognised without any configuration
+ */
+export const DEFAULT_EXTRA_SUFFIXES: ReadonlyArray<[string, string]> = [
+  ["trailer", "trailer"],
+  ["behindthescenes", "behindthescenes"],
+  ["deleted", "deleted"],
+  ["featurette", "featurette"],
+  ["interview", "interview"],
+  ["scene", "scene"],
+  ["short", "short"],
+  ["clip", "clip"],
+  ["other", "other"],
+];
+
+// Built-in suffixes that are also common release group tags
+// ("Movie.2010.1080p-SCENE.mkv")
+const AMBIGUOUS_EXTRA_SUFFIXES = new Set(["scene", "short", "clip", "other"]);
+
+/**
+ * Get the extra suffixes for a scan: the defaults plus SCANNER_EXTRA_SUFFIXES,
+ * a comma-separated list of "suffix" or "suffix=type" entries
+ * ("teaser=trailer,bts=behindthescenes"). Invalid entries are skipped with
+ * a warning.
+ */
+export function getExtraSuffixes(
+  value: string | undefined = process.env.SCANNER_EXTRA_SUFFIXES,
+): ExtraSuffixMap {
+  const suffixes: ExtraSuffixMap = new Map(DEFAULT_EXTRA_SUFFIXES);
+
+  if (!value || value.trim() === "") {
+    return suffixes;
+  }
+
+  for (const rawEntry of value.split(",")) {
+    const entry = rawEntry.trim();
+    if (!entry) continue;
+
+    const [suffix = "", type = suffix] = entry
+      .split("=")
+      .map((part) => part.trim().toLowerCase());
+
+    if (!/^[a-z0-9]+$/.test(suffix) || !/^[a-z0-9]+$/.test(type)) {
+      logger.warn(
+        `Ignoring invalid SCANNER_EXTRA_SUFFIXES entry "${entry}": use letters and digits, as "suffix" or "suffix=type"`,
+      );
+      continue;
+    }
+
+    suffixes.set(suffix, type);
+  }
+
+  return suffixes;
+}
+
+/**
+ * Match a file name against the extra suffixes
+ * The suffix must come right before the extension, directly after a dash
+ * ("Movie (2010)-trailer.mkv"); "Spider-Man.mkv" stays a main video
+ * because "man" is not a suffix. A suffix that is also a release group tag
+ * (scene, short, clip, other) only makes an extra next to a main file of the
+ * same name ("Movie (2010)-scene.mkv" beside "Movie (2010).mkv").
+ *
+ * @param folderFileNames - Names of the files in the same folder, or null
+ * when they are not known
+ * @returns The extra type and the file name with the suffix removed, or
+ * null for a main video
+ */
+export function matchExtraSuffix(
+  fileName: string,
+  suffixes: ExtraSuffixMap,
+  folderFileNames: ReadonlyArray<string> | null,
+): { extraType: string; baseName: string } | null {
+  const match = fileName.match(/^(.+)-([A-Za-z0-9]+)(\.[^.]+)$/);
+  if (!match || !match[1] || !match[2] || !match[3]) {
+    return null;
+  }
+
+  const suffix = match[2].toLowerCase();
+  const extraType = suffixes.get(suffix);
+  if (!extraType) {
+    return null;
+  }
+
+  const mainName = match[1];
+  if (
+    AMBIGUOUS_EXTRA_SUFFIXES.has(suffix) &&
+    !folderFileNames?.some(
+      (name) =>
+        name !== fileName && name.slice(0, -extname(name).length) === mainName,
+    )
+  ) {
+    return null;
+  }
+
+  return { extraType, baseName: `${match[1]}${match[3]}` };
+}
+
+/**
+ * Get the names of central trailer folders: folders directly under a movie
+ * library root that hold trailers for many movies ("Trailers/Inception
+ * (2010).mkv"), as written by trailer downloaders. Read from
+ * SCANNER_TRAILER_FOLDERS, a comma-separated list; none by default.
+ * Names are compared case-insensitively and returned lowercased.
+ */
+export function getTrailerFolders(
+  value: string | undefined = process.env.SCANNER_TRAILER_FOLDERS,
+): Set<string> {
+  const folders = new Set<string>();
+
+  if (!value || value.trim() === "") {
+    return folders;
+  }
+
+  for (const rawName of value.split(",")) {
+    const name = rawName.trim();
+    if (!name) continue;
+
+    if (name.includes("/") || name.includes("\\")) {
+      logger.warn(
+        `Ignoring invalid SCANNER_TRAILER_FOLDERS entry "${name}": use a folder name, not a path`,
+      );
+      continue;
+    }
+
+    folders.add(name.toLowerCase());
+  }
+
+  return folders;
+}
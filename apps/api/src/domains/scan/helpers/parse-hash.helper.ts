@@ -0,0 +1,108 @@
+/**
+ * Parse hashes
+ * Movie and episode rows store a hash of what the TMDB lookup was based on
+ * (parsed title, year and media type). When a file parses the same way on
+ * the next scan, the TMDB match it got last time is reused instead of
+ * searching TMDB again.
+ */
+
+import { createHash } from "crypto";
+import prisma from "@/lib/database/prisma";
+import { logger, mapContainerToHostPath } from "@/lib/utils";
+import type { ExtractedIds } from "@/lib/utils";
+import type { MediaEntry } from "../scan.types";
+
+/**
+ * Hash the fields of a parsed name that decide its TMDB match
+ */
+export function getParseHash(
+  extractedIds: ExtractedIds,
+  mediaType: "movie" | "tv",
+): string {
+  return createHash("sha256")
+    .update(
+      JSON.stringify([
+        extractedIds.title ?? "",
+        extractedIds.year ?? null,
+        mediaType,
+      ]),
+    )
+    .digest("hex");
+}
+
+/**
+ * Give files that still parse the way they did when they were saved the
+ * TMDB ID they were matched to, so no search is needed for them. Only files
+ * without a TMDB ID of their own are looked up; extras are saved against
+ * their movie and have no row of their own to compare. Returns how many
+ * files were matched.
+ */
+export async function applyUnchangedMatches(
+  mediaEntries: MediaEntry[],
+  mediaType: "movie" | "tv",
+  originalPath?: string,
+): Promise<number> {
+  const entriesByPath = new Map<string, MediaEntry>();
+  for (const entry of mediaEntries) {
+    if (entry.isDirectory || entry.extractedIds.tmdbId) continue;
+    if (mediaType === "movie" && entry.isExtra) continue;
+    entriesByPath.set(mapContainerToHostPath(entry.path, originalPath), entry);
+  }
+
+  if (entriesByPath.size === 0) {
+    return 0;
+  }
+
+  const filePaths = [...entriesByPath.keys()];
+  const where = { filePath: { in: filePaths }, parseHash: { not: null } };
+  const mediaSelect = {
+    select: {
+      externalIds: {
+        where: { source: "TMDB" as const },
+        select: { externalId: true },
+      },
+    },
+  };
+
+  const rows =
+    mediaType === "movie"
+      ? await prisma.movie.findMany({
+          where,
+          select: { filePath: true, parseHash: true, media: mediaSelect },
+        })
+      : (
+          await prisma.episode.findMany({
+            where,
+            select: {
+              filePath: true,
+              parseHash: true,
+              season: {
+                select: { tvShow: { select: { media: mediaSelect } } },
+              },
+            },
+          })
+        ).map(({ season, ...episode }) => ({
+          ...episode,
+          media: season.tvShow.media,
+        }));
+
+  let matched = 0;
+  for (const row of rows) {
+    const entry = row.filePath ? entriesByPath.get(row.filePath) : undefined;
+    const tmdbId = row.media.externalIds[0]?.externalId;
+    if (!entry || !tmdbId) continue;
+
+    if (row.parseHash === getParseHash(entry.extractedIds, mediaType)) {
+      entry.extractedIds.tmdbId = tmdbId;
+      matched++;
+    }
+  }
+
+  if (matched > 0) {
+    logger.info(
+      `⏭️  ${matched} file(s) parse as before, reusing their TMDB match (use rescan=true to search again)`,
+    );
+  }
+
+  return matched;
+}
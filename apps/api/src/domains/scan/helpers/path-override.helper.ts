@@ -0,0 +1,122 @@
+/**
+ * Path overrides
+ * Manual title/year/season/episode values for files the parser gets wrong,
+ * keyed by file or folder path per library
+ */
+
+import { dirname, resolve } from "path";
+import prisma from "@/lib/database/prisma";
+import { logger, mapContainerToHostPath } from "@/lib/utils";
+import type { ExtractedIds } from "@/lib/utils";
+
+/**
+ * Values an override forces in place of filename parsing
+ */
+export interface PathOverrideValues {
+  title: string | null;
+  year: number | null;
+  season: number | null;
+  episode: number | null;
+}
+
+/**
+ * Overrides for one library, keyed by normalized path
+ */
+export type PathOverrideIndex = Map<string, PathOverrideValues>;
+
+/**
+ * Looks up the override for a scanned file path, if any
+ */
+export type PathOverrideResolver = (
+  filePath: string,
+) => PathOverrideValues | undefined;
+
+/**
+ * Normalize a path for override matching: absolute, forward slashes,
+ * no trailing slash
+ */
+export function normalizeOverridePath(path: string): string {
+  const normalized = resolve(path.trim()).replace(/\\/g, "/");
+  return normalized.length > 1 ? normalized.replace(/\/+$/, "") : normalized;
+}
+
+/**
+ * Load a library's overrides for a scan
+ */
+export async function loadPathOverrides(
+  libraryId: string,
+): Promise<PathOverrideIndex> {
+  const overrides = await prisma.pathOverride.findMany({
+    where: { libraryId },
+  });
+
+  const index: PathOverrideIndex = new Map();
+  for (const override of overrides) {
+    index.set(normalizeOverridePath(override.path), {
+      title: override.title,
+      year: override.year,
+      season: override.season,
+      episode: override.episode,
+    });
+  }
+
+  if (index.size > 0) {
+    logger.info(`📌 Loaded ${index.size} path override(s) for this library`);
+  }
+
+  return index;
+}
+
+/**
+ * Create a resolver that matches scanned paths against overrides.
+ * Paths are compared as stored on media rows (host paths for Docker), and
+ * a file's own override wins over one on any of its folders, nearest first.
+ *
+ * @param index - Overrides loaded with loadPathOverrides
+ * @param originalPath - Original library path when scanning a mapped path
+ */
+export function createPathOverrideResolver(
+  index: PathOverrideIndex,
+  originalPath?: string,
+): PathOverrideResolver | undefined {
+  if (index.size === 0) {
+    return undefined;
+  }
+
+  return (filePath: string) => {
+    let current = normalizeOverridePath(
+      mapContainerToHostPath(filePath, originalPath),
+    );
+
+    while (true) {
+      const override = index.get(current);
+      if (override) {
+        return override;
+      }
+
+      const parent = dirname(current);
+      if (parent === current) {
+        return undefined;
+      }
+      current = parent;
+    }
+  };
+}
+
+/**
+ * Replace parsed values with the ones an override sets
+ */
+export function applyPathOverride(
+  extractedIds: ExtractedIds,
+  override: PathOverrideValues,
+): ExtractedIds {
+  const result = { ...extractedIds };
+  if (override.title !== null) {
+    result.title = override.title;
+    result.titleSource = "override";
+  }
+  if (override.year !== null) result.year = String(override.year);
+  if (override.season !== null) result.season = override.season;
+  if (override.episode !== null) result.episode = override.episode;
+  return result;
+}
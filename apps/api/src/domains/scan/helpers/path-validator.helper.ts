@@ -3,9 +3,19 @@
  * Validates directory depth and structure based on media type
  */
 
-import { logger } from "@/lib/utils";
-import { relative } from "path";
-import { getDefaultVideoExtensions } from "./file-filter.helper";
+import { logger, mapHostToContainerPath } from "@/lib/utils";
+import { basename, isAbsolute, relative, resolve, sep } from "path";
+import {
+  getDefaultVideoExtensions,
+  getEntrySkipReason,
+} from "./file-filter.helper";
+import { mapWithConcurrency } from "./rate-limiter.helper";
+import { scanFs } from "./scan-fs.helper";
+import type { SkipReason } from "./scan-skips.helper";
+import { OperationTimeoutError, withTimeout } from "./timeout-helper";
+
+// Listed files statted at a time
+const LISTED_FILE_CHECK_CONCURRENCY = 8;
 
 export interface PathValidationOptions {
   mediaType: "movie" | "tv";
@@ -250,6 +260,161 @@ export function isDepthValid(
   return depth <= effectiveMaxDepth;
 }
 
+/**
+ * Split a file path into segments relative to the scan root
+ * Returns null when the file does not resolve under the root (e.g. a different
+ * drive on Windows, or a symlink target elsewhere) so callers never derive
+ * depth or folder names from ".." segments
+ */
+function getRelativePathParts(
+  rootPath: string,
+  filePath: string,
+): string[] | null {
+  const relativePath = relative(rootPath, filePath);
+
+  if (
+    !relativePath ||
+    isAbsolute(relativePath) ||
+    relativePath === ".." ||
+    relativePath.startsWith(`..${sep}`)
+  ) {
+    return null;
+  }
+
+  return relativePath.split(/[\\/]/).filter(Boolean);
+}
+
+/**
+ * Resolve a file listed for a scan, relative to the scan root or absolute
+ * Returns null when it does not lie inside the root
+ */
+export function resolveListedFile(
+  rootPath: string,
+  filePath: string,
+): string | null {
+  const resolved = resolve(rootPath, filePath);
+  return getRelativePathParts(rootPath, resolved) ? resolved : null;
+}
+
+/**
+ * Check the files listed for a scan. Each must lie inside the root, exist,
+ * not be a file the scanner skips, and have one of the scan's extensions.
+ * Files are statted a few at a time, each under the scanner's per-file
+ * deadline (SCANNER_FILE_DEADLINE_SECONDS), so a hung mount rejects the
+ * file instead of holding the request.
+ * Returns the valid files as absolute paths and why the others were rejected.
+ */
+export async function checkListedFiles(
+  rootPath: string,
+  files: string[],
+  fileExtensions: string[] | undefined,
+  fileDeadlineMs: number,
+): Promise<{
+  files: string[];
+  rejectedFiles: Array<{ path: string; reason: string }>;
+}> {
+  const extensions = (
+    fileExtensions && fileExtensions.length > 0
+      ? fileExtensions
+      : getDefaultVideoExtensions()
+  ).map((ext) => ext.toLowerCase());
+
+  const checkFile = async (file: string) => {
+    const resolved = resolveListedFile(
+      rootPath,
+      isAbsolute(file) ? mapHostToContainerPath(file) : file,
+    );
+    if (!resolved) {
+      return { resolved, reason: "Not inside the scan path" };
+    }
+
+    try {
+      const stats = await withTimeout(
+        scanFs.stat(resolved),
+        fileDeadlineMs,
+        `Stat ${resolved}`,
+      );
+      const skipReason = getEntrySkipReason(basename(resolved), false);
+      if (!stats.isFile()) {
+        return { resolved, reason: "Not a file" };
+      }
+      if (skipReason) {
+        return { resolved, reason: `Left out by the scanner (${skipReason})` };
+      }
+      if (!extensions.some((ext) => resolved.toLowerCase().endsWith(ext))) {
+        return { resolved, reason: "Not one of the scan's file extensions" };
+      }
+      return { resolved, reason: null };
+    } catch (error) {
+      return {
+        resolved,
+        reason:
+          error instanceof OperationTimeoutError
+            ? "The drive did not answer in time"
+            : "Does not exist or is not accessible",
+      };
+    }
+  };
+
+  const checked = await mapWithConcurrency(
+    files,
+    LISTED_FILE_CHECK_CONCURRENCY,
+    checkFile,
+  );
+
+  const valid = new Set<string>();
+  const rejectedFiles: Array<{ path: string; reason: string }> = [];
+  checked.forEach(({ resolved, reason }, index) => {
+    if (reason) {
+      rejectedFiles.push({ path: files[index]!, reason });
+    } else {
+      valid.add(resolved!);
+    }
+  });
+
+  return { files: Array.from(valid), rejectedFiles };
+}
+
+/**
+ * Get the top-level library folder a subpath lies in, which is the unit the
+ * batch scanner works in ("Breaking Bad/Season 5" scans "Breaking Bad")
+ * Returns null for a subpath that is the root itself or leaves it
+ */
+export function getSubpathFolder(
+  rootPath: string,
+  subpath: string,
+): string | null {
+  const parts = getRelativePathParts(rootPath, resolve(rootPath, subpath));
+  return parts?.[0] ?? null;
+}
+
+/**
+ * Get why the walker would never reach a subpath: a folder on the way to
+ * it is one the scanner skips (hidden, system or extras)
+ * Returns null when the walker reaches it, or when it leaves the root
+ */
+export function getSubpathSkipReason(
+  rootPath: string,
+  subpath: string,
+  options: { includeExtras?: boolean } = {},
+): SkipReason | null {
+  const parts = getRelativePathParts(rootPath, resolve(rootPath, subpath));
+  for (const part of parts ?? []) {
+    const reason = getEntrySkipReason(part, true, options);
+    if (reason) {
+      return reason;
+    }
+  }
+  return null;
+}
+
+/**
+ * Reason reported for files that resolve outside the scan root
+ */
+function outsideRootReason(rootPath: string, filePath: string): string {
+  return `File resolves outside the scan root (${filePath} is not under ${rootPath})`;
+}
+
 /**
  * Validate movie path structure
  * Valid patterns:
@@ -263,8 +428,16 @@ export function validateMoviePath(
   rootPath: string,
   filePath: string,
 ): { valid: boolean; reason?: string; relativeDepth: number } {
-  const relativePath = relative(rootPath, filePath);
-  const pathParts = relativePath.split("/").filter(Boolean);
+  const pathParts = getRelativePathParts(rootPath, filePath);
+
+  if (!pathParts) {
+    return {
+      valid: false,
+      reason: outsideRootReason(rootPath, filePath),
+      relativeDepth: 0,
+    };
+  }
+
   const relativeDepth = pathParts.length - 1; // Subtract 1 because the file itself doesn't count as depth
 
   const maxDepth = DEPTH_CONSTRAINTS.movie.max;
@@ -297,6 +470,7 @@ export function validateTvShowPath(
     season?: number;
     episode?: number;
     title?: string;
+    isExtra?: boolean;
   },
 ): {
   valid: boolean;
@@ -304,8 +478,16 @@ export function validateTvShowPath(
   relativeDepth: number;
   showFolder?: string;
 } {
-  const relativePath = relative(rootPath, filePath);
-  const pathParts = relativePath.split("/").filter(Boolean);
+  const pathParts = getRelativePathParts(rootPath, filePath);
+
+  if (!pathParts) {
+    return {
+      valid: false,
+      reason: outsideRootReason(rootPath, filePath),
+      relativeDepth: 0,
+    };
+  }
+
   const relativeDepth = pathParts.length - 1; // Subtract 1 for the file itself
 
   const maxDepth = DEPTH_CONSTRAINTS.tv.max;
@@ -332,7 +514,7 @@ export function validateTvShowPath(
   // Check if file has episode information
   const hasEpisodeInfo = !!(extractedIds.season && extractedIds.episode);
 
-  if (!hasEpisodeInfo) {
+  if (!hasEpisodeInfo && !extractedIds.isExtra) {
     return {
       valid: false,
       reason:
@@ -366,6 +548,7 @@ export function validateMediaPath(
     season?: number;
     episode?: number;
     title?: string;
+    isExtra?: boolean;
   },
 ): { valid: boolean; reason?: string; metadata?: any } {
   if (mediaType === "movie") {
@@ -1,5 +1,5 @@
 /**
- * Rate limiter for TMDB API calls
+ * Rate limiter for TMDB API calls, and a bounded map for file system calls
  * TMDB allows ~40 requests per 10 seconds
  */
 
@@ -81,3 +81,27 @@ export function createRateLimiter(
     },
   };
 }
+
+/**
+ * Run fn over items with at most `concurrency` calls in flight
+ */
+export async function mapWithConcurrency<T, R>(
+  items: T[],
+  concurrency: number,
+  fn: (item: T) => Promise<R>,
+): Promise<R[]> {
+  const results: R[] = new Array(items.length);
+  let next = 0;
+
+  const worker = async () => {
+    while (next < items.length) {
+      const index = next++;
+      results[index] = await fn(items[index]!);
+    }
+  };
+
+  await Promise.all(
+    Array.from({ length: Math.min(concurrency, items.length) }, worker),
+  );
+  return results;
+}
@@ -0,0 +1,190 @@
+/**
+ * Live scan activity tracking
+ * Records what each running scan job is doing right now, so a scan that
+ * looks stalled can be traced to the folder or file it is waiting on, and
+ * how fast it is going
+ */
+
+export type ScanActivityStage = "walking" | "fetching-metadata" | "saving";
+
+export interface ScanActivity {
+  stage: ScanActivityStage;
+  item: string; // Folder being walked/matched, or file being saved
+  since: Date;
+}
+
+// Keyed by scan job ID; only jobs running in this process have an entry
+const activities = new Map<string, ScanActivity>();
+
+/**
+ * Rates are measured over this much recent history, so they follow speed
+ * changes instead of averaging over the whole scan
+ */
+export const THROUGHPUT_WINDOW_MS = 5 * 60 * 1000;
+
+// Samples kept per job; older ones are overwritten
+const MAX_THROUGHPUT_SAMPLES = 256;
+
+// Running totals at a point in time
+interface ThroughputSample {
+  at: number;
+  folders: number;
+  files: number;
+}
+
+interface ThroughputBuffer {
+  samples: ThroughputSample[]; // Ring buffer of MAX_THROUGHPUT_SAMPLES
+  next: number; // Slot the next sample goes in
+  folders: number;
+  files: number;
+}
+
+export interface ScanThroughput {
+  filesPerSecond: number;
+  foldersPerSecond: number;
+  // Null when the remaining work is unknown or nothing finished recently
+  estimatedSecondsRemaining: number | null;
+  estimatedCompletionAt: Date | null;
+}
+
+const throughput = new Map<string, ThroughputBuffer>();
+
+/**
+ * Record the stage a scan job just entered
+ */
+export function setScanActivity(
+  scanJobId: string,
+  stage: ScanActivityStage,
+  item: string,
+) {
+  activities.set(scanJobId, { stage, item, since: new Date() });
+}
+
+/**
+ * Forget a scan job's activity once it stops running
+ */
+export function clearScanActivity(scanJobId: string) {
+  activities.delete(scanJobId);
+  throughput.delete(scanJobId);
+}
+
+/**
+ * Current activity of a scan job, or null if it is not running here
+ */
+export function getScanActivity(scanJobId: string) {
+  const activity = activities.get(scanJobId);
+  if (!activity) {
+    return null;
+  }
+
+  return {
+    ...activity,
+    elapsedMs: Date.now() - activity.since.getTime(),
+  };
+}
+
+/**
+ * Start measuring a scan job's rate as it starts (or resumes) processing
+ * The zero sample taken here is the baseline, so the time until the first
+ * folder finishes counts against the rate.
+ * A paused job is cleared when it stops, so a resumed run starts measuring
+ * afresh instead of counting the pause against its rate
+ */
+export function startScanThroughput(
+  scanJobId: string,
+  now: number = Date.now(),
+): void {
+  throughput.set(scanJobId, {
+    samples: [{ at: now, folders: 0, files: 0 }],
+    next: 1,
+    folders: 0,
+    files: 0,
+  });
+}
+
+/**
+ * Record work a scan job just finished
+ */
+export function recordScanThroughput(
+  scanJobId: string,
+  folders: number,
+  files: number,
+  now: number = Date.now(),
+) {
+  if (!throughput.has(scanJobId)) {
+    startScanThroughput(scanJobId, now);
+  }
+  const buffer = throughput.get(scanJobId)!;
+
+  buffer.folders += folders;
+  buffer.files += files;
+  buffer.samples[buffer.next % MAX_THROUGHPUT_SAMPLES] = {
+    at: now,
+    folders: buffer.folders,
+    files: buffer.files,
+  };
+  buffer.next++;
+}
+
+/**
+ * Files and folders per second over the recent window, with an estimated
+ * finish when the number of folders left is known
+ * Returns null if the job is not running here or has no recent samples
+ *
+ * @param remainingFolders - Folders still to process, if known
+ */
+export function getScanThroughput(
+  scanJobId: string,
+  remainingFolders?: number,
+  now: number = Date.now(),
+): ScanThroughput | null {
+  const buffer = throughput.get(scanJobId);
+  if (!buffer) {
+    return null;
+  }
+
+  // Measured from the last sample taken before the window, so folders that
+  // take longer than the window still give a rate. Without one (a young
+  // job), from the oldest sample kept. The newest is always the totals.
+  const windowStart = now - THROUGHPUT_WINDOW_MS;
+  let baseline: ThroughputSample | undefined;
+  let oldest: ThroughputSample | undefined;
+  for (const sample of buffer.samples) {
+    if (sample.at <= windowStart && (!baseline || sample.at > baseline.at)) {
+      baseline = sample;
+    }
+    if (!oldest || sample.at < oldest.at) {
+      oldest = sample;
+    }
+  }
+  baseline ??= oldest;
+  if (!baseline) {
+    return null;
+  }
+
+  // Measured up to now, so a stall brings the rate down instead of freezing it
+  const elapsedSeconds = (now - baseline.at) / 1000;
+  if (elapsedSeconds <= 0) {
+    return null;
+  }
+
+  const filesPerSecond = (buffer.files - baseline.files) / elapsedSeconds;
+  const foldersPerSecond = (buffer.folders - baseline.folders) / elapsedSeconds;
+
+  let estimatedSecondsRemaining: number | null = null;
+  if (remainingFolders !== undefined && foldersPerSecond > 0) {
+    estimatedSecondsRemaining = Math.ceil(
+      Math.max(0, remainingFolders) / foldersPerSecond,
+    );
+  }
+
+  return {
+    filesPerSecond: Math.round(filesPerSecond * 100) / 100,
+    foldersPerSecond: Math.round(foldersPerSecond * 100) / 100,
+    estimatedSecondsRemaining,
+    estimatedCompletionAt:
+      estimatedSecondsRemaining !== null
+        ? new Date(now + estimatedSecondsRemaining * 1000)
+        : null,
+  };
+}
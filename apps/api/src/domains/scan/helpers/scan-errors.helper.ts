@@ -0,0 +1,171 @@
+/**
+ * Scan error aggregation
+ * Groups scan failures by directory so a broken subtree shows up in the
+ * scan summary instead of being buried in thousands of log lines
+ */
+
+import { dirname } from "path";
+import { logger } from "@/lib/utils";
+import prisma from "@/lib/database/prisma";
+
+/**
+ * Number of directories reported in scan summaries
+ */
+export const TOP_ERROR_DIRECTORIES = 10;
+
+/**
+ * Directories tracked at once. When full, the directory with the fewest
+ * errors is dropped to make room, so memory stays bounded on huge scans
+ * while the directories with the most failures are kept.
+ */
+export const MAX_TRACKED_ERROR_DIRECTORIES = 500;
+
+// Longest sample error kept per directory
+const MAX_SAMPLE_ERROR_LENGTH = 500;
+
+export interface DirectoryErrorSummary {
+  directory: string;
+  count: number;
+  sampleError: string; // First error recorded for the directory
+}
+
+export interface ScanErrorCollector {
+  /**
+   * Record a failure. Files count against their parent directory, and a
+   * directory that failed as a whole counts against itself.
+   */
+  record: (path: string, error: unknown, isDirectory?: boolean) => void;
+  getTotal: () => number;
+  getTopDirectories: (limit?: number) => DirectoryErrorSummary[];
+}
+
+/**
+ * Creates a collector for one scan
+ *
+ * @param options.initial - Directories carried over from an earlier run of the same scan job
+ * @param options.initialTotal - Error count carried over with them
+ * @param options.maxDirectories - Directories tracked before the smallest is evicted
+ */
+export function createScanErrorCollector(
+  options: {
+    initial?: DirectoryErrorSummary[];
+    initialTotal?: number;
+    maxDirectories?: number;
+  } = {},
+): ScanErrorCollector {
+  const {
+    initial = [],
+    initialTotal = 0,
+    maxDirectories = MAX_TRACKED_ERROR_DIRECTORIES,
+  } = options;
+  const directories = new Map<string, DirectoryErrorSummary>();
+  let total = initialTotal;
+
+  for (const summary of initial.slice(0, maxDirectories)) {
+    directories.set(summary.directory, { ...summary });
+  }
+
+  // Drop the directory with the fewest errors; on a tie the one tracked
+  // longest goes first (Map keeps insertion order)
+  function evictSmallest(): void {
+    let smallest: DirectoryErrorSummary | undefined;
+    for (const summary of directories.values()) {
+      if (!smallest || summary.count < smallest.count) {
+        smallest = summary;
+      }
+    }
+    if (smallest) {
+      directories.delete(smallest.directory);
+    }
+  }
+
+  return {
+    record(path, error, isDirectory = false) {
+      const directory = isDirectory ? path : dirname(path);
+      total++;
+
+      const existing = directories.get(directory);
+      if (existing) {
+        existing.count++;
+        return;
+      }
+
+      if (directories.size >= maxDirectories) {
+        evictSmallest();
+      }
+
+      const message = error instanceof Error ? error.message : String(error);
+      directories.set(directory, {
+        directory,
+        count: 1,
+        sampleError: message.slice(0, MAX_SAMPLE_ERROR_LENGTH),
+      });
+    },
+
+    getTotal() {
+      return total;
+    },
+
+    getTopDirectories(limit = TOP_ERROR_DIRECTORIES) {
+      return Array.from(directories.values())
+        .sort((a, b) => b.count - a.count)
+        .slice(0, limit)
+        .map((summary) => ({ ...summary }));
+    },
+  };
+}
+
+/**
+ * Read the directory summary stored on a scan job
+ * Returns an empty list for jobs without one or with unreadable JSON
+ */
+export function parseErrorDirectories(
+  errorDirectories: string | null,
+): DirectoryErrorSummary[] {
+  if (!errorDirectories) {
+    return [];
+  }
+
+  try {
+    const parsed: unknown = JSON.parse(errorDirectories);
+    return Array.isArray(parsed) ? (parsed as DirectoryErrorSummary[]) : [];
+  } catch (error) {
+    logger.warn(
+      `Ignoring unreadable scan error summary: ${error instanceof Error ? error.message : error}`,
+    );
+    return [];
+  }
+}
+
+/**
+ * Store a batch scan's error summary on its job for the status endpoint
+ */
+export async function saveScanJobErrors(
+  scanJobId: string,
+  collector: ScanErrorCollector,
+): Promise<void> {
+  await prisma.scanJob.update({
+    where: { id: scanJobId },
+    data: {
+      errorCount: collector.getTotal(),
+      errorDirectories: JSON.stringify(collector.getTopDirectories()),
+    },
+  });
+}
+
+/**
+ * Log where a scan's errors came from
+ */
+export function logErrorDirectories(collector: ScanErrorCollector): void {
+  const total = collector.getTotal();
+  if (total === 0) {
+    return;
+  }
+
+  logger.warn(`⚠️  ${total} error(s) during scan. Most affected directories:`);
+  for (const summary of collector.getTopDirectories()) {
+    logger.warn(
+      `   ${summary.count} × ${summary.directory} (e.g. ${summary.sampleError})`,
+    );
+  }
+}
@@ -0,0 +1,61 @@
+/**
+ * File system access of the scanner
+ * The walker and the helpers it calls read folders and files through
+ * scanFs instead of fs/promises, so tests can walk an in-memory tree by
+ * swapping the implementation with setScanFileSystem.
+ */
+
+import { opendir, readFile, stat } from "fs/promises";
+
+/**
+ * An entry of a folder, as opendir yields it
+ */
+export interface ScanDirEntry {
+  name: string;
+  isDirectory(): boolean;
+  isFile(): boolean;
+}
+
+/**
+ * The details of a file or folder the scanner reads
+ */
+export interface ScanStats {
+  size: number;
+  mtime: Date;
+  mtimeMs: number;
+  isDirectory(): boolean;
+  isFile(): boolean;
+}
+
+export interface ScanFileSystem {
+  opendir(
+    path: string,
+    options?: { bufferSize?: number },
+  ): Promise<AsyncIterable<ScanDirEntry>>;
+  stat(path: string): Promise<ScanStats>;
+  readFile(path: string): Promise<string>; // UTF-8
+}
+
+const nodeFileSystem: ScanFileSystem = {
+  opendir: (path, options) => opendir(path, options),
+  stat: (path) => stat(path),
+  readFile: (path) => readFile(path, "utf8"),
+};
+
+let current: ScanFileSystem = nodeFileSystem;
+
+/**
+ * The file system scans read from
+ */
+export const scanFs: ScanFileSystem = {
+  opendir: (path, options) => current.opendir(path, options),
+  stat: (path) => current.stat(path),
+  readFile: (path) => current.readFile(path),
+};
+
+/**
+ * Replace the file system scans read from; null restores the real one
+ */
+export function setScanFileSystem(fileSystem: ScanFileSystem | null): void {
+  current = fileSystem ?? nodeFileSystem;
+}
@@ -6,6 +6,29 @@
 import { logger } from "@/lib/utils";
 import prisma from "@/lib/database/prisma";
 import { ScanJobStatus } from "@/lib/database";
+import { getScanActivity, getScanThroughput } from "./scan-activity.helper";
+import { parseErrorDirectories } from "./scan-errors.helper";
+
+/**
+ * Scan job statuses after which a job will not make further progress
+ */
+const TERMINAL_SCAN_JOB_STATUSES: ScanJobStatus[] = [
+  ScanJobStatus.COMPLETED,
+  ScanJobStatus.FAILED,
+];
+
+/**
+ * Error recorded on jobs interrupted by a server restart
+ * Startup re-enqueues FAILED jobs carrying this message when resuming is enabled
+ */
+export const SERVICE_RESTARTED_ERROR = "Service restarted";
+
+/**
+ * Check whether a scan job status is terminal (completed or failed)
+ */
+export function isTerminalScanJobStatus(status: ScanJobStatus): boolean {
+  return TERMINAL_SCAN_JOB_STATUSES.includes(status);
+}
 
 /**
  * Mark stale scan jobs as FAILED
@@ -124,7 +147,98 @@ export async function getScanJobStatus(scanJobId: string) {
       startedAt: job.startedAt,
       lastBatchAt: job.lastBatchAt,
       completedAt: job.completedAt,
+      resumedAfterRestartAt: job.resumedAfterRestartAt,
     },
+    resumedAfterRestart: job.resumedAfterRestartAt !== null,
+    requestedBy: {
+      origin: job.origin,
+      userAgent: job.userAgent,
+      remoteAddress: job.remoteAddress,
+    },
+    // What the scan is working on right now (null unless running in this process)
+    activity: getScanActivity(job.id),
+    // Recent rate and estimated finish (null unless running in this process)
+    throughput: getScanThroughput(
+      job.id,
+      job.totalFolders - job.processedCount - job.failedCount,
+    ),
     error: job.errorMessage,
+    errorCount: job.errorCount,
+    errorDirectories: parseErrorDirectories(job.errorDirectories),
   };
 }
+
+/**
+ * List scan jobs, newest first, with a summary of each
+ * Returns one page of jobs and the total count
+ */
+export async function listScanJobs(options: {
+  libraryId?: string;
+  skip: number;
+  take: number;
+}) {
+  const where = options.libraryId ? { libraryId: options.libraryId } : {};
+
+  const [total, jobs] = await Promise.all([
+    prisma.scanJob.count({ where }),
+    prisma.scanJob.findMany({
+      where,
+      orderBy: { createdAt: "desc" },
+      skip: options.skip,
+      take: options.take,
+      include: {
+        library: { select: { id: true, name: true } },
+        _count: { select: { scanAdditions: true } },
+      },
+    }),
+  ]);
+
+  const items = jobs.map((job) => ({
+    id: job.id,
+    status: job.status,
+    library: job.library,
+    scanPath: job.scanPath,
+    mediaType: job.mediaType,
+    requestedBy: {
+      origin: job.origin,
+      userAgent: job.userAgent,
+      remoteAddress: job.remoteAddress,
+    },
+    counts: {
+      totalFolders: job.totalFolders,
+      processedFolders: job.processedCount,
+      failedFolders: job.failedCount,
+      itemsSaved: job.totalItemsSaved,
+      itemsAdded: job._count.scanAdditions,
+      errors: job.errorCount,
+    },
+    timestamps: {
+      createdAt: job.createdAt,
+      startedAt: job.startedAt,
+      completedAt: job.completedAt,
+    },
+    // Null until the job has finished
+    durationMs:
+      job.startedAt && job.completedAt
+        ? job.completedAt.getTime() - job.startedAt.getTime()
+        : null,
+    error: job.errorMessage,
+  }));
+
+  return { items, total };
+}
+
+/**
+ * Find the in-progress job scanning a library path, if any
+ */
+export async function findInProgressScanJobId(
+  scanPath: string,
+): Promise<string | null> {
+  const job = await prisma.scanJob.findFirst({
+    where: { scanPath, status: ScanJobStatus.IN_PROGRESS },
+    orderBy: { createdAt: "desc" },
+    select: { id: true },
+  });
+
+  return job?.id ?? null;
+}
@@ -0,0 +1,119 @@
+/**
+ * Scan safety limits
+ * Bounds runaway scans caused by pointing a library at the wrong directory,
+ * and single files that hang on a failing drive
+ */
+
+import { logger } from "@/lib/utils";
+import prisma from "@/lib/database/prisma";
+import { ScanJobStatus } from "@/lib/database";
+
+/**
+ * Default maximum number of media files collected by a single scan
+ * High enough for very large libraries, low enough to stop a whole-disk walk
+ */
+export const DEFAULT_MAX_FILES_PER_SCAN = 200000;
+
+/**
+ * Get the maximum number of media files a single scan may collect
+ * Read from SCANNER_MAX_FILES_PER_SCAN, falling back to the default
+ */
+export function getMaxFilesPerScan(
+  value: string | undefined = process.env.SCANNER_MAX_FILES_PER_SCAN,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_MAX_FILES_PER_SCAN;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isInteger(parsed) || parsed <= 0) {
+    logger.warn(
+      `Invalid SCANNER_MAX_FILES_PER_SCAN "${value}", using default of ${DEFAULT_MAX_FILES_PER_SCAN}`,
+    );
+    return DEFAULT_MAX_FILES_PER_SCAN;
+  }
+
+  return parsed;
+}
+
+/**
+ * Default time allowed for processing a single file, in seconds
+ */
+export const DEFAULT_FILE_DEADLINE_SECONDS = 300;
+
+/**
+ * Get the time allowed for processing a single file, in milliseconds
+ * Read from SCANNER_FILE_DEADLINE_SECONDS, falling back to the default
+ */
+export function getFileDeadlineMs(
+  value: string | undefined = process.env.SCANNER_FILE_DEADLINE_SECONDS,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_FILE_DEADLINE_SECONDS * 1000;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isFinite(parsed) || parsed <= 0) {
+    logger.warn(
+      `Invalid SCANNER_FILE_DEADLINE_SECONDS "${value}", using default of ${DEFAULT_FILE_DEADLINE_SECONDS}`,
+    );
+    return DEFAULT_FILE_DEADLINE_SECONDS * 1000;
+  }
+
+  return parsed * 1000;
+}
+
+/**
+ * Default time a scan request with wait set is held open, in seconds
+ */
+export const DEFAULT_SYNC_SCAN_BUDGET_SECONDS = 60;
+
+/**
+ * Get how long a scan request with wait set is held open, in milliseconds
+ * Read from SCANNER_SYNC_BUDGET_SECONDS, falling back to the default
+ */
+export function getSyncScanBudgetMs(
+  value: string | undefined = process.env.SCANNER_SYNC_BUDGET_SECONDS,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_SYNC_SCAN_BUDGET_SECONDS * 1000;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isFinite(parsed) || parsed <= 0) {
+    logger.warn(
+      `Invalid SCANNER_SYNC_BUDGET_SECONDS "${value}", using default of ${DEFAULT_SYNC_SCAN_BUDGET_SECONDS}`,
+    );
+    return DEFAULT_SYNC_SCAN_BUDGET_SECONDS * 1000;
+  }
+
+  return parsed * 1000;
+}
+
+/**
+ * Build the warning recorded when a scan stops at the file limit
+ */
+export function getFileLimitMessage(maxFiles: number): string {
+  return `File limit reached: stopped after ${maxFiles} media files (SCANNER_MAX_FILES_PER_SCAN). Check the library path, or raise the limit and resume the scan.`;
+}
+
+/**
+ * Mark a batch scan job as stopped by the file limit
+ * The job is PAUSED rather than FAILED so it can be resumed once the limit is raised
+ */
+export async function markScanJobFileLimitReached(
+  scanJobId: string,
+  maxFiles: number,
+): Promise<void> {
+  const message = getFileLimitMessage(maxFiles);
+
+  await prisma.scanJob.update({
+    where: { id: scanJobId },
+    data: {
+      status: ScanJobStatus.PAUSED,
+      errorMessage: message,
+    },
+  });
+
+  logger.warn(`⚠️  Scan job ${scanJobId}: ${message}`);
+}
@@ -0,0 +1,321 @@
+/**
+ * Scan queue
+ * Runs one scan at a time so slow mounts are not overwhelmed
+ */
+
+import { randomUUID } from "crypto";
+import { resolve } from "path";
+import { logger } from "@/lib/utils";
+
+/**
+ * Finished scans whose durations feed the start time estimate
+ */
+export const RECENT_SCAN_SAMPLES = 10;
+
+/**
+ * Default cap on scans waiting in the queue (0 = unlimited)
+ */
+export const DEFAULT_MAX_QUEUED_SCANS = 0;
+
+/**
+ * Get the most scans allowed to wait in the queue
+ * Read from SCANNER_MAX_QUEUED_SCANS (0 = unlimited), falling back to the
+ * default
+ */
+export function getMaxQueuedScans(
+  value: string | undefined = process.env.SCANNER_MAX_QUEUED_SCANS,
+): number {
+  if (!value || value.trim() === "") {
+    return DEFAULT_MAX_QUEUED_SCANS;
+  }
+
+  const parsed = Number(value.trim());
+  if (!Number.isInteger(parsed) || parsed < 0) {
+    logger.warn(
+      `Invalid SCANNER_MAX_QUEUED_SCANS "${value}", using default of ${DEFAULT_MAX_QUEUED_SCANS}`,
+    );
+    return DEFAULT_MAX_QUEUED_SCANS;
+  }
+
+  return parsed;
+}
+
+/**
+ * High priority scans wait only behind other high priority scans, so an
+ * interactive scan is not stuck behind scheduled library rescans
+ */
+export type ScanPriority = "high" | "normal";
+
+interface QueuedScan {
+  id: string;
+  task: () => Promise<void>;
+  priority: ScanPriority;
+  coalesceKey?: string;
+  onCancel?: () => void;
+}
+
+let activeScan: {
+  id: string;
+  promise: Promise<void>;
+  startedAt: number;
+  coalesceKey?: string;
+} | null = null;
+const scanQueue: QueuedScan[] = [];
+// Durations in ms of the most recent finished scans, oldest first
+const recentDurations: number[] = [];
+
+function recordScanDuration(durationMs: number) {
+  recentDurations.push(durationMs);
+  if (recentDurations.length > RECENT_SCAN_SAMPLES) {
+    recentDurations.shift();
+  }
+}
+
+function processQueue() {
+  if (activeScan) {
+    logger.info("📋 Scan queued - another scan is in progress");
+    return;
+  }
+
+  const nextScan = scanQueue.shift();
+  if (!nextScan) return;
+
+  const startedAt = Date.now();
+  activeScan = {
+    id: nextScan.id,
+    coalesceKey: nextScan.coalesceKey,
+    startedAt,
+    promise: nextScan.task().finally(() => {
+      recordScanDuration(Date.now() - startedAt);
+      activeScan = null;
+      processQueue(); // Process next in queue
+    }),
+  };
+}
+
+/**
+ * Rough start time for the scan at a queue position, from the average of
+ * recent scan durations. A queued scan's size is unknown until it runs, so
+ * this is a guess; null until a scan has finished.
+ */
+function estimateStartAt(queuePosition: number, now: number): Date | null {
+  if (recentDurations.length === 0) {
+    return null;
+  }
+
+  const averageMs =
+    recentDurations.reduce((sum, duration) => sum + duration, 0) /
+    recentDurations.length;
+  // The running scan is assumed to take an average scan, but never less
+  // than it already has
+  const activeRemainingMs = activeScan
+    ? Math.max(0, averageMs - (now - activeScan.startedAt))
+    : 0;
+
+  return new Date(
+    now + activeRemainingMs + averageMs * Math.max(0, queuePosition - 1),
+  );
+}
+
+/**
+ * Whether paths differing only in case name the same folder here
+ * (macOS and Windows filesystems are case-insensitive by default)
+ */
+function isCaseInsensitivePlatform(): boolean {
+  return process.platform === "darwin" || process.platform === "win32";
+}
+
+/**
+ * Options of a scan request that change what the scan does
+ */
+export interface ScanCoalesceOptions {
+  mediaType: string;
+  libraryName?: string;
+  files?: string[];
+  subpaths?: string[];
+  maxDepth?: number;
+  fileExtensions?: string[];
+  rescan?: boolean;
+  includeExtras?: boolean;
+  recordSkips?: boolean;
+  batchScan?: boolean;
+}
+
+/**
+ * Key under which identical scan requests are coalesced: the same root
+ * folder (however it is written), listed files and subpaths (in any order)
+ * and every option that changes what the scan does
+ * A request that scans more, such as the whole library while a subpath
+ * scan runs, gets a different key and is queued.
+ */
+export function getScanCoalesceKey(
+  rootPath: string,
+  options: ScanCoalesceOptions,
+  caseInsensitive = isCaseInsensitivePlatform(),
+): string {
+  // resolve drops "." segments, doubled and trailing slashes
+  const normalize = (path: string) => {
+    const resolved = resolve(rootPath, path);
+    return caseInsensitive ? resolved.toLowerCase() : resolved;
+  };
+  const normalizeAll = (paths?: string[]) =>
+    paths ? Array.from(new Set(paths.map(normalize))).sort() : null;
+  const fileExtensions = options.fileExtensions
+    ? Array.from(
+        new Set(options.fileExtensions.map((ext) => ext.toLowerCase())),
+      ).sort()
+    : null;
+
+  // Unset options are keyed as their defaults
+  return JSON.stringify([
+    normalize(rootPath),
+    options.mediaType,
+    options.libraryName ?? "",
+    normalizeAll(options.files),
+    normalizeAll(options.subpaths),
+    options.maxDepth ?? null,
+    fileExtensions,
+    !!options.rescan,
+    !!options.includeExtras,
+    !!options.recordSkips,
+    options.batchScan !== false,
+  ]);
+}
+
+/**
+ * Find a running or waiting scan queued with a coalesce key
+ * Returns its queue ID and place in the queue (0 = running), or null
+ */
+export function findCoalescedScan(coalesceKey: string): {
+  queued: boolean;
+  queuePosition: number;
+  queueId: string;
+  estimatedStartAt: Date | null;
+} | null {
+  if (activeScan?.coalesceKey === coalesceKey) {
+    return {
+      queued: false,
+      queuePosition: 0,
+      queueId: activeScan.id,
+      estimatedStartAt: null,
+    };
+  }
+
+  const index = scanQueue.findIndex(
+    (scan) => scan.coalesceKey === coalesceKey,
+  );
+  if (index === -1) {
+    return null;
+  }
+
+  return {
+    queued: true,
+    queuePosition: index + 1,
+    queueId: scanQueue[index]!.id,
+    estimatedStartAt: estimateStartAt(index + 1, Date.now()),
+  };
+}
+
+/**
+ * Add a scan to the queue, starting it right away if nothing is running
+ * A high priority scan goes ahead of every normal one already waiting; the
+ * running scan is never interrupted
+ * A coalesce key lets later identical requests find this scan with
+ * findCoalescedScan while it runs or waits
+ * onCancel is called if the scan is cancelled with cancelQueuedScan before
+ * it starts
+ * Returns whether it had to wait, its place in the queue (1 = next to run),
+ * an ID to check on it while it waits, and a rough start time
+ */
+export function enqueueScan(
+  task: () => Promise<void>,
+  priority: ScanPriority = "normal",
+  coalesceKey?: string,
+  onCancel?: () => void,
+): {
+  queued: boolean;
+  queuePosition: number;
+  queueId: string;
+  estimatedStartAt: Date | null;
+} {
+  const queued = activeScan !== null;
+  const queueId = randomUUID();
+  const firstNormal =
+    priority === "high"
+      ? scanQueue.findIndex((scan) => scan.priority === "normal")
+      : -1;
+  const index = firstNormal === -1 ? scanQueue.length : firstNormal;
+  scanQueue.splice(index, 0, {
+    id: queueId,
+    task,
+    priority,
+    coalesceKey,
+    onCancel,
+  });
+  const queuePosition = queued ? index + 1 : 0;
+
+  processQueue();
+
+  return {
+    queued,
+    queuePosition,
+    queueId,
+    estimatedStartAt: queued
+      ? estimateStartAt(queuePosition, Date.now())
+      : null,
+  };
+}
+
+/**
+ * Where a waiting scan sits in the queue now
+ * Returns null once the scan has started (or was never queued here)
+ */
+export function getQueuedScan(queueId: string): {
+  queuePosition: number;
+  estimatedStartAt: Date | null;
+} | null {
+  const index = scanQueue.findIndex((scan) => scan.id === queueId);
+  if (index === -1) {
+    return null;
+  }
+
+  const queuePosition = index + 1;
+  return {
+    queuePosition,
+    estimatedStartAt: estimateStartAt(queuePosition, Date.now()),
+  };
+}
+
+/**
+ * Take a waiting scan out of the queue; the scans behind it move up
+ * Returns false once the scan has started (or was never queued here),
+ * since a running scan is never interrupted
+ */
+export function cancelQueuedScan(queueId: string): boolean {
+  const index = scanQueue.findIndex((scan) => scan.id === queueId);
+  if (index === -1) {
+    return false;
+  }
+
+  const [scan] = scanQueue.splice(index, 1);
+  logger.info(`📋 Queued scan cancelled (${index + 1} in queue)`);
+  scan!.onCancel?.();
+  return true;
+}
+
+/**
+ * Check whether a new scan would have to wait in a queue that is already
+ * at SCANNER_MAX_QUEUED_SCANS
+ */
+export function isScanQueueFull(maxQueued = getMaxQueuedScans()): boolean {
+  return (
+    maxQueued > 0 && activeScan !== null && scanQueue.length >= maxQueued
+  );
+}
+
+/**
+ * Check whether no scan is running or waiting
+ */
+export function isScanQueueIdle(): boolean {
+  return activeScan === null && scanQueue.length === 0;
+}
@@ -0,0 +1,71 @@
+/**
+ * Scan requesters
+ * Several services start scans (the app, scripts, the scheduler). Each
+ * batch scan job records which one asked, with its User-Agent and address,
+ * so a scan can be traced back to where it came from.
+ */
+
+import { isIP } from "net";
+import type { ScanRequester } from "../scan.types";
+
+// Longest User-Agent kept; the rest is cut off
+const MAX_USER_AGENT_LENGTH = 500;
+
+/**
+ * Whether requester addresses are masked before they are stored
+ * Read from SCANNER_REDACT_REQUESTER_IP; off unless set to "true"
+ */
+export function isRequesterIpRedacted(
+  value: string | undefined = process.env.SCANNER_REDACT_REQUESTER_IP,
+): boolean {
+  return value?.trim().toLowerCase() === "true";
+}
+
+/**
+ * Mask a client address down to its network: the last part of an IPv4
+ * address ("192.168.1.0") or all but the first three groups of an IPv6
+ * address ("2001:db8:85a3::"). Anything else is dropped.
+ */
+export function redactRemoteAddress(address: string): string | null {
+  if (isIP(address) === 4) {
+    return address.replace(/\.\d+$/, ".0");
+  }
+
+  if (isIP(address) === 6) {
+    // Expand "::" so the first groups can be counted
+    const [head = "", tail] = address.split("::");
+    const headGroups = head ? head.split(":") : [];
+    const tailGroups = tail ? tail.split(":") : [];
+    const missing = 8 - headGroups.length - tailGroups.length;
+    const groups =
+      tail === undefined
+        ? headGroups
+        : [...headGroups, ...Array(missing).fill("0"), ...tailGroups];
+    return `${groups.slice(0, 3).join(":")}::`;
+  }
+
+  return null;
+}
+
+/**
+ * Build the requester recorded on a scan job from what the request sent
+ * IPv4 addresses seen through an IPv6 socket ("::ffff:10.0.0.5") are
+ * stored as plain IPv4.
+ */
+export function createScanRequester(request: {
+  origin?: string;
+  userAgent?: string;
+  remoteAddress?: string;
+}): ScanRequester {
+  const address = request.remoteAddress?.replace(/^::ffff:(?=[\d.]+$)/, "");
+
+  return {
+    origin: request.origin ?? null,
+    userAgent: request.userAgent?.slice(0, MAX_USER_AGENT_LENGTH) || null,
+    remoteAddress: address
+      ? isRequesterIpRedacted()
+        ? redactRemoteAddress(address)
+        : address
+      : null,
+  };
+}
@@ -0,0 +1,213 @@
+/**
+ * Per-file scan results file
+ * When SCANNER_RESULTS_FILE is set, every file a scan tries to save is
+ * written to it as one JSON line: the parsed name, the TMDB match and the
+ * save outcome. Unlike the log, the lines are meant for tools, e.g. diffing
+ * how two versions parse the same library.
+ */
+
+import { createWriteStream, existsSync, WriteStream } from "fs";
+import { rename } from "fs/promises";
+import { logger, mapContainerToHostPath } from "@/lib/utils";
+import type { MediaEntry } from "../scan.types";
+
+/**
+ * What happens to an existing results file when a scan starts
+ * - append: lines are added to the end
+ * - truncate: the file is emptied
+ * - rotate: the file is renamed to <file>.1 (replacing an older one)
+ */
+export type ResultsFileMode = "append" | "truncate" | "rotate";
+
+const RESULTS_FILE_MODES: readonly ResultsFileMode[] = [
+  "append",
+  "truncate",
+  "rotate",
+];
+
+/**
+ * How saving a file ended
+ * - saved: saved (new or updated)
+ * - no-match: no TMDB match, not saved
+ * - not-in-library: trailer whose movie is not in the library
+ * - inferior-copy: a better copy of the same movie is saved
+ * - timeout: gave up after SCANNER_FILE_DEADLINE_SECONDS
+ * - failed: saving threw an error
+ */
+export type FileResultOutcome =
+  | "saved"
+  | "no-match"
+  | "not-in-library"
+  | "inferior-copy"
+  | "timeout"
+  | "failed";
+
+/**
+ * Get the results file path from SCANNER_RESULTS_FILE, or null when unset
+ */
+export function getResultsFilePath(
+  value: string | undefined = process.env.SCANNER_RESULTS_FILE,
+): string | null {
+  return value && value.trim() !== "" ? value.trim() : null;
+}
+
+/**
+ * Get what happens to the results file when a scan starts
+ * Read from SCANNER_RESULTS_FILE_MODE, falling back to "append"
+ */
+export function getResultsFileMode(
+  value: string | undefined = process.env.SCANNER_RESULTS_FILE_MODE,
+): ResultsFileMode {
+  if (!value || value.trim() === "") {
+    return "append";
+  }
+
+  const mode = value.trim().toLowerCase() as ResultsFileMode;
+  if (!RESULTS_FILE_MODES.includes(mode)) {
+    logger.warn(
+      `Invalid SCANNER_RESULTS_FILE_MODE "${value}" (use append, truncate or rotate), using append`,
+    );
+    return "append";
+  }
+
+  return mode;
+}
+
+// A results file being written, shared by the scans writing to it so
+// their lines are not interleaved or cut off by a truncate or rotate
+interface OpenResultsFile {
+  stream: WriteStream;
+  writers: number;
+  failed: boolean;
+}
+
+const openResultsFiles = new Map<string, OpenResultsFile>();
+
+export interface ScanResultsWriter {
+  write: (
+    mediaEntry: MediaEntry,
+    result: {
+      outcome: FileResultOutcome;
+      title?: string;
+      isNew?: boolean;
+      error?: string;
+    },
+  ) => void;
+  close: () => Promise<void>;
+}
+
+/**
+ * Open the results file for one scan, or return null when it is not
+ * configured. If the file cannot be written, a warning is logged and the
+ * scan goes on without it. A resumed scan job continues its file, so it
+ * passes `continueScan` to always append. A scan that starts while another
+ * is still writing the file appends to the same stream; the file is only
+ * truncated or rotated when no scan has it open.
+ */
+export async function openScanResultsFile(context: {
+  libraryId: string;
+  mediaType: "movie" | "tv";
+  scanJobId?: string;
+  originalPath?: string;
+  continueScan?: boolean;
+}): Promise<ScanResultsWriter | null> {
+  const filePath = getResultsFilePath();
+  if (!filePath) {
+    return null;
+  }
+
+  let file = openResultsFiles.get(filePath);
+  if (file) {
+    file.writers++;
+    logger.info(
+      `📝 Writing per-file scan results to ${filePath} (shared with a running scan)`,
+    );
+  } else {
+    const mode = context.continueScan ? "append" : getResultsFileMode();
+
+    try {
+      if (mode === "rotate" && existsSync(filePath)) {
+        await rename(filePath, `${filePath}.1`);
+      }
+    } catch (error) {
+      logger.warn(
+        `Could not rotate scan results file ${filePath}: ${error instanceof Error ? error.message : error}`,
+      );
+    }
+
+    // Another scan may have opened the file while this one rotated it
+    file = openResultsFiles.get(filePath);
+    if (file) {
+      file.writers++;
+    } else {
+      const opened: OpenResultsFile = {
+        stream: createWriteStream(filePath, {
+          flags: mode === "truncate" ? "w" : "a",
+        }),
+        writers: 1,
+        failed: false,
+      };
+      opened.stream.on("error", (error) => {
+        if (!opened.failed) {
+          opened.failed = true;
+          logger.warn(
+            `Stopped writing scan results to ${filePath}: ${error.message}`,
+          );
+        }
+      });
+      openResultsFiles.set(filePath, opened);
+      file = opened;
+      logger.info(
+        `📝 Writing per-file scan results to ${filePath} (${mode})`,
+      );
+    }
+  }
+
+  const shared = file;
+  let closed = false;
+
+  return {
+    write(mediaEntry, result) {
+      if (closed || shared.failed) return;
+
+      shared.stream.write(
+        JSON.stringify({
+          time: new Date().toISOString(),
+          scanJobId: context.scanJobId ?? null,
+          libraryId: context.libraryId,
+          mediaType: context.mediaType,
+          path: mapContainerToHostPath(mediaEntry.path, context.originalPath),
+          size: mediaEntry.size,
+          modified: mediaEntry.modified,
+          parsed: mediaEntry.extractedIds,
+          isExtra: mediaEntry.isExtra ?? false,
+          tmdbId: mediaEntry.metadata ? mediaEntry.metadata.id : null,
+          ...result,
+        }) + "\n",
+      );
+    },
+
+    // The stream is ended once the last scan writing to it closes
+    close() {
+      return new Promise((resolve) => {
+        if (closed) {
+          resolve();
+          return;
+        }
+        closed = true;
+        shared.writers--;
+        if (shared.writers > 0) {
+          resolve();
+          return;
+        }
+
+        openResultsFiles.delete(filePath);
+        if (shared.failed || shared.stream.destroyed) {
+          resolve();
+          return;
+        }
+        shared.stream.end(() => resolve());
+      });
+    },
+  };
+}
@@ -0,0 +1,163 @@
+/**
+ * Skip reasons
+ * One list of the reasons a file or folder is left out of a scan, shared by
+ * the walker and the save step, and an optional per-job record of every
+ * skipped path so "why was this file skipped?" has an answer
+ */
+
+import prisma from "@/lib/database/prisma";
+import { logger } from "@/lib/utils";
+
+/**
+ * Why an entry was skipped
+ * - hidden: name starts with a dot
+ * - system: OS, NAS or version control folder, or a system file
+ * - extras-folder: bonus-content folder, skipped unless extras are included
+ * - sample: sample clip
+ * - sidecar: subtitles, .nfo or image next to a video
+ * - not-media: extension is not one of the scan's video extensions
+ * - bad-structure: video is not where the media type expects it
+ * - too-deep: video is nested deeper than its media type allows
+ * - timeout: reading or saving the file took longer than its deadline
+ * - inaccessible: the file could not be read (permissions, I/O errors)
+ * - no-match: no TMDB match was found for the parsed title
+ * - not-in-library: trailer whose movie is not in the library
+ * - save-failed: saving the file failed
+ * - inferior-copy: a better copy of the same movie is saved
+ *   (SCANNER_KEEP_BEST_COPY)
+ * - disc-structure: BDMV or VIDEO_TS folder; movie scans take only its main
+ *   title, TV scans leave it out
+ * - skip-sentinel: folder holds an active .dester-skip-until file, so it and
+ *   everything below it were left out
+ */
+export const SKIP_REASONS = [
+  "hidden",
+  "system",
+  "extras-folder",
+  "sample",
+  "sidecar",
+  "not-media",
+  "bad-structure",
+  "too-deep",
+  "timeout",
+  "inaccessible",
+  "no-match",
+  "not-in-library",
+  "save-failed",
+  "inferior-copy",
+  "disc-structure",
+  "skip-sentinel",
+] as const;
+
+export type SkipReason = (typeof SKIP_REASONS)[number];
+
+/**
+ * Skipped paths recorded per scan job. Past this, skips are only counted
+ * so a library pointed at the wrong directory cannot flood the table.
+ */
+export const MAX_RECORDED_SKIPS = 50000;
+
+// Longest detail kept per skip
+const MAX_SKIP_DETAIL_LENGTH = 500;
+
+export interface ScanSkipRecorder {
+  record: (path: string, reason: SkipReason, detail?: string) => void;
+  /**
+   * Write the skips recorded since the last flush to the job
+   */
+  flush: () => Promise<void>;
+  getTotal: () => number;
+}
+
+/**
+ * Creates a skip recorder for one batch scan job
+ * Skips already stored for the job (by an earlier run) count toward the cap
+ */
+export async function createScanSkipRecorder(
+  scanJobId: string,
+  maxRecorded = MAX_RECORDED_SKIPS,
+): Promise<ScanSkipRecorder> {
+  let stored = await prisma.scanSkip.count({ where: { scanJobId } });
+  let total = 0;
+  let warned = false;
+  let pending: Array<{
+    scanJobId: string;
+    path: string;
+    reason: SkipReason;
+    detail: string | null;
+  }> = [];
+
+  return {
+    record(path, reason, detail) {
+      total++;
+
+      if (stored + pending.length >= maxRecorded) {
+        if (!warned) {
+          warned = true;
+          logger.warn(
+            `⚠️  Recorded ${maxRecorded} skipped files for this scan, further skips are only counted`,
+          );
+        }
+        return;
+      }
+
+      pending.push({
+        scanJobId,
+        path,
+        reason,
+        detail: detail ? detail.slice(0, MAX_SKIP_DETAIL_LENGTH) : null,
+      });
+    },
+
+    async flush() {
+      if (pending.length === 0) {
+        return;
+      }
+
+      const rows = pending;
+      pending = [];
+      await prisma.scanSkip.createMany({ data: rows });
+      stored += rows.length;
+    },
+
+    getTotal() {
+      return total;
+    },
+  };
+}
+
+/**
+ * List the skips recorded for a scan job, optionally of one reason
+ * Returns null if the job does not exist
+ */
+export async function listScanSkips(
+  scanJobId: string,
+  options: { reason?: SkipReason; skip: number; take: number },
+) {
+  const job = await prisma.scanJob.findUnique({
+    where: { id: scanJobId },
+    select: { id: true },
+  });
+
+  if (!job) {
+    return null;
+  }
+
+  const where = {
+    scanJobId,
+    ...(options.reason ? { reason: options.reason } : {}),
+  };
+
+  const [total, skips] = await Promise.all([
+    prisma.scanSkip.count({ where }),
+    prisma.scanSkip.findMany({
+      where,
+      orderBy: [{ createdAt: "asc" }, { id: "asc" }],
+      skip: options.skip,
+      take: options.take,
+      select: { path: true, reason: true, detail: true, createdAt: true },
+    }),
+  ]);
+
+  return { items: skips, total };
+}
@@ -0,0 +1,78 @@
+/**
+ * Scanner version stamping
+ * Optionally records which scanner version created or last updated a file row
+ */
+
+import { readFileSync } from "fs";
+import { join } from "path";
+import prisma from "@/lib/database/prisma";
+import { logger } from "@/lib/utils";
+
+let scannerVersion: string | null = null;
+
+// Read version from package.json
+function getPackageVersion(): string {
+  try {
+    const packageJson = JSON.parse(
+      readFileSync(join(__dirname, "../../../../package.json"), "utf-8"),
+    );
+    return packageJson.version;
+  } catch (error) {
+    return "unknown";
+  }
+}
+
+/**
+ * Check that the scannerVersion column exists on the file tables
+ * Databases that have not run the migration yet simply skip stamping
+ */
+async function hasScannerVersionColumns(): Promise<boolean> {
+  const rows = await prisma.$queryRaw<Array<{ table_name: string }>>`
+    SELECT table_name
+    FROM information_schema.columns
+    WHERE table_schema = current_schema()
+      AND column_name = 'scannerVersion'
+      AND table_name IN ('Movie', 'Episode')
+  `;
+  return rows.length === 2;
+}
+
+/**
+ * Enable version stamping when SCANNER_RECORD_VERSION is set
+ * The version comes from SCANNER_VERSION (set at image build time) or package.json
+ * Returns the version that will be recorded, or null if stamping is off
+ */
+export async function initializeScannerVersion(
+  enabled = process.env.SCANNER_RECORD_VERSION === "true",
+): Promise<string | null> {
+  scannerVersion = null;
+
+  if (!enabled) {
+    return null;
+  }
+
+  try {
+    if (!(await hasScannerVersionColumns())) {
+      logger.warn(
+        "⚠️  SCANNER_RECORD_VERSION is set but the scannerVersion column is missing - run database migrations to enable it",
+      );
+      return null;
+    }
+  } catch (error) {
+    logger.warn(
+      `⚠️  Could not check for the scannerVersion column, version stamping disabled: ${error instanceof Error ? error.message : error}`,
+    );
+    return null;
+  }
+
+  scannerVersion = process.env.SCANNER_VERSION?.trim() || getPackageVersion();
+  return scannerVersion;
+}
+
+/**
+ * Fields to merge into Movie/Episode writes
+ * Empty when version stamping is disabled so the column is left untouched
+ */
+export function getScannerVersionData(): { scannerVersion?: string } {
+  return scannerVersion ? { scannerVersion } : {};
+}
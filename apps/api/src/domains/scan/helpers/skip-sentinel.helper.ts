@@ -0,0 +1,95 @@
+/**
+ * "Do not scan yet" sentinel files
+ * A folder holding a .dester-skip-until file is left out of scans, with
+ * everything below it, while a reorganization is in progress. The file holds
+ * an RFC 3339 timestamp after which the folder is scanned again, or nothing
+ * to skip it until the file is removed.
+ */
+
+import { join } from "path";
+import { logger } from "@/lib/utils";
+import { scanFs } from "./scan-fs.helper";
+import { withTimeout } from "./timeout-helper";
+
+export const SKIP_SENTINEL_FILE = ".dester-skip-until";
+
+// RFC 3339 date-time, e.g. 2025-12-01T18:00:00Z or 2025-12-01T18:00:00+01:00
+const RFC3339_PATTERN =
+  /^\d{4}-\d{2}-\d{2}[Tt ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:[Zz]|[+-]\d{2}:\d{2})$/;
+
+/**
+ * Parse the contents of a sentinel file
+ * Returns the time the skip ends, or null for an indefinite skip. A value
+ * that is not an RFC 3339 timestamp is treated as indefinite.
+ */
+export function parseSkipSentinel(
+  contents: string,
+  sentinelPath: string = SKIP_SENTINEL_FILE,
+): Date | null {
+  const value = contents.trim();
+  if (value === "") {
+    return null;
+  }
+
+  const until = new Date(value);
+  if (!RFC3339_PATTERN.test(value) || Number.isNaN(until.getTime())) {
+    logger.warn(
+      `Invalid timestamp "${value.slice(0, 100)}" in ${sentinelPath} (use RFC 3339, e.g. 2025-12-01T18:00:00Z), skipping the folder until the file is removed`,
+    );
+    return null;
+  }
+
+  return until;
+}
+
+/**
+ * Check a folder for an active sentinel file
+ * Returns `{ until }` while the folder should be skipped (`until` is null
+ * for an indefinite skip), or null when there is no sentinel or it has
+ * expired. Walkers call this only for folders whose listing shows the file.
+ */
+export async function getActiveSkipSentinel(
+  folderPath: string,
+  fileDeadlineMs?: number,
+  now: Date = new Date(),
+): Promise<{ until: Date | null } | null> {
+  const sentinelPath = join(folderPath, SKIP_SENTINEL_FILE);
+
+  let contents: string;
+  try {
+    contents = fileDeadlineMs
+      ? await withTimeout(
+          scanFs.readFile(sentinelPath),
+          fileDeadlineMs,
+          `Read ${sentinelPath}`,
+        )
+      : await scanFs.readFile(sentinelPath);
+  } catch (error) {
+    // No sentinel (or it cannot be read): scan the folder
+    if ((error as NodeJS.ErrnoException).code !== "ENOENT") {
+      logger.warn(
+        `Cannot read ${sentinelPath}, scanning the folder: ${error instanceof Error ? error.message : error}`,
+      );
+    }
+    return null;
+  }
+
+  const until = parseSkipSentinel(contents, sentinelPath);
+  if (until && until.getTime() <= now.getTime()) {
+    logger.debug(
+      `${sentinelPath} expired at ${until.toISOString()}, scanning the folder`,
+    );
+    return null;
+  }
+
+  return { until };
+}
+
+/**
+ * Describe an active sentinel for logs and skip records
+ */
+export function describeSkipSentinel(sentinel: { until: Date | null }): string {
+  return sentinel.until
+    ? `${SKIP_SENTINEL_FILE} until ${sentinel.until.toISOString()}`
+    : `${SKIP_SENTINEL_FILE} (no end time)`;
+}
@@ -5,6 +5,22 @@
 
 import { logger } from "@/lib/utils";
 
+/**
+ * Error thrown when an operation runs past its timeout
+ * Lets callers tell a hung drive apart from an operation that failed
+ */
+export class OperationTimeoutError extends Error {
+  constructor(
+    public operationName: string,
+    public timeoutMs: number,
+  ) {
+    super(
+      `Operation "${operationName}" timed out after ${timeoutMs}ms. This may indicate a slow or unresponsive mounted drive.`,
+    );
+    this.name = "OperationTimeoutError";
+  }
+}
+
 /**
  * Execute a promise with a timeout
  * Useful for operations on slow or unresponsive mounted drives
@@ -18,11 +34,7 @@ export async function withTimeout<T>(
 
   const timeoutPromise = new Promise<never>((_, reject) => {
     timeoutHandle = setTimeout(() => {
-      reject(
-        new Error(
-          `Operation "${operationName}" timed out after ${timeoutMs}ms. This may indicate a slow or unresponsive mounted drive.`,
-        ),
-      );
+      reject(new OperationTimeoutError(operationName, timeoutMs));
     }, timeoutMs);
   });
 
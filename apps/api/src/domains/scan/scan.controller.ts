@@ -1,6 +1,12 @@
 import { Request, Response } from "express";
 import { scanServices } from "./scan.services";
-import { scanPathSchema } from "./scan.schema";
+import {
+  scanPathSchema,
+  analyzePathSchema,
+  scanStreamSchema,
+  listScanJobsSchema,
+  listScanSkipsSchema,
+} from "./scan.schema";
 import { getTmdbApiKey } from "../../core/config/settings";
 import { z } from "zod";
 import {
@@ -8,35 +14,287 @@ import {
   mapHostToContainerPath,
   asyncHandler,
   ValidationError,
+  NotFoundError,
+  ConflictError,
+  ServiceUnavailableError,
   sendSuccess,
+  createPaginationMeta,
+  runWithLogContext,
 } from "@/lib/utils";
 import { wsManager } from "@/lib/websocket";
 import {
   isDangerousRootPath,
   isMediaRootPath,
   detectMediaTypeMismatch,
+  isTerminalScanJobStatus,
+  enqueueScan,
+  getQueuedScan,
+  cancelQueuedScan,
+  isScanQueueFull,
+  getSubpathFolder,
+  getSubpathSkipReason,
+  getScanThroughput,
+  getScanCoalesceKey,
+  findCoalescedScan,
+  checkListedFiles,
+  getFileDeadlineMs,
+  findInProgressScanJobId,
+  getSyncScanBudgetMs,
+  createScanRequester,
 } from "./helpers";
 import { existsSync, statSync } from "fs";
+import { join } from "path";
 
 type ScanPathRequest = z.infer<typeof scanPathSchema>;
+type AnalyzePathRequest = z.infer<typeof analyzePathSchema>;
+type ScanStreamRequest = z.infer<typeof scanStreamSchema>;
+type ListScanJobsRequest = z.infer<typeof listScanJobsSchema>;
+type ListScanSkipsRequest = z.infer<typeof listScanSkipsSchema>;
+
+// How often an open progress stream re-checks the job status and sends a heartbeat
+const SCAN_STREAM_POLL_INTERVAL_MS = 5000;
+
+/**
+ * Map a requested root to its path in the container and check that it is a
+ * readable directory
+ */
+function resolveScanDirectory(path: string): string {
+  // Map host path to container path if running in Docker
+  const mappedPath = mapHostToContainerPath(path);
+
+  // Validate that the path exists and is accessible
+  try {
+    if (!existsSync(mappedPath)) {
+      throw new ValidationError(
+        `Path does not exist or is not accessible: ${path}`,
+      );
+    }
+
+    const stats = statSync(mappedPath);
+    if (!stats.isDirectory()) {
+      throw new ValidationError(`Path must be a directory, not a file: ${path}`);
+    }
+  } catch (error) {
+    if (error instanceof ValidationError) {
+      throw error;
+    }
+    throw new ValidationError(
+      `Cannot access path: ${path}. Please check permissions and path validity.`,
+    );
+  }
 
-// Scan queue to prevent overwhelming slow mounts
-let activeScan: Promise<void> | null = null;
-const scanQueue: Array<() => Promise<void>> = [];
+  return mappedPath;
+}
+
+type ScanRunResult =
+  | Awaited<ReturnType<typeof scanServices.post>>
+  | Awaited<ReturnType<typeof scanServices.postBatched>>
+  | Awaited<ReturnType<typeof scanServices.postComics>>;
+
+/**
+ * Answer a scan request with wait set once the scan finishes, or with 202
+ * and the progress so far when SCANNER_SYNC_BUDGET_SECONDS runs out first.
+ * The scan goes on in the background either way.
+ */
+async function waitForScan(
+  path: string,
+  options: ScanPathRequest["options"],
+  scanDone: Promise<ScanRunResult>,
+  queueId: string,
+  res: Response,
+  responseData: Record<string, unknown>,
+) {
+  const budgetMs = getSyncScanBudgetMs();
+  let timer: NodeJS.Timeout | undefined;
+  const budgetSpent = new Promise<null>((resolve) => {
+    timer = setTimeout(() => resolve(null), budgetMs);
+  });
 
-async function processQueue() {
-  if (activeScan) {
-    logger.info("📋 Scan queued - another scan is in progress");
-    return;
+  // A failed scan rejects here and is answered by the error handler
+  const result = await Promise.race([scanDone, budgetSpent]).finally(() =>
+    clearTimeout(timer),
+  );
+
+  if (result) {
+    return sendSuccess(
+      res,
+      {
+        path: path,
+        mediaType: options?.mediaType,
+        queueId,
+        stillRunning: false,
+        result,
+        ...responseData,
+      },
+      200,
+      "Scan completed.",
+    );
   }
 
-  if (scanQueue.length === 0) return;
+  // Only batch scans have a job to report progress from
+  const waiting = getQueuedScan(queueId);
+  const scanJobId = waiting ? null : await findInProgressScanJobId(path);
+  const status = scanJobId ? await scanServices.getJobStatus(scanJobId) : null;
+  logger.info(
+    `⏱️  Scan still ${waiting ? "queued" : "running"} after ${budgetMs / 1000}s - answering with the progress so far`,
+  );
+
+  return sendSuccess(
+    res,
+    {
+      path: path,
+      mediaType: options?.mediaType,
+      queued: waiting !== null,
+      queueId,
+      ...waiting,
+      stillRunning: true,
+      scanJobId,
+      progress: status?.progress ?? null,
+      ...responseData,
+    },
+    202,
+    waiting
+      ? `Scan still queued (${waiting.queuePosition} in queue). It will run in the background.`
+      : "Scan still running in the background. Progress will be sent via WebSocket.",
+  );
+}
 
-  const nextScan = scanQueue.shift()!;
-  activeScan = nextScan().finally(() => {
-    activeScan = null;
-    processQueue(); // Process next in queue
+/**
+ * Queue a scan (or start it right away) and answer with 202
+ * With options.wait the answer is held until the scan finishes, within the
+ * sync budget
+ * The scan runs in its own log context so options.logLevel only affects it
+ */
+async function queueScan(
+  path: string,
+  options: ScanPathRequest["options"],
+  runScan: () => Promise<ScanRunResult>,
+  res: Response,
+  responseData: Record<string, unknown> = {},
+) {
+  // A request identical to a scan that is already running or waiting gets
+  // that scan back, unless forced
+  const coalesceKey = getScanCoalesceKey(path, {
+    ...options,
+    mediaType: options?.mediaType ?? "movie",
   });
+  const existing = options?.force ? null : findCoalescedScan(coalesceKey);
+  if (existing) {
+    // A running batch scan already has a job to follow
+    const scanJobId = existing.queued ? null : await findInProgressScanJobId(path);
+    logger.info(
+      `📋 The same scan is already ${existing.queued ? "queued" : "running"} - not queueing it again`,
+    );
+    return sendSuccess(
+      res,
+      {
+        path: path,
+        mediaType: options?.mediaType,
+        ...existing,
+        coalesced: true,
+        scanJobId,
+        ...responseData,
+      },
+      202,
+      existing.queued
+        ? `The same scan is already queued (${existing.queuePosition} in queue). Set force to queue another.`
+        : "The same scan is already running. Set force to queue another.",
+    );
+  }
+
+  if (isScanQueueFull()) {
+    throw new ServiceUnavailableError(
+      "Scan queue is full. Try again once queued scans have started.",
+    );
+  }
+
+  // Settled when the scan finishes, for a request that waits for it
+  let resolveScan: (result: ScanRunResult) => void = () => {};
+  let rejectScan: (error: unknown) => void = () => {};
+  const scanDone = new Promise<ScanRunResult>((resolve, reject) => {
+    resolveScan = resolve;
+    rejectScan = reject;
+  });
+  // Nothing listens unless the request waits
+  scanDone.catch(() => {});
+
+  // Queue the scan to prevent overwhelming slow mounts
+  const scanTask = async () => {
+    const scanPromise = runWithLogContext(
+      { logLevel: options?.logLevel },
+      runScan,
+    );
+
+    return scanPromise
+      .then((result) => {
+        if ("totalFiles" in result) {
+          logger.info(
+            `✅ Scan completed: ${result.libraryName} (${result.totalSaved}/${result.totalFiles} items)`,
+          );
+        } else {
+          logger.info(`✅ Batch scan completed: ${result.libraryName}`);
+          logger.info(
+            `   📁 Folders: ${result.foldersProcessed}/${result.totalFolders} processed, ${result.foldersFailed} failed`,
+          );
+          logger.info(
+            `   🎬 Media Items: ${result.totalItemsSaved} saved to database`,
+          );
+        }
+        resolveScan(result);
+      })
+      .catch((error) => {
+        // Send error via WebSocket
+        const errorMessage =
+          error instanceof Error ? error.message : "Failed to scan path";
+        logger.error(`❌ Scan failed: ${errorMessage}`);
+        wsManager.sendScanError({
+          error: errorMessage,
+        });
+        rejectScan(error);
+      });
+  };
+
+  // Add to queue or start immediately. A request waiting on a scan that
+  // is cancelled before it starts is answered with 409.
+  const { queued, queuePosition, queueId, estimatedStartAt } = enqueueScan(
+    scanTask,
+    options?.priority,
+    coalesceKey,
+    () => rejectScan(new ConflictError("Scan was cancelled before it started")),
+  );
+  if (options?.wait) {
+    return waitForScan(path, options, scanDone, queueId, res, responseData);
+  }
+  if (queued) {
+    logger.info(`📋 Scan queued (${queuePosition} in queue)`);
+    return sendSuccess(
+      res,
+      {
+        path: path,
+        mediaType: options?.mediaType,
+        queued: true,
+        queueId,
+        queuePosition,
+        estimatedStartAt,
+        ...responseData,
+      },
+      202,
+      `Scan queued. ${queuePosition} scan(s) ahead in queue. Progress will be sent via WebSocket when started.`,
+    );
+  } else {
+    return sendSuccess(
+      res,
+      {
+        path: path,
+        mediaType: options?.mediaType,
+        queued: false,
+        queueId,
+        ...responseData,
+      },
+      202,
+      "Scan started successfully. Progress will be sent via WebSocket.",
+    );
+  }
 }
 
 export const scanControllers = {
@@ -53,31 +311,7 @@ export const scanControllers = {
       );
     }
 
-    // Map host path to container path if running in Docker
-    const mappedPath = mapHostToContainerPath(path);
-
-    // Validate that the path exists and is accessible
-    try {
-      if (!existsSync(mappedPath)) {
-        throw new ValidationError(
-          `Path does not exist or is not accessible: ${path}`,
-        );
-      }
-
-      const stats = statSync(mappedPath);
-      if (!stats.isDirectory()) {
-        throw new ValidationError(
-          `Path must be a directory, not a file: ${path}`,
-        );
-      }
-    } catch (error) {
-      if (error instanceof ValidationError) {
-        throw error;
-      }
-      throw new ValidationError(
-        `Cannot access path: ${path}. Please check permissions and path validity.`,
-      );
-    }
+    const mappedPath = resolveScanDirectory(path);
 
     // Check if this is a broad media root path with multiple collections
     // Note: For TV shows, having multiple show folders is EXPECTED and normal
@@ -102,6 +336,29 @@ export const scanControllers = {
       }
     }
 
+    // Comics are scanned in one pass and have no metadata provider
+    if (mediaType === "comic") {
+      if (options?.subpaths || options?.recordSkips || options?.files) {
+        throw new ValidationError(
+          "subpaths, files and recordSkips are not supported for comic scans",
+        );
+      }
+
+      logger.info(`Scanning comics: ${mappedPath} (original: ${path})`);
+
+      return queueScan(
+        path,
+        options,
+        () =>
+          scanServices.postComics(mappedPath, {
+            maxDepth: options?.maxDepth,
+            libraryName: options?.libraryName,
+            originalPath: path !== mappedPath ? path : undefined,
+          }),
+        res,
+      );
+    }
+
     // Get TMDB API key from database settings
     const tmdbApiKey = await getTmdbApiKey();
     if (!tmdbApiKey) {
@@ -110,9 +367,91 @@ export const scanControllers = {
       );
     }
 
+    // Targeted rescan: only the library folders the subpaths lie in
+    let folders: string[] | undefined;
+    if (options?.subpaths) {
+      if (options.batchScan === false) {
+        throw new ValidationError("subpaths require batch scanning");
+      }
+
+      const folderSet = new Set<string>();
+      for (const subpath of options.subpaths) {
+        const folder = getSubpathFolder(mappedPath, subpath);
+        if (!folder) {
+          throw new ValidationError(
+            `Subpath must be a folder inside ${path}: ${subpath}`,
+          );
+        }
+        let isDirectory = false;
+        try {
+          isDirectory = statSync(join(mappedPath, subpath)).isDirectory();
+        } catch {
+          throw new ValidationError(`Subpath does not exist: ${subpath}`);
+        }
+        if (!isDirectory) {
+          throw new ValidationError(`Subpath must be a folder: ${subpath}`);
+        }
+        const skipReason = getSubpathSkipReason(mappedPath, subpath, {
+          includeExtras: options.includeExtras,
+        });
+        if (skipReason) {
+          throw new ValidationError(
+            `Subpath is left out by the scanner (${skipReason}): ${subpath}`,
+          );
+        }
+        folderSet.add(folder);
+      }
+      folders = Array.from(folderSet);
+    }
+
+    // Explicit file list: only the valid files are scanned, without walking
+    // the library; the others are listed in the response
+    let files: string[] | undefined;
+    let rejectedFiles: Array<{ path: string; reason: string }> | undefined;
+    if (options?.files) {
+      if (options.subpaths) {
+        throw new ValidationError("files and subpaths cannot be combined");
+      }
+      if (options.batchScan === true) {
+        throw new ValidationError("files are scanned without batch scanning");
+      }
+
+      ({ files, rejectedFiles } = await checkListedFiles(
+        mappedPath,
+        options.files,
+        options.fileExtensions,
+        getFileDeadlineMs(),
+      ));
+      if (files.length === 0) {
+        throw new ValidationError(
+          "None of the listed files can be scanned",
+          rejectedFiles.map((rejected) => ({
+            field: "options.files",
+            message: rejected.reason,
+            received: rejected.path,
+          })),
+        );
+      }
+      for (const rejected of rejectedFiles) {
+        logger.warn(`Not scanning ${rejected.path}: ${rejected.reason}`);
+      }
+    }
+
+    if (options?.recordSkips && (options.batchScan === false || files)) {
+      throw new ValidationError("recordSkips requires batch scanning");
+    }
+
     const finalOptions = {
       ...options,
+      mediaType,
+      folders,
+      files,
       tmdbApiKey,
+      requester: createScanRequester({
+        origin: options?.origin,
+        userAgent: req.get("user-agent"),
+        remoteAddress: req.ip ?? req.socket.remoteAddress,
+      }),
       // Pass the original path for database storage and display
       originalPath: path !== mappedPath ? path : undefined,
     };
@@ -140,7 +479,7 @@ export const scanControllers = {
     // 2. OR it's a TV show library (5 per batch)
     // 3. OR it's a movie library (25 per batch - better for large/slow storage)
     // Only disable if explicitly set to false
-    const useBatchScan = options?.batchScan !== false;
+    const useBatchScan = options?.batchScan !== false && !files;
 
     if (useBatchScan) {
       logger.info(
@@ -150,69 +489,36 @@ export const scanControllers = {
       logger.info(`📁 Using full directory scanning mode`);
     }
 
-    // Queue the scan to prevent overwhelming slow mounts
-    const scanTask = async () => {
-      const scanPromise = useBatchScan
-        ? scanServices.postBatched(mappedPath, finalOptions)
-        : scanServices.post(mappedPath, finalOptions);
-
-      return scanPromise
-        .then((result) => {
-          if ("totalFiles" in result) {
-            logger.info(
-              `✅ Scan completed: ${result.libraryName} (${result.totalSaved}/${result.totalFiles} items)`,
-            );
-          } else {
-            logger.info(`✅ Batch scan completed: ${result.libraryName}`);
-            logger.info(
-              `   📁 Folders: ${result.foldersProcessed}/${result.totalFolders} processed, ${result.foldersFailed} failed`,
-            );
-            logger.info(
-              `   🎬 Media Items: ${result.totalItemsSaved} saved to database`,
-            );
-          }
-        })
-        .catch((error) => {
-          // Send error via WebSocket
-          const errorMessage =
-            error instanceof Error ? error.message : "Failed to scan path";
-          logger.error(`❌ Scan failed: ${errorMessage}`);
-          wsManager.sendScanError({
-            error: errorMessage,
-          });
-        });
-    };
+    return queueScan(
+      path,
+      options,
+      () =>
+        useBatchScan
+          ? scanServices.postBatched(mappedPath, finalOptions)
+          : scanServices.post(mappedPath, finalOptions),
+      res,
+      rejectedFiles ? { rejectedFiles } : {},
+    );
+  }),
 
-    // Add to queue or start immediately
-    if (activeScan) {
-      scanQueue.push(scanTask);
-      logger.info(`📋 Scan queued (${scanQueue.length} in queue)`);
-      return sendSuccess(
-        res,
-        {
-          path: path,
-          mediaType: options?.mediaType,
-          queued: true,
-          queuePosition: scanQueue.length,
-        },
-        202,
-        `Scan queued. ${scanQueue.length} scan(s) ahead in queue. Progress will be sent via WebSocket when started.`,
-      );
-    } else {
-      activeScan = scanTask();
-      processQueue(); // Start processing queue
+  /**
+   * Count what a directory holds (extensions, sizes, depths, largest files)
+   * without scanning it. Nothing is saved.
+   */
+  analyze: asyncHandler(async (req: Request, res: Response) => {
+    const { path, options } = req.validatedData as AnalyzePathRequest;
 
-      return sendSuccess(
-        res,
-        {
-          path: path,
-          mediaType: options?.mediaType,
-          queued: false,
-        },
-        202,
-        "Scan started successfully. Progress will be sent via WebSocket.",
-      );
-    }
+    const mappedPath = resolveScanDirectory(path);
+    logger.info(`Analyzing path: ${mappedPath} (original: ${path})`);
+
+    const analysis = await scanServices.analyze(mappedPath, {
+      maxDepth: options?.maxDepth,
+      maxFiles: options?.maxFiles,
+      timeLimitSeconds: options?.timeLimitSeconds,
+      originalPath: path !== mappedPath ? path : undefined,
+    });
+
+    return sendSuccess(res, { path, ...analysis });
   }),
 
   /**
@@ -236,8 +542,9 @@ export const scanControllers = {
     logger.info(`Resuming scan job: ${scanJobId}`);
 
     // Start the resume in the background (don't await)
-    scanServices
-      .resumeScanJob(scanJobId, tmdbApiKey)
+    runWithLogContext({ scanJobId }, () =>
+      scanServices.resumeScanJob(scanJobId, tmdbApiKey),
+    )
       .then((result) => {
         logger.info(`✅ Resumed scan completed: ${result.libraryName}`);
         logger.info(
@@ -286,6 +593,193 @@ export const scanControllers = {
     return sendSuccess(res, status);
   }),
 
+  /**
+   * List past and running scan jobs, newest first
+   */
+  listJobs: asyncHandler(async (req: Request, res: Response) => {
+    const { libraryId, page, limit } = req.validatedData as ListScanJobsRequest;
+    const result = await scanServices.listJobs({
+      libraryId,
+      skip: (page - 1) * limit,
+      take: limit,
+    });
+
+    return sendSuccess(
+      res,
+      result.items,
+      200,
+      undefined,
+      createPaginationMeta(page, limit, result.total),
+    );
+  }),
+
+  /**
+   * List the files a scan job skipped and why
+   */
+  listSkips: asyncHandler(async (req: Request, res: Response) => {
+    const { scanJobId } = req.params;
+    const { reason, page, limit } = req.validatedData as ListScanSkipsRequest;
+
+    if (!scanJobId) {
+      throw new ValidationError("Scan job ID is required");
+    }
+
+    const result = await scanServices.listSkips(scanJobId, {
+      reason,
+      skip: (page - 1) * limit,
+      take: limit,
+    });
+
+    if (!result) {
+      throw new NotFoundError("Scan job", scanJobId);
+    }
+
+    return sendSuccess(
+      res,
+      result.items,
+      200,
+      undefined,
+      createPaginationMeta(page, limit, result.total),
+    );
+  }),
+
+  /**
+   * Get the position of a scan waiting in the queue
+   */
+  getQueueStatus: asyncHandler(async (req: Request, res: Response) => {
+    const { queueId } = req.params;
+
+    if (!queueId) {
+      throw new ValidationError("Queue ID is required");
+    }
+
+    const queuedScan = getQueuedScan(queueId);
+
+    if (!queuedScan) {
+      throw new NotFoundError("Queued scan", queueId);
+    }
+
+    return sendSuccess(res, { queueId, ...queuedScan });
+  }),
+
+  /**
+   * Cancel a scan that is still waiting in the queue
+   */
+  cancelQueued: asyncHandler(async (req: Request, res: Response) => {
+    const { queueId } = req.params;
+
+    if (!queueId) {
+      throw new ValidationError("Queue ID is required");
+    }
+
+    if (!cancelQueuedScan(queueId)) {
+      throw new NotFoundError("Queued scan", queueId);
+    }
+
+    return sendSuccess(
+      res,
+      { queueId, cancelled: true },
+      200,
+      "Queued scan cancelled.",
+    );
+  }),
+
+  /**
+   * Stream scan job progress as Server-Sent Events until the job finishes
+   */
+  streamProgress: asyncHandler(async (req: Request, res: Response) => {
+    const { id } = req.validatedData as ScanStreamRequest;
+
+    const initialStatus = await scanServices.getJobStatus(id);
+
+    if (!initialStatus) {
+      throw new NotFoundError("Scan job", id);
+    }
+
+    res.status(200);
+    res.setHeader("Content-Type", "text/event-stream");
+    // no-transform stops the compression middleware from buffering events
+    res.setHeader("Cache-Control", "no-cache, no-transform");
+    res.setHeader("Connection", "keep-alive");
+    res.flushHeaders();
+
+    const sendEvent = (event: string, data: unknown) => {
+      res.write(`event: ${event}\ndata: ${JSON.stringify(data)}\n\n`);
+    };
+
+    sendEvent("status", initialStatus);
+
+    if (isTerminalScanJobStatus(initialStatus.status)) {
+      sendEvent("complete", initialStatus);
+      res.end();
+      return;
+    }
+
+    let closed = false;
+    let pollTimer: NodeJS.Timeout | null = null;
+    let unsubscribe: (() => void) | null = null;
+
+    const closeStream = () => {
+      if (closed) return;
+      closed = true;
+      if (pollTimer) clearInterval(pollTimer);
+      if (unsubscribe) unsubscribe();
+      res.end();
+    };
+
+    // The ScanJob row is the source of truth for terminal states
+    const checkForCompletion = async () => {
+      try {
+        const status = await scanServices.getJobStatus(id);
+        if (closed) return;
+
+        if (!status) {
+          sendEvent("error", { error: `Scan job ${id} no longer exists` });
+          closeStream();
+        } else if (isTerminalScanJobStatus(status.status)) {
+          sendEvent("complete", status);
+          closeStream();
+        }
+      } catch (error) {
+        logger.error(
+          `Failed to check scan job ${id} for stream: ${error instanceof Error ? error.message : error}`,
+        );
+      }
+    };
+
+    unsubscribe = wsManager.subscribeToScanEvents((event) => {
+      if (closed || event.scanJobId !== id) return;
+
+      if (event.type === "scan:progress") {
+        sendEvent("progress", {
+          phase: event.phase,
+          progress: event.progress,
+          current: event.current,
+          total: event.total,
+          message: event.message,
+          throughput: getScanThroughput(
+            id,
+            event.total > 0 ? event.total - event.current : undefined,
+          ),
+        });
+      } else if (event.type === "scan:error") {
+        // Folder-level errors don't end the job, so only forward them
+        sendEvent("scan-error", { error: event.error });
+        void checkForCompletion();
+      } else {
+        void checkForCompletion();
+      }
+    });
+
+    pollTimer = setInterval(() => {
+      if (closed) return;
+      res.write(": heartbeat\n\n");
+      void checkForCompletion();
+    }, SCAN_STREAM_POLL_INTERVAL_MS);
+
+    req.on("close", closeStream);
+  }),
+
   /**
    * Cleanup stale scan jobs
    */
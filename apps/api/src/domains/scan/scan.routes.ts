@@ -1,7 +1,13 @@
 import express, { Router } from "express";
 import { scanControllers } from "./scan.controller";
-import { validateBody } from "../../lib/middleware";
-import { scanPathSchema } from "./scan.schema";
+import { validateBody, validateQuery } from "../../lib/middleware";
+import {
+  scanPathSchema,
+  analyzePathSchema,
+  scanStreamSchema,
+  listScanJobsSchema,
+  listScanSkipsSchema,
+} from "./scan.schema";
 
 const router: Router = express.Router();
 
@@ -16,6 +22,9 @@ const router: Router = express.Router();
  *       - Extracts IDs from filenames and folder names (supports {tmdb-XXX}, {imdb-ttXXX}, {tvdb-XXX} formats)
  *       - Stores media information in the database with proper relationships
  *       - Supports both movies and TV shows
+ *       - Runs one scan at a time. A scan sent while another runs is queued
+ *         and the 202 response includes its `queueId`, `queuePosition` and
+ *         `estimatedStartAt` (null until a scan has finished since startup)
  *     tags: [Scan]
  *     requestBody:
  *       required: true
@@ -43,8 +52,8 @@ const router: Router = express.Router();
  *                     example: 3
  *                   mediaType:
  *                     type: string
- *                     enum: [movie, tv]
- *                     description: Media type for TMDB API calls (movie or tv). Required for proper metadata fetching.
+ *                     enum: [movie, tv, comic]
+ *                     description: Media type for TMDB API calls (movie or tv). Required for proper metadata fetching. comic scans .cbz archives (each folder is a series) without TMDB, in one pass.
  *                     example: tv
  *                   fileExtensions:
  *                     type: array
@@ -61,12 +70,59 @@ const router: Router = express.Router();
  *                     example: "My Anime Library"
  *                   rescan:
  *                     type: boolean
- *                     description: If true, re-fetches metadata from TMDB even if it already exists in the database. If false or omitted, skips items that already have metadata.
+ *                     description: If true, re-fetches metadata from TMDB even if it already exists in the database. If false or omitted, skips items that already have metadata, and files whose parsed title, year and type are unchanged keep their previous TMDB match instead of being searched again.
  *                     default: false
  *                     example: false
+ *                   includeExtras:
+ *                     type: boolean
+ *                     description: TV scans only. If true, files in a show's Extras, Featurettes, Behind The Scenes (etc.) folders are saved as specials in season 0, titled from the file name. If false or omitted, those folders are skipped.
+ *                     default: false
+ *                     example: false
+ *                   subpaths:
+ *                     type: array
+ *                     items:
+ *                       type: string
+ *                     description: Rescan only these folders, relative to path, instead of the whole library. Each subpath is scanned through the top-level library folder it lies in ("Breaking Bad/Season 5" rescans "Breaking Bad"). Subpaths must be existing folders inside path, and not inside a folder the scanner leaves out (hidden, system or extras folders). Batch scanning only.
+ *                     example: ["Breaking Bad", "The Wire/Season 2"]
+ *                   priority:
+ *                     type: string
+ *                     enum: [high, normal]
+ *                     description: Queue priority. A high priority scan runs before every normal scan already waiting (such as scheduled rescans), but never interrupts the running scan.
+ *                     default: normal
+ *                     example: high
+ *                   logLevel:
+ *                     type: string
+ *                     enum: [info, debug]
+ *                     description: Log level for this scan only. With debug, the scan's debug lines are logged while the rest of the server stays at its own level. Every line from the scan is prefixed with its scan job (once created), library ID and media type, such as "[scan job=... library=... movie]".
+ *                     example: debug
+ *                   recordSkips:
+ *                     type: boolean
+ *                     description: Record every file and folder the scan leaves out, with the reason, so it can be looked up with GET /api/v1/scan/job/{scanJobId}/skips. Off by default because of the volume. Up to 50,000 skips are recorded per scan job. Batch scanning only.
+ *                     default: false
+ *                     example: true
+ *                   files:
+ *                     type: array
+ *                     items:
+ *                       type: string
+ *                     description: Scan only these files (relative to path, or absolute inside it) instead of walking the library, e.g. the files a downloader just finished. Only the folders leading to them are read. Each file must exist, not be a file the scanner leaves out, and have one of the scan's extensions. Files that fail are listed in the response as rejectedFiles with a reason; if none pass, the request fails. Cannot be combined with subpaths or batch scanning.
+ *                     maxItems: 1000
+ *                     example: ["Inception (2010)/Inception (2010).mkv"]
+ *                   force:
+ *                     type: boolean
+ *                     description: A request for the same path (after normalizing it), media type, library name, files and subpaths, with the same scan options, as a scan that is already queued or running returns that scan with coalesced set to true instead of queueing a duplicate. Set force to queue it anyway.
+ *                     default: false
+ *                   origin:
+ *                     type: string
+ *                     maxLength: 100
+ *                     description: Name of the service starting the scan, such as admin-ui or a script name. Batch scan jobs record it with the request's User-Agent and client address, for GET /api/v1/scan/job/{scanJobId}.
+ *                     example: "admin-ui"
+ *                   wait:
+ *                     type: boolean
+ *                     description: Hold the request until the scan finishes and answer with its result. When SCANNER_SYNC_BUDGET_SECONDS (default 60) runs out first, the answer is 202 with stillRunning set, the scan job and its progress so far, and the scan goes on in the background as without wait. A request coalesced with a scan already queued or running is answered right away.
+ *                     default: false
  *     responses:
  *       200:
- *         description: Successful scan
+ *         description: Scan finished within the sync budget (wait set)
  *         content:
  *           application/json:
  *             schema:
@@ -78,41 +134,80 @@ const router: Router = express.Router();
  *                 data:
  *                   type: object
  *                   properties:
- *                     libraryId:
+ *                     queueId:
  *                       type: string
- *                       description: The ID of the library that was scanned
- *                       example: "clxxxx1234567890abcdefgh"
- *                     libraryName:
- *                       type: string
- *                       description: The name of the library
- *                       example: "My Anime Library"
- *                     totalFiles:
- *                       type: number
- *                       description: Total number of media files discovered
- *                       example: 15
- *                     totalSaved:
- *                       type: number
- *                       description: Total number saved to database
- *                       example: 14
- *                     cacheStats:
+ *                     stillRunning:
+ *                       type: boolean
+ *                       example: false
+ *                     result:
  *                       type: object
- *                       description: Metadata cache statistics
+ *                       description: What the scan returned
  *                       properties:
- *                         metadataFromCache:
- *                           type: number
- *                           description: Items using existing metadata
- *                           example: 10
- *                         metadataFromTMDB:
- *                           type: number
- *                           description: Items with fresh TMDB metadata
- *                           example: 5
- *                         totalMetadataFetched:
+ *                         libraryId:
+ *                           type: string
+ *                           description: The ID of the library that was scanned
+ *                           example: "clxxxx1234567890abcdefgh"
+ *                         libraryName:
+ *                           type: string
+ *                           description: The name of the library
+ *                           example: "My Anime Library"
+ *                         totalFiles:
  *                           type: number
- *                           description: Total items with metadata
+ *                           description: Total number of media files discovered
  *                           example: 15
+ *                         totalSaved:
+ *                           type: number
+ *                           description: Total number saved to database
+ *                           example: 14
+ *                         cacheStats:
+ *                           type: object
+ *                           description: Metadata cache statistics
+ *                           properties:
+ *                             metadataFromCache:
+ *                               type: number
+ *                               description: Items using existing metadata
+ *                               example: 10
+ *                             metadataFromTMDB:
+ *                               type: number
+ *                               description: Items with fresh TMDB metadata
+ *                               example: 5
+ *                             totalMetadataFetched:
+ *                               type: number
+ *                               description: Total items with metadata
+ *                               example: 15
  *                 message:
  *                   type: string
- *                   example: "Scan completed successfully"
+ *                   example: "Scan completed."
+ *       202:
+ *         description: Scan queued or started. With wait set, the scan is still queued or running after the sync budget and stillRunning is true
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     queued:
+ *                       type: boolean
+ *                     queueId:
+ *                       type: string
+ *                     queuePosition:
+ *                       type: number
+ *                     stillRunning:
+ *                       type: boolean
+ *                       description: Only with wait set
+ *                     scanJobId:
+ *                       type: string
+ *                       nullable: true
+ *                       description: With wait set, the job of a running batch scan. Other scans have no job
+ *                     progress:
+ *                       type: object
+ *                       nullable: true
+ *                       description: With wait set, the progress of the scan job so far, as returned by GET /api/v1/scan/job/{scanJobId}
  *       400:
  *         description: Bad request - Invalid path, validation error, or missing TMDB API key
  *         content:
@@ -129,6 +224,8 @@ const router: Router = express.Router();
  *                 message:
  *                   type: string
  *                   example: "TMDB API key is required. Please configure it in settings."
+ *       503:
+ *         description: Scan queue is full (SCANNER_MAX_QUEUED_SCANS)
  *       500:
  *         description: Internal server error
  *         content:
@@ -148,6 +245,142 @@ const router: Router = express.Router();
  */
 router.post("/path", validateBody(scanPathSchema), scanControllers.post);
 
+/**
+ * @swagger
+ * /api/v1/scan/analyze:
+ *   post:
+ *     summary: Analyze a directory without scanning it
+ *     description: |
+ *       Walks a directory and reports what it holds, to help choose file
+ *       extensions and size settings before scanning it as a library.
+ *       - Extension histogram, including non-video files
+ *       - File size buckets, files per folder depth and the 20 largest files
+ *       - Stops at maxFiles files or timeLimitSeconds, with truncated set
+ *       Nothing is parsed, looked up or saved to the database.
+ *     tags: [Scan]
+ *     requestBody:
+ *       required: true
+ *       content:
+ *         application/json:
+ *           schema:
+ *             type: object
+ *             required:
+ *               - path
+ *             properties:
+ *               path:
+ *                 type: string
+ *                 description: Directory to analyze. The same paths as a scan are allowed
+ *                 example: /Volumes/External/Library/Media/Movies
+ *               options:
+ *                 type: object
+ *                 properties:
+ *                   maxDepth:
+ *                     type: number
+ *                     minimum: 0
+ *                     maximum: 10
+ *                   maxFiles:
+ *                     type: number
+ *                     minimum: 1
+ *                     maximum: 1000000
+ *                     default: 100000
+ *                   timeLimitSeconds:
+ *                     type: number
+ *                     minimum: 1
+ *                     maximum: 300
+ *                     default: 30
+ *     responses:
+ *       200:
+ *         description: Directory analysis
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     path:
+ *                       type: string
+ *                     totalFiles:
+ *                       type: number
+ *                     totalDirectories:
+ *                       type: number
+ *                     totalBytes:
+ *                       type: number
+ *                     skippedEntries:
+ *                       type: number
+ *                       description: Hidden and system files and folders
+ *                     unreadable:
+ *                       type: number
+ *                     truncated:
+ *                       type: boolean
+ *                     stoppedBy:
+ *                       type: string
+ *                       enum: [file-limit, time-limit]
+ *                       nullable: true
+ *                     durationMs:
+ *                       type: number
+ *                     extensions:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           extension:
+ *                             type: string
+ *                             example: ".mkv"
+ *                           count:
+ *                             type: number
+ *                           totalBytes:
+ *                             type: number
+ *                           isVideo:
+ *                             type: boolean
+ *                     sizeBuckets:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           label:
+ *                             type: string
+ *                             example: "1-4 GB"
+ *                           minBytes:
+ *                             type: number
+ *                           maxBytes:
+ *                             type: number
+ *                             nullable: true
+ *                           count:
+ *                             type: number
+ *                           totalBytes:
+ *                             type: number
+ *                     depths:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           depth:
+ *                             type: number
+ *                           files:
+ *                             type: number
+ *                     largestFiles:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           path:
+ *                             type: string
+ *                           size:
+ *                             type: number
+ *       400:
+ *         description: Invalid path, or the path is not a readable directory
+ */
+router.post(
+  "/analyze",
+  validateBody(analyzePathSchema),
+  scanControllers.analyze,
+);
+
 /**
  * @swagger
  * /api/v1/scan/resume/{scanJobId}:
@@ -196,12 +429,163 @@ router.post("/path", validateBody(scanPathSchema), scanControllers.post);
  */
 router.post("/resume/:scanJobId", scanControllers.resumeScan);
 
+/**
+ * @swagger
+ * /api/v1/scan/jobs:
+ *   get:
+ *     summary: List scan jobs
+ *     description: |
+ *       Lists batch scan jobs, newest first, with their status, counts and
+ *       timestamps, for a scan history view. Scans that do not run as a
+ *       batch scan job are not listed. `itemsAdded` counts files the scan
+ *       added to the library for the first time. `durationMs` is null until
+ *       the job has finished.
+ *     tags: [Scan]
+ *     parameters:
+ *       - in: query
+ *         name: libraryId
+ *         schema:
+ *           type: string
+ *         description: Only list jobs of this library
+ *       - in: query
+ *         name: page
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           default: 1
+ *       - in: query
+ *         name: limit
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           maximum: 100
+ *           default: 20
+ *     responses:
+ *       200:
+ *         description: One page of scan jobs
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: array
+ *                   items:
+ *                     type: object
+ *                     properties:
+ *                       id:
+ *                         type: string
+ *                       status:
+ *                         type: string
+ *                         enum: [PENDING, IN_PROGRESS, PAUSED, COMPLETED, FAILED]
+ *                       library:
+ *                         type: object
+ *                         properties:
+ *                           id:
+ *                             type: string
+ *                           name:
+ *                             type: string
+ *                       scanPath:
+ *                         type: string
+ *                       mediaType:
+ *                         type: string
+ *                       requestedBy:
+ *                         type: object
+ *                         description: Who started the job. Fields are null when unknown
+ *                         properties:
+ *                           origin:
+ *                             type: string
+ *                             nullable: true
+ *                             example: "admin-ui"
+ *                           userAgent:
+ *                             type: string
+ *                             nullable: true
+ *                           remoteAddress:
+ *                             type: string
+ *                             nullable: true
+ *                             example: "192.168.1.0"
+ *                       counts:
+ *                         type: object
+ *                         properties:
+ *                           totalFolders:
+ *                             type: integer
+ *                           processedFolders:
+ *                             type: integer
+ *                           failedFolders:
+ *                             type: integer
+ *                           itemsSaved:
+ *                             type: integer
+ *                           itemsAdded:
+ *                             type: integer
+ *                           errors:
+ *                             type: integer
+ *                       timestamps:
+ *                         type: object
+ *                         properties:
+ *                           createdAt:
+ *                             type: string
+ *                             format: date-time
+ *                           startedAt:
+ *                             type: string
+ *                             format: date-time
+ *                             nullable: true
+ *                           completedAt:
+ *                             type: string
+ *                             format: date-time
+ *                             nullable: true
+ *                       durationMs:
+ *                         type: integer
+ *                         nullable: true
+ *                       error:
+ *                         type: string
+ *                         nullable: true
+ *                 meta:
+ *                   type: object
+ *                   properties:
+ *                     page:
+ *                       type: integer
+ *                     limit:
+ *                       type: integer
+ *                     total:
+ *                       type: integer
+ *                     totalPages:
+ *                       type: integer
+ *                     hasNext:
+ *                       type: boolean
+ *                     hasPrev:
+ *                       type: boolean
+ *       400:
+ *         description: Invalid query parameters
+ */
+router.get(
+  "/jobs",
+  validateQuery(listScanJobsSchema),
+  scanControllers.listJobs,
+);
+
 /**
  * @swagger
  * /api/v1/scan/job/{scanJobId}:
  *   get:
  *     summary: Get scan job status
- *     description: Get detailed status information about a scan job
+ *     description: |
+ *       Get detailed status information about a scan job. `errorCount` counts
+ *       the files and folders that failed so far, and `errorDirectories`
+ *       lists the 10 directories with the most failures, each with a count
+ *       and a sample error.
+ *
+ *       While the job runs in this server, `throughput` gives files and
+ *       folders per second over the last 5 minutes, and an estimated finish
+ *       from the folders left. The estimate is null until a folder finishes
+ *       or when nothing finished in the last 5 minutes.
+ *
+ *       `requestedBy` records who started the job: the `origin` sent with
+ *       the scan request (`scheduler` for scheduled scans), its User-Agent
+ *       and the client address. The address is masked to its network when
+ *       SCANNER_REDACT_REQUESTER_IP is set.
  *     tags: [Scan]
  *     parameters:
  *       - in: path
@@ -217,6 +601,205 @@ router.post("/resume/:scanJobId", scanControllers.resumeScan);
  */
 router.get("/job/:scanJobId", scanControllers.getJobStatus);
 
+/**
+ * @swagger
+ * /api/v1/scan/job/{scanJobId}/skips:
+ *   get:
+ *     summary: List the files a scan skipped
+ *     description: |
+ *       Lists the files and folders a scan job left out and why, in the
+ *       order they were skipped. Only scans started with
+ *       `options.recordSkips` record skips; for others the list is empty.
+ *
+ *       Reasons:
+ *       - `hidden`, `system`: hidden, OS, NAS or version control entries
+ *       - `extras-folder`: bonus-content folder, without `includeExtras`
+ *       - `sample`, `sidecar`: sample clips; subtitles, .nfo files and images
+ *       - `not-media`: not one of the scan's video extensions
+ *       - `bad-structure`, `too-deep`: not where the media type expects it, or below `maxDepth`
+ *       - `timeout`, `inaccessible`: the file could not be read in time or at all
+ *       - `no-match`: no TMDB match for the parsed title
+ *       - `not-in-library`: trailer whose movie is not in the library
+ *       - `save-failed`: saving the file failed
+ *       - `inferior-copy`: a better copy of the same movie is saved (SCANNER_KEEP_BEST_COPY)
+ *       - `disc-structure`: BDMV or VIDEO_TS folder; movie scans take only its main title (named in the detail), TV scans leave it out
+ *       - `skip-sentinel`: folder holds an active `.dester-skip-until` file, so it and everything below it were left out
+ *     tags: [Scan]
+ *     parameters:
+ *       - in: path
+ *         name: scanJobId
+ *         required: true
+ *         schema:
+ *           type: string
+ *       - in: query
+ *         name: reason
+ *         schema:
+ *           type: string
+ *           enum: [hidden, system, extras-folder, sample, sidecar, not-media, bad-structure, too-deep, timeout, inaccessible, no-match, not-in-library, save-failed, inferior-copy, disc-structure, skip-sentinel]
+ *         description: Only list skips with this reason
+ *       - in: query
+ *         name: page
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           default: 1
+ *       - in: query
+ *         name: limit
+ *         schema:
+ *           type: integer
+ *           minimum: 1
+ *           maximum: 500
+ *           default: 100
+ *     responses:
+ *       200:
+ *         description: One page of skipped files
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: array
+ *                   items:
+ *                     type: object
+ *                     properties:
+ *                       path:
+ *                         type: string
+ *                       reason:
+ *                         type: string
+ *                       detail:
+ *                         type: string
+ *                         nullable: true
+ *                       createdAt:
+ *                         type: string
+ *                         format: date-time
+ *                 meta:
+ *                   type: object
+ *                   properties:
+ *                     page:
+ *                       type: integer
+ *                     limit:
+ *                       type: integer
+ *                     total:
+ *                       type: integer
+ *                     totalPages:
+ *                       type: integer
+ *                     hasNext:
+ *                       type: boolean
+ *                     hasPrev:
+ *                       type: boolean
+ *       400:
+ *         description: Invalid query parameters
+ *       404:
+ *         description: Scan job not found
+ */
+router.get(
+  "/job/:scanJobId/skips",
+  validateQuery(listScanSkipsSchema),
+  scanControllers.listSkips,
+);
+
+/**
+ * @swagger
+ * /api/v1/scan/queue/{queueId}:
+ *   get:
+ *     summary: Get the position of a queued scan
+ *     description: |
+ *       Returns where a scan queued by `POST /api/v1/scan/path` waits. The
+ *       position counts from 1 (next to run) and moves up as earlier scans
+ *       finish or are cancelled, or back when a high priority scan is
+ *       queued ahead of a normal one. `estimatedStartAt` averages the last
+ *       10 scan durations, so it is rough, and null until a scan has
+ *       finished since startup.
+ *
+ *       Once the scan starts it leaves the queue and this returns 404;
+ *       follow it over WebSocket from then on. The queue lives in memory
+ *       and is lost on restart.
+ *     tags: [Scan]
+ *     parameters:
+ *       - in: path
+ *         name: queueId
+ *         required: true
+ *         schema:
+ *           type: string
+ *     responses:
+ *       200:
+ *         description: Scan is still waiting
+ *       404:
+ *         description: Scan already started or unknown queue ID
+ */
+router.get("/queue/:queueId", scanControllers.getQueueStatus);
+
+/**
+ * @swagger
+ * /api/v1/scan/queue/{queueId}:
+ *   delete:
+ *     summary: Cancel a queued scan
+ *     description: |
+ *       Takes a scan queued by `POST /api/v1/scan/path` out of the queue
+ *       before it starts. The scans behind it move up one place. A request
+ *       that is waiting on the scan (`wait: true`) is answered with 409.
+ *
+ *       A scan that has already started is never interrupted; this returns
+ *       404 for it.
+ *     tags: [Scan]
+ *     parameters:
+ *       - in: path
+ *         name: queueId
+ *         required: true
+ *         schema:
+ *           type: string
+ *     responses:
+ *       200:
+ *         description: Scan cancelled before it started
+ *       404:
+ *         description: Scan already started or unknown queue ID
+ */
+router.delete("/queue/:queueId", scanControllers.cancelQueued);
+
+/**
+ * @swagger
+ * /api/v1/scan/stream:
+ *   get:
+ *     summary: Stream scan job progress via Server-Sent Events
+ *     description: |
+ *       Holds the connection open and streams progress for a scan job as Server-Sent Events.
+ *       Designed for use with the browser `EventSource` API.
+ *       - `status` - Current job status, sent once when the stream opens
+ *       - `progress` - Phase, percentage, current/total counts, the item being processed and the current throughput and ETA
+ *       - `scan-error` - A folder failed to process (the scan continues)
+ *       - `complete` - Final job status; the stream is closed afterwards
+ *       The stream closes once the scan job reaches COMPLETED or FAILED.
+ *     tags: [Scan]
+ *     parameters:
+ *       - in: query
+ *         name: id
+ *         required: true
+ *         schema:
+ *           type: string
+ *         description: The ID of the scan job to follow
+ *         example: "clxxxx1234567890abcdefgh"
+ *     responses:
+ *       200:
+ *         description: Event stream opened
+ *         content:
+ *           text/event-stream:
+ *             schema:
+ *               type: string
+ *       400:
+ *         description: Missing scan job ID
+ *       404:
+ *         description: Scan job not found
+ */
+router.get(
+  "/stream",
+  validateQuery(scanStreamSchema),
+  scanControllers.streamProgress,
+);
+
 /**
  * @swagger
  * /api/v1/scan/cleanup:
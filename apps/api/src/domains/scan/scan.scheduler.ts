@@ -0,0 +1,221 @@
+import { existsSync } from "fs";
+import prisma from "@/lib/database/prisma";
+import { MediaType } from "@/lib/database";
+import {
+  logger,
+  mapHostToContainerPath,
+  runWithLogContext,
+} from "@/lib/utils";
+import { getTmdbApiKey } from "../../core/config/settings";
+import { scanServices } from "./scan.services";
+import {
+  checkStaleLibraries,
+  enqueueScan,
+  getLibraryStaleAfterMs,
+  isScanQueueIdle,
+  isScanQueueFull,
+  parseDurationMs,
+} from "./helpers";
+
+// Shortest allowed gap between scheduled runs
+const MIN_SCHEDULE_INTERVAL_MS = 5 * 60 * 1000;
+
+// How often libraries are checked for staleness (LIBRARY_STALE_AFTER)
+const STALE_CHECK_INTERVAL_MS = 60 * 60 * 1000;
+
+let scheduleTimer: NodeJS.Timeout | null = null;
+let staleCheckTimer: NodeJS.Timeout | null = null;
+
+/**
+ * Parse SCAN_SCHEDULE into an interval in milliseconds
+ * Accepts durations such as "30m", "6h", "1d" or "1h30m"
+ */
+export function parseScanSchedule(value: string): number {
+  const schedule = value.trim().toLowerCase();
+
+  if (schedule.split(/\s+/).length >= 5) {
+    throw new Error(
+      `Invalid SCAN_SCHEDULE "${value}": cron expressions are not supported, use a duration such as "6h"`,
+    );
+  }
+
+  const intervalMs = parseDurationMs(schedule);
+  if (intervalMs === null) {
+    throw new Error(
+      `Invalid SCAN_SCHEDULE "${value}": expected a duration such as "30m", "6h" or "1d"`,
+    );
+  }
+
+  if (intervalMs < MIN_SCHEDULE_INTERVAL_MS) {
+    throw new Error(
+      `Invalid SCAN_SCHEDULE "${value}": the interval must be at least 5 minutes`,
+    );
+  }
+
+  return intervalMs;
+}
+
+/**
+ * Queue a rescan of every library that has a path and a movie/TV type
+ * Resolves once all queued scans have finished
+ */
+async function runScheduledScans(): Promise<void> {
+  if (!isScanQueueIdle()) {
+    logger.info("⏰ Scheduled scan skipped - another scan is still running");
+    return;
+  }
+
+  const tmdbApiKey = await getTmdbApiKey();
+  if (!tmdbApiKey) {
+    logger.warn("⏰ Scheduled scan skipped - TMDB API key not configured");
+    return;
+  }
+
+  const libraries = await prisma.library.findMany({
+    where: {
+      isLibrary: true,
+      libraryPath: { not: null },
+      libraryType: { in: [MediaType.MOVIE, MediaType.TV_SHOW] },
+    },
+  });
+
+  if (libraries.length === 0) {
+    logger.info("⏰ Scheduled scan: no libraries to scan");
+    return;
+  }
+
+  logger.info(`⏰ Scheduled scan: scanning ${libraries.length} library(s)`);
+
+  // Libraries are queued one at a time, each after the previous finished,
+  // so a scheduled run holds at most one queue slot and scans requested in
+  // the meantime run between libraries
+  for (const library of libraries) {
+    const libraryPath = library.libraryPath!;
+    const mappedPath = mapHostToContainerPath(libraryPath);
+
+    if (!existsSync(mappedPath)) {
+      logger.warn(
+        `⏰ Skipping ${library.name} - path not accessible: ${libraryPath}`,
+      );
+      continue;
+    }
+
+    if (isScanQueueFull()) {
+      logger.warn(
+        `⏰ Skipping ${library.name} - scan queue is full (SCANNER_MAX_QUEUED_SCANS)`,
+      );
+      continue;
+    }
+
+    await new Promise<void>((resolve) => {
+      enqueueScan(async () => {
+        logger.info(`⏰ Scheduled scan started: ${library.name}`);
+        try {
+          const result = await runWithLogContext({}, () =>
+            scanServices.postBatched(mappedPath, {
+              tmdbApiKey,
+              mediaType:
+                library.libraryType === MediaType.TV_SHOW ? "tv" : "movie",
+              libraryName: library.name,
+              originalPath:
+                libraryPath !== mappedPath ? libraryPath : undefined,
+              requester: {
+                origin: "scheduler",
+                userAgent: null,
+                remoteAddress: null,
+              },
+            }),
+          );
+          logger.info(
+            `⏰ Scheduled scan completed: ${result.libraryName} (${result.totalFolders} folders)`,
+          );
+        } catch (error) {
+          logger.error(
+            `⏰ Scheduled scan failed for ${library.name}: ${error instanceof Error ? error.message : error}`,
+          );
+        } finally {
+          resolve();
+        }
+      });
+    });
+  }
+}
+
+/**
+ * Start the periodic rescan if SCAN_SCHEDULE is set
+ * The next run is scheduled only after the previous one finishes
+ * Returns the interval in milliseconds, or null when disabled
+ */
+export function startScanScheduler(
+  schedule: string | undefined = process.env.SCAN_SCHEDULE,
+): number | null {
+  if (!schedule?.trim()) {
+    return null;
+  }
+
+  const intervalMs = parseScanSchedule(schedule);
+
+  const scheduleNext = () => {
+    scheduleTimer = setTimeout(() => {
+      runScheduledScans()
+        .catch((error: unknown) => {
+          logger.error(
+            `⏰ Scheduled scan run failed: ${error instanceof Error ? error.message : error}`,
+          );
+        })
+        .finally(scheduleNext);
+    }, intervalMs);
+  };
+
+  scheduleNext();
+  return intervalMs;
+}
+
+/**
+ * Stop the periodic rescan (used on shutdown)
+ */
+export function stopScanScheduler() {
+  if (scheduleTimer) {
+    clearTimeout(scheduleTimer);
+    scheduleTimer = null;
+  }
+}
+
+function runStaleLibraryCheck(staleAfterMs: number) {
+  checkStaleLibraries(staleAfterMs).catch((error: unknown) => {
+    logger.error(
+      `Library staleness check failed: ${error instanceof Error ? error.message : error}`,
+    );
+  });
+}
+
+/**
+ * Start the hourly staleness check if LIBRARY_STALE_AFTER is set
+ * The first check runs right away. Returns the threshold in milliseconds, or
+ * null when disabled.
+ */
+export function startStaleLibraryCheck(
+  value: string | undefined = process.env.LIBRARY_STALE_AFTER,
+): number | null {
+  const staleAfterMs = getLibraryStaleAfterMs(value);
+  if (staleAfterMs === null) {
+    return null;
+  }
+
+  runStaleLibraryCheck(staleAfterMs);
+  staleCheckTimer = setInterval(
+    () => runStaleLibraryCheck(staleAfterMs),
+    STALE_CHECK_INTERVAL_MS,
+  );
+  return staleAfterMs;
+}
+
+/**
+ * Stop the staleness check (used on shutdown)
+ */
+export function stopStaleLibraryCheck() {
+  if (staleCheckTimer) {
+    clearInterval(staleCheckTimer);
+    staleCheckTimer = null;
+  }
+}
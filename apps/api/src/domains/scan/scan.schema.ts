@@ -1,43 +1,49 @@
 import { z } from "zod";
 import { isDangerousRootPath } from "./helpers/path-validator.helper";
+import { SKIP_REASONS } from "./helpers/scan-skips.helper";
 
 /**
  * General string validation schema
  */
 const sanitizedStringSchema = z.string().max(1000, "String is too long");
 
+/**
+ * Root directory of a scan or analysis
+ */
+const rootPathSchema = z
+  .string()
+  .min(1, "Path is required")
+  .max(5000, "Path is too long")
+  .refine(
+    (path) => {
+      // Basic path validation - no directory traversal, no dangerous characters
+      return (
+        !path.includes("..") &&
+        !path.includes("<") &&
+        !path.includes(">") &&
+        !path.includes("\0")
+      );
+    },
+    {
+      message: "Invalid or unsafe file path",
+    },
+  )
+  .refine(
+    (path) => {
+      // Prevent scanning dangerous root paths
+      return !isDangerousRootPath(path);
+    },
+    {
+      message:
+        "Cannot scan system root directories or entire drives. Please specify a media folder (e.g., /Users/username/Movies or C:\\Media\\Movies)",
+    },
+  );
+
 /**
  * File path validation schema for scanning local directories
  */
 export const scanPathSchema = z.object({
-  path: z
-    .string()
-    .min(1, "Path is required")
-    .max(5000, "Path is too long")
-    .refine(
-      (path) => {
-        // Basic path validation - no directory traversal, no dangerous characters
-        return (
-          !path.includes("..") &&
-          !path.includes("<") &&
-          !path.includes(">") &&
-          !path.includes("\0")
-        );
-      },
-      {
-        message: "Invalid or unsafe file path",
-      },
-    )
-    .refine(
-      (path) => {
-        // Prevent scanning dangerous root paths
-        return !isDangerousRootPath(path);
-      },
-      {
-        message:
-          "Cannot scan system root directories or entire drives. Please specify a media folder (e.g., /Users/username/Movies or C:\\Media\\Movies)",
-      },
-    ),
+  path: rootPathSchema,
   options: z
     .object({
       maxDepth: z
@@ -47,18 +53,135 @@ export const scanPathSchema = z.object({
         .max(10)
         .optional()
         .describe(
-          "Maximum directory depth to scan. Defaults to 2 for movies, 4 for TV shows",
+          "Maximum directory depth to scan. Defaults to 2 for movies, 4 for TV shows, 3 for comics",
+        ),
+      mediaType: z
+        .enum(["movie", "tv", "comic"])
+        .default("movie")
+        .describe(
+          "comic scans .cbz archives; each folder is a series. Comic scans do not batch and need no TMDB key",
         ),
-      mediaType: z.enum(["movie", "tv"]).default("movie"),
       fileExtensions: z.array(sanitizedStringSchema).max(20).optional(),
       libraryName: z.string().min(1).max(100).optional(),
       rescan: z.boolean().optional(),
+      includeExtras: z
+        .boolean()
+        .optional()
+        .describe(
+          "TV scans only: save files in Extras/Featurettes/etc. folders of a show as specials (season 0) instead of skipping them",
+        ),
       batchScan: z
         .boolean()
         .optional()
         .describe(
           "Enable batch scanning mode for large libraries. Automatically enabled for TV shows. Batches: 5 shows or 25 movies per batch.",
         ),
+      subpaths: z
+        .array(sanitizedStringSchema.min(1))
+        .min(1)
+        .max(500)
+        .optional()
+        .describe(
+          "Rescan only these folders, relative to path. Each is scanned through its top-level folder in the library",
+        ),
+      priority: z
+        .enum(["high", "normal"])
+        .optional()
+        .describe(
+          "Queue priority. High priority scans run before normal ones already waiting, such as scheduled rescans",
+        ),
+      logLevel: z
+        .enum(["info", "debug"])
+        .optional()
+        .describe(
+          "Log level for this scan only. Debug lines from the scan are logged even when the server logs at info",
+        ),
+      recordSkips: z
+        .boolean()
+        .optional()
+        .describe(
+          "Record every skipped file and why on the scan job. Batch scanning only",
+        ),
+      files: z
+        .array(sanitizedStringSchema.min(1))
+        .min(1)
+        .max(1000)
+        .optional()
+        .describe(
+          "Scan only these files, relative to path or absolute inside it, instead of walking the library. Files that are not valid are listed in the response",
+        ),
+      force: z
+        .boolean()
+        .optional()
+        .describe(
+          "Queue the scan even if the same scan (path, media type, library name, files, subpaths and options) is already queued or running",
+        ),
+      origin: z
+        .string()
+        .trim()
+        .min(1)
+        .max(100)
+        .optional()
+        .describe(
+          "Name of the service starting the scan, such as admin-ui or a script name. Recorded on the scan job with the User-Agent and client address",
+        ),
+      wait: z
+        .boolean()
+        .optional()
+        .describe(
+          "Answer once the scan finishes, for up to SCANNER_SYNC_BUDGET_SECONDS. A scan still running then goes on in the background and is answered with 202, its job and the progress so far",
+        ),
     })
     .optional(),
 });
+
+/**
+ * Body schema for analyzing a directory without scanning it
+ */
+export const analyzePathSchema = z.object({
+  path: rootPathSchema,
+  options: z
+    .object({
+      maxDepth: z.number().int().min(0).max(10).optional(),
+      maxFiles: z
+        .number()
+        .int()
+        .min(1)
+        .max(1000000)
+        .optional()
+        .describe("Stop after this many files. Defaults to 100000"),
+      timeLimitSeconds: z
+        .number()
+        .int()
+        .min(1)
+        .max(300)
+        .optional()
+        .describe("Stop walking after this many seconds. Defaults to 30"),
+    })
+    .optional(),
+});
+
+/**
+ * Query schema for streaming scan job progress over Server-Sent Events
+ */
+export const scanStreamSchema = z.object({
+  id: z.string().min(1, "Scan job ID is required"),
+});
+
+/**
+ * Query schema for listing past scan jobs
+ */
+export const listScanJobsSchema = z.object({
+  libraryId: z.string().min(1).optional(),
+  page: z.coerce.number().int().min(1).default(1),
+  limit: z.coerce.number().int().min(1).max(100).default(20),
+});
+
+/**
+ * Query schema for listing the files a scan skipped
+ */
+export const listScanSkipsSchema = z.object({
+  reason: z.enum(SKIP_REASONS).optional(),
+  page: z.coerce.number().int().min(1).default(1),
+  limit: z.coerce.number().int().min(1).max(500).default(100),
+});
@@ -1,6 +1,7 @@
-import { logger } from "@/lib/utils";
+import { logger, setLogContextFields } from "@/lib/utils";
 import type { TmdbSeasonMetadata } from "@/lib/providers/tmdb/tmdb.types";
-import type { TmdbMetadata } from "./scan.types";
+import type { ScanRequester, TmdbMetadata } from "./scan.types";
+import type { SkipReason } from "./helpers";
 import prisma from "@/lib/database/prisma";
 import { MediaType } from "@/lib/database";
 import { wsManager } from "@/lib/websocket";
@@ -12,15 +13,49 @@ import {
   fetchMetadataForEntries,
   fetchSeasonMetadata,
   saveMediaToDatabase,
+  applyUnchangedMatches,
   discoverFoldersToScan,
   createScanJob,
   getNextBatch,
   markBatchProcessed,
   processFolderBatch,
+  parseScanRequestPayload,
   cleanupStaleJobs,
   getScanJobStatus,
+  listScanJobs,
+  listScanSkips,
+  getScanAdditionSummary,
+  clearScanActivity,
+  startScanThroughput,
+  getMaxFilesPerScan,
+  getFileLimitMessage,
+  markScanJobFileLimitReached,
+  loadPathOverrides,
+  createPathOverrideResolver,
+  getFileDeadlineMs,
+  getTrailerFolders,
+  OperationTimeoutError,
+  saveThroughOutages,
+  DatabaseOutageError,
+  markScanJobDatabaseOutage,
+  createScanErrorCollector,
+  createScanSkipRecorder,
+  openScanResultsFile,
+  parseErrorDirectories,
+  saveScanJobErrors,
+  logErrorDirectories,
+  collectComicEntries,
+  saveComicToDatabase,
+  analyzeDirectory,
+  recordSuccessfulScan,
 } from "./helpers";
 
+// Number of new item titles included in scan completion events
+const MAX_NEW_ITEM_TITLES = 20;
+
+// Media items found between progress events while walking a directory
+const SCAN_PROGRESS_INTERVAL = 500;
+
 export const scanServices = {
   post: async (
     rootPath: string,
@@ -32,6 +67,8 @@ export const scanServices = {
       libraryName?: string;
       rescan?: boolean;
       originalPath?: string; // Store original path for database if different from scanning path
+      includeExtras?: boolean; // TV only: save Extras/Featurettes/... files as season 0
+      files?: string[]; // Only scan these files (absolute paths inside rootPath)
     },
   ) => {
     const {
@@ -42,6 +79,8 @@ export const scanServices = {
       libraryName,
       rescan = false,
       originalPath,
+      includeExtras = false,
+      files,
     } = options;
 
     // Set reasonable default maxDepth based on media type if not provided
@@ -87,6 +126,7 @@ export const scanServices = {
       },
     });
 
+    setLogContextFields({ libraryId: library.id, mediaType });
     logger.info(`✓ Library ready: ${library.name} (ID: ${library.id})\n`);
 
     const rateLimiter = createRateLimiter();
@@ -107,15 +147,70 @@ export const scanServices = {
       message: "Starting directory scan...",
       libraryId: library.id,
     });
+    if (files) {
+      logger.info(`Scanning ${files.length} listed file(s) only`);
+    }
 
+    const maxFiles = getMaxFilesPerScan();
+    let fileLimitReached = false;
+    const fileDeadlineMs = getFileDeadlineMs();
+    let filesTimedOut = 0;
+    let depthLimitedFolders = 0;
+    let sentinelSkippedFolders = 0;
+    const errorCollector = createScanErrorCollector();
+    const resolveOverride = createPathOverrideResolver(
+      await loadPathOverrides(library.id),
+      originalPath,
+    );
     const mediaEntries = await collectMediaEntries(rootPath, {
       maxDepth: effectiveMaxDepth,
       mediaType,
       fileExtensions: finalFileExtensions,
+      includeExtras,
+      maxFiles,
+      resolveOverride,
+      errorCollector,
+      onProgress: (count) => {
+        // Keep clients informed while a large folder is still being walked
+        if (count % SCAN_PROGRESS_INTERVAL === 0) {
+          wsManager.sendScanProgress({
+            phase: "scanning",
+            progress: 0,
+            current: count,
+            total: 0,
+            message: `Scanning directory... found ${count} media items`,
+            libraryId: library.id,
+          });
+        }
+      },
+      onLimitReached: () => {
+        fileLimitReached = true;
+      },
+      fileDeadlineMs,
+      onFileTimeout: () => {
+        filesTimedOut++;
+      },
+      onDepthLimit: () => {
+        depthLimitedFolders++;
+      },
+      onSentinelSkip: () => {
+        sentinelSkippedFolders++;
+      },
+      trailerFolders: getTrailerFolders(),
+      onlyFiles: files,
     });
 
+    if (fileLimitReached) {
+      logger.warn(`⚠️  ${getFileLimitMessage(maxFiles)}`);
+    }
+
     logger.info(`\n✓ Found ${mediaEntries.length} media items\n`);
 
+    // Titles that fell back to the raw file name are unlikely to match TMDB
+    const lowConfidenceTitles = mediaEntries.filter(
+      (e) => e.extractedIds.titleFallback,
+    ).length;
+
     // Send scanning complete progress
     wsManager.sendScanProgress({
       phase: "scanning",
@@ -130,10 +225,19 @@ export const scanServices = {
     if (mediaEntries.length === 0) {
       logger.info("⚠️  No media items found. Scan complete.\n");
 
+      logErrorDirectories(errorCollector);
+      // Nothing found because folders were skipped is not a full scan
+      if (!files && sentinelSkippedFolders === 0) {
+        await recordSuccessfulScan(library.id);
+      }
+
       wsManager.sendScanComplete({
         libraryId: library.id,
         totalItems: 0,
         message: `Scan complete! No media items found in "${library.name}"`,
+        sentinelSkippedFolders,
+        errorCount: errorCollector.getTotal(),
+        errorDirectories: errorCollector.getTopDirectories(),
       });
 
       return {
@@ -141,6 +245,9 @@ export const scanServices = {
         libraryName: library.name,
         totalFiles: 0,
         totalSaved: 0,
+        sentinelSkippedFolders,
+        errorCount: errorCollector.getTotal(),
+        errorDirectories: errorCollector.getTopDirectories(),
         cacheStats: {
           metadataFromCache: 0,
           metadataFromTMDB: 0,
@@ -166,6 +273,8 @@ export const scanServices = {
     let existingMetadataMap = new Map<string, TmdbMetadata>();
     if (!rescan) {
       logger.info("🔍 Checking for existing metadata in database...");
+      await applyUnchangedMatches(mediaEntries, mediaType, originalPath);
+
       const tmdbIdsToCheck = mediaEntries
         .filter((e) => e.extractedIds.tmdbId)
         .map((e) => e.extractedIds.tmdbId!);
@@ -215,6 +324,11 @@ export const scanServices = {
 
     const mediaFilesToSave = mediaEntries.filter((e) => !e.isDirectory);
     let savedCount = 0;
+    let extrasSaved = 0;
+    let unmatchedTrailers = 0;
+    // Only a capped sample of titles is kept, so huge libraries stay bounded
+    let newItemsCount = 0;
+    const newItemTitles: string[] = [];
 
     wsManager.sendScanProgress({
       phase: "saving",
@@ -225,49 +339,127 @@ export const scanServices = {
       libraryId: library.id,
     });
 
-    for (const mediaEntry of mediaEntries) {
-      // Only save files (not directories)
-      if (!mediaEntry.isDirectory) {
-        try {
-          await saveMediaToDatabase(
-            mediaEntry,
-            mediaType,
-            tmdbApiKey,
-            episodeMetadataCache,
-            library.id,
-            originalPath,
-          );
-          savedCount++;
-
-          // Send progress update every 2 items or at 100%
-          if (savedCount % 2 === 0 || savedCount === mediaFilesToSave.length) {
-            const progress =
-              75 + Math.floor((savedCount / mediaFilesToSave.length) * 25);
-            wsManager.sendScanProgress({
-              phase: "saving",
-              progress,
-              current: savedCount,
-              total: mediaFilesToSave.length,
-              message: `Saving to database: ${savedCount}/${mediaFilesToSave.length}`,
-              libraryId: library.id,
+    const resultsWriter = await openScanResultsFile({
+      libraryId: library.id,
+      mediaType,
+      originalPath,
+    });
+
+    try {
+      for (const mediaEntry of mediaEntries) {
+        // Only save files (not directories)
+        if (!mediaEntry.isDirectory) {
+          try {
+            const saved = await saveThroughOutages(
+              () =>
+                saveMediaToDatabase(
+                  mediaEntry,
+                  mediaType,
+                  tmdbApiKey,
+                  episodeMetadataCache,
+                  library.id,
+                  originalPath,
+                ),
+              mediaEntry.name,
+            );
+            if (saved?.isNew) {
+              newItemsCount++;
+              if (newItemTitles.length < MAX_NEW_ITEM_TITLES) {
+                newItemTitles.push(saved.title);
+              }
+            }
+            if (saved?.isExtra) {
+              extrasSaved++;
+            }
+            if (!saved && mediaEntry.fromTrailerFolder) {
+              unmatchedTrailers++;
+            }
+            resultsWriter?.write(
+              mediaEntry,
+              saved
+                ? {
+                    outcome: saved.inferiorTo ? "inferior-copy" : "saved",
+                    title: saved.title,
+                    isNew: saved.isNew,
+                  }
+                : {
+                    outcome: mediaEntry.fromTrailerFolder
+                      ? "not-in-library"
+                      : "no-match",
+                  },
+            );
+            savedCount++;
+
+            // Send progress update every 2 items or at 100%
+            if (
+              savedCount % 2 === 0 ||
+              savedCount === mediaFilesToSave.length
+            ) {
+              const progress =
+                75 + Math.floor((savedCount / mediaFilesToSave.length) * 25);
+              wsManager.sendScanProgress({
+                phase: "saving",
+                progress,
+                current: savedCount,
+                total: mediaFilesToSave.length,
+                message: `Saving to database: ${savedCount}/${mediaFilesToSave.length}`,
+                libraryId: library.id,
+              });
+            }
+          } catch (error) {
+            // The database stayed down: fail the scan rather than drop files
+            if (error instanceof DatabaseOutageError) {
+              throw error;
+            }
+
+            const message =
+              error instanceof Error ? error.message : String(error);
+            if (error instanceof OperationTimeoutError) {
+              filesTimedOut++;
+              logger.warn(
+                `⏱️  Gave up on ${mediaEntry.name} after ${error.timeoutMs / 1000}s`,
+              );
+            } else {
+              logger.error(`Failed to save ${mediaEntry.name}: ${message}`);
+            }
+            resultsWriter?.write(mediaEntry, {
+              outcome:
+                error instanceof OperationTimeoutError ? "timeout" : "failed",
+              error: message,
             });
+            errorCollector.record(mediaEntry.path, error);
+            savedCount++;
           }
-        } catch (error) {
-          logger.error(
-            `Failed to save ${mediaEntry.name}: ${error instanceof Error ? error.message : error}`,
-          );
-          savedCount++;
         }
       }
+    } finally {
+      await resultsWriter?.close();
     }
 
     logger.info("\n✅ Scan complete!\n");
+    logErrorDirectories(errorCollector);
+    if (!files && !fileLimitReached) {
+      await recordSuccessfulScan(library.id);
+    }
 
     // Send completion message
     wsManager.sendScanComplete({
       libraryId: library.id,
       totalItems: savedCount,
-      message: `Scan complete! Saved ${savedCount} items to library "${library.name}"`,
+      message: fileLimitReached
+        ? `Scan stopped at the file limit! Saved ${savedCount} items to library "${library.name}"`
+        : `Scan complete! Saved ${savedCount} items to library "${library.name}"`,
+      newItemsCount,
+      newItemTitles,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
     });
 
     return {
@@ -275,6 +467,15 @@ export const scanServices = {
       libraryName: library.name,
       totalFiles: mediaEntries.length,
       totalSaved: savedCount,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
       cacheStats: {
         metadataFromCache: metadataStats.metadataFromCache,
         metadataFromTMDB: metadataStats.metadataFromTMDB,
@@ -283,6 +484,219 @@ export const scanServices = {
     };
   },
 
+  /**
+   * Count what a directory holds without scanning it
+   * Walks only; nothing is parsed, looked up or written to the database.
+   */
+  analyze: async (
+    rootPath: string,
+    options: {
+      maxDepth?: number;
+      maxFiles?: number;
+      timeLimitSeconds?: number;
+      originalPath?: string;
+    },
+  ) => {
+    const analysis = await analyzeDirectory(rootPath, {
+      maxDepth: options.maxDepth,
+      maxFiles: options.maxFiles,
+      timeLimitMs:
+        options.timeLimitSeconds !== undefined
+          ? options.timeLimitSeconds * 1000
+          : undefined,
+      fileDeadlineMs: getFileDeadlineMs(),
+      originalPath: options.originalPath,
+    });
+
+    logger.info(
+      `🔎 Analyzed ${rootPath}: ${analysis.totalFiles} files in ${analysis.totalDirectories} folders${analysis.stoppedBy ? ` (stopped at the ${analysis.stoppedBy})` : ""}`,
+    );
+
+    return analysis;
+  },
+
+  /**
+   * Scan a folder of comic archives (.cbz)
+   * Each archive is saved as a comic; no metadata provider is involved.
+   */
+  postComics: async (
+    rootPath: string,
+    options: {
+      maxDepth?: number;
+      libraryName?: string;
+      originalPath?: string;
+    },
+  ) => {
+    const { libraryName, originalPath } = options;
+
+    // Comics: max 3 levels (/comics/Publisher/Series/Issue 1.cbz)
+    const effectiveMaxDepth = options.maxDepth ?? 3;
+
+    const displayPath = originalPath || rootPath;
+    const finalLibraryName = libraryName || `Library - ${displayPath}`;
+    const librarySlug = finalLibraryName
+      .toLowerCase()
+      .replace(/[^a-z0-9]+/g, "-")
+      .replace(/(^-|-$)/g, "");
+
+    logger.info(`📚 Creating/getting library: ${finalLibraryName}`);
+
+    const library = await prisma.library.upsert({
+      where: { slug: librarySlug },
+      update: {
+        name: finalLibraryName,
+        libraryPath: displayPath,
+        libraryType: MediaType.COMIC,
+        isLibrary: true,
+      },
+      create: {
+        name: finalLibraryName,
+        slug: librarySlug,
+        libraryPath: displayPath,
+        libraryType: MediaType.COMIC,
+        isLibrary: true,
+      },
+    });
+
+    setLogContextFields({ libraryId: library.id, mediaType: "comic" });
+    logger.info(`✓ Library ready: ${library.name} (ID: ${library.id})\n`);
+
+    // Phase 1: Scan directory structure
+    logger.info("📁 Phase 1: Scanning for comic archives...");
+    logger.info(`Max depth: ${effectiveMaxDepth} (comic mode)`);
+    wsManager.sendScanProgress({
+      phase: "scanning",
+      progress: 0,
+      current: 0,
+      total: 0,
+      message: "Starting directory scan...",
+      libraryId: library.id,
+    });
+
+    const maxFiles = getMaxFilesPerScan();
+    let fileLimitReached = false;
+    const fileDeadlineMs = getFileDeadlineMs();
+    let filesTimedOut = 0;
+    const errorCollector = createScanErrorCollector();
+    const comics = await collectComicEntries(rootPath, {
+      maxDepth: effectiveMaxDepth,
+      maxFiles,
+      fileDeadlineMs,
+      errorCollector,
+      onProgress: (count) => {
+        if (count % SCAN_PROGRESS_INTERVAL === 0) {
+          wsManager.sendScanProgress({
+            phase: "scanning",
+            progress: 0,
+            current: count,
+            total: 0,
+            message: `Scanning directory... found ${count} comics`,
+            libraryId: library.id,
+          });
+        }
+      },
+      onLimitReached: () => {
+        fileLimitReached = true;
+      },
+      onFileTimeout: () => {
+        filesTimedOut++;
+      },
+    });
+
+    if (fileLimitReached) {
+      logger.warn(`⚠️  ${getFileLimitMessage(maxFiles)}`);
+    }
+
+    logger.info(`\n✓ Found ${comics.length} comics\n`);
+
+    // Phase 2: Read page counts and save to database
+    logger.info("💾 Phase 2: Reading archives and saving to database...");
+    wsManager.sendScanProgress({
+      phase: "saving",
+      progress: 25,
+      current: 0,
+      total: comics.length,
+      message: `Found ${comics.length} comics, saving to database...`,
+      libraryId: library.id,
+    });
+
+    let savedCount = 0;
+    let newItemsCount = 0;
+    const newItemTitles: string[] = [];
+
+    for (const comic of comics) {
+      try {
+        const saved = await saveComicToDatabase(
+          comic,
+          library.id,
+          originalPath,
+          fileDeadlineMs,
+        );
+        if (saved.isNew) {
+          newItemsCount++;
+          if (newItemTitles.length < MAX_NEW_ITEM_TITLES) {
+            newItemTitles.push(saved.title);
+          }
+        }
+      } catch (error) {
+        if (error instanceof OperationTimeoutError) {
+          filesTimedOut++;
+          logger.warn(
+            `⏱️  Gave up on ${comic.name} after ${error.timeoutMs / 1000}s`,
+          );
+        } else {
+          logger.error(
+            `Failed to save ${comic.name}: ${error instanceof Error ? error.message : error}`,
+          );
+        }
+        errorCollector.record(comic.path, error);
+      }
+      savedCount++;
+
+      if (savedCount % 10 === 0 || savedCount === comics.length) {
+        wsManager.sendScanProgress({
+          phase: "saving",
+          progress: 25 + Math.floor((savedCount / comics.length) * 75),
+          current: savedCount,
+          total: comics.length,
+          message: `Saving to database: ${savedCount}/${comics.length}`,
+          libraryId: library.id,
+        });
+      }
+    }
+
+    logger.info("\n✅ Scan complete!\n");
+    logErrorDirectories(errorCollector);
+    if (!fileLimitReached) {
+      await recordSuccessfulScan(library.id);
+    }
+
+    wsManager.sendScanComplete({
+      libraryId: library.id,
+      totalItems: savedCount,
+      message: fileLimitReached
+        ? `Scan stopped at the file limit! Saved ${savedCount} comics to library "${library.name}"`
+        : `Scan complete! Saved ${savedCount} comics to library "${library.name}"`,
+      newItemsCount,
+      newItemTitles,
+      fileLimitReached,
+      filesTimedOut,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
+    });
+
+    return {
+      libraryId: library.id,
+      libraryName: library.name,
+      totalFiles: comics.length,
+      totalSaved: savedCount,
+      fileLimitReached,
+      filesTimedOut,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
+    };
+  },
+
   /**
    * Batch scanning service - processes large libraries in manageable batches
    * Ideal for remote/slow storage (FTP, SMB, etc.)
@@ -297,6 +711,10 @@ export const scanServices = {
       libraryName?: string;
       rescan?: boolean;
       originalPath?: string;
+      includeExtras?: boolean;
+      folders?: string[]; // Top-level folders to scan instead of all of them
+      recordSkips?: boolean; // Record every skipped file and why on the job
+      requester?: ScanRequester; // Who asked for the scan, kept on the job
     },
   ) => {
     const {
@@ -307,6 +725,9 @@ export const scanServices = {
       libraryName,
       rescan = false,
       originalPath,
+      includeExtras = false,
+      recordSkips = false,
+      requester,
     } = options;
 
     // Set reasonable default maxDepth based on media type if not provided
@@ -350,6 +771,7 @@ export const scanServices = {
       },
     });
 
+    setLogContextFields({ libraryId: library.id, mediaType });
     logger.info(`✓ Library ready: ${library.name} (ID: ${library.id})\n`);
 
     // Step 1: Discover folders to scan
@@ -363,14 +785,26 @@ export const scanServices = {
       libraryId: library.id,
     });
 
-    const folders = await discoverFoldersToScan(rootPath, mediaType);
+    let rootSkipped = false;
+    const folders = options.folders?.length
+      ? options.folders
+      : await discoverFoldersToScan(rootPath, mediaType, {
+          onSentinelSkip: () => {
+            rootSkipped = true;
+          },
+        });
 
     if (folders.length === 0) {
       logger.info("⚠️  No folders found to scan.");
+      // A root skipped by a sentinel file was not scanned at all
+      if (!rootSkipped) {
+        await recordSuccessfulScan(library.id);
+      }
       wsManager.sendScanComplete({
         libraryId: library.id,
         totalItems: 0,
         message: `No ${mediaType === "tv" ? "shows" : "movies"} found in "${library.name}"`,
+        sentinelSkippedFolders: rootSkipped ? 1 : 0,
       });
 
       return {
@@ -388,7 +822,21 @@ export const scanServices = {
       displayPath,
       mediaType === "tv" ? MediaType.TV_SHOW : MediaType.MOVIE,
       folders,
+      {
+        rootPath,
+        mediaType,
+        maxDepth,
+        fileExtensions,
+        libraryName,
+        rescan,
+        originalPath,
+        includeExtras,
+        recordSkips,
+        targeted: Boolean(options.folders?.length),
+      },
+      requester,
     );
+    setLogContextFields({ scanJobId });
 
     wsManager.sendScanProgress({
       phase: "batching",
@@ -402,73 +850,147 @@ export const scanServices = {
 
     // Step 3: Process batches
     let totalSaved = 0;
+    let extrasSaved = 0;
     let batchNumber = 0;
+    const maxFiles = getMaxFilesPerScan();
+    let filesFound = 0;
+    let fileLimitReached = false;
+    let filesTimedOut = 0;
+    let lowConfidenceTitles = 0;
+    let depthLimitedFolders = 0;
+    let sentinelSkippedFolders = 0;
+    let unmatchedTrailers = 0;
+    const pathOverrides = await loadPathOverrides(library.id);
+    const errorCollector = createScanErrorCollector();
+    const skipRecorder = recordSkips
+      ? await createScanSkipRecorder(scanJobId)
+      : undefined;
+    const resultsWriter = await openScanResultsFile({
+      libraryId: library.id,
+      mediaType,
+      scanJobId,
+      originalPath,
+    });
 
-    while (true) {
-      const batch = await getNextBatch(scanJobId);
-
-      if (!batch || batch.length === 0) {
-        break;
-      }
-
-      batchNumber++;
-      logger.info(
-        `\n📦 Processing batch ${batchNumber} (${batch.length} folders)`,
-      );
-
-      const result = await processFolderBatch(scanJobId, batch, {
-        rootPath,
-        mediaType,
-        tmdbApiKey,
-        libraryId: library.id,
-        maxDepth: effectiveMaxDepth,
-        fileExtensions: finalFileExtensions,
-        rescan,
-        originalPath,
-      });
-
-      totalSaved += result.totalSaved;
-
-      // Mark batch as processed
-      await markBatchProcessed(
-        scanJobId,
-        result.processedFolders,
-        result.failedFolders,
-        result.totalSaved,
-      );
+    startScanThroughput(scanJobId);
+    try {
+      while (true) {
+        const batch = await getNextBatch(scanJobId);
 
-      // Send batch completion update
-      const scanJob = await prisma.scanJob.findUnique({
-        where: { id: scanJobId },
-      });
+        if (!batch || batch.length === 0) {
+          break;
+        }
 
-      if (scanJob) {
-        const progressPercent = Math.floor(
-          ((scanJob.processedCount + scanJob.failedCount) /
-            scanJob.totalFolders) *
-            100,
+        batchNumber++;
+        logger.info(
+          `\n📦 Processing batch ${batchNumber} (${batch.length} folders)`,
         );
 
-        wsManager.sendScanProgress({
-          phase: "batching",
-          progress: progressPercent,
-          current: scanJob.processedCount + scanJob.failedCount,
-          total: scanJob.totalFolders,
-          message: `Batch ${scanJob.currentBatch}/${scanJob.totalBatches} complete: ${result.processedFolders.length} success, ${result.failedFolders.length} failed (${scanJob.processedCount + scanJob.failedCount}/${scanJob.totalFolders} folders)`,
+        const result = await processFolderBatch(scanJobId, batch, {
+          rootPath,
+          mediaType,
+          tmdbApiKey,
           libraryId: library.id,
+          maxDepth: effectiveMaxDepth,
+          fileExtensions: finalFileExtensions,
+          rescan,
+          originalPath,
+          includeExtras,
+          maxFiles: maxFiles - filesFound,
+          pathOverrides,
+          errorCollector,
+          skipRecorder,
+          resultsWriter,
+        });
+
+        totalSaved += result.totalSaved;
+        extrasSaved += result.extrasSaved;
+        filesFound += result.filesFound;
+        filesTimedOut += result.filesTimedOut;
+        lowConfidenceTitles += result.lowConfidenceTitles;
+        depthLimitedFolders += result.depthLimitedFolders;
+        sentinelSkippedFolders += result.sentinelSkippedFolders;
+        unmatchedTrailers += result.unmatchedTrailers;
+
+        // Mark batch as processed
+        await markBatchProcessed(
           scanJobId,
+          result.processedFolders,
+          result.failedFolders,
+          result.totalSaved,
+        );
+        await saveScanJobErrors(scanJobId, errorCollector);
+        await skipRecorder?.flush();
+
+        // Stop here and leave the remaining folders pending
+        if (result.fileLimitReached) {
+          fileLimitReached = true;
+          await markScanJobFileLimitReached(scanJobId, maxFiles);
+          break;
+        }
+
+        // Send batch completion update
+        const scanJob = await prisma.scanJob.findUnique({
+          where: { id: scanJobId },
         });
+
+        if (scanJob) {
+          const progressPercent = Math.floor(
+            ((scanJob.processedCount + scanJob.failedCount) /
+              scanJob.totalFolders) *
+              100,
+          );
+
+          wsManager.sendScanProgress({
+            phase: "batching",
+            progress: progressPercent,
+            current: scanJob.processedCount + scanJob.failedCount,
+            total: scanJob.totalFolders,
+            message: `Batch ${scanJob.currentBatch}/${scanJob.totalBatches} complete: ${result.processedFolders.length} success, ${result.failedFolders.length} failed (${scanJob.processedCount + scanJob.failedCount}/${scanJob.totalFolders} folders)`,
+            libraryId: library.id,
+            scanJobId,
+          });
+        }
       }
+    } catch (error) {
+      if (error instanceof DatabaseOutageError) {
+        await markScanJobDatabaseOutage(scanJobId, error);
+      }
+      throw error;
+    } finally {
+      clearScanActivity(scanJobId);
+      await resultsWriter?.close();
+    }
+
+    if (!fileLimitReached) {
+      logger.info("\n✅ Batch scan complete!\n");
     }
+    logErrorDirectories(errorCollector);
 
-    logger.info("\n✅ Batch scan complete!\n");
+    const additionSummary = await getScanAdditionSummary(
+      scanJobId,
+      MAX_NEW_ITEM_TITLES,
+    );
 
     // Send final completion message
     wsManager.sendScanComplete({
       libraryId: library.id,
       totalItems: totalSaved,
-      message: `Batch scan complete! Saved ${totalSaved} items to library "${library.name}"`,
+      message: fileLimitReached
+        ? `Batch scan paused at the file limit! Saved ${totalSaved} items to library "${library.name}"`
+        : `Batch scan complete! Saved ${totalSaved} items to library "${library.name}"`,
       scanJobId,
+      origin: requester?.origin ?? null,
+      ...additionSummary,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
     });
 
     // Get final scan job stats
@@ -483,6 +1005,15 @@ export const scanServices = {
       foldersProcessed: finalScanJob?.processedCount || 0,
       foldersFailed: finalScanJob?.failedCount || 0,
       totalItemsSaved: finalScanJob?.totalItemsSaved || 0,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
       scanJobId,
     };
   },
@@ -509,6 +1040,11 @@ export const scanServices = {
       throw new Error("Scan job is already in progress");
     }
 
+    setLogContextFields({
+      scanJobId,
+      libraryId: scanJob.libraryId,
+      mediaType: scanJob.mediaType === MediaType.TV_SHOW ? "tv" : "movie",
+    });
     logger.info(
       `🔄 Resuming scan job ${scanJobId} for library: ${scanJob.library.name}`,
     );
@@ -518,22 +1054,56 @@ export const scanServices = {
       where: { id: scanJobId },
       data: {
         status: "IN_PROGRESS",
+        errorMessage: null,
         startedAt: scanJob.startedAt || new Date(),
       },
     });
 
     const mediaType = scanJob.mediaType === MediaType.TV_SHOW ? "tv" : "movie";
-    const rootPath = scanJob.scanPath;
 
-    // Get default file extensions
-    const finalFileExtensions = getDefaultVideoExtensions();
+    // Reuse the original request options when the job stored them
+    const requestPayload = parseScanRequestPayload(scanJob.requestPayload);
+    const rootPath = requestPayload?.rootPath || scanJob.scanPath;
+    const originalPath = requestPayload?.originalPath;
 
-    // Set maxDepth based on media type
-    const effectiveMaxDepth = mediaType === "tv" ? 4 : 2;
+    // Use the requested file extensions, or the defaults
+    const finalFileExtensions =
+      requestPayload?.fileExtensions && requestPayload.fileExtensions.length > 0
+        ? requestPayload.fileExtensions
+        : getDefaultVideoExtensions();
+
+    // Set maxDepth based on media type unless the request specified one
+    const effectiveMaxDepth =
+      requestPayload?.maxDepth ?? (mediaType === "tv" ? 4 : 2);
 
     // Process remaining batches
     let totalSaved = 0;
+    let extrasSaved = 0;
     let batchNumber = 0;
+    const maxFiles = getMaxFilesPerScan();
+    let filesFound = 0;
+    let fileLimitReached = false;
+    let filesTimedOut = 0;
+    let lowConfidenceTitles = 0;
+    let depthLimitedFolders = 0;
+    let sentinelSkippedFolders = 0;
+    let unmatchedTrailers = 0;
+    const pathOverrides = await loadPathOverrides(scanJob.libraryId);
+    // Keep counting from where the earlier run stopped
+    const errorCollector = createScanErrorCollector({
+      initial: parseErrorDirectories(scanJob.errorDirectories),
+      initialTotal: scanJob.errorCount,
+    });
+    const skipRecorder = requestPayload?.recordSkips
+      ? await createScanSkipRecorder(scanJobId)
+      : undefined;
+    const resultsWriter = await openScanResultsFile({
+      libraryId: scanJob.libraryId,
+      mediaType,
+      scanJobId,
+      originalPath,
+      continueScan: true,
+    });
 
     wsManager.sendScanProgress({
       phase: "batching",
@@ -549,75 +1119,131 @@ export const scanServices = {
       scanJobId,
     });
 
-    while (true) {
-      const batch = await getNextBatch(scanJobId);
-
-      if (!batch || batch.length === 0) {
-        break;
-      }
-
-      batchNumber++;
-      logger.info(
-        `\n📦 Processing batch ${batchNumber} (${batch.length} folders)`,
-      );
-
-      const result = await processFolderBatch(scanJobId, batch, {
-        rootPath,
-        mediaType,
-        tmdbApiKey,
-        libraryId: scanJob.libraryId,
-        maxDepth: effectiveMaxDepth,
-        fileExtensions: finalFileExtensions,
-        rescan: false,
-      });
-
-      totalSaved += result.totalSaved;
-
-      // Mark batch as processed
-      await markBatchProcessed(
-        scanJobId,
-        result.processedFolders,
-        result.failedFolders,
-        result.totalSaved,
-      );
+    startScanThroughput(scanJobId);
+    try {
+      while (true) {
+        const batch = await getNextBatch(scanJobId);
 
-      // Send batch completion update
-      const updatedScanJob = await prisma.scanJob.findUnique({
-        where: { id: scanJobId },
-      });
+        if (!batch || batch.length === 0) {
+          break;
+        }
 
-      if (updatedScanJob) {
-        const progressPercent = Math.floor(
-          ((updatedScanJob.processedCount + updatedScanJob.failedCount) /
-            updatedScanJob.totalFolders) *
-            100,
+        batchNumber++;
+        logger.info(
+          `\n📦 Processing batch ${batchNumber} (${batch.length} folders)`,
         );
 
-        wsManager.sendScanProgress({
-          phase: "batching",
-          progress: progressPercent,
-          current: updatedScanJob.processedCount + updatedScanJob.failedCount,
-          total: updatedScanJob.totalFolders,
-          message: `Batch ${updatedScanJob.currentBatch}/${updatedScanJob.totalBatches} complete: ${result.processedFolders.length} success, ${result.failedFolders.length} failed (${updatedScanJob.processedCount + updatedScanJob.failedCount}/${updatedScanJob.totalFolders} folders)`,
+        const result = await processFolderBatch(scanJobId, batch, {
+          rootPath,
+          mediaType,
+          tmdbApiKey,
           libraryId: scanJob.libraryId,
+          maxDepth: effectiveMaxDepth,
+          fileExtensions: finalFileExtensions,
+          rescan: requestPayload?.rescan ?? false,
+          originalPath,
+          includeExtras: requestPayload?.includeExtras ?? false,
+          maxFiles: maxFiles - filesFound,
+          pathOverrides,
+          errorCollector,
+          skipRecorder,
+          resultsWriter,
+        });
+
+        totalSaved += result.totalSaved;
+        extrasSaved += result.extrasSaved;
+        filesFound += result.filesFound;
+        filesTimedOut += result.filesTimedOut;
+        lowConfidenceTitles += result.lowConfidenceTitles;
+        depthLimitedFolders += result.depthLimitedFolders;
+        sentinelSkippedFolders += result.sentinelSkippedFolders;
+        unmatchedTrailers += result.unmatchedTrailers;
+
+        // Mark batch as processed
+        await markBatchProcessed(
           scanJobId,
+          result.processedFolders,
+          result.failedFolders,
+          result.totalSaved,
+        );
+        await saveScanJobErrors(scanJobId, errorCollector);
+        await skipRecorder?.flush();
+
+        // Stop here and leave the remaining folders pending
+        if (result.fileLimitReached) {
+          fileLimitReached = true;
+          await markScanJobFileLimitReached(scanJobId, maxFiles);
+          break;
+        }
+
+        // Send batch completion update
+        const updatedScanJob = await prisma.scanJob.findUnique({
+          where: { id: scanJobId },
         });
+
+        if (updatedScanJob) {
+          const progressPercent = Math.floor(
+            ((updatedScanJob.processedCount + updatedScanJob.failedCount) /
+              updatedScanJob.totalFolders) *
+              100,
+          );
+
+          wsManager.sendScanProgress({
+            phase: "batching",
+            progress: progressPercent,
+            current:
+              updatedScanJob.processedCount + updatedScanJob.failedCount,
+            total: updatedScanJob.totalFolders,
+            message: `Batch ${updatedScanJob.currentBatch}/${updatedScanJob.totalBatches} complete: ${result.processedFolders.length} success, ${result.failedFolders.length} failed (${updatedScanJob.processedCount + updatedScanJob.failedCount}/${updatedScanJob.totalFolders} folders)`,
+            libraryId: scanJob.libraryId,
+            scanJobId,
+          });
+        }
       }
+    } catch (error) {
+      if (error instanceof DatabaseOutageError) {
+        await markScanJobDatabaseOutage(scanJobId, error);
+      }
+      throw error;
+    } finally {
+      clearScanActivity(scanJobId);
+      await resultsWriter?.close();
     }
 
-    logger.info("\n✅ Resumed scan complete!\n");
+    if (!fileLimitReached) {
+      logger.info("\n✅ Resumed scan complete!\n");
+    }
+    logErrorDirectories(errorCollector);
 
     // Get final scan job stats
     const finalScanJob = await prisma.scanJob.findUnique({
       where: { id: scanJobId },
     });
 
+    const additionSummary = await getScanAdditionSummary(
+      scanJobId,
+      MAX_NEW_ITEM_TITLES,
+    );
+
     // Send final completion message
     wsManager.sendScanComplete({
       libraryId: scanJob.libraryId,
       totalItems: finalScanJob?.totalItemsSaved || 0,
-      message: `Resumed scan complete! Total: ${finalScanJob?.totalItemsSaved || 0} items in library "${scanJob.library.name}"`,
+      message: fileLimitReached
+        ? `Resumed scan paused at the file limit! Total: ${finalScanJob?.totalItemsSaved || 0} items in library "${scanJob.library.name}"`
+        : `Resumed scan complete! Total: ${finalScanJob?.totalItemsSaved || 0} items in library "${scanJob.library.name}"`,
       scanJobId,
+      origin: scanJob.origin,
+      ...additionSummary,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
     });
 
     return {
@@ -627,6 +1253,15 @@ export const scanServices = {
       foldersProcessed: finalScanJob?.processedCount || 0,
       foldersFailed: finalScanJob?.failedCount || 0,
       totalItemsSaved: finalScanJob?.totalItemsSaved || 0,
+      extrasSaved,
+      fileLimitReached,
+      filesTimedOut,
+      lowConfidenceTitles,
+      depthLimitedFolders,
+      sentinelSkippedFolders,
+      unmatchedTrailers,
+      errorCount: errorCollector.getTotal(),
+      errorDirectories: errorCollector.getTopDirectories(),
       scanJobId,
     };
   },
@@ -638,6 +1273,28 @@ export const scanServices = {
     return getScanJobStatus(scanJobId);
   },
 
+  /**
+   * List scan jobs, newest first, optionally for one library
+   */
+  listJobs: async (options: {
+    libraryId?: string;
+    skip: number;
+    take: number;
+  }) => {
+    return listScanJobs(options);
+  },
+
+  /**
+   * List the files a scan job skipped, optionally of one reason
+   * Returns null if the job does not exist
+   */
+  listSkips: async (
+    scanJobId: string,
+    options: { reason?: SkipReason; skip: number; take: number },
+  ) => {
+    return listScanSkips(scanJobId, options);
+  },
+
   /**
    * Manually cleanup stale jobs
    */
@@ -29,4 +29,64 @@ export interface TmdbMetadata {
 export interface MediaEntry extends FileEntry {
   extractedIds: ExtractedIds;
   metadata?: TmdbMetadata;
+  // TV extras (files in an Extras/Featurettes/... folder of a show) are
+  // saved as season 0 entries titled from the file name. Movie extras
+  // ("Movie (2010)-trailer.mkv") are attached to their movie.
+  isExtra?: boolean;
+  extraTitle?: string;
+  extraType?: string; // Movie extras only: type from the filename suffix
+  // Movie extras only: found in a central trailer folder, so linked only to
+  // a movie already in the library and skipped otherwise
+  fromTrailerFolder?: boolean;
+}
+
+// Who asked for a scan, recorded on its ScanJob for auditing
+export interface ScanRequester {
+  origin: string | null; // Service that started it, e.g. "scheduler"
+  userAgent: string | null;
+  remoteAddress: string | null; // Masked when SCANNER_REDACT_REQUESTER_IP is set
+}
+
+// Scan request options stored on a ScanJob so it can be resumed after a restart
+// (the TMDB API key is read from settings again and never stored here)
+export interface ScanRequestPayload {
+  rootPath: string;
+  mediaType: "movie" | "tv";
+  maxDepth?: number;
+  fileExtensions?: string[];
+  libraryName?: string;
+  rescan?: boolean;
+  originalPath?: string;
+  includeExtras?: boolean;
+  recordSkips?: boolean;
+  targeted?: boolean; // Only some folders (subpaths), not a full library scan
+}
+
+// Result of POST /scan/analyze: what a directory holds, without saving anything
+export interface DirectoryAnalysis {
+  totalFiles: number;
+  totalDirectories: number;
+  totalBytes: number;
+  skippedEntries: number; // Hidden and system files and folders
+  unreadable: number; // Files and folders that could not be read
+  truncated: boolean;
+  stoppedBy: "file-limit" | "time-limit" | null;
+  durationMs: number;
+  // Most common first; "(none)" for files without an extension
+  extensions: Array<{
+    extension: string;
+    count: number;
+    totalBytes: number;
+    isVideo: boolean; // One of the default video extensions
+  }>;
+  sizeBuckets: Array<{
+    label: string;
+    minBytes: number;
+    maxBytes: number | null; // null for the open-ended last bucket
+    count: number;
+    totalBytes: number;
+  }>;
+  // Files per folder depth below the root (0 = directly in it)
+  depths: Array<{ depth: number; files: number }>;
+  largestFiles: Array<{ path: string; size: number }>;
 }
@@ -21,6 +21,8 @@ export const searchServices = {
             mode: "insensitive",
           },
           type: "MOVIE",
+          // A movie known only from its extras has no file to show
+          movie: { filePath: { not: null } },
         },
         include: {
           movie: true,
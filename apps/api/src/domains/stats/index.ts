@@ -0,0 +1,4 @@
+export * from "./stats.controller";
+export * from "./stats.routes";
+export * from "./stats.schema";
+export * from "./stats.services";
@@ -0,0 +1,21 @@
+import { Request, Response } from "express";
+import { statsServices } from "./stats.services";
+import { sendSuccess, asyncHandler, NotFoundError } from "../../lib/utils";
+import { z } from "zod";
+import { technicalStatsSchema } from "./stats.schema";
+
+type TechnicalStatsQuery = z.infer<typeof technicalStatsSchema>;
+
+export const statsControllers = {
+  /**
+   * Get file counts and sizes by resolution across the libraries
+   */
+  getTechnicalStats: asyncHandler(async (req: Request, res: Response) => {
+    const { libraryId } = req.validatedData as TechnicalStatsQuery;
+    const stats = await statsServices.getTechnicalStats(libraryId);
+    if (!stats) {
+      throw new NotFoundError("Library", libraryId);
+    }
+    return sendSuccess(res, stats);
+  }),
+};
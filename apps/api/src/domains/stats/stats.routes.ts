@@ -0,0 +1,86 @@
+import express, { Router } from "express";
+import { statsControllers } from "./stats.controller";
+import { validateQuery } from "../../lib/middleware";
+import { technicalStatsSchema } from "./stats.schema";
+
+const router: Router = express.Router();
+
+/**
+ * @swagger
+ * /api/v1/stats/technical:
+ *   get:
+ *     summary: File counts and sizes by resolution
+ *     description: |
+ *       Counts movie and episode files and adds up their sizes by
+ *       resolution, across all libraries or in one, for storage and
+ *       transcoding planning. Resolutions are parsed from file names:
+ *       1080i counts as 1080p, 576p and 480p as sd, and files without a
+ *       resolution tag as unknown. Every bucket is listed, even when empty.
+ *       Files marked missing by library verify are left out.
+ *
+ *       Results are cached for 5 minutes, so a scan that just finished may
+ *       not show yet. Video codec and HDR format are not stored, so they
+ *       are not broken down.
+ *     tags: [Stats]
+ *     parameters:
+ *       - in: query
+ *         name: libraryId
+ *         schema:
+ *           type: string
+ *         description: Only count files of this library
+ *     responses:
+ *       200:
+ *         description: Files by resolution
+ *         content:
+ *           application/json:
+ *             schema:
+ *               type: object
+ *               properties:
+ *                 success:
+ *                   type: boolean
+ *                   example: true
+ *                 data:
+ *                   type: object
+ *                   properties:
+ *                     libraryId:
+ *                       type: string
+ *                       nullable: true
+ *                     generatedAt:
+ *                       type: string
+ *                       format: date-time
+ *                     totals:
+ *                       type: object
+ *                       properties:
+ *                         files:
+ *                           type: number
+ *                           example: 1520
+ *                         totalBytes:
+ *                           type: string
+ *                           example: "8123456789012"
+ *                     byResolution:
+ *                       type: array
+ *                       items:
+ *                         type: object
+ *                         properties:
+ *                           resolution:
+ *                             type: string
+ *                             enum: ["2160p", "1440p", "1080p", "720p", sd, unknown]
+ *                           movies:
+ *                             type: number
+ *                           episodes:
+ *                             type: number
+ *                           files:
+ *                             type: number
+ *                           totalBytes:
+ *                             type: string
+ *                             description: Total size in bytes, as a string
+ *       404:
+ *         description: Library not found
+ */
+router.get(
+  "/technical",
+  validateQuery(technicalStatsSchema),
+  statsControllers.getTechnicalStats,
+);
+
+export default router;
@@ -0,0 +1,7 @@
+import { z } from "zod";
+
+export const technicalStatsSchema = z.object({
+  libraryId: z.string().min(1).optional(),
+});
+
+export type TechnicalStatsQuery = z.infer<typeof technicalStatsSchema>;
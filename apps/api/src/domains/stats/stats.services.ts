@@ -0,0 +1,158 @@
+import { prisma } from "../../lib/database";
+
+// Stats are recomputed at most this often per library filter
+const TECHNICAL_STATS_CACHE_MS = 5 * 60 * 1000;
+
+/**
+ * Resolution buckets, best first. Resolutions come from file names, so
+ * files without a resolution tag are counted as unknown.
+ */
+const RESOLUTION_BUCKETS = [
+  "2160p",
+  "1440p",
+  "1080p",
+  "720p",
+  "sd",
+  "unknown",
+] as const;
+
+type ResolutionBucket = (typeof RESOLUTION_BUCKETS)[number];
+
+// Stored resolutions are normalized when parsed ("4K" is stored as "2160p")
+function getResolutionBucket(resolution: string | null): ResolutionBucket {
+  switch (resolution) {
+    case "2160p":
+    case "1440p":
+    case "720p":
+      return resolution;
+    case "1080p":
+    case "1080i":
+      return "1080p";
+    case "576p":
+    case "480p":
+      return "sd";
+    default:
+      return "unknown";
+  }
+}
+
+interface TechnicalStats {
+  libraryId: string | null;
+  generatedAt: Date;
+  totals: { files: number; totalBytes: string };
+  byResolution: Array<{
+    resolution: ResolutionBucket;
+    movies: number;
+    episodes: number;
+    files: number;
+    totalBytes: string; // BigInt as string for JSON serialization
+  }>;
+}
+
+const technicalStatsCache = new Map<
+  string,
+  { expiresAt: number; stats: TechnicalStats }
+>();
+
+export const statsServices = {
+  /**
+   * Count movie and episode files and their total size by resolution,
+   * across all libraries or in one
+   * Rows without a file (such as a movie known only from its extras) and
+   * files marked missing by library verify are left out. Results are cached
+   * for 5 minutes. Returns null if the library does not exist.
+   */
+  getTechnicalStats: async (
+    libraryId?: string,
+  ): Promise<TechnicalStats | null> => {
+    const cacheKey = libraryId ?? "";
+    const cached = technicalStatsCache.get(cacheKey);
+    if (cached && cached.expiresAt > Date.now()) {
+      return cached.stats;
+    }
+
+    if (libraryId) {
+      const library = await prisma.library.findUnique({
+        where: { id: libraryId },
+        select: { id: true },
+      });
+      if (!library) {
+        return null;
+      }
+    }
+
+    const inLibrary = libraryId
+      ? { libraries: { some: { libraryId } } }
+      : undefined;
+
+    // Grouped in the database; there are only a handful of resolutions
+    const [movieGroups, episodeGroups] = await Promise.all([
+      prisma.movie.groupBy({
+        by: ["resolution"],
+        where: {
+          filePath: { not: null },
+          missingSince: null,
+          media: inLibrary,
+        },
+        _count: { _all: true },
+        _sum: { fileSize: true },
+      }),
+      prisma.episode.groupBy({
+        by: ["resolution"],
+        where: {
+          filePath: { not: null },
+          missingSince: null,
+          season: { tvShow: { media: inLibrary } },
+        },
+        _count: { _all: true },
+        _sum: { fileSize: true },
+      }),
+    ]);
+
+    const buckets = new Map(
+      RESOLUTION_BUCKETS.map((resolution) => [
+        resolution,
+        { movies: 0, episodes: 0, totalBytes: BigInt(0) },
+      ]),
+    );
+    for (const group of movieGroups) {
+      const bucket = buckets.get(getResolutionBucket(group.resolution))!;
+      bucket.movies += group._count._all;
+      bucket.totalBytes += group._sum.fileSize ?? BigInt(0);
+    }
+    for (const group of episodeGroups) {
+      const bucket = buckets.get(getResolutionBucket(group.resolution))!;
+      bucket.episodes += group._count._all;
+      bucket.totalBytes += group._sum.fileSize ?? BigInt(0);
+    }
+
+    // Every bucket is listed, empty ones too, so gaps stay visible
+    let totalFiles = 0;
+    let totalBytes = BigInt(0);
+    const byResolution = RESOLUTION_BUCKETS.map((resolution) => {
+      const bucket = buckets.get(resolution)!;
+      totalFiles += bucket.movies + bucket.episodes;
+      totalBytes += bucket.totalBytes;
+      return {
+        resolution,
+        movies: bucket.movies,
+        episodes: bucket.episodes,
+        files: bucket.movies + bucket.episodes,
+        totalBytes: bucket.totalBytes.toString(),
+      };
+    });
+
+    const stats: TechnicalStats = {
+      libraryId: libraryId ?? null,
+      generatedAt: new Date(),
+      totals: { files: totalFiles, totalBytes: totalBytes.toString() },
+      byResolution,
+    };
+    technicalStatsCache.set(cacheKey, {
+      expiresAt: Date.now() + TECHNICAL_STATS_CACHE_MS,
+      stats,
+    });
+
+    return stats;
+  },
+};
@@ -7,9 +7,24 @@ import {
   setupRoutes,
   prisma,
 } from "./lib";
-import { logger } from "./lib/utils";
+import {
+  logger,
+  initializeJunkMarkers,
+  runWithLogContext,
+} from "./lib/utils";
 import { wsManager } from "./lib/websocket";
 import { settingsManager } from "./core/config/settings";
+import {
+  enqueueScan,
+  initializeScannerVersion,
+  SERVICE_RESTARTED_ERROR,
+} from "./domains/scan/helpers";
+import {
+  startScanScheduler,
+  startStaleLibraryCheck,
+  stopScanScheduler,
+  stopStaleLibraryCheck,
+} from "./domains/scan/scan.scheduler";
 
 const app = express();
 const httpServer = createServer(app);
@@ -29,8 +44,34 @@ wsManager.initialize(httpServer);
 const startServer = async () => {
   try {
     logger.info("Starting DesterLib server...");
+
+    // Compile title-cleaning markers now so invalid configuration fails fast
+    const junkMarkers = initializeJunkMarkers();
+    logger.info(`🧹 Loaded ${junkMarkers.length} title junk markers`);
     await settingsManager.initialize();
 
+    // Validate SCAN_SCHEDULE before listening so a bad value fails fast
+    const scanIntervalMs = startScanScheduler();
+    if (scanIntervalMs) {
+      logger.info(
+        `⏰ Scheduled library scans every ${Math.round(scanIntervalMs / 60000)} minutes`,
+      );
+    }
+
+    const staleAfterMs = startStaleLibraryCheck();
+    if (staleAfterMs) {
+      logger.info(
+        `⏰ Reporting libraries not fully scanned for ${Math.round(staleAfterMs / 60000)} minutes as stale`,
+      );
+    }
+
+    const scannerVersion = await initializeScannerVersion();
+    if (scannerVersion) {
+      logger.info(
+        `🏷️  Recording scanner version ${scannerVersion} on scanned files`,
+      );
+    }
+
     const isFirstRun = await settingsManager.isFirstRun();
     const tmdbApiKey = await settingsManager.getTmdbApiKey();
 
@@ -65,16 +106,45 @@ const startServer = async () => {
         logger.info("⚠️  TMDB API key not configured - add it in settings");
       }
 
-      // Auto-resume interrupted scan jobs from previous session
+      // Scans that were running when the server stopped cannot continue
       logger.info(
         "🔍 Checking for interrupted scan jobs from previous session...",
       );
-      const interruptedJobs = await prisma.scanJob.findMany({
+      const { count: interruptedCount } = await prisma.scanJob.updateMany({
         where: {
           status: {
             in: ["IN_PROGRESS", "PENDING"],
           },
         },
+        data: { status: "FAILED", errorMessage: SERVICE_RESTARTED_ERROR },
+      });
+
+      if (interruptedCount > 0) {
+        logger.info(
+          `⏸️  Marked ${interruptedCount} interrupted scan job(s) as failed`,
+        );
+      }
+
+      // Resuming is opt-in
+      if (
+        process.env.SCANNER_RESUME_ON_START?.trim().toLowerCase() !== "true"
+      ) {
+        if (interruptedCount > 0) {
+          logger.info(
+            "⏭️  SCANNER_RESUME_ON_START is not true - interrupted scans will not be resumed",
+          );
+        } else {
+          logger.info("✅ No interrupted scans found");
+        }
+        return;
+      }
+
+      // Re-enqueue jobs interrupted by this or an earlier restart
+      const interruptedJobs = await prisma.scanJob.findMany({
+        where: {
+          status: "FAILED",
+          errorMessage: SERVICE_RESTARTED_ERROR,
+        },
         include: {
           library: {
             select: { name: true },
@@ -106,31 +176,33 @@ const startServer = async () => {
             continue;
           }
 
-          // First, mark as FAILED so resumeScanJob can pick it up
+          // Flag the job so status listings show why it is running again
           await prisma.scanJob.update({
             where: { id: job.id },
-            data: { status: "FAILED" },
+            data: { resumedAfterRestartAt: new Date() },
           });
 
-          // Resume in background (small delay to ensure DB update propagates)
-          setTimeout(() => {
-            scanServices
-              .resumeScanJob(job.id, tmdbApiKey)
-              .then((result) => {
-                logger.info(
-                  `✅ Auto-resumed scan completed: ${result.libraryName} (${result.totalItemsSaved} additional items)`,
-                );
-              })
-              .catch((error: unknown) => {
-                logger.error(
-                  `❌ Auto-resume failed for ${job.library.name}: ${error instanceof Error ? error.message : error}`,
-                );
-              });
-          }, 100);
+          // Resume through the scan queue, so a resumed job never runs
+          // alongside a scheduled or requested scan
+          enqueueScan(async () => {
+            try {
+              const result = await runWithLogContext(
+                { scanJobId: job.id },
+                () => scanServices.resumeScanJob(job.id, tmdbApiKey),
+              );
+              logger.info(
+                `✅ Auto-resumed scan completed: ${result.libraryName} (${result.totalItemsSaved} additional items)`,
+              );
+            } catch (error) {
+              logger.error(
+                `❌ Auto-resume failed for ${job.library.name}: ${error instanceof Error ? error.message : error}`,
+              );
+            }
+          });
         }
 
         logger.info(
-          `✅ Started auto-resume for ${interruptedJobs.length} scan job(s)`,
+          `✅ Queued auto-resume for ${interruptedJobs.length} scan job(s)`,
         );
       } else {
         logger.info("✅ No interrupted scans found");
@@ -150,6 +222,10 @@ const gracefulShutdown = async (signal: string) => {
     logger.info("HTTP server closed");
   });
 
+  // Stop scheduled scans
+  stopScanScheduler();
+  stopStaleLibraryCheck();
+
   // Close WebSocket connections
   wsManager.close();
 
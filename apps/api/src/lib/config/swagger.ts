@@ -97,6 +97,10 @@ const options = {
         name: "Logs",
         description: "API logs and debugging endpoints",
       },
+      {
+        name: "Stats",
+        description: "Library statistics endpoints",
+      },
     ],
     components: {
       schemas: {
@@ -120,6 +124,37 @@ const options = {
               type: "number",
               example: 123.45,
             },
+            staleLibraries: {
+              type: "object",
+              description:
+                "Result of the last library staleness check (only when LIBRARY_STALE_AFTER is set)",
+              properties: {
+                checkedAt: {
+                  type: "string",
+                  format: "date-time",
+                },
+                count: {
+                  type: "number",
+                  example: 0,
+                },
+                libraries: {
+                  type: "array",
+                  items: {
+                    type: "object",
+                    properties: {
+                      id: { type: "string" },
+                      name: { type: "string" },
+                      libraryPath: { type: "string", nullable: true },
+                      lastSuccessfulScanAt: {
+                        type: "string",
+                        format: "date-time",
+                        nullable: true,
+                      },
+                    },
+                  },
+                },
+              },
+            },
           },
         },
         Library: {
@@ -198,6 +233,19 @@ const options = {
               description: "Number of media items in the library",
               example: 42,
             },
+            lastSuccessfulScanAt: {
+              type: "string",
+              format: "date-time",
+              nullable: true,
+              description:
+                "When a full scan of the library last finished (null if never)",
+            },
+            isStale: {
+              type: "boolean",
+              description:
+                "Whether the library had no full scan within LIBRARY_STALE_AFTER",
+              example: false,
+            },
           },
           required: [
             "id",
@@ -1,3 +1,12 @@
+import { sanitizeTitle } from "./sanitization.util";
+import { applyTitleCase, getTitleCaseMode } from "./title-case.util";
+import type { TitleCaseMode } from "./title-case.util";
+
+export type SourceType = "BLURAY" | "WEB-DL" | "WEBRIP" | "HDTV" | "DVD";
+
+// Where the scanner took a file's title from
+export type TitleSource = "filename" | "folder" | "override" | "override-file";
+
 export interface ExtractedIds {
   tmdbId?: string;
   imdbId?: string;
@@ -6,9 +15,387 @@ export interface ExtractedIds {
   title?: string;
   season?: number;
   episode?: number;
+  // Last episode of a range that runs into a later season (S01E13-S02E02)
+  rangeEnd?: { season: number; episode: number };
+  is3D?: boolean;
+  isRemux?: boolean;
+  sourceType?: SourceType;
+  resolution?: string;
+  language?: string; // ISO 639-1 code from a release language tag
+  isProper?: boolean;
+  isRepack?: boolean;
+  isInternal?: boolean;
+  titleSource?: TitleSource; // Set by the scanner, not by extractIds
+  titleTruncated?: boolean; // Title was cut to MAX_TITLE_LENGTH
+  titleFallback?: boolean; // Cleaning left no title; the raw file name was used
+}
+
+/**
+ * Release source tags, checked in order. The first match wins.
+ */
+const SOURCE_TYPE_PATTERNS: Array<{ type: SourceType; pattern: RegExp }> = [
+  { type: "BLURAY", pattern: /\b(?:Blu-?Ray|BDRip|BRRip|BD)\b/gi },
+  { type: "WEB-DL", pattern: /\bWEB-?DL\b/gi },
+  { type: "WEBRIP", pattern: /\bWEB-?Rip\b/gi },
+  { type: "HDTV", pattern: /\bHDTV\b/gi },
+  { type: "DVD", pattern: /\b(?:DVD-?Rip|DVD[59]?)\b/gi },
+];
+
+// Stereo layout tags that are never words: a layout right after "3D",
+// "HSBS" and "Half-SBS"/"Half-OU". A bare "3D" is not treated as a tag
+// because it is often part of the title ("Step Up 3D").
+const STEREO_3D_PATTERN =
+  /\b(?:3D[\s._-]*(?:H(?:alf)?-?)?(?:SBS|OU)|H-?SBS|Half[\s._-]?(?:SBS|OU))\b/gi;
+
+// Bare "SBS", "OU" and "HOU" are also words ("Tout ou rien"), so they only
+// count in upper case among the tags after the title
+const BARE_STEREO_3D_PATTERN = /\b(?:H-?)?(?:SBS|OU)\b/g;
+
+const REMUX_PATTERN = /\bREMUX\b/gi;
+
+// Resolution tags, normalized to the vertical line count ("2160p")
+const RESOLUTION_PATTERN = /\b(2160p|1440p|1080[pi]|720p|576p|480p|4K|UHD)\b/i;
+
+/**
+ * Read the resolution tag from a filename, if any
+ * Tokens are left in place for the title cleanup to remove
+ */
+function detectResolution(name: string): string | undefined {
+  const match = name.match(RESOLUTION_PATTERN);
+  if (!match || !match[1]) {
+    return undefined;
+  }
+
+  const token = match[1].toLowerCase();
+  return token === "4k" || token === "uhd" ? "2160p" : token;
 }
 
-export function extractIds(name: string): ExtractedIds {
+// Release language tags mapped to ISO 639-1 codes
+const LANGUAGE_TOKENS: Record<string, string> = {
+  FRENCH: "fr",
+  TRUEFRENCH: "fr",
+  VFF: "fr",
+  VFQ: "fr",
+  VOSTFR: "fr",
+  GERMAN: "de",
+  ITALIAN: "it",
+  ITA: "it",
+  SPANISH: "es",
+  CASTELLANO: "es",
+  LATINO: "es",
+  PORTUGUESE: "pt",
+  DUTCH: "nl",
+  SWEDISH: "sv",
+  DANISH: "da",
+  NORWEGIAN: "no",
+  FINNISH: "fi",
+  POLISH: "pl",
+  RUSSIAN: "ru",
+  JAPANESE: "ja",
+  KOREAN: "ko",
+  CHINESE: "zh",
+  HINDI: "hi",
+};
+
+const LANGUAGE_PATTERN = new RegExp(
+  `\\b(${Object.keys(LANGUAGE_TOKENS).join("|")})\\b`,
+  "gi",
+);
+
+// Anime batch numbering: "[Group] Show - 01 - Title [1080p].mkv". The bare
+// number must sit between dashes, or before a bracket, the extension or the
+// end, so resolutions ("1080p", "1920x1080") never match.
+const ANIME_EPISODE_PATTERN =
+  /\s-\s(\d{1,4})(?:v\d)?(?=\s-\s|\s*[[(]|\.[a-z0-9]{2,4}$|\s*$)/gi;
+
+/**
+ * Find an anime-style "- NN -" episode number, skipping anything that
+ * looks like a year
+ */
+function matchAnimeEpisode(
+  name: string,
+): { episode: number; index: number } | undefined {
+  for (const match of name.matchAll(ANIME_EPISODE_PATTERN)) {
+    const digits = match[1];
+    if (!digits || /^(?:19|20)\d{2}$/.test(digits)) continue;
+
+    const episode = parseInt(digits, 10);
+    if (episode > 0) {
+      return { episode, index: match.index ?? 0 };
+    }
+  }
+  return undefined;
+}
+
+// Year, episode, resolution or source tag ending the title part of a name.
+// Language words are common in titles ("The Italian Job"), so they only
+// count as tags after one of these.
+const RELEASE_MARKER_PATTERN =
+  /(?:^|[\s._\-[(])(?:(?:19|20)\d{2}|[Ss]\d{1,2}[Ee]\d{1,2}|\d{3,4}[pi]|4K|UHD|Blu-?Ray|WEB-?DL|WEB-?Rip|HDTV|DVD-?Rip)(?=$|[\s._\-\])])/i;
+
+/**
+ * Split a name where its release tags start, at the first year, episode,
+ * resolution or source tag. The tag part is empty when there is none.
+ */
+function splitAtReleaseMarker(name: string): {
+  titlePart: string;
+  tagPart: string;
+} {
+  const marker = RELEASE_MARKER_PATTERN.exec(name);
+  if (!marker) {
+    return { titlePart: name, tagPart: "" };
+  }
+
+  return {
+    titlePart: name.slice(0, marker.index),
+    tagPart: name.slice(marker.index),
+  };
+}
+
+/**
+ * Capture the first release language tag and remove language tags that
+ * follow the title, leaving language words inside the title alone
+ */
+function consumeLanguage(name: string): {
+  remaining: string;
+  language?: string;
+} {
+  const { titlePart, tagPart } = splitAtReleaseMarker(name);
+  let language: string | undefined;
+
+  const tags = tagPart.replace(LANGUAGE_PATTERN, (token) => {
+    language = language ?? LANGUAGE_TOKENS[token.toUpperCase()];
+    return " ";
+  });
+
+  return { remaining: titlePart + tags, language };
+}
+
+// Re-release flags, used to prefer a fixed copy over the original. They
+// are also words ("Internal Affairs"), so they only count after the title.
+const PROPER_PATTERN = /\bPROPER\b/gi;
+const REPACK_PATTERN = /\bREPACK\b/gi;
+const INTERNAL_PATTERN = /\bINTERNAL\b/gi;
+
+/**
+ * Capture 3D, remux, source and re-release tags as attributes and remove
+ * them from the name, so the title cleanup below never sees (or
+ * double-handles) them
+ */
+function consumeSourceAttributes(name: string): {
+  remaining: string;
+  is3D: boolean;
+  isRemux: boolean;
+  sourceType?: SourceType;
+  isProper: boolean;
+  isRepack: boolean;
+  isInternal: boolean;
+} {
+  let remaining = name;
+  let is3D = false;
+  let isRemux = false;
+  let sourceType: SourceType | undefined;
+  let isProper = false;
+  let isRepack = false;
+  let isInternal = false;
+
+  const markAs3D = () => {
+    is3D = true;
+    return " ";
+  };
+  remaining = remaining.replace(STEREO_3D_PATTERN, markAs3D);
+  const { titlePart, tagPart } = splitAtReleaseMarker(remaining);
+  const tags = tagPart
+    .replace(BARE_STEREO_3D_PATTERN, markAs3D)
+    .replace(PROPER_PATTERN, () => {
+      isProper = true;
+      return " ";
+    })
+    .replace(REPACK_PATTERN, () => {
+      isRepack = true;
+      return " ";
+    })
+    .replace(INTERNAL_PATTERN, () => {
+      isInternal = true;
+      return " ";
+    });
+  remaining = titlePart + tags;
+
+  remaining = remaining.replace(REMUX_PATTERN, () => {
+    isRemux = true;
+    return " ";
+  });
+
+  for (const { type, pattern } of SOURCE_TYPE_PATTERNS) {
+    remaining = remaining.replace(pattern, () => {
+      sourceType = sourceType ?? type;
+      return " ";
+    });
+  }
+
+  return {
+    remaining,
+    is3D,
+    isRemux,
+    sourceType,
+    isProper,
+    isRepack,
+    isInternal,
+  };
+}
+
+/**
+ * Scene/regional release tags that slip past the cleanup patterns below.
+ * Mixed-case markers (scene style, e.g. "LiNE") only match their exact casing
+ * so real words like "Line" in "The Thin Red Line" are left alone.
+ */
+const BUILT_IN_JUNK_MARKERS = [
+  "LiNE",
+  "iTA",
+  "NORDiC",
+  "MULTi",
+  "TELESYNC",
+  "TELECINE",
+  "HDCAM",
+];
+
+/**
+ * Words that commonly appear in titles; markers spelling one of these in
+ * anything but scene-style casing ("KiNG") are rejected because they would
+ * blank real titles
+ */
+const COMMON_TITLE_WORDS = new Set([
+  "a",
+  "an",
+  "and",
+  "at",
+  "by",
+  "day",
+  "for",
+  "from",
+  "in",
+  "king",
+  "life",
+  "line",
+  "love",
+  "man",
+  "night",
+  "of",
+  "on",
+  "part",
+  "story",
+  "the",
+  "to",
+  "war",
+  "with",
+  "world",
+]);
+
+interface JunkMarkerMatcher {
+  markers: string[];
+  caseInsensitive: RegExp | null;
+  caseSensitive: RegExp | null;
+}
+
+let junkMarkerMatcher: JunkMarkerMatcher | null = null;
+
+function isMixedCase(marker: string): boolean {
+  return /[a-z]/.test(marker) && /[A-Z]/.test(marker);
+}
+
+// Scene-style casing such as "LiNE", never the way a title spells a word
+// ("Line")
+function isSceneCase(marker: string): boolean {
+  return isMixedCase(marker) && !/^[A-Z][a-z0-9]*$/.test(marker);
+}
+
+function buildMarkerRegex(markers: string[], flags: string): RegExp | null {
+  if (markers.length === 0) return null;
+  return new RegExp(`\\b(?:${markers.join("|")})\\b`, flags);
+}
+
+/**
+ * Parse and validate a comma-separated list of junk markers
+ * Throws if any marker is empty, not a single alphanumeric token, or would
+ * strip common title words
+ */
+export function parseJunkMarkers(value: string): string[] {
+  const markers = value.split(",").map((marker) => marker.trim());
+  const errors: string[] = [];
+
+  markers.forEach((marker, index) => {
+    if (!marker) {
+      errors.push(`entry ${index + 1} is empty`);
+    } else if (!/^[A-Za-z0-9]{2,}$/.test(marker)) {
+      errors.push(
+        `"${marker}" must be a single token of at least 2 letters or digits`,
+      );
+    } else if (
+      !isSceneCase(marker) &&
+      COMMON_TITLE_WORDS.has(marker.toLowerCase())
+    ) {
+      errors.push(`"${marker}" would remove a common title word`);
+    }
+  });
+
+  if (errors.length > 0) {
+    throw new Error(`Invalid SCANNER_EXTRA_JUNK_MARKERS: ${errors.join("; ")}`);
+  }
+
+  return markers;
+}
+
+/**
+ * Compile the junk marker matcher from the built-in list plus
+ * SCANNER_EXTRA_JUNK_MARKERS. Called at startup so bad configuration fails
+ * fast; extractIds falls back to compiling lazily.
+ */
+export function initializeJunkMarkers(
+  extraMarkers: string | undefined = process.env.SCANNER_EXTRA_JUNK_MARKERS,
+): string[] {
+  const extras = extraMarkers?.trim() ? parseJunkMarkers(extraMarkers) : [];
+  const markers = Array.from(new Set([...BUILT_IN_JUNK_MARKERS, ...extras]));
+
+  junkMarkerMatcher = {
+    markers,
+    caseInsensitive: buildMarkerRegex(
+      markers.filter((marker) => !isMixedCase(marker)),
+      "gi",
+    ),
+    caseSensitive: buildMarkerRegex(markers.filter(isMixedCase), "g"),
+  };
+
+  return markers;
+}
+
+/**
+ * Remove configured junk markers (whole words only) from a title
+ */
+function stripJunkMarkers(title: string): string {
+  if (!junkMarkerMatcher) {
+    initializeJunkMarkers();
+  }
+
+  const { caseInsensitive, caseSensitive } = junkMarkerMatcher!;
+  let result = title;
+  if (caseInsensitive) result = result.replace(caseInsensitive, "");
+  if (caseSensitive) result = result.replace(caseSensitive, "");
+  return result;
+}
+
+// SCANNER_TITLE_CASE, read on first use
+let titleCaseMode: TitleCaseMode | undefined;
+
+/**
+ * Parse IDs, year, title, episode numbers and release attributes from a
+ * file or folder name
+ * Anime-style "Show - 01" numbering is only read for TV, since in a movie
+ * name a bare number after a dash is part of the title ("Movie - 2").
+ */
+export function extractIds(
+  name: string,
+  options: { mediaType?: "movie" | "tv" } = {},
+): ExtractedIds {
   const result: ExtractedIds = {};
 
   // Extract TMDB ID: {tmdb-12345} or tmdb-12345 or [tmdb-12345]
@@ -27,14 +414,66 @@ export function extractIds(name: string): ExtractedIds {
   const yearMatch = name.match(/[[(](\d{4})[\])]/);
   if (yearMatch) result.year = yearMatch[1];
 
+  // Year-first names: "1994 - The Shawshank Redemption" or "(2003) Oldboy".
+  // A leading number only counts as the year when no other year follows,
+  // so "2001 A Space Odyssey (1968)" keeps its numeric title, and when more
+  // than an extension follows, so "1917.mkv" is titled "1917".
+  let titleSource = name;
+  // Where the title ends when a later year cuts it short
+  let titleEnd: number | undefined;
+  const yearFirstMatch = name.match(
+    /^([[(])?((?:19|20)\d{2})[\])]?(?:\s*[-._]+\s*|\s+)(?=\S)/,
+  );
+  const afterYearFirst = yearFirstMatch
+    ? name.slice(yearFirstMatch[0].length)
+    : "";
+  const onlyExtensionFollows =
+    !!yearFirstMatch &&
+    /^(mkv|mp4|avi|mov|wmv|m4v|webm|flv|mpg|mpeg|m2ts|ts)$/i.test(
+      afterYearFirst,
+    );
+  // "1917.2019.1080p.mkv": a bare leading number followed by a year is the
+  // title, and the later year is the release year
+  const laterYearMatch =
+    yearFirstMatch && !yearFirstMatch[1]
+      ? afterYearFirst.match(/(?:^|[\s._-])((?:19|20)\d{2})(?=$|[\s._\-[(])/)
+      : null;
+  if (
+    yearFirstMatch &&
+    !onlyExtensionFollows &&
+    (!yearMatch || yearMatch.index === 0)
+  ) {
+    if (laterYearMatch) {
+      result.year = laterYearMatch[1];
+      titleEnd = yearFirstMatch[0].length + (laterYearMatch.index ?? 0);
+    } else {
+      result.year = yearFirstMatch[2];
+      titleSource = afterYearFirst;
+    }
+  }
+
   // Extract season and episode: S01E01, s01e01, 1x01, Season 01, etc.
   const seasonEpisodeMatch = name.match(/[Ss](\d{1,2})[Ee](\d{1,2})/);
   if (seasonEpisodeMatch && seasonEpisodeMatch[1] && seasonEpisodeMatch[2]) {
     result.season = parseInt(seasonEpisodeMatch[1], 10);
     result.episode = parseInt(seasonEpisodeMatch[2], 10);
+
+    // Multi-part finale/premiere files can span two seasons
+    const crossSeasonMatch = name.match(
+      /[Ss](\d{1,2})[Ee](\d{1,2})\s*[-._]+\s*[Ss](\d{1,2})[Ee](\d{1,2})/,
+    );
+    if (crossSeasonMatch && crossSeasonMatch[3] && crossSeasonMatch[4]) {
+      const endSeason = parseInt(crossSeasonMatch[3], 10);
+      if (endSeason > result.season) {
+        result.rangeEnd = {
+          season: endSeason,
+          episode: parseInt(crossSeasonMatch[4], 10),
+        };
+      }
+    }
   } else {
-    // Try alternative format: 1x01
-    const altMatch = name.match(/(\d{1,2})x(\d{1,2})/);
+    // Try alternative format: 1x01 (not part of a resolution like 1920x1080)
+    const altMatch = name.match(/(?<!\d)(\d{1,2})x(\d{1,2})(?!\d)/);
     if (altMatch && altMatch[1] && altMatch[2]) {
       result.season = parseInt(altMatch[1], 10);
       result.episode = parseInt(altMatch[2], 10);
@@ -47,8 +486,48 @@ export function extractIds(name: string): ExtractedIds {
     }
   }
 
+  // Anime batches number episodes "Show - 01 - Title"; the number is
+  // absolute, so it goes in season 1 unless a season was named
+  const animeEpisode =
+    options.mediaType === "tv" && result.episode === undefined
+      ? matchAnimeEpisode(name)
+      : undefined;
+  if (animeEpisode) {
+    result.season = result.season ?? 1;
+    result.episode = animeEpisode.episode;
+  }
+
+  // Capture language and source attributes before cleaning strips their tokens
+  const languageResult = consumeLanguage(titleSource);
+  if (languageResult.language) result.language = languageResult.language;
+
+  const sourceAttributes = consumeSourceAttributes(languageResult.remaining);
+  if (sourceAttributes.is3D) result.is3D = true;
+  if (sourceAttributes.isRemux) result.isRemux = true;
+  if (sourceAttributes.sourceType) {
+    result.sourceType = sourceAttributes.sourceType;
+  }
+  const resolution = detectResolution(titleSource);
+  if (resolution) result.resolution = resolution;
+  if (sourceAttributes.isProper) result.isProper = true;
+  if (sourceAttributes.isRepack) result.isRepack = true;
+  if (sourceAttributes.isInternal) result.isInternal = true;
+
+  // For anime batch names, everything after the episode number is the
+  // episode title and release tags
+  let titleRemaining =
+    titleEnd !== undefined
+      ? name.slice(0, titleEnd)
+      : sourceAttributes.remaining;
+  if (animeEpisode) {
+    const remainingMatch = matchAnimeEpisode(titleRemaining);
+    if (remainingMatch) {
+      titleRemaining = titleRemaining.slice(0, remainingMatch.index);
+    }
+  }
+
   // Clean title (remove IDs, year, season/episode info, and common patterns)
-  let cleanTitle = name
+  let cleanTitle = titleRemaining
     // Remove file extension first
     .replace(/\.(mkv|mp4|avi|mov|wmv|m4v|webm|flv|mpg|mpeg|m2ts|ts)$/i, "")
     // Remove release group tags at start [GroupName]
@@ -86,7 +565,10 @@ export function extractIds(name: string): ExtractedIds {
   // This removes quality indicators, codecs, cuts, and release groups
   cleanTitle = cleanTitle
     // Remove resolution and quality (2160p, 1080p, 720p, 480p, 4K, UHD, HD, SD, etc.)
-    .replace(/\b(2160p|1080p|1440p|720p|480p|360p|4K|8K|UHD|FHD|HD|SD)\b/gi, "")
+    .replace(
+      /\b(2160p|1080[pi]|1440p|720p|576p|480p|360p|4K|8K|UHD|FHD|HD|SD)\b/gi,
+      "",
+    )
     // Remove source/release type (BluRay, BDRip, WEB-DL, WEBRip, HDTV, DVDRip, etc.)
     .replace(
       /\b(BluRay|Blu-?Ray|BDRip|BD|BRRip|WEB-?DL|WEBRip|WEB|HDTV|DVDRip|DVD|AMZN|ATVP|MA|DS4K|35mm|IMAX)\b/gi,
@@ -103,13 +585,16 @@ export function extractIds(name: string): ExtractedIds {
     .replace(/\b(HDR10\+?|HDR|DV|Dolby\s*Vision|SDR)\b/gi, "")
     // Remove remaster/cut/version info (REMASTERED, EXTENDED, IMAX, Director's Cut, etc.)
     .replace(
-      /\b(REMASTERED|EXTENDED|UNRATED|THEATRICAL|Director'?s?\s*Cut|Open\s*Matte|The\s*Super\s*Duper\s*Cut|PROPER)\b/gi,
+      /\b(REMASTERED|EXTENDED|UNRATED|THEATRICAL|Director'?s?\s*Cut|Open\s*Matte|The\s*Super\s*Duper\s*Cut)\b/gi,
       "",
     )
+    // Remove re-release flags of names with no tags to read them from;
+    // upper case only, so "Internal Affairs" keeps its title
+    .replace(/\b(PROPER|REPACK|INTERNAL)\b/g, "")
     // Remove media type keywords
     .replace(/\b(bluray|brrip|webrip|web)\b/gi, "")
     // Remove common tags and metadata
-    .replace(/\b(INTERNAL|LIMITED|FESTIVAL|SCREENER|R5|CAM)\b/gi, "")
+    .replace(/\b(LIMITED|FESTIVAL|SCREENER|R5|CAM)\b/gi, "")
     // Remove file size indicators
     .replace(/\b(\d+(\.\d+)?\s?(GB|MB|GiB|MiB))\b/gi, "")
     // Remove remaining empty brackets/parentheses
@@ -120,10 +605,18 @@ export function extractIds(name: string): ExtractedIds {
     .replace(/\s+/g, " ")
     .trim();
 
+  // Remove built-in and configured (SCANNER_EXTRA_JUNK_MARKERS) release tags
+  cleanTitle = stripJunkMarkers(cleanTitle).replace(/\s+/g, " ").trim();
+
   // Final cleanup: Remove release group tags at the end
   // They're usually all caps or mixed case names after a dash or space at the end
   // Examples: KIMJI, RAV1NE, PSA, FLUX, CRUCiBLE, Ralphy, etc.
-  cleanTitle = cleanTitle.replace(/\s+[A-Z][A-Za-z0-9]*$/i, "").trim();
+  // Anime batch names carry the group in leading brackets and were already
+  // cut at the episode number, and names cut at a later year end with the
+  // title, so their last word is part of the title
+  if (!animeEpisode && titleEnd === undefined) {
+    cleanTitle = cleanTitle.replace(/\s+[A-Z][A-Za-z0-9]*$/i, "").trim();
+  }
 
   // Fix common movie title patterns that may have been mangled
   cleanTitle = cleanTitle
@@ -132,7 +625,23 @@ export function extractIds(name: string): ExtractedIds {
     // Normalize "and the" patterns
     .replace(/\band\s+the\b/gi, "and the");
 
-  result.title = cleanTitle || name; // Fallback to original name if cleaning results in empty string
+  titleCaseMode ??= getTitleCaseMode();
+  let sanitized = sanitizeTitle(applyTitleCase(cleanTitle, titleCaseMode));
+
+  // Names that are all junk leave nothing usable ("-.1080p.x264.mkv"), so
+  // fall back to the raw name without its extension, or to "Untitled" when
+  // that has no letter or digit either ("-.mkv"). A title is never empty.
+  const hasLetterOrDigit = (title: string) => /[\p{L}\p{N}]/u.test(title);
+  if (!hasLetterOrDigit(sanitized.title)) {
+    result.titleFallback = true;
+    sanitized = sanitizeTitle(name.replace(/\.[^.]+$/, ""));
+    if (!hasLetterOrDigit(sanitized.title)) {
+      sanitized = { title: "Untitled", truncated: false };
+    }
+  }
+
+  result.title = sanitized.title;
+  if (sanitized.truncated) result.titleTruncated = true;
 
   return result;
 }
@@ -7,4 +7,7 @@ export * from "./docker-path.util";
 export * from "./mime-types.util";
 export * from "./response-handlers.util";
 export * from "./media-finder.util";
+export * from "./string-similarity.util";
+export * from "./title-case.util";
+export * from "./log-context.util";
 export { default as logger } from "./logger";
@@ -0,0 +1,62 @@
+/**
+ * Per-scan log context
+ * Lets one scan log at a more verbose level than the rest of the server and
+ * tags its lines with its job, library and media type, without passing a
+ * logger around
+ */
+
+import { AsyncLocalStorage } from "async_hooks";
+
+export type ScopedLogLevel = "info" | "debug";
+
+export interface LogContext {
+  logLevel?: ScopedLogLevel;
+  scanJobId?: string;
+  libraryId?: string;
+  mediaType?: string;
+}
+
+// Fields that identify the scan a line came from
+export type LogContextFields = Omit<LogContext, "logLevel">;
+
+const logContextStorage = new AsyncLocalStorage<LogContext>();
+
+/**
+ * Run a function with a log context; everything it awaits or schedules
+ * logs with that context
+ */
+export function runWithLogContext<T>(context: LogContext, fn: () => T): T {
+  return logContextStorage.run({ ...context }, fn);
+}
+
+/**
+ * Get the log context of the current call, if any
+ */
+export function getLogContext(): LogContext | undefined {
+  return logContextStorage.getStore();
+}
+
+/**
+ * Add fields to the rest of the current scan's log lines, as the scan
+ * learns its library and creates its job
+ */
+export function setLogContextFields(fields: LogContextFields): void {
+  const context = logContextStorage.getStore();
+  if (context) {
+    Object.assign(context, fields);
+  }
+}
+
+/**
+ * Format the scan fields of a context as a log line prefix, such as
+ * "[scan job=ck1 library=ck2 tv]", or an empty string when there are none
+ */
+export function formatLogContextPrefix(context: LogContextFields): string {
+  const parts = [
+    context.scanJobId && `job=${context.scanJobId}`,
+    context.libraryId && `library=${context.libraryId}`,
+    context.mediaType,
+  ].filter(Boolean);
+
+  return parts.length > 0 ? `[scan ${parts.join(" ")}] ` : "";
+}
@@ -1,5 +1,6 @@
 import winston from "winston";
 import { WebSocketTransport } from "./websocket-transport";
+import { formatLogContextPrefix, getLogContext } from "./log-context.util";
 
 // Define log levels
 const levels = {
@@ -22,8 +23,37 @@ const colors = {
 // Tell winston about our colors
 winston.addColors(colors);
 
+// Level for everything outside a scan with its own log level
+const defaultLevel: keyof typeof levels =
+  process.env.NODE_ENV === "development" ? "debug" : "info";
+
+// The logger itself lets every level through so a scan with logLevel
+// "debug" can log at debug; this drops lines above the level that applies
+// to the current call, and tags lines from a scan with its job and library
+export function scopeLogLine(info: winston.Logform.TransformableInfo) {
+  const context = getLogContext();
+  const level = info[Symbol.for("level")] as keyof typeof levels;
+  const allowed = Math.max(
+    levels[defaultLevel],
+    context?.logLevel ? levels[context.logLevel] : 0,
+  );
+
+  if (levels[level] > allowed) {
+    return false;
+  }
+
+  if (context) {
+    info.message = `${formatLogContextPrefix(context)}${info.message}`;
+  }
+
+  return info;
+}
+
+const scopedLevel = winston.format(scopeLogLine);
+
 // Define format for logs
 const format = winston.format.combine(
+  scopedLevel(),
   winston.format.timestamp({ format: "YYYY-MM-DD HH:mm:ss" }),
   winston.format.colorize({ all: true }),
   winston.format.printf((info) => {
@@ -56,7 +86,7 @@ const transports = [
 
 // Create the logger
 const logger = winston.createLogger({
-  level: process.env.NODE_ENV === "development" ? "debug" : "info",
+  level: "debug",
   levels,
   format,
   transports,
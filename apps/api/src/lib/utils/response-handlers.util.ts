@@ -121,6 +121,15 @@ export class UnprocessableEntityError extends ApiError {
   }
 }
 
+/**
+ * 503 Service Unavailable Error
+ */
+export class ServiceUnavailableError extends ApiError {
+  constructor(message: string) {
+    super(message, 503, "Service unavailable");
+  }
+}
+
 // ==================== Response Handlers ====================
 
 /**
@@ -96,6 +96,59 @@ export function sanitizeString(
   return sanitized;
 }
 
+/**
+ * Longest title kept, in code points
+ */
+export const MAX_TITLE_LENGTH = 512;
+
+/**
+ * A cleaned title, and whether it was cut to MAX_TITLE_LENGTH
+ */
+export interface SanitizedTitle {
+  title: string;
+  truncated: boolean;
+}
+
+/**
+ * Cleans a title before it is stored. Parsed filenames can carry newlines,
+ * NUL bytes or broken encodings that would otherwise end up verbatim in
+ * exports and logs.
+ * @param input - Title parsed from a filename or read from a provider
+ * @param maxLength - Longest title kept, in code points
+ * @returns Title without control characters or unpaired surrogates, with
+ * whitespace collapsed, plus whether it had to be truncated
+ */
+export function sanitizeTitle(
+  input: string,
+  maxLength: number = MAX_TITLE_LENGTH,
+): SanitizedTitle {
+  if (typeof input !== "string") {
+    return { title: "", truncated: false };
+  }
+
+  // Control characters become spaces so words on either side stay apart.
+  // Using unicode escapes - intentionally includes control characters
+  const cleaned = input
+    .replace(/[\u0000-\u001F\u007F-\u009F]/g, " ")
+    .replace(
+      /[\uD800-\uDBFF](?![\uDC00-\uDFFF])|(?<![\uD800-\uDBFF])[\uDC00-\uDFFF]/g,
+      "\uFFFD",
+    )
+    .replace(/\s+/g, " ")
+    .trim();
+
+  // Count code points, not UTF-16 units, so a pair is never split
+  const codePoints = Array.from(cleaned);
+  if (codePoints.length <= maxLength) {
+    return { title: cleaned, truncated: false };
+  }
+
+  return {
+    title: codePoints.slice(0, maxLength).join("").trimEnd(),
+    truncated: true,
+  };
+}
+
 /**
  * Recursively sanitizes an object's string properties
  * @param obj - Object to sanitize
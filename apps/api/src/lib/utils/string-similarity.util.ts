@@ -0,0 +1,73 @@
+/**
+ * String similarity utilities for spotting near-duplicate titles
+ */
+
+/**
+ * Normalize a title for comparison: lowercase, accents removed, and only
+ * letters and digits kept, so "Spider Man", "Spider-Man" and "SpiderMan"
+ * all compare equal
+ */
+export function normalizeTitleForComparison(title: string): string {
+  return title
+    .normalize("NFKD")
+    .replace(/[\u0300-\u036f]/g, "")
+    .toLowerCase()
+    .replace(/[^\p{L}\p{N}]+/gu, "");
+}
+
+/**
+ * Levenshtein edit distance between two strings
+ * @param a - First string
+ * @param b - Second string
+ * @param maxDistance - Stop early once the distance is known to exceed this
+ * @returns The distance, or maxDistance + 1 when it exceeds maxDistance
+ */
+export function levenshteinDistance(
+  a: string,
+  b: string,
+  maxDistance: number = Infinity,
+): number {
+  if (a === b) {
+    return 0;
+  }
+  if (Math.abs(a.length - b.length) > maxDistance) {
+    return maxDistance + 1;
+  }
+
+  let previous = Array.from({ length: b.length + 1 }, (_, i) => i);
+
+  for (let i = 1; i <= a.length; i++) {
+    const current = [i];
+    let rowMin = i;
+
+    for (let j = 1; j <= b.length; j++) {
+      const cost = a[i - 1] === b[j - 1] ? 0 : 1;
+      const value = Math.min(
+        previous[j]! + 1,
+        current[j - 1]! + 1,
+        previous[j - 1]! + cost,
+      );
+      current.push(value);
+      rowMin = Math.min(rowMin, value);
+    }
+
+    if (rowMin > maxDistance) {
+      return maxDistance + 1;
+    }
+    previous = current;
+  }
+
+  return previous[b.length]!;
+}
+
+/**
+ * Similarity score between 0 and 1 derived from the edit distance
+ */
+export function getTitleSimilarity(
+  distance: number,
+  a: string,
+  b: string,
+): number {
+  const longest = Math.max(a.length, b.length);
+  return longest === 0 ? 1 : 1 - distance / longest;
+}
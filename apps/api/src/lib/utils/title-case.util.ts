@@ -0,0 +1,116 @@
+/**
+ * Title casing for parsed titles
+ * Tidies titles from names in ALL CAPS or all lowercase, keeping acronyms
+ * and Roman numerals
+ */
+
+import logger from "./logger";
+
+export type TitleCaseMode = "none" | "titlecase" | "sentencecase";
+
+const TITLE_CASE_MODES: readonly TitleCaseMode[] = [
+  "none",
+  "titlecase",
+  "sentencecase",
+];
+
+// Lowercase inside a title unless they start or end it, or follow a colon
+const SMALL_WORDS = new Set([
+  "a",
+  "an",
+  "and",
+  "as",
+  "at",
+  "but",
+  "by",
+  "for",
+  "in",
+  "nor",
+  "of",
+  "on",
+  "or",
+  "the",
+  "to",
+  "vs",
+  "with",
+]);
+
+const ROMAN_NUMERAL = /^(?=[IVXLC])C{0,3}(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$/i;
+
+/**
+ * Get the casing applied to parsed titles
+ * Read from SCANNER_TITLE_CASE, falling back to "none"
+ */
+export function getTitleCaseMode(
+  value: string | undefined = process.env.SCANNER_TITLE_CASE,
+): TitleCaseMode {
+  if (!value || value.trim() === "") {
+    return "none";
+  }
+
+  const mode = value.trim().toLowerCase() as TitleCaseMode;
+  if (!TITLE_CASE_MODES.includes(mode)) {
+    logger.warn(
+      `Invalid SCANNER_TITLE_CASE "${value}" (use none, titlecase or sentencecase), using none`,
+    );
+    return "none";
+  }
+
+  return mode;
+}
+
+function capitalize(word: string): string {
+  return word.charAt(0).toUpperCase() + word.slice(1).toLowerCase();
+}
+
+/**
+ * Recase a title
+ * Roman numerals ("Rocky II") are uppercased. Unless the whole title
+ * is uppercase, words already in capitals ("FBI") and words with inner
+ * capitals ("McQueen", "iCarly") are kept as they are.
+ */
+export function applyTitleCase(title: string, mode: TitleCaseMode): string {
+  if (mode === "none") {
+    return title;
+  }
+
+  const allCaps = title === title.toUpperCase();
+  const words = title.split(" ");
+
+  return words
+    .map((word, index) => {
+      const previous = words[index - 1];
+      const startsPhrase =
+        index === 0 ||
+        previous === "-" ||
+        (previous !== undefined && /[:!?.]$/.test(previous));
+      const endsTitle = index === words.length - 1;
+
+      // Hyphenated words are cased part by part ("Spider-Man")
+      return word.replace(/[\p{L}\p{N}']+/gu, (part, offset: number) => {
+        const letters = part.replace(/[^\p{L}]/gu, "");
+        if (!letters) {
+          return part;
+        }
+        const upper = part.toUpperCase();
+        const lower = part.toLowerCase();
+        if (ROMAN_NUMERAL.test(part) && (part === upper || part === lower)) {
+          return upper;
+        }
+        if (!allCaps && /\p{Lu}/u.test(part.slice(1))) {
+          return part;
+        }
+
+        const firstPart =
+          startsPhrase && offset === word.search(/[\p{L}\p{N}]/u);
+        if (mode === "sentencecase") {
+          return firstPart ? capitalize(part) : lower;
+        }
+        if (SMALL_WORDS.has(lower) && !firstPart && !endsTitle) {
+          return lower;
+        }
+        return capitalize(part);
+      });
+    })
+    .join(" ");
+}
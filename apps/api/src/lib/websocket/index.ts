@@ -31,6 +31,23 @@ interface ScanComplete {
   totalItems: number;
   message: string;
   scanJobId?: string;
+  origin?: string | null; // Service that asked for the scan, if it said
+  newItemsCount?: number; // Files added to the library for the first time
+  newItemTitles?: string[]; // Capped sample of the new files' titles
+  extrasSaved?: number; // TV extras saved as season 0 entries
+  fileLimitReached?: boolean; // Scan stopped early at SCANNER_MAX_FILES_PER_SCAN
+  filesTimedOut?: number; // Files skipped after SCANNER_FILE_DEADLINE_SECONDS
+  lowConfidenceTitles?: number; // Files titled from the raw name because cleaning left nothing
+  depthLimitedFolders?: number; // Folders holding only subfolders below maxDepth
+  sentinelSkippedFolders?: number; // Folders left out by a .dester-skip-until file
+  unmatchedTrailers?: number; // Trailer folder files with no movie in the library
+  errorCount?: number; // Files and folders that failed
+  // Directories with the most failures, most first
+  errorDirectories?: Array<{
+    directory: string;
+    count: number;
+    sampleError: string;
+  }>;
 }
 
 interface ScanError {
@@ -48,11 +65,29 @@ interface LogMessage {
   meta?: Record<string, unknown>;
 }
 
-type WebSocketMessage = ScanProgress | ScanComplete | ScanError | LogMessage;
+interface LibraryStale {
+  type: "library:stale";
+  libraryId: string;
+  libraryName: string;
+  lastSuccessfulScanAt: string | null; // null when never fully scanned
+  staleAfterMs: number; // LIBRARY_STALE_AFTER
+}
+
+type WebSocketMessage =
+  | ScanProgress
+  | ScanComplete
+  | ScanError
+  | LogMessage
+  | LibraryStale;
+
+type ScanEvent = ScanProgress | ScanComplete | ScanError;
+type ScanEventListener = (event: ScanEvent) => void;
 
 // Module-level state
 let wss: WebSocketServer | null = null;
 const clients: Set<WebSocket> = new Set();
+// In-process listeners (e.g. SSE streams) that mirror scan broadcasts
+const scanEventListeners: Set<ScanEventListener> = new Set();
 
 export function initializeWebSocket(server: HTTPServer) {
   wss = new WebSocketServer({ server, path: "/ws" });
@@ -84,6 +119,10 @@ export function initializeWebSocket(server: HTTPServer) {
 }
 
 export function broadcast(message: WebSocketMessage) {
+  if (message.type !== "log:message" && message.type !== "library:stale") {
+    notifyScanEventListeners(message);
+  }
+
   const payload = JSON.stringify(message);
   let successCount = 0;
   let failCount = 0;
@@ -109,6 +148,29 @@ export function broadcast(message: WebSocketMessage) {
   }
 }
 
+function notifyScanEventListeners(event: ScanEvent) {
+  scanEventListeners.forEach((listener) => {
+    try {
+      listener(event);
+    } catch (error) {
+      logger.error(
+        `Scan event listener failed: ${error instanceof Error ? error.message : error}`,
+      );
+    }
+  });
+}
+
+/**
+ * Subscribe to scan progress/complete/error events in-process
+ * Returns a function that removes the listener
+ */
+export function subscribeToScanEvents(listener: ScanEventListener) {
+  scanEventListeners.add(listener);
+  return () => {
+    scanEventListeners.delete(listener);
+  };
+}
+
 export function sendScanProgress(data: Omit<ScanProgress, "type">) {
   broadcast({
     type: "scan:progress",
@@ -137,6 +199,13 @@ export function sendLogMessage(data: Omit<LogMessage, "type">) {
   });
 }
 
+export function sendLibraryStale(data: Omit<LibraryStale, "type">) {
+  broadcast({
+    type: "library:stale",
+    ...data,
+  });
+}
+
 export function getClientCount(): number {
   return clients.size;
 }
@@ -159,6 +228,8 @@ export const wsManager = {
   sendScanComplete,
   sendScanError,
   sendLogMessage,
+  sendLibraryStale,
+  subscribeToScanEvents,
   getClientCount,
   close: closeWebSocket,
 };
@@ -167,5 +238,7 @@ export type {
   ScanComplete,
   ScanError,
   LogMessage,
+  LibraryStale,
   WebSocketMessage,
+  ScanEvent,
 };
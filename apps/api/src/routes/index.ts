@@ -1,6 +1,7 @@
 import express, { Router } from "express";
 import { readFileSync } from "fs";
 import { join } from "path";
+import { getLastStaleLibraryCheck } from "../domains/scan/helpers";
 
 const router: Router = express.Router();
 
@@ -21,7 +22,11 @@ const getApiVersion = (): string => {
  * /health:
  *   get:
  *     summary: Health check endpoint
- *     description: Returns the current status of the API including version information
+ *     description: |
+ *       Returns the current status of the API including version information.
+ *       When LIBRARY_STALE_AFTER is set, it also lists the libraries found
+ *       stale by the last hourly check. Stale libraries do not change the
+ *       status.
  *     tags: [Health]
  *     responses:
  *       200:
@@ -32,11 +37,20 @@ const getApiVersion = (): string => {
  *               $ref: '#/components/schemas/HealthResponse'
  */
 router.get("/health", (req, res) => {
+  const staleCheck = getLastStaleLibraryCheck();
+
   res.status(200).json({
     status: "OK",
     version: getApiVersion(),
     timestamp: new Date().toISOString(),
     uptime: process.uptime(),
+    ...(staleCheck && {
+      staleLibraries: {
+        checkedAt: staleCheck.checkedAt,
+        count: staleCheck.staleLibraries.length,
+        libraries: staleCheck.staleLibraries,
+      },
+    }),
   });
 });
 
@@ -7,6 +7,7 @@ import streamRoutes from "../../domains/stream/stream.routes";
 import settingsRoutes from "../../domains/settings/settings.routes";
 import searchRoutes from "../../domains/search/search.routes";
 import logsRoutes from "../../domains/logs/logs.routes";
+import statsRoutes from "../../domains/stats/stats.routes";
 
 const router: Router = express.Router();
 
@@ -34,4 +35,7 @@ router.use("/settings", settingsRoutes);
 // Logs routes
 router.use("/logs", logsRoutes);
 
+// Stats routes
+router.use("/stats", statsRoutes);
+
 export default router;
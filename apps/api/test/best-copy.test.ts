@@ -0,0 +1,36 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  compareMovieCopies,
+  isStoredCopyPresent,
+} from "../src/domains/scan/helpers/best-copy.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+describe("compareMovieCopies", () => {
+  it("prefers the higher resolution, then the larger file", () => {
+    const hd = { size: 9000, resolution: "1080p" };
+    const uhd = { size: 4000, resolution: "2160p" };
+    assert.ok(compareMovieCopies(uhd, hd, "resolution") > 0);
+    assert.ok(compareMovieCopies(uhd, hd, "size") < 0);
+  });
+});
+
+describe("isStoredCopyPresent", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("tells a deleted copy from one that is still there", async () => {
+    setScanFileSystem(new MemoryFileSystem({ "/movies/Heat.mkv": "" }));
+
+    assert.equal(await isStoredCopyPresent("/movies/Heat.mkv", 1000), true);
+    assert.equal(await isStoredCopyPresent("/movies/Gone.mkv", 1000), false);
+  });
+
+  it("keeps a copy its drive does not answer for", async () => {
+    const fileSystem = new MemoryFileSystem({ "/movies/Heat.mkv": "" });
+    fileSystem.stall("/movies/Heat.mkv");
+    setScanFileSystem(fileSystem);
+
+    assert.equal(await isStoredCopyPresent("/movies/Heat.mkv", 20), true);
+  });
+});
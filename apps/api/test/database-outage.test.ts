@@ -0,0 +1,102 @@
+import { before, beforeEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { Prisma } from "@prisma/client";
+import type * as DatabaseOutage from "../src/domains/scan/helpers/database-outage.helper";
+import { installFakePrisma } from "./support/fake-prisma";
+
+// Installed before the helper is imported, which happens in before()
+const prisma = installFakePrisma();
+let outage: typeof DatabaseOutage;
+
+const connectionLost = () =>
+  new Prisma.PrismaClientKnownRequestError("Can't reach database server", {
+    code: "P1001",
+    clientVersion: "6",
+  });
+
+before(async () => {
+  outage = await import("../src/domains/scan/helpers/database-outage.helper");
+});
+
+describe("saveThroughOutages", () => {
+  let databaseUp: boolean;
+  let pings: number;
+
+  beforeEach(() => {
+    databaseUp = false;
+    pings = 0;
+    prisma.$queryRaw = async () => {
+      pings++;
+      if (!databaseUp) throw connectionLost();
+      return [{ "?column?": 1 }];
+    };
+  });
+
+  it("saves the file again once the database is back", async () => {
+    const saved: string[] = [];
+    let attempts = 0;
+
+    const result = await outage.saveThroughOutages(
+      async () => {
+        attempts++;
+        if (attempts === 1) {
+          // The database comes back while the scan waits
+          setTimeout(() => (databaseUp = true), 5);
+          throw connectionLost();
+        }
+        saved.push("Heat (1995).mkv");
+        return "saved";
+      },
+      "Heat (1995).mkv",
+      200,
+    );
+
+    assert.equal(result, "saved");
+    assert.equal(attempts, 2);
+    assert.deepEqual(saved, ["Heat (1995).mkv"]);
+    assert.ok(pings >= 1);
+  });
+
+  it("stops the scan once the outage outlasts the limit", async () => {
+    await assert.rejects(
+      outage.saveThroughOutages(
+        async () => {
+          throw connectionLost();
+        },
+        "Heat (1995).mkv",
+        30,
+      ),
+      outage.DatabaseOutageError,
+    );
+  });
+
+  it("throws other save errors without waiting", async () => {
+    await assert.rejects(
+      outage.saveThroughOutages(
+        async () => {
+          throw new Error("Unique constraint failed");
+        },
+        "Heat (1995).mkv",
+        200,
+      ),
+      /Unique constraint failed/,
+    );
+    assert.equal(pings, 0);
+  });
+});
+
+describe("isDatabaseConnectionError", () => {
+  it("tells lost connections from failed queries", () => {
+    assert.equal(outage.isDatabaseConnectionError(connectionLost()), true);
+    assert.equal(
+      outage.isDatabaseConnectionError(
+        new Prisma.PrismaClientKnownRequestError("Unique constraint failed", {
+          code: "P2002",
+          clientVersion: "6",
+        }),
+      ),
+      false,
+    );
+    assert.equal(outage.isDatabaseConnectionError(new Error("boom")), false);
+  });
+});
@@ -0,0 +1,72 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import { getDiscTitleName } from "../src/domains/scan/helpers/disc-structure.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+import type { MemoryFile } from "./support/memory-fs";
+
+async function scanMovies(files: Record<string, MemoryFile>) {
+  setScanFileSystem(new MemoryFileSystem(files));
+  return collectMediaEntries("/movies", {
+    mediaType: "movie",
+    fileExtensions: getDefaultVideoExtensions(),
+  });
+}
+
+describe("disc folders", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("takes the largest stream of a Blu-ray", async () => {
+    const entries = await scanMovies({
+      "/movies/Alien (1979) 1080p Remux/BDMV/STREAM/00001.m2ts": { size: 50 },
+      "/movies/Alien (1979) 1080p Remux/BDMV/STREAM/00800.m2ts": {
+        size: 900,
+      },
+    });
+
+    assert.equal(entries.length, 1);
+    assert.equal(
+      entries[0]?.path,
+      "/movies/Alien (1979) 1080p Remux/BDMV/STREAM/00800.m2ts",
+    );
+    assert.equal(entries[0]?.extractedIds.title, "Alien");
+    assert.equal(entries[0]?.extractedIds.isRemux, true);
+  });
+
+  it("takes the largest DVD title set with default extensions", async () => {
+    const entries = await scanMovies({
+      "/movies/Heat (1995)/VIDEO_TS/VIDEO_TS.VOB": { size: 10 },
+      "/movies/Heat (1995)/VIDEO_TS/VTS_01_0.VOB": { size: 10 },
+      "/movies/Heat (1995)/VIDEO_TS/VTS_01_1.VOB": { size: 100 },
+      "/movies/Heat (1995)/VIDEO_TS/VTS_02_1.VOB": { size: 1000 },
+      "/movies/Heat (1995)/VIDEO_TS/VTS_02_2.VOB": { size: 400 },
+    });
+
+    assert.equal(entries.length, 1);
+    assert.equal(
+      entries[0]?.path,
+      "/movies/Heat (1995)/VIDEO_TS/VTS_02_1.VOB",
+    );
+    assert.equal(entries[0]?.size, 1400);
+    assert.equal(entries[0]?.extractedIds.title, "Heat");
+  });
+});
+
+describe("getDiscTitleName", () => {
+  it("names a disc title after the folder the disc is in", () => {
+    assert.equal(
+      getDiscTitleName("/movies/Alien (1979) Remux/BDMV/STREAM/00800.m2ts"),
+      "Alien (1979) Remux.m2ts",
+    );
+    assert.equal(
+      getDiscTitleName("/movies/Heat (1995)/video_ts/VTS_01_1.VOB"),
+      "Heat (1995).vob",
+    );
+  });
+
+  it("leaves files outside disc folders alone", () => {
+    assert.equal(getDiscTitleName("/movies/Heat (1995)/Heat.m2ts"), null);
+  });
+});
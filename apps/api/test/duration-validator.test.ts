@@ -0,0 +1,46 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  getMaxDurationMinutes,
+  sanitizeDuration,
+} from "../src/domains/scan/helpers/duration-validator.helper";
+
+describe("sanitizeDuration", () => {
+  afterEach(() => {
+    delete process.env.SCANNER_MAX_DURATION_MINUTES;
+  });
+
+  it("keeps runtimes in range, rounded to whole minutes", () => {
+    assert.equal(sanitizeDuration(1, "/m/a.mkv"), 1);
+    assert.equal(sanitizeDuration(118.4, "/m/a.mkv"), 118);
+    assert.equal(sanitizeDuration(24 * 60, "/m/a.mkv"), 24 * 60);
+  });
+
+  it("drops missing, zero and negative runtimes", () => {
+    assert.equal(sanitizeDuration(null, "/m/a.mkv"), null);
+    assert.equal(sanitizeDuration(undefined, "/m/a.mkv"), null);
+    assert.equal(sanitizeDuration(0, "/m/a.mkv"), null);
+    assert.equal(sanitizeDuration(-5, "/m/a.mkv"), null);
+    assert.equal(sanitizeDuration(NaN, "/m/a.mkv"), null);
+  });
+
+  it("drops a fraction of a minute instead of saving 0", () => {
+    assert.equal(sanitizeDuration(0.4, "/m/a.mkv"), null);
+  });
+
+  it("drops runtimes above the maximum", () => {
+    assert.equal(sanitizeDuration(24 * 60 + 1, "/m/a.mkv"), null);
+
+    process.env.SCANNER_MAX_DURATION_MINUTES = "300";
+    assert.equal(sanitizeDuration(300, "/m/a.mkv"), 300);
+    assert.equal(sanitizeDuration(301, "/m/a.mkv"), null);
+  });
+});
+
+describe("getMaxDurationMinutes", () => {
+  it("falls back to 24 hours for values below one minute", () => {
+    assert.equal(getMaxDurationMinutes("0.5"), 24 * 60);
+    assert.equal(getMaxDurationMinutes("abc"), 24 * 60);
+    assert.equal(getMaxDurationMinutes("90"), 90);
+  });
+});
@@ -0,0 +1,269 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  extractIds,
+  initializeJunkMarkers,
+  parseJunkMarkers,
+} from "../src/lib/utils/external-id.util";
+
+describe("extractIds", () => {
+  describe("year-first names", () => {
+    it("reads a leading year followed by the title", () => {
+      const ids = extractIds("1995 - Heat.mkv");
+      assert.equal(ids.year, "1995");
+      assert.equal(ids.title, "Heat");
+    });
+
+    it("reads a bracketed leading year", () => {
+      const ids = extractIds("(2003) Oldboy.mkv");
+      assert.equal(ids.year, "2003");
+      assert.equal(ids.title, "Oldboy");
+    });
+
+    it("keeps a numeric title when only the extension follows", () => {
+      const ids = extractIds("1917.mkv");
+      assert.equal(ids.title, "1917");
+      assert.equal(ids.year, undefined);
+    });
+
+    it("keeps a numeric title followed by a bracketed year", () => {
+      const ids = extractIds("2001 A Space Odyssey (1968).mkv");
+      assert.equal(ids.year, "1968");
+      assert.match(ids.title ?? "", /^2001 A Space/);
+    });
+
+    it("keeps a numeric title followed by a dotted year", () => {
+      const ids = extractIds("1917.2019.1080p.BluRay.mkv");
+      assert.equal(ids.title, "1917");
+      assert.equal(ids.year, "2019");
+      assert.equal(ids.resolution, "1080p");
+      assert.equal(ids.sourceType, "BLURAY");
+    });
+
+    it("keeps every word of a title cut at a dotted year", () => {
+      const ids = extractIds("2001.A.Space.Odyssey.1968.1080p.mkv");
+      assert.equal(ids.title, "2001 A Space Odyssey");
+      assert.equal(ids.year, "1968");
+    });
+  });
+
+  describe("anime episode numbers", () => {
+    it("reads absolute episode numbers in TV names", () => {
+      const ids = extractIds("[Group] Frieren - 07 - Title [1080p].mkv", {
+        mediaType: "tv",
+      });
+      assert.equal(ids.season, 1);
+      assert.equal(ids.episode, 7);
+      assert.equal(ids.title, "Frieren");
+    });
+
+    it("keeps a named season", () => {
+      const ids = extractIds("Frieren Season 2 - 03 [1080p].mkv", {
+        mediaType: "tv",
+      });
+      assert.equal(ids.season, 2);
+      assert.equal(ids.episode, 3);
+    });
+
+    it("does not take a year for an episode number", () => {
+      const ids = extractIds("Show - 2019 - Special.mkv", { mediaType: "tv" });
+      assert.equal(ids.episode, undefined);
+    });
+
+    it("leaves numbers after a dash in movie names alone", () => {
+      for (const ids of [
+        extractIds("Movie - 2 [1080p].mkv", { mediaType: "movie" }),
+        extractIds("Movie - 2 [1080p].mkv"),
+      ]) {
+        assert.equal(ids.season, undefined);
+        assert.equal(ids.episode, undefined);
+      }
+    });
+  });
+
+  describe("junk markers", () => {
+    afterEach(() => {
+      initializeJunkMarkers("");
+    });
+
+    it("parses a comma-separated list", () => {
+      assert.deepEqual(parseJunkMarkers(" HC, FRENCHiE ,KORSUB"), [
+        "HC",
+        "FRENCHiE",
+        "KORSUB",
+      ]);
+    });
+
+    it("rejects empty entries and entries that are not one token", () => {
+      assert.throws(() => parseJunkMarkers("HC,,KORSUB"), /entry 2 is empty/);
+      assert.throws(() => parseJunkMarkers("HC,"), /entry 2 is empty/);
+      assert.throws(() => parseJunkMarkers("WEB DL"), /single token/);
+      assert.throws(() => parseJunkMarkers("X"), /single token/);
+    });
+
+    it("rejects markers that would blank common title words", () => {
+      for (const marker of ["the", "Line", "WAR", "Love"]) {
+        assert.throws(
+          () => parseJunkMarkers(marker),
+          /would remove a common title word/,
+          marker,
+        );
+      }
+      assert.throws(() => initializeJunkMarkers("HC,King"), /King/);
+    });
+
+    it("accepts mixed-case markers that spell a common word", () => {
+      assert.deepEqual(parseJunkMarkers("KiNG,LiFE"), ["KiNG", "LiFE"]);
+    });
+
+    it("merges configured markers with the built-in ones", () => {
+      const markers = initializeJunkMarkers("HC,LiNE");
+      assert.ok(markers.includes("HC"));
+      assert.ok(markers.includes("NORDiC"));
+      assert.equal(markers.filter((marker) => marker === "LiNE").length, 1);
+    });
+
+    it("strips built-in and configured markers from titles", () => {
+      initializeJunkMarkers("HC");
+      for (const name of [
+        "Heat (1995) NORDiC 1080p BluRay x264-GRP.mkv",
+        "Heat (1995) TELESYNC x264-GRP.mkv",
+        "Heat (1995) HC 1080p BluRay x264-GRP.mkv",
+      ]) {
+        assert.equal(extractIds(name).title, "Heat", name);
+      }
+    });
+
+    it("leaves real words spelled like mixed-case markers alone", () => {
+      assert.equal(
+        extractIds("The Thin Red Line (1998) 1080p BluRay x264-GRP.mkv").title,
+        "The Thin Red Line",
+      );
+      assert.equal(
+        extractIds("Nordic Noir Multi Part (2020) 1080p x264-GRP.mkv").title,
+        "Nordic Noir Multi Part",
+      );
+    });
+  });
+
+  describe("3D, remux and source tags", () => {
+    const corpus = [
+      {
+        name: "Avatar (2009) 3D.HSBS.1080p.BluRay.x264-GRP.mkv",
+        title: "Avatar",
+        is3D: true,
+        isRemux: undefined,
+        sourceType: "BLURAY",
+      },
+      {
+        name: "Gravity (2013) 1080p BluRay REMUX AVC DTS-HD MA 5.1-FGT.mkv",
+        title: "Gravity",
+        is3D: undefined,
+        isRemux: true,
+        sourceType: "BLURAY",
+      },
+      {
+        name: "Heat (1995) 1080p BDRip x264-GRP.mkv",
+        title: "Heat",
+        is3D: undefined,
+        isRemux: undefined,
+        sourceType: "BLURAY",
+      },
+      {
+        name: "The Bear (2022) 1080p WEB-DL x264-NTb.mkv",
+        title: "The Bear",
+        is3D: undefined,
+        isRemux: undefined,
+        sourceType: "WEB-DL",
+      },
+    ];
+
+    for (const { name, ...expected } of corpus) {
+      it(`reads the tags of "${name}"`, () => {
+        const ids = extractIds(name);
+        assert.deepEqual(
+          {
+            title: ids.title,
+            is3D: ids.is3D,
+            isRemux: ids.isRemux,
+            sourceType: ids.sourceType,
+          },
+          expected,
+        );
+      });
+    }
+
+    it("reads bare layout tags after the title", () => {
+      assert.equal(extractIds("Avatar (2009) SBS.mkv").is3D, true);
+      assert.equal(extractIds("Avatar (2009) H-OU 1080p.mkv").is3D, true);
+      assert.equal(extractIds("Avatar 3D Half-SBS (2009).mkv").is3D, true);
+    });
+
+    it("leaves layout words inside titles alone", () => {
+      const tout = extractIds("Tout ou rien (2017) 1080p.mkv");
+      assert.equal(tout.is3D, undefined);
+      assert.match(tout.title ?? "", /^Tout ou\b/);
+
+      const ou = extractIds("Ou est passe.mkv");
+      assert.equal(ou.is3D, undefined);
+      assert.match(ou.title ?? "", /^Ou est\b/);
+
+      const stepUp = extractIds("Step Up 3D (2010).mkv");
+      assert.equal(stepUp.is3D, undefined);
+      assert.equal(stepUp.title, "Step Up 3D");
+    });
+  });
+
+  describe("re-release flags", () => {
+    it("reads each flag and cleans it from the title", () => {
+      const proper = extractIds("Heat (1995) PROPER 1080p BluRay x264-GRP.mkv");
+      assert.equal(proper.isProper, true);
+      assert.equal(proper.title, "Heat");
+
+      const repack = extractIds("Heat (1995) 1080p REPACK BluRay x264-GRP.mkv");
+      assert.equal(repack.isRepack, true);
+      assert.equal(repack.title, "Heat");
+
+      const internal = extractIds(
+        "Heat (1995) iNTERNAL 1080p BluRay x264-GRP.mkv",
+      );
+      assert.equal(internal.isInternal, true);
+      assert.equal(internal.title, "Heat");
+    });
+
+    it("reads flags after an episode number", () => {
+      const ids = extractIds("The.Bear.S02E03.REPACK.1080p.WEB-DL-NTb.mkv");
+      assert.equal(ids.isRepack, true);
+      assert.equal(ids.episode, 3);
+    });
+
+    it("leaves flag words inside titles alone", () => {
+      const ids = extractIds(
+        "Internal Affairs (2006) 1080p BluRay x264-GRP.mkv",
+      );
+      assert.equal(ids.isInternal, undefined);
+      assert.equal(ids.isProper, undefined);
+      assert.equal(ids.title, "Internal Affairs");
+    });
+  });
+
+  describe("title fallback", () => {
+    it("falls back to the raw name when cleaning leaves no title", () => {
+      const ids = extractIds("-.1080p.x264.mkv");
+      assert.equal(ids.titleFallback, true);
+      assert.match(ids.title ?? "", /1080p/);
+    });
+
+    for (const name of ["-.mkv", "___.mkv", "[ ].mkv", ".mkv", "- - -"]) {
+      it(`titles "${name}" Untitled`, () => {
+        const ids = extractIds(name);
+        assert.equal(ids.title, "Untitled");
+        assert.equal(ids.titleFallback, true);
+      });
+    }
+
+    it("does not flag titles that survive cleaning", () => {
+      assert.equal(extractIds("Heat (1995).mkv").titleFallback, undefined);
+    });
+  });
+});
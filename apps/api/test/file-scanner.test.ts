@@ -0,0 +1,209 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import type { SkipReason } from "../src/domains/scan/helpers/scan-skips.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+import type { MemoryFile } from "./support/memory-fs";
+
+function useFixture(files: Record<string, MemoryFile>): MemoryFileSystem {
+  const fileSystem = new MemoryFileSystem(files);
+  setScanFileSystem(fileSystem);
+  return fileSystem;
+}
+
+async function scan(
+  rootPath: string,
+  mediaType: "movie" | "tv",
+  options: { maxDepth?: number } = {},
+) {
+  const skips = new Map<string, SkipReason>();
+  const depthLimited: string[] = [];
+  const entries = await collectMediaEntries(rootPath, {
+    mediaType,
+    fileExtensions: getDefaultVideoExtensions(),
+    maxDepth: options.maxDepth,
+    onSkip: (path, reason) => skips.set(path, reason),
+    onDepthLimit: (folderPath) => depthLimited.push(folderPath),
+  });
+  return { entries, skips, depthLimited };
+}
+
+describe("collectMediaEntries", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("collects movies and takes the title from a titled folder", async () => {
+    useFixture({
+      "/movies/Heat (1995).mkv": { size: 700 },
+      "/movies/Inception (2010)/Inception.2010.1080p.mkv": { size: 900 },
+    });
+
+    const { entries } = await scan("/movies", "movie");
+
+    const byPath = new Map(entries.map((entry) => [entry.path, entry]));
+    assert.equal(entries.length, 2);
+    assert.equal(byPath.get("/movies/Heat (1995).mkv")?.size, 700);
+    assert.equal(
+      byPath.get("/movies/Heat (1995).mkv")?.extractedIds.title,
+      "Heat",
+    );
+    const inception = byPath.get(
+      "/movies/Inception (2010)/Inception.2010.1080p.mkv",
+    );
+    assert.equal(inception?.extractedIds.title, "Inception");
+    assert.equal(inception?.extractedIds.year, "2010");
+    assert.equal(inception?.extractedIds.titleSource, "folder");
+  });
+
+  it("collects episodes with the show from the show folder", async () => {
+    useFixture({
+      "/tv/Lost (2004)/Season 1/Lost.S01E01.720p.mkv": "",
+      "/tv/Lost (2004)/Season 1/Lost.S01E02.mkv": "",
+    });
+
+    const { entries } = await scan("/tv", "tv");
+
+    assert.deepEqual(
+      entries.map((entry) => entry.extractedIds.episode).sort(),
+      [1, 2],
+    );
+    for (const entry of entries) {
+      assert.equal(entry.extractedIds.title, "Lost");
+      assert.equal(entry.extractedIds.year, "2004");
+      assert.equal(entry.extractedIds.season, 1);
+    }
+  });
+
+  it("leaves out hidden, system, sample, sidecar and extras entries", async () => {
+    useFixture({
+      "/movies/.DS_Store": "",
+      "/movies/@eaDir/Heat (1995).mkv": "",
+      "/movies/Heat (1995)/Heat (1995).mkv": "",
+      "/movies/Heat (1995)/Heat (1995).srt": "",
+      "/movies/Heat (1995)/heat-sample.mkv": "",
+      "/movies/Heat (1995)/Featurettes/Making Of.mkv": "",
+    });
+
+    const { entries, skips } = await scan("/movies", "movie");
+
+    assert.deepEqual(
+      entries.map((entry) => entry.path),
+      ["/movies/Heat (1995)/Heat (1995).mkv"],
+    );
+    assert.equal(skips.get("/movies/.DS_Store"), "hidden");
+    assert.equal(skips.get("/movies/@eaDir"), "system");
+    assert.equal(skips.get("/movies/Heat (1995)/Heat (1995).srt"), "sidecar");
+    assert.equal(skips.get("/movies/Heat (1995)/heat-sample.mkv"), "sample");
+    assert.equal(
+      skips.get("/movies/Heat (1995)/Featurettes"),
+      "extras-folder",
+    );
+  });
+
+  it("skips movies nested deeper than movies allow", async () => {
+    useFixture({
+      "/movies/a/b/c/Deep (2001).mkv": "",
+      "/movies/a/b/Shallow (2002).mkv": "",
+    });
+
+    const { entries, skips } = await scan("/movies", "movie");
+
+    assert.deepEqual(
+      entries.map((entry) => entry.path),
+      ["/movies/a/b/Shallow (2002).mkv"],
+    );
+    assert.equal(skips.get("/movies/a/b/c/Deep (2001).mkv"), "too-deep");
+  });
+
+  it("reports folders that hold only folders below maxDepth", async () => {
+    useFixture({
+      "/tv/Breaking Bad (2008)/Season 1/Breaking.Bad.S01E01.mkv": "",
+    });
+
+    const { entries, skips, depthLimited } = await scan("/tv", "tv", {
+      maxDepth: 1,
+    });
+
+    assert.equal(entries.length, 0);
+    assert.equal(skips.get("/tv/Breaking Bad (2008)/Season 1"), "too-deep");
+    assert.deepEqual(depthLimited, ["/tv/Breaking Bad (2008)"]);
+  });
+
+  it("reads anime episode numbers only in TV scans", async () => {
+    useFixture({
+      "/movies/Movie - 2 [1080p].mkv": "",
+      "/tv/Frieren/[Group] Frieren - 07 [1080p].mkv": "",
+    });
+
+    const movies = await scan("/movies", "movie");
+    const episodes = await scan("/tv", "tv");
+
+    assert.equal(movies.entries.length, 1);
+    assert.equal(movies.entries[0]?.extractedIds.episode, undefined);
+    assert.equal(episodes.entries.length, 1);
+    assert.equal(episodes.entries[0]?.extractedIds.season, 1);
+    assert.equal(episodes.entries[0]?.extractedIds.episode, 7);
+  });
+
+  it("takes only episodes from a TV scan of a mixed folder", async () => {
+    useFixture({
+      "/media/Heat (1995).mkv": "",
+      "/media/Inception (2010)/Inception.mkv": "",
+      "/media/Lost (2004)/Season 1/Lost.S01E01.mkv": "",
+    });
+
+    const { entries, skips } = await scan("/media", "tv");
+
+    assert.deepEqual(
+      entries.map((entry) => entry.path),
+      ["/media/Lost (2004)/Season 1/Lost.S01E01.mkv"],
+    );
+    assert.equal(skips.get("/media/Heat (1995).mkv"), "bad-structure");
+    assert.equal(
+      skips.get("/media/Inception (2010)/Inception.mkv"),
+      "bad-structure",
+    );
+  });
+
+  it("skips a file the drive does not answer for and moves on", async () => {
+    const fileSystem = useFixture({
+      "/movies/Heat (1995).mkv": "",
+      "/movies/Oldboy (2003).mkv": "",
+    });
+    fileSystem.stall("/movies/Heat (1995).mkv");
+    const skips = new Map<string, SkipReason>();
+    const timedOut: string[] = [];
+
+    const entries = await collectMediaEntries("/movies", {
+      mediaType: "movie",
+      fileExtensions: getDefaultVideoExtensions(),
+      fileDeadlineMs: 20,
+      onSkip: (path, reason) => skips.set(path, reason),
+      onFileTimeout: (filePath) => timedOut.push(filePath),
+    });
+
+    assert.deepEqual(
+      entries.map((entry) => entry.path),
+      ["/movies/Oldboy (2003).mkv"],
+    );
+    assert.equal(skips.get("/movies/Heat (1995).mkv"), "timeout");
+    assert.deepEqual(timedOut, ["/movies/Heat (1995).mkv"]);
+  });
+
+  it("keeps the in-root path of symlinked files and does not follow symlinked folders", async () => {
+    useFixture({
+      "/elsewhere/Linked.mkv": { size: 500 },
+      "/elsewhere/films/Other (2011).mkv": "",
+      "/movies/Linked (2012).mkv": { link: "/elsewhere/Linked.mkv" },
+      "/movies/Shortcut": { link: "/elsewhere/films" },
+    });
+
+    const { entries, skips } = await scan("/movies", "movie");
+
+    assert.equal(entries.length, 1);
+    assert.equal(entries[0]?.path, "/movies/Linked (2012).mkv");
+    assert.equal(entries[0]?.size, 500);
+    assert.equal(skips.get("/movies/Shortcut"), "not-media");
+  });
+});
@@ -0,0 +1,61 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { analyzeDirectory } from "../src/domains/scan/helpers/library-analysis.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+describe("analyzeDirectory", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("counts files by extension and leaves out hidden entries", async () => {
+    setScanFileSystem(
+      new MemoryFileSystem({
+        "/media/.DS_Store": "",
+        "/media/Heat (1995)/Heat (1995).mkv": { size: 700 },
+        "/media/Heat (1995)/Heat (1995).srt": { size: 10 },
+      }),
+    );
+
+    const analysis = await analyzeDirectory("/media");
+
+    assert.equal(analysis.totalFiles, 2);
+    assert.equal(analysis.totalDirectories, 1);
+    assert.equal(analysis.totalBytes, 710);
+    assert.equal(analysis.skippedEntries, 1);
+    assert.equal(analysis.stoppedBy, null);
+    assert.deepEqual(
+      analysis.extensions.map((extension) => extension.extension).sort(),
+      [".mkv", ".srt"],
+    );
+  });
+
+  it("stops at the time limit when a stat stalls", async () => {
+    const fileSystem = new MemoryFileSystem({
+      "/media/Heat (1995).mkv": "",
+      "/media/Oldboy (2003).mkv": "",
+    });
+    fileSystem.stall("/media/Oldboy (2003).mkv");
+    setScanFileSystem(fileSystem);
+
+    const analysis = await analyzeDirectory("/media", {
+      timeLimitMs: 50,
+      fileDeadlineMs: 60 * 60 * 1000,
+    });
+
+    assert.equal(analysis.stoppedBy, "time-limit");
+    assert.equal(analysis.totalFiles, 1);
+    assert.equal(analysis.unreadable, 0);
+    assert.ok(analysis.durationMs < 1000);
+  });
+
+  it("answers at the time limit when a listing never returns", async () => {
+    const fileSystem = new MemoryFileSystem({ "/media/Heat (1995).mkv": "" });
+    fileSystem.opendir = () => new Promise(() => {});
+    setScanFileSystem(fileSystem);
+
+    const analysis = await analyzeDirectory("/media", { timeLimitMs: 50 });
+
+    assert.equal(analysis.stoppedBy, "time-limit");
+    assert.equal(analysis.totalFiles, 0);
+  });
+});
@@ -0,0 +1,320 @@
+import { before, beforeEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import type * as LibraryServices from "../src/domains/library/library.services";
+import { installFakePrisma } from "./support/fake-prisma";
+import type { FakePrisma } from "./support/fake-prisma";
+
+// Installed before the service is imported, which happens in before()
+const prisma = installFakePrisma();
+let libraryServices: typeof LibraryServices.libraryServices;
+
+// eslint-disable-next-line @typescript-eslint/no-explicit-any
+type Row = Record<string, any>;
+
+const LIBRARY = {
+  id: "library-1",
+  name: "Everything",
+  slug: "everything",
+  description: null,
+  posterUrl: null,
+  backdropUrl: null,
+  libraryPath: "/media",
+  libraryType: null,
+};
+
+function fileRow(filePath: string | null) {
+  return {
+    filePath,
+    fileSize: filePath ? 1000n : null,
+    fileModifiedAt: filePath ? new Date("2025-01-01T00:00:00Z") : null,
+    is3D: false,
+    isRemux: false,
+    sourceType: null,
+    resolution: null,
+    language: null,
+    isProper: false,
+    isRepack: false,
+    isInternal: false,
+    titleSource: null,
+    scannerVersion: null,
+    missingSince: null,
+  };
+}
+
+function mediaRow(id: string, title: string, tmdbId: string): Row {
+  return {
+    id,
+    title,
+    description: null,
+    posterUrl: null,
+    backdropUrl: null,
+    meshGradientColors: [],
+    releaseDate: null,
+    rating: null,
+    externalIds: [{ source: "TMDB", externalId: tmdbId }],
+    genres: [],
+    people: [],
+    movie: null,
+    tvShow: null,
+  };
+}
+
+/**
+ * Serve a library for export: a movie, and two shows of the same title
+ * with no year, each with one episode
+ */
+function useExportFixture() {
+  const heat = mediaRow("media-heat", "Heat", "949");
+  heat.movie = { duration: 170, trailerUrl: null, ...fileRow("/m/Heat.mkv") };
+  const shows = [
+    ["show-us", "34307", "/tv/Shameless US/S01E01.mkv"],
+    ["show-uk", "1906", "/tv/Shameless UK/S01E01.mkv"],
+  ].map(([id, tmdbId, filePath]) => {
+    const media = mediaRow(`media-${id}`, "Shameless", tmdbId!);
+    media.tvShow = { id, creator: null, network: null };
+    return { media, season: { id: `${id}-s1`, tvShowId: id }, filePath };
+  });
+
+  prisma.mediaLibrary = {
+    findMany: async () =>
+      [heat, ...shows.map((show) => show.media)].map((media, index) => ({
+        id: `link-${index}`,
+        media,
+      })),
+  };
+  prisma.season = {
+    findMany: async ({ where }: Row) =>
+      shows
+        .filter((show) => show.season.tvShowId === where.tvShowId)
+        .map((show) => ({ ...show.season, number: 1, posterUrl: null })),
+  };
+  prisma.episode = {
+    findMany: async ({ where }: Row) =>
+      shows
+        .filter((show) => show.season.id === where.seasonId)
+        .map((show) => ({
+          number: 1,
+          title: "Pilot",
+          fileTitle: null,
+          duration: null,
+          airDate: null,
+          stillPath: null,
+          ...fileRow(show.filePath!),
+        })),
+  };
+}
+
+/**
+ * An in-memory database with just the queries an import makes
+ */
+function useImportDatabase(movies: Row[] = [], media: Row[] = []) {
+  const db = {
+    media,
+    externalIds: media.flatMap((row) =>
+      row.externalIds.map((id: Row) => ({ ...id, mediaId: row.id })),
+    ),
+    movies,
+    tvShows: [] as Row[],
+    seasons: [] as Row[],
+    episodes: [] as Row[],
+  };
+  let nextId = 0;
+  const newId = (prefix: string) => `${prefix}-${++nextId}`;
+  const withTvShow = (row: Row | undefined) =>
+    row && {
+      ...row,
+      tvShow: db.tvShows.find((show) => show.mediaId === row.id) ?? null,
+    };
+  const matches = (row: Row, where: Row) =>
+    Object.entries(where).every(([key, value]) => row[key] === value);
+  const upsert =
+    (rows: Row[], prefix: string, key: (where: Row) => Row) =>
+    async ({ where, update, create }: Row) => {
+      const existing = rows.find((row) => matches(row, key(where)));
+      if (existing) return Object.assign(existing, update);
+      const created = { id: newId(prefix), ...create };
+      rows.push(created);
+      return created;
+    };
+
+  const noop = async () => ({ id: "noop" });
+  Object.assign(prisma, {
+    library: { findUnique: async () => LIBRARY },
+    media: {
+      findFirst: async ({ where }: Row) => {
+        const tmdb = where.externalIds?.some;
+        if (tmdb) {
+          const owner = db.externalIds.find(
+            (id) =>
+              id.source === tmdb.source && id.externalId === tmdb.externalId,
+          );
+          return withTvShow(
+            db.media.find((row) => row.id === owner?.mediaId) ?? undefined,
+          );
+        }
+        const hasTmdbId = (row: Row) =>
+          db.externalIds.some(
+            (id) => id.mediaId === row.id && id.source === "TMDB",
+          );
+        return withTvShow(
+          db.media.find(
+            (row) =>
+              row.type === where.type &&
+              row.title === where.title &&
+              !(where.externalIds?.none && hasTmdbId(row)),
+          ),
+        );
+      },
+      create: async ({ data }: Row) => {
+        const created = { id: newId("media"), ...data };
+        db.media.push(created);
+        return created;
+      },
+      update: async ({ where, data }: Row) =>
+        Object.assign(db.media.find((row) => row.id === where.id)!, data),
+    },
+    externalId: {
+      createMany: async ({ data }: Row) => {
+        for (const id of data) {
+          if (
+            !db.externalIds.some(
+              (row) =>
+                row.source === id.source && row.externalId === id.externalId,
+            )
+          ) {
+            db.externalIds.push(id);
+          }
+        }
+      },
+    },
+    genre: { upsert: noop },
+    mediaGenre: { upsert: noop },
+    person: { upsert: noop },
+    mediaPerson: { upsert: noop },
+    mediaLibrary: { upsert: noop },
+    movie: {
+      findUnique: async ({ where }: Row) =>
+        db.movies.find((row) => matches(row, where)) ?? null,
+      upsert: upsert(db.movies, "movie", (where) => where),
+    },
+    tVShow: { upsert: upsert(db.tvShows, "show", (where) => where) },
+    season: {
+      upsert: upsert(db.seasons, "season", (where) => where.tvShowId_number),
+    },
+    episode: {
+      findUnique: async ({ where }: Row) =>
+        db.episodes.find((row) => matches(row, where)) ?? null,
+      update: async ({ where, data }: Row) =>
+        Object.assign(db.episodes.find((row) => row.id === where.id)!, data),
+      upsert: upsert(db.episodes, "episode", (where) => where.seasonId_number),
+    },
+  });
+  return db;
+}
+
+async function exportLines(): Promise<string[]> {
+  const lines: string[] = [];
+  for await (const record of libraryServices.exportRecords(LIBRARY as never)) {
+    lines.push(JSON.stringify(record));
+  }
+  return lines;
+}
+
+// The TMDB ID of the show an imported episode ended up in
+function showTmdbIdOf(
+  db: ReturnType<typeof useImportDatabase>,
+  filePath: string,
+) {
+  const episode = db.episodes.find((row) => row.filePath === filePath)!;
+  const season = db.seasons.find((row) => row.id === episode.seasonId)!;
+  const show = db.tvShows.find((row) => row.id === season.tvShowId)!;
+  return db.externalIds.find((id) => id.mediaId === show.mediaId)?.externalId;
+}
+
+describe("library export and import", () => {
+  before(async () => {
+    ({ libraryServices } = await import(
+      "../src/domains/library/library.services"
+    ));
+  });
+
+  beforeEach(() => {
+    for (const key of Object.keys(prisma as FakePrisma)) {
+      if (key !== "$on") delete prisma[key];
+    }
+  });
+
+  it("restores every record of an export", async () => {
+    useExportFixture();
+    const lines = await exportLines();
+    const db = useImportDatabase();
+
+    const result = await libraryServices.importRecords(LIBRARY.id, lines);
+
+    assert.equal(result.recordsFailed, 0, JSON.stringify(result.errors));
+    assert.equal(result.moviesImported, 1);
+    assert.equal(result.tvShowsImported, 2);
+    assert.equal(result.seasonsImported, 2);
+    assert.equal(result.episodesImported, 2);
+    assert.equal(db.movies[0]?.filePath, "/m/Heat.mkv");
+    assert.equal(db.movies[0]?.fileSize, 1000n);
+    assert.equal(db.tvShows.length, 2);
+  });
+
+  it("keeps shows of the same title apart by TMDB ID", async () => {
+    useExportFixture();
+    const lines = await exportLines();
+    const db = useImportDatabase();
+
+    await libraryServices.importRecords(LIBRARY.id, lines);
+
+    assert.equal(showTmdbIdOf(db, "/tv/Shameless US/S01E01.mkv"), "34307");
+    assert.equal(showTmdbIdOf(db, "/tv/Shameless UK/S01E01.mkv"), "1906");
+  });
+
+  it("keeps a movie's stored file that is still on disk", async () => {
+    useExportFixture();
+    const lines = await exportLines();
+    const stored = mediaRow("media-stored", "Heat", "949");
+    stored.type = "MOVIE";
+    const db = useImportDatabase(
+      [
+        {
+          id: "movie-stored",
+          mediaId: stored.id,
+          ...fileRow("/m/Heat.4k.mkv"),
+        },
+      ],
+      [stored],
+    );
+
+    const result = await libraryServices.importRecords(LIBRARY.id, lines);
+
+    assert.equal(result.movieFilesKept, 1);
+    assert.equal(db.movies.length, 1);
+    assert.equal(db.movies[0]?.filePath, "/m/Heat.4k.mkv");
+  });
+
+  it("takes the exported file when the stored one is missing", async () => {
+    useExportFixture();
+    const lines = await exportLines();
+    const stored = mediaRow("media-stored", "Heat", "949");
+    stored.type = "MOVIE";
+    const db = useImportDatabase(
+      [
+        {
+          id: "movie-stored",
+          mediaId: stored.id,
+          ...fileRow("/m/Heat.4k.mkv"),
+          missingSince: new Date(),
+        },
+      ],
+      [stored],
+    );
+
+    const result = await libraryServices.importRecords(LIBRARY.id, lines);
+
+    assert.equal(result.movieFilesKept, 0);
+    assert.equal(db.movies[0]?.filePath, "/m/Heat.mkv");
+  });
+});
@@ -0,0 +1,111 @@
+import { afterEach, beforeEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import { peekListing } from "../src/domains/scan/helpers/listing-cache.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+function scan(rootPath: string) {
+  return collectMediaEntries(rootPath, {
+    mediaType: "movie",
+    fileExtensions: getDefaultVideoExtensions(),
+  });
+}
+
+describe("listing cache", () => {
+  let fileSystem: MemoryFileSystem;
+  // Each test uses its own root, so listings cached by one do not leak into
+  // the next
+  let root: string;
+  let testCount = 0;
+
+  beforeEach(() => {
+    process.env.SCANNER_LISTING_CACHE = "true";
+    root = `/movies-${++testCount}`;
+    fileSystem = new MemoryFileSystem({
+      [`${root}/Heat (1995).mkv`]: { size: 700 },
+      [`${root}/Inception (2010)/Inception (2010).mkv`]: { size: 900 },
+    });
+    setScanFileSystem(fileSystem);
+  });
+
+  afterEach(() => {
+    delete process.env.SCANNER_LISTING_CACHE;
+    setScanFileSystem(null);
+  });
+
+  it("does not list unchanged folders again", async () => {
+    await scan(root);
+    fileSystem.calls.opendir.length = 0;
+
+    const entries = await scan(root);
+
+    assert.equal(entries.length, 2);
+    assert.deepEqual(fileSystem.calls.opendir, []);
+  });
+
+  it("lists a folder again once a file was added to it", async () => {
+    await scan(root);
+    fileSystem.write(`${root}/Oldboy (2003).mkv`);
+    fileSystem.calls.opendir.length = 0;
+
+    const entries = await scan(root);
+
+    assert.equal(entries.length, 3);
+    assert.deepEqual(fileSystem.calls.opendir, [root]);
+  });
+
+  it("still reads the details of every file", async () => {
+    await scan(root);
+    fileSystem.write(`${root}/Heat (1995).mkv`, { size: 800 });
+    fileSystem.calls.stat.length = 0;
+
+    const entries = await scan(root);
+
+    const heat = entries.find((entry) =>
+      entry.path.endsWith("Heat (1995).mkv"),
+    );
+    assert.equal(heat?.size, 800);
+    assert.ok(fileSystem.calls.stat.includes(`${root}/Heat (1995).mkv`));
+  });
+
+  it("lists every time when the cache is off", async () => {
+    delete process.env.SCANNER_LISTING_CACHE;
+    await scan(root);
+    fileSystem.calls.opendir.length = 0;
+
+    await scan(root);
+
+    assert.deepEqual(fileSystem.calls.opendir, [
+      root,
+      `${root}/Inception (2010)`,
+    ]);
+  });
+});
+
+describe("peekListing", () => {
+  const listing = ["a.mkv", "b.mkv", "c.mkv", "dester.json"].map((name) => ({
+    name,
+    isDirectory: () => false,
+  }));
+
+  it("finds files in a folder listed in one batch", async () => {
+    const peeked = await peekListing(listing, 10);
+
+    assert.equal(peeked.hasFile("dester.json"), true);
+    assert.equal(peeked.hasFile(".dester-skip-until"), false);
+  });
+
+  it("cannot rule out files in a folder larger than one batch", async () => {
+    const peeked = await peekListing(listing, 2);
+    assert.equal(peeked.hasFile("a.mkv"), true);
+    assert.equal(peeked.hasFile("dester.json"), null);
+
+    const names: string[] = [];
+    for await (const entry of peeked.entries) {
+      names.push(entry.name);
+    }
+    assert.deepEqual(names, ["a.mkv", "b.mkv", "c.mkv", "dester.json"]);
+  });
+});
@@ -0,0 +1,62 @@
+import { describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { scopeLogLine } from "../src/lib/utils/logger";
+import {
+  runWithLogContext,
+  setLogContextFields,
+} from "../src/lib/utils/log-context.util";
+
+// A line as winston hands it to a format
+function line(level: string, message: string) {
+  return { level, message, [Symbol.for("level")]: level };
+}
+
+// The message of a line after the scan's log context is applied, or null
+// when the line is dropped
+function logged(level: string, message: string): string | null {
+  const result = scopeLogLine(line(level, message));
+  return result ? String(result.message) : null;
+}
+
+describe("scan log level", () => {
+  it("drops debug lines outside a scan", () => {
+    assert.equal(logged("debug", "Walking /media"), null);
+    assert.equal(logged("info", "Server started"), "Server started");
+  });
+
+  it("logs debug lines only for the scan that asked for them", async () => {
+    const lines: Array<string | null> = [];
+    const pause = () => new Promise((resolve) => setTimeout(resolve, 5));
+
+    await Promise.all([
+      runWithLogContext({ logLevel: "debug" }, async () => {
+        setLogContextFields({ scanJobId: "job-1" });
+        await pause();
+        lines.push(logged("debug", "Parsed Heat (1995).mkv"));
+      }),
+      runWithLogContext({ logLevel: "info" }, async () => {
+        setLogContextFields({ scanJobId: "job-2" });
+        await pause();
+        lines.push(logged("debug", "Parsed Lost.S01E01.mkv"));
+        lines.push(logged("info", "Saved Lost"));
+      }),
+    ]);
+
+    assert.deepEqual(lines, [
+      "[scan job=job-1] Parsed Heat (1995).mkv",
+      null,
+      "[scan job=job-2] Saved Lost",
+    ]);
+    assert.equal(logged("debug", "After the scans"), null);
+  });
+
+  it("tags lines with the job, library and media type", () => {
+    runWithLogContext({ libraryId: "lib-1", mediaType: "tv" }, () => {
+      setLogContextFields({ scanJobId: "job-1" });
+      assert.equal(
+        logged("warn", "Folder skipped"),
+        "[scan job=job-1 library=lib-1 tv] Folder skipped",
+      );
+    });
+  });
+});
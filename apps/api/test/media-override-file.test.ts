@@ -0,0 +1,101 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+import type { MemoryFile } from "./support/memory-fs";
+
+async function scan(
+  rootPath: string,
+  mediaType: "movie" | "tv",
+  files: Record<string, MemoryFile>,
+) {
+  setScanFileSystem(new MemoryFileSystem(files));
+  const entries = await collectMediaEntries(rootPath, {
+    mediaType,
+    fileExtensions: getDefaultVideoExtensions(),
+  });
+  return new Map(entries.map((entry) => [entry.path, entry.extractedIds]));
+}
+
+describe("dester.json override files", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("pins the title, year and TMDB ID of a movie", async () => {
+    const ids = await scan("/movies", "movie", {
+      "/movies/alien-dc/a.l.i.e.n.mkv": "",
+      "/movies/alien-dc/dester.json": JSON.stringify({
+        title: "Alien",
+        year: 1979,
+        tmdbId: 348,
+      }),
+    });
+
+    const alien = ids.get("/movies/alien-dc/a.l.i.e.n.mkv");
+    assert.equal(alien?.title, "Alien");
+    assert.equal(alien?.year, "1979");
+    assert.equal(alien?.tmdbId, "348");
+    assert.equal(alien?.titleSource, "override-file");
+  });
+
+  it("numbers each episode from its entry under files", async () => {
+    const ids = await scan("/tv", "tv", {
+      "/tv/Lost (2004)/Season 1/pilot part one.mkv": "",
+      "/tv/Lost (2004)/Season 1/pilot part two.mkv": "",
+      "/tv/Lost (2004)/Season 1/dester.json": JSON.stringify({
+        season: 1,
+        files: {
+          "pilot part one.mkv": { episode: 1 },
+          "pilot part two.mkv": { episode: 2 },
+        },
+      }),
+    });
+
+    const one = ids.get("/tv/Lost (2004)/Season 1/pilot part one.mkv");
+    const two = ids.get("/tv/Lost (2004)/Season 1/pilot part two.mkv");
+    assert.deepEqual([one?.season, one?.episode], [1, 1]);
+    assert.deepEqual([two?.season, two?.episode], [1, 2]);
+  });
+
+  it("ignores a top-level episode in a folder of several videos", async () => {
+    const ids = await scan("/tv", "tv", {
+      "/tv/Lost (2004)/Season 1/Lost.S01E01.mkv": "",
+      "/tv/Lost (2004)/Season 1/Lost.S01E02.mkv": "",
+      "/tv/Lost (2004)/Season 1/dester.json": JSON.stringify({
+        episode: 5,
+        tmdbId: 4607,
+      }),
+    });
+
+    assert.deepEqual(
+      [...ids.values()].map((entry) => entry.episode).sort(),
+      [1, 2],
+    );
+    for (const entry of ids.values()) {
+      assert.equal(entry.tmdbId, undefined);
+    }
+  });
+
+  it("ignores a malformed override file and parses the names", async () => {
+    for (const contents of ['{"title": "Alien",', '{"titel": "Alien"}']) {
+      const ids = await scan("/movies", "movie", {
+        "/movies/Heat (1995)/Heat (1995).mkv": "",
+        "/movies/Heat (1995)/dester.json": contents,
+      });
+
+      const heat = ids.get("/movies/Heat (1995)/Heat (1995).mkv");
+      assert.equal(heat?.title, "Heat");
+      assert.notEqual(heat?.titleSource, "override-file");
+    }
+  });
+
+  it("skips videos in a folder marked as the other media type", async () => {
+    const ids = await scan("/movies", "movie", {
+      "/movies/Lost (2004)/Lost.S01E01.mkv": "",
+      "/movies/Lost (2004)/dester.json": '{"mediaType": "tv"}',
+    });
+
+    assert.equal(ids.size, 0);
+  });
+});
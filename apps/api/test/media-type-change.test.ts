@@ -0,0 +1,130 @@
+import { before, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import type * as MediaTypeChange from "../src/domains/scan/helpers/media-type-change.helper";
+import { installFakePrisma } from "./support/fake-prisma";
+
+// Installed before the helper is imported, which happens in before()
+const prisma = installFakePrisma();
+let releaseEpisodeFile: typeof MediaTypeChange.releaseEpisodeFile;
+let releaseReclassifiedFile: typeof MediaTypeChange.releaseReclassifiedFile;
+
+const heldEpisode = {
+  id: "episode-1",
+  number: 3,
+  season: { number: 1, tvShow: { media: { title: "Lost" } } },
+};
+
+before(async () => {
+  ({ releaseEpisodeFile, releaseReclassifiedFile } = await import(
+    "../src/domains/scan/helpers/media-type-change.helper"
+  ));
+});
+
+describe("releaseEpisodeFile", () => {
+  it("unlinks the episode that holds the file", async () => {
+    const updates: unknown[] = [];
+    prisma.episode = {
+      findUnique: async () => heldEpisode,
+      update: async (args: unknown) => updates.push(args),
+    };
+
+    const released = await releaseEpisodeFile(
+      "/tv/Lost/Featurettes/Pilot.mkv",
+      "is now saved as an extra",
+      "unlink",
+    );
+
+    assert.equal(released, true);
+    assert.deepEqual(updates, [
+      {
+        where: { id: "episode-1" },
+        data: {
+          filePath: null,
+          fileSize: null,
+          fileModifiedAt: null,
+          missingSince: null,
+        },
+      },
+    ]);
+  });
+
+  it("deletes the episode in delete mode", async () => {
+    const deleted: unknown[] = [];
+    prisma.episode = {
+      findUnique: async () => heldEpisode,
+      delete: async (args: unknown) => deleted.push(args),
+    };
+
+    await releaseEpisodeFile("/tv/Lost/Pilot.mkv", "is now a movie", "delete");
+
+    assert.deepEqual(deleted, [{ where: { id: "episode-1" } }]);
+  });
+
+  it("leaves files no episode holds alone", async () => {
+    prisma.episode = { findUnique: async () => null };
+
+    assert.equal(
+      await releaseEpisodeFile("/tv/New.mkv", "is now saved as an extra"),
+      false,
+    );
+  });
+});
+
+describe("releaseReclassifiedFile", () => {
+  const heldMovie = {
+    id: "movie-1",
+    mediaId: "media-1",
+    media: { title: "Lost Pilot" },
+  };
+
+  it("unlinks a movie whose file is now scanned as an episode", async () => {
+    const updates: unknown[] = [];
+    prisma.movie = {
+      findUnique: async () => heldMovie,
+      update: async (args: unknown) => updates.push(args),
+    };
+
+    const released = await releaseReclassifiedFile(
+      "/media/Lost.S01E01.mkv",
+      "tv",
+      "unlink",
+    );
+
+    assert.equal(released, true);
+    assert.deepEqual(updates, [
+      {
+        where: { id: "movie-1" },
+        data: {
+          filePath: null,
+          fileSize: null,
+          fileModifiedAt: null,
+          missingSince: null,
+        },
+      },
+    ]);
+  });
+
+  it("deletes the movie's media in delete mode", async () => {
+    const deleted: unknown[] = [];
+    prisma.movie = { findUnique: async () => heldMovie };
+    prisma.media = { delete: async (args: unknown) => deleted.push(args) };
+
+    await releaseReclassifiedFile("/media/Lost.S01E01.mkv", "tv", "delete");
+
+    assert.deepEqual(deleted, [{ where: { id: "media-1" } }]);
+  });
+
+  it("releases the episode of a file now scanned as a movie", async () => {
+    const updates: unknown[] = [];
+    prisma.episode = {
+      findUnique: async () => heldEpisode,
+      update: async (args: unknown) => updates.push(args),
+    };
+
+    assert.equal(
+      await releaseReclassifiedFile("/media/Lost.mkv", "movie", "unlink"),
+      true,
+    );
+    assert.equal(updates.length, 1);
+  });
+});
@@ -0,0 +1,68 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import {
+  getExtraSuffixes,
+  matchExtraSuffix,
+} from "../src/domains/scan/helpers/movie-extras.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+describe("matchExtraSuffix", () => {
+  const suffixes = getExtraSuffixes("");
+
+  it("matches a suffix after a dash", () => {
+    assert.deepEqual(
+      matchExtraSuffix("Inception (2010)-trailer.mkv", suffixes, null),
+      { extraType: "trailer", baseName: "Inception (2010).mkv" },
+    );
+  });
+
+  it("leaves dashed titles alone", () => {
+    assert.equal(matchExtraSuffix("Spider-Man.mkv", suffixes, null), null);
+  });
+
+  it("takes a release group tag for a suffix only next to a main file", () => {
+    const name = "Heat.1995.1080p-SCENE.mkv";
+    assert.equal(matchExtraSuffix(name, suffixes, [name]), null);
+    assert.equal(matchExtraSuffix(name, suffixes, null), null);
+    assert.equal(
+      matchExtraSuffix("Heat (1995)-scene.mkv", suffixes, [
+        "Heat (1995).mkv",
+        "Heat (1995)-scene.mkv",
+      ])?.extraType,
+      "scene",
+    );
+  });
+});
+
+describe("collectMediaEntries with movie extras", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("scans a file with a release group tag as a movie", async () => {
+    setScanFileSystem(
+      new MemoryFileSystem({
+        "/movies/Heat (1995)/Heat.1995.1080p-SCENE.mkv": "",
+        "/movies/Oldboy (2003)/Oldboy (2003).mkv": "",
+        "/movies/Oldboy (2003)/Oldboy (2003)-clip.mkv": "",
+      }),
+    );
+
+    const entries = await collectMediaEntries("/movies", {
+      mediaType: "movie",
+      fileExtensions: getDefaultVideoExtensions(),
+    });
+
+    const byPath = new Map(entries.map((entry) => [entry.path, entry]));
+    assert.equal(entries.length, 3);
+    assert.equal(
+      byPath.get("/movies/Heat (1995)/Heat.1995.1080p-SCENE.mkv")?.extraType,
+      undefined,
+    );
+    assert.equal(
+      byPath.get("/movies/Oldboy (2003)/Oldboy (2003)-clip.mkv")?.extraType,
+      "clip",
+    );
+  });
+});
@@ -0,0 +1,107 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  checkListedFiles,
+  getSubpathFolder,
+  getSubpathSkipReason,
+} from "../src/domains/scan/helpers/path-validator.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+describe("getSubpathFolder", () => {
+  it("scans a subpath through its top-level folder", () => {
+    assert.equal(
+      getSubpathFolder("/tv", "Breaking Bad/Season 5"),
+      "Breaking Bad",
+    );
+  });
+
+  it("rejects the root and paths that leave it", () => {
+    assert.equal(getSubpathFolder("/tv", "."), null);
+    assert.equal(getSubpathFolder("/tv", "../movies"), null);
+  });
+});
+
+describe("getSubpathSkipReason", () => {
+  it("accepts folders the walker reaches", () => {
+    assert.equal(getSubpathSkipReason("/tv", "Lost/Season 1"), null);
+  });
+
+  it("rejects subpaths under folders the walker skips", () => {
+    assert.equal(getSubpathSkipReason("/tv", ".hidden/Lost"), "hidden");
+    assert.equal(getSubpathSkipReason("/tv", "Lost/@eaDir"), "system");
+    assert.equal(
+      getSubpathSkipReason("/movies", "Heat (1995)/Featurettes"),
+      "extras-folder",
+    );
+  });
+
+  it("accepts extras folders when extras are included", () => {
+    assert.equal(
+      getSubpathSkipReason("/tv", "Lost/Featurettes", {
+        includeExtras: true,
+      }),
+      null,
+    );
+  });
+});
+
+describe("checkListedFiles", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("keeps scannable files and says why others were rejected", async () => {
+    setScanFileSystem(
+      new MemoryFileSystem({
+        "/movies/Heat (1995).mkv": "",
+        "/movies/Heat (1995).srt": "",
+        "/movies/Oldboy (2003)/": "",
+      }),
+    );
+
+    const { files, rejectedFiles } = await checkListedFiles(
+      "/movies",
+      [
+        "Heat (1995).mkv",
+        "/movies/Heat (1995).mkv",
+        "Heat (1995).srt",
+        "Oldboy (2003)",
+        "Missing.mkv",
+        "../tv/Lost.mkv",
+      ],
+      undefined,
+      1000,
+    );
+
+    assert.deepEqual(files, ["/movies/Heat (1995).mkv"]);
+    assert.deepEqual(
+      rejectedFiles.map((rejected) => rejected.reason),
+      [
+        "Left out by the scanner (sidecar)",
+        "Not a file",
+        "Does not exist or is not accessible",
+        "Not inside the scan path",
+      ],
+    );
+  });
+
+  it("rejects a file the drive does not answer for", async () => {
+    const fileSystem = new MemoryFileSystem({
+      "/movies/Heat (1995).mkv": "",
+      "/movies/Oldboy (2003).mkv": "",
+    });
+    fileSystem.stall("/movies/Heat (1995).mkv");
+    setScanFileSystem(fileSystem);
+
+    const { files, rejectedFiles } = await checkListedFiles(
+      "/movies",
+      ["Heat (1995).mkv", "Oldboy (2003).mkv"],
+      undefined,
+      20,
+    );
+
+    assert.deepEqual(files, ["/movies/Oldboy (2003).mkv"]);
+    assert.deepEqual(rejectedFiles, [
+      { path: "Heat (1995).mkv", reason: "The drive did not answer in time" },
+    ]);
+  });
+});
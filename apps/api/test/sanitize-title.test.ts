@@ -0,0 +1,70 @@
+import { describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { randomBytes } from "crypto";
+import {
+  MAX_TITLE_LENGTH,
+  sanitizeTitle,
+} from "../src/lib/utils/sanitization.util";
+import { extractIds } from "../src/lib/utils/external-id.util";
+
+// Whether a string is valid UTF-16, with every surrogate paired
+const isWellFormed = (value: string) =>
+  !/[\uD800-\uDBFF](?![\uDC00-\uDFFF])|(?<![\uD800-\uDBFF])[\uDC00-\uDFFF]/.test(
+    value,
+  );
+
+// Read random bytes as UTF-16 units, which gives lone surrogates too
+const toUtf16 = (bytes: Buffer) =>
+  Array.from({ length: bytes.length >> 1 }, (_, index) =>
+    String.fromCharCode(bytes.readUInt16LE(index * 2)),
+  ).join("");
+
+describe("sanitizeTitle", () => {
+  it("turns newlines and NUL bytes into single spaces", () => {
+    assert.deepEqual(sanitizeTitle("Heat\n\u0000(1995)\r\n  Extended\t"), {
+      title: "Heat (1995) Extended",
+      truncated: false,
+    });
+  });
+
+  it("replaces unpaired surrogates and keeps pairs", () => {
+    assert.equal(sanitizeTitle("Amélie 🎬").title, "Amélie 🎬");
+    assert.equal(
+      sanitizeTitle("Broken \uD83C title").title,
+      "Broken \uFFFD title",
+    );
+  });
+
+  it("truncates long titles by code point and flags it", () => {
+    const result = sanitizeTitle("🎬".repeat(MAX_TITLE_LENGTH + 10));
+
+    assert.equal(result.truncated, true);
+    assert.equal(Array.from(result.title).length, MAX_TITLE_LENGTH);
+    assert.ok(isWellFormed(result.title));
+  });
+
+  it("never throws and always returns clean text for random bytes", () => {
+    for (let run = 0; run < 2000; run++) {
+      const bytes = randomBytes(1 + (run % 700));
+      for (const input of [
+        bytes.toString("utf8"),
+        bytes.toString("latin1"),
+        toUtf16(bytes),
+      ]) {
+        const { title } = sanitizeTitle(input);
+
+        assert.ok(isWellFormed(title), JSON.stringify(input));
+        assert.doesNotMatch(title, /[\u0000-\u001F\u007F-\u009F]/);
+        assert.ok(Array.from(title).length <= MAX_TITLE_LENGTH);
+        assert.equal(title, title.trim());
+      }
+    }
+  });
+});
+
+describe("parsed titles", () => {
+  it("reach the scanner without control characters", () => {
+    const ids = extractIds("Heat\n\u0000Director's Cut (1995).mkv");
+    assert.doesNotMatch(ids.title ?? "", /[\u0000-\u001F]/);
+  });
+});
@@ -0,0 +1,69 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  clearScanActivity,
+  getScanThroughput,
+  recordScanThroughput,
+  startScanThroughput,
+} from "../src/domains/scan/helpers/scan-activity.helper";
+
+const JOB = "job-1";
+const START = Date.UTC(2025, 0, 1);
+const minutes = (count: number) => START + count * 60 * 1000;
+
+describe("scan throughput", () => {
+  afterEach(() => clearScanActivity(JOB));
+
+  it("counts the time before the first folder against the rate", () => {
+    startScanThroughput(JOB, START);
+    recordScanThroughput(JOB, 1, 30, minutes(1));
+
+    const rate = getScanThroughput(JOB, 9, minutes(1));
+
+    assert.equal(rate?.foldersPerSecond, 0.02);
+    assert.equal(rate?.filesPerSecond, 0.5);
+    assert.equal(rate?.estimatedSecondsRemaining, 540);
+  });
+
+  it("gives a rate when folders take longer than the window", () => {
+    startScanThroughput(JOB, START);
+    recordScanThroughput(JOB, 1, 10, minutes(10));
+    recordScanThroughput(JOB, 1, 10, minutes(20));
+
+    const rate = getScanThroughput(JOB, 3, minutes(20));
+
+    // From the sample at 10 minutes, the last before the window
+    assert.equal(rate?.filesPerSecond, 0.02);
+    assert.equal(rate?.estimatedSecondsRemaining, 1800);
+  });
+
+  it("follows a change in speed instead of averaging the whole scan", () => {
+    startScanThroughput(JOB, START);
+    for (let minute = 1; minute <= 20; minute++) {
+      recordScanThroughput(JOB, 1, 0, minutes(minute));
+    }
+    // Ten times faster for the last five minutes
+    for (let step = 1; step <= 50; step++) {
+      recordScanThroughput(JOB, 1, 0, minutes(20 + step / 10));
+    }
+
+    const rate = getScanThroughput(JOB, 100, minutes(25));
+
+    assert.ok(rate && rate.foldersPerSecond > 0.15);
+  });
+
+  it("slows down while nothing finishes", () => {
+    startScanThroughput(JOB, START);
+    recordScanThroughput(JOB, 10, 0, minutes(1));
+
+    const early = getScanThroughput(JOB, 10, minutes(1));
+    const later = getScanThroughput(JOB, 10, minutes(4));
+
+    assert.ok(early && later);
+    assert.ok(later.foldersPerSecond < early.foldersPerSecond);
+  });
+
+  it("has no rate for a job that is not running", () => {
+    assert.equal(getScanThroughput("unknown", 1, START), null);
+  });
+});
@@ -0,0 +1,123 @@
+import { describe, it } from "node:test";
+import assert from "node:assert/strict";
+import {
+  cancelQueuedScan,
+  enqueueScan,
+  getQueuedScan,
+  getScanCoalesceKey,
+  isScanQueueIdle,
+} from "../src/domains/scan/helpers/scan-queue.helper";
+import type { ScanCoalesceOptions } from "../src/domains/scan/helpers/scan-queue.helper";
+
+// A scan that runs until the test finishes it
+function heldScan(started: string[], name: string) {
+  let finish = () => {};
+  return {
+    task: () => {
+      started.push(name);
+      return new Promise<void>((resolve) => (finish = resolve));
+    },
+    finish: () => finish(),
+  };
+}
+
+// Let a finished scan hand over to the next one in the queue
+const settle = () => new Promise((resolve) => setImmediate(resolve));
+
+describe("scan queue", () => {
+  it("moves scans up as earlier ones finish or are cancelled", async () => {
+    const started: string[] = [];
+    const cancelled: string[] = [];
+    const [first, second, third, fourth] = [
+      "first",
+      "second",
+      "third",
+      "fourth",
+    ].map((name) => heldScan(started, name));
+
+    assert.equal(enqueueScan(first!.task).queued, false);
+    const secondId = enqueueScan(second!.task).queueId;
+    const thirdId = enqueueScan(third!.task, "normal", undefined, () =>
+      cancelled.push("third"),
+    ).queueId;
+    const fourthId = enqueueScan(fourth!.task).queueId;
+    const position = (queueId: string) =>
+      getQueuedScan(queueId)?.queuePosition ?? null;
+
+    // One scan runs at a time
+    assert.deepEqual(started, ["first"]);
+    assert.deepEqual([secondId, thirdId, fourthId].map(position), [1, 2, 3]);
+
+    first!.finish();
+    await settle();
+    assert.deepEqual(started, ["first", "second"]);
+    assert.deepEqual([secondId, thirdId, fourthId].map(position), [
+      null,
+      1,
+      2,
+    ]);
+
+    // A running scan cannot be cancelled; a waiting one leaves the queue
+    assert.equal(cancelQueuedScan(secondId), false);
+    assert.equal(cancelQueuedScan(thirdId), true);
+    assert.equal(cancelQueuedScan(thirdId), false);
+    assert.deepEqual(cancelled, ["third"]);
+    assert.equal(position(thirdId), null);
+    assert.equal(position(fourthId), 1);
+
+    second!.finish();
+    await settle();
+    assert.deepEqual(started, ["first", "second", "fourth"]);
+
+    fourth!.finish();
+    await settle();
+    assert.equal(isScanQueueIdle(), true);
+  });
+});
+
+describe("getScanCoalesceKey", () => {
+  const key = (rootPath: string, options: Partial<ScanCoalesceOptions> = {}) =>
+    getScanCoalesceKey(rootPath, { mediaType: "tv", ...options }, false);
+
+  it("matches however the root path is written", () => {
+    assert.equal(key("/media/tv/"), key("/media//tv/."));
+  });
+
+  it("matches subpaths in any order", () => {
+    assert.equal(
+      key("/tv", { subpaths: ["Lost", "Heroes/"] }),
+      key("/tv", { subpaths: ["./Heroes", "Lost"] }),
+    );
+  });
+
+  it("keeps scans of different subpaths apart", () => {
+    assert.notEqual(
+      key("/tv", { subpaths: ["Lost"] }),
+      key("/tv", { subpaths: ["Heroes"] }),
+    );
+  });
+
+  it("keeps a full library scan apart from a subpath scan", () => {
+    assert.notEqual(key("/tv"), key("/tv", { subpaths: ["Lost"] }));
+  });
+
+  it("keeps scans with different options apart", () => {
+    for (const options of [
+      { includeExtras: true },
+      { rescan: true },
+      { recordSkips: true },
+      { batchScan: false },
+      { maxDepth: 1 },
+      { fileExtensions: [".mkv"] },
+    ]) {
+      assert.notEqual(key("/tv"), key("/tv", options));
+    }
+  });
+
+  it("keys unset options as their defaults", () => {
+    assert.equal(
+      key("/tv"),
+      key("/tv", { rescan: false, includeExtras: false, batchScan: true }),
+    );
+  });
+});
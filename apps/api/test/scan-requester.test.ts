@@ -0,0 +1,109 @@
+import { afterEach, before, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import type * as BatchScanner from "../src/domains/scan/helpers/batch-scanner.helper";
+import {
+  createScanRequester,
+  redactRemoteAddress,
+} from "../src/domains/scan/helpers/scan-requester.helper";
+import { installFakePrisma } from "./support/fake-prisma";
+
+// Installed before the batch scanner is imported, which happens in before()
+const prisma = installFakePrisma();
+let createScanJob: typeof BatchScanner.createScanJob;
+
+describe("createScanRequester", () => {
+  afterEach(() => {
+    delete process.env.SCANNER_REDACT_REQUESTER_IP;
+  });
+
+  it("keeps the origin, User-Agent and address as sent", () => {
+    assert.deepEqual(
+      createScanRequester({
+        origin: "admin-ui",
+        userAgent: "Mozilla/5.0",
+        remoteAddress: "192.168.1.23",
+      }),
+      {
+        origin: "admin-ui",
+        userAgent: "Mozilla/5.0",
+        remoteAddress: "192.168.1.23",
+      },
+    );
+  });
+
+  it("stores IPv4 addresses seen through IPv6 as IPv4", () => {
+    const requester = createScanRequester({ remoteAddress: "::ffff:10.0.0.5" });
+    assert.equal(requester.remoteAddress, "10.0.0.5");
+  });
+
+  it("masks addresses when SCANNER_REDACT_REQUESTER_IP is set", () => {
+    process.env.SCANNER_REDACT_REQUESTER_IP = "true";
+
+    assert.equal(
+      createScanRequester({ remoteAddress: "::ffff:10.0.0.5" }).remoteAddress,
+      "10.0.0.0",
+    );
+    assert.equal(
+      createScanRequester({ remoteAddress: "2001:db8:85a3::8a2e:370:7334" })
+        .remoteAddress,
+      "2001:db8:85a3::",
+    );
+  });
+
+  it("leaves out what the request did not send", () => {
+    assert.deepEqual(createScanRequester({}), {
+      origin: null,
+      userAgent: null,
+      remoteAddress: null,
+    });
+  });
+});
+
+describe("redactRemoteAddress", () => {
+  it("keeps the first three groups of a shortened IPv6 address", () => {
+    assert.equal(redactRemoteAddress("2001:db8::1"), "2001:db8:0::");
+    assert.equal(redactRemoteAddress("::1"), "0:0:0::");
+  });
+
+  it("drops anything that is not an address", () => {
+    assert.equal(redactRemoteAddress("localhost"), null);
+  });
+});
+
+describe("createScanJob", () => {
+  before(async () => {
+    ({ createScanJob } = await import(
+      "../src/domains/scan/helpers/batch-scanner.helper"
+    ));
+  });
+
+  it("stores the requester on the job", async () => {
+    let created: Record<string, unknown> | undefined;
+    prisma.scanJob = {
+      create: async ({ data }: { data: Record<string, unknown> }) => {
+        created = data;
+        return { id: "job-1", ...data };
+      },
+    };
+    process.env.SCANNER_REDACT_REQUESTER_IP = "true";
+
+    const scanJobId = await createScanJob(
+      "library-1",
+      "/media/tv",
+      "TV_SHOW" as never,
+      ["Lost"],
+      undefined,
+      createScanRequester({
+        origin: "scheduler",
+        userAgent: "curl/8.0",
+        remoteAddress: "203.0.113.9",
+      }),
+    );
+
+    delete process.env.SCANNER_REDACT_REQUESTER_IP;
+    assert.equal(scanJobId, "job-1");
+    assert.equal(created?.origin, "scheduler");
+    assert.equal(created?.userAgent, "curl/8.0");
+    assert.equal(created?.remoteAddress, "203.0.113.0");
+  });
+});
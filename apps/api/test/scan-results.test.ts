@@ -0,0 +1,78 @@
+import { after, afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { mkdtempSync, readFileSync, rmSync } from "fs";
+import { tmpdir } from "os";
+import { join } from "path";
+import { openScanResultsFile } from "../src/domains/scan/helpers/scan-results.helper";
+import type { MediaEntry } from "../src/domains/scan/scan.types";
+
+function entry(path: string): MediaEntry {
+  return {
+    path,
+    name: path.split("/").pop()!,
+    isDirectory: false,
+    size: 0,
+    modified: new Date(0),
+    extractedIds: {},
+  } as unknown as MediaEntry;
+}
+
+describe("openScanResultsFile", () => {
+  const directory = mkdtempSync(join(tmpdir(), "scan-results-"));
+  const filePath = join(directory, "results.jsonl");
+
+  afterEach(() => {
+    delete process.env.SCANNER_RESULTS_FILE;
+    delete process.env.SCANNER_RESULTS_FILE_MODE;
+    rmSync(filePath, { force: true });
+  });
+  after(() => rmSync(directory, { recursive: true, force: true }));
+
+  it("shares the file between scans that overlap", async () => {
+    process.env.SCANNER_RESULTS_FILE = filePath;
+    process.env.SCANNER_RESULTS_FILE_MODE = "truncate";
+
+    const first = await openScanResultsFile({
+      libraryId: "movies",
+      mediaType: "movie",
+      scanJobId: "first",
+    });
+    first!.write(entry("/movies/Heat.mkv"), { outcome: "saved" });
+    const second = await openScanResultsFile({
+      libraryId: "tv",
+      mediaType: "tv",
+      scanJobId: "second",
+    });
+    second!.write(entry("/tv/Lost.S01E01.mkv"), { outcome: "no-match" });
+    await first!.close();
+    second!.write(entry("/tv/Lost.S01E02.mkv"), { outcome: "saved" });
+    await second!.close();
+
+    const lines = readFileSync(filePath, "utf8")
+      .trim()
+      .split("\n")
+      .map((line) => JSON.parse(line));
+    assert.deepEqual(
+      lines.map((line) => [line.scanJobId, line.path]),
+      [
+        ["first", "/movies/Heat.mkv"],
+        ["second", "/tv/Lost.S01E01.mkv"],
+        ["second", "/tv/Lost.S01E02.mkv"],
+      ],
+    );
+  });
+
+  it("ignores writes after close", async () => {
+    process.env.SCANNER_RESULTS_FILE = filePath;
+
+    const writer = await openScanResultsFile({
+      libraryId: "movies",
+      mediaType: "movie",
+    });
+    await writer!.close();
+    writer!.write(entry("/movies/Heat.mkv"), { outcome: "saved" });
+    await writer!.close();
+
+    assert.equal(readFileSync(filePath, "utf8"), "");
+  });
+});
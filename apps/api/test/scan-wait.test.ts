@@ -0,0 +1,104 @@
+import { after, afterEach, before, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { mkdtempSync, rmSync } from "fs";
+import { tmpdir } from "os";
+import { join } from "path";
+import type * as ScanController from "../src/domains/scan/scan.controller";
+import type * as ScanServices from "../src/domains/scan/scan.services";
+import { installFakePrisma } from "./support/fake-prisma";
+
+// Installed before the controller is imported, which happens in before()
+const prisma = installFakePrisma();
+let scanControllers: typeof ScanController.scanControllers;
+let scanServices: typeof ScanServices.scanServices;
+
+interface Answer {
+  status: number;
+  // eslint-disable-next-line @typescript-eslint/no-explicit-any
+  body: any;
+}
+
+/**
+ * Call the scan handler with wait set and resolve with its answer
+ */
+function postScanAndWait(path: string): Promise<Answer> {
+  return new Promise((resolve, reject) => {
+    let status = 200;
+    const res = {
+      status(code: number) {
+        status = code;
+        return res;
+      },
+      json(body: unknown) {
+        resolve({ status, body });
+        return res;
+      },
+    };
+    const req = {
+      validatedData: { path, options: { mediaType: "comic", wait: true } },
+    };
+    scanControllers.post(req as never, res as never, reject);
+  });
+}
+
+describe("scan requests that wait", () => {
+  const root = mkdtempSync(join(tmpdir(), "dester-wait-"));
+  const postComics = () => scanServices.postComics;
+  let originalPostComics: ReturnType<typeof postComics>;
+
+  before(async () => {
+    ({ scanControllers } = await import("../src/domains/scan/scan.controller"));
+    ({ scanServices } = await import("../src/domains/scan/scan.services"));
+    originalPostComics = scanServices.postComics;
+  });
+
+  afterEach(() => {
+    scanServices.postComics = originalPostComics;
+    delete process.env.SCANNER_SYNC_BUDGET_SECONDS;
+  });
+
+  after(() => rmSync(root, { recursive: true, force: true }));
+
+  it("answers with the result when the scan finishes in time", async () => {
+    const result = { libraryName: "Comics", totalFiles: 2, totalSaved: 2 };
+    scanServices.postComics = (async () => result) as never;
+
+    const answer = await postScanAndWait(root);
+
+    assert.equal(answer.status, 200);
+    assert.equal(answer.body.data.stillRunning, false);
+    assert.deepEqual(answer.body.data.result, result);
+  });
+
+  it("answers 202 with the progress so far once the budget runs out", async () => {
+    process.env.SCANNER_SYNC_BUDGET_SECONDS = "0.05";
+    let finishScan: () => void = () => {};
+    scanServices.postComics = (() =>
+      new Promise((resolve) => {
+        finishScan = () =>
+          resolve({ libraryName: "Comics", totalFiles: 1, totalSaved: 1 });
+      })) as never;
+    prisma.scanJob = { findFirst: async () => ({ id: "job-1" }) };
+    const originalGetJobStatus = scanServices.getJobStatus;
+    scanServices.getJobStatus = (async () => ({
+      progress: { processedFolders: 3, totalFolders: 10 },
+    })) as never;
+
+    try {
+      const answer = await postScanAndWait(root);
+
+      assert.equal(answer.status, 202);
+      assert.equal(answer.body.data.stillRunning, true);
+      assert.equal(answer.body.data.queued, false);
+      assert.equal(answer.body.data.scanJobId, "job-1");
+      assert.deepEqual(answer.body.data.progress, {
+        processedFolders: 3,
+        totalFolders: 10,
+      });
+    } finally {
+      // The scan goes on in the background; let it end so the queue is free
+      finishScan();
+      scanServices.getJobStatus = originalGetJobStatus;
+    }
+  });
+});
@@ -0,0 +1,130 @@
+import { afterEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import { collectMediaEntries } from "../src/domains/scan/helpers/file-scanner.helper";
+import { getDefaultVideoExtensions } from "../src/domains/scan/helpers/file-filter.helper";
+import { setScanFileSystem } from "../src/domains/scan/helpers/scan-fs.helper";
+import {
+  getActiveSkipSentinel,
+  parseSkipSentinel,
+} from "../src/domains/scan/helpers/skip-sentinel.helper";
+import type { SkipReason } from "../src/domains/scan/helpers/scan-skips.helper";
+import { MemoryFileSystem } from "./support/memory-fs";
+
+const NOW = new Date("2025-06-01T12:00:00Z");
+
+function useFixture(sentinel: string): MemoryFileSystem {
+  const fileSystem = new MemoryFileSystem({
+    "/movies/Heat (1995).mkv": "",
+    "/movies/Moving/Oldboy (2003).mkv": "",
+    "/movies/Moving/.dester-skip-until": sentinel,
+  });
+  setScanFileSystem(fileSystem);
+  return fileSystem;
+}
+
+async function scan(options: { fileDeadlineMs?: number } = {}) {
+  const skips = new Map<string, SkipReason>();
+  const sentinelSkips: string[] = [];
+  const entries = await collectMediaEntries("/movies", {
+    mediaType: "movie",
+    fileExtensions: getDefaultVideoExtensions(),
+    fileDeadlineMs: options.fileDeadlineMs,
+    onSkip: (path, reason) => skips.set(path, reason),
+    onSentinelSkip: (folderPath) => sentinelSkips.push(folderPath),
+  });
+  return {
+    paths: entries.map((entry) => entry.path).sort(),
+    skips,
+    sentinelSkips,
+  };
+}
+
+describe("parseSkipSentinel", () => {
+  it("reads an RFC 3339 timestamp", () => {
+    assert.equal(
+      parseSkipSentinel("2025-12-01T18:00:00+01:00\n")?.toISOString(),
+      "2025-12-01T17:00:00.000Z",
+    );
+  });
+
+  it("treats an empty file as an indefinite skip", () => {
+    assert.equal(parseSkipSentinel("  \n"), null);
+  });
+
+  it("treats a malformed timestamp as an indefinite skip", () => {
+    assert.equal(parseSkipSentinel("next tuesday"), null);
+    assert.equal(parseSkipSentinel("2025-12-01"), null);
+  });
+});
+
+describe("getActiveSkipSentinel", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("is active until its end time", async () => {
+    useFixture("2025-06-02T00:00:00Z");
+    const sentinel = await getActiveSkipSentinel("/movies/Moving", 1000, NOW);
+    assert.equal(sentinel?.until?.toISOString(), "2025-06-02T00:00:00.000Z");
+  });
+
+  it("has expired after its end time", async () => {
+    useFixture("2025-05-01T00:00:00Z");
+    assert.equal(
+      await getActiveSkipSentinel("/movies/Moving", 1000, NOW),
+      null,
+    );
+  });
+
+  it("gives up on a sentinel the drive does not answer for", async () => {
+    const fileSystem = useFixture("");
+    fileSystem.stall("/movies/Moving/.dester-skip-until");
+    assert.equal(await getActiveSkipSentinel("/movies/Moving", 20, NOW), null);
+  });
+});
+
+describe("collectMediaEntries with sentinel files", () => {
+  afterEach(() => setScanFileSystem(null));
+
+  it("skips the folder of an active sentinel", async () => {
+    const fileSystem = useFixture("2999-01-01T00:00:00Z");
+
+    const { paths, skips, sentinelSkips } = await scan();
+
+    assert.deepEqual(paths, ["/movies/Heat (1995).mkv"]);
+    assert.equal(skips.get("/movies/Moving"), "skip-sentinel");
+    assert.deepEqual(sentinelSkips, ["/movies/Moving"]);
+    assert.ok(
+      !fileSystem.calls.stat.includes("/movies/Moving/Oldboy (2003).mkv"),
+    );
+  });
+
+  it("scans the folder of an expired sentinel", async () => {
+    useFixture("2000-01-01T00:00:00Z");
+
+    const { paths, sentinelSkips } = await scan();
+
+    assert.deepEqual(paths, [
+      "/movies/Heat (1995).mkv",
+      "/movies/Moving/Oldboy (2003).mkv",
+    ]);
+    assert.deepEqual(sentinelSkips, []);
+  });
+
+  it("treats a malformed sentinel as an indefinite skip", async () => {
+    useFixture("soon");
+
+    const { paths, skips } = await scan();
+
+    assert.deepEqual(paths, ["/movies/Heat (1995).mkv"]);
+    assert.equal(skips.get("/movies/Moving"), "skip-sentinel");
+  });
+
+  it("reads marker files only in folders that hold them", async () => {
+    const fileSystem = useFixture("");
+
+    await scan({ fileDeadlineMs: 1000 });
+
+    assert.deepEqual(fileSystem.calls.readFile, [
+      "/movies/Moving/.dester-skip-until",
+    ]);
+  });
+});
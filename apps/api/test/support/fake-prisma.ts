@@ -0,0 +1,15 @@
+/**
+ * Stand-in Prisma client for tests of code that queries the database
+ * The database module reuses globalThis.__prisma when it is set, so a test
+ * installs the fake first and imports the code under test afterwards. Only
+ * the model methods a test assigns exist.
+ */
+
+// eslint-disable-next-line @typescript-eslint/no-explicit-any
+export type FakePrisma = Record<string, any>;
+
+export function installFakePrisma(): FakePrisma {
+  const fake: FakePrisma = { $on() {} };
+  (globalThis as { __prisma?: unknown }).__prisma = fake;
+  return fake;
+}
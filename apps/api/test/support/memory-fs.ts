@@ -0,0 +1,203 @@
+/**
+ * In-memory file system for scanner tests
+ * Build a fixture tree from paths, hand it to setScanFileSystem, and the
+ * walker reads it instead of the disk. Every call is recorded, so tests can
+ * assert what a scan read.
+ */
+
+import { basename, dirname, join } from "path";
+import type {
+  ScanDirEntry,
+  ScanFileSystem,
+  ScanStats,
+} from "../../src/domains/scan/helpers/scan-fs.helper";
+
+/**
+ * A fixture file: its contents, or its size and modification time
+ * A file with `link` is a symlink to that path.
+ */
+export type MemoryFile =
+  | string
+  | { contents?: string; size?: number; mtime?: Date; link?: string };
+
+interface FileNode {
+  kind: "file";
+  contents: string;
+  size: number;
+  mtime: Date;
+}
+
+interface DirNode {
+  kind: "dir";
+  mtime: Date;
+  children: Set<string>;
+}
+
+interface LinkNode {
+  kind: "link";
+  target: string;
+  mtime: Date;
+}
+
+type Node = FileNode | DirNode | LinkNode;
+
+export const FIXTURE_MTIME = new Date("2025-01-01T00:00:00Z");
+
+function fsError(code: string, operation: string, path: string): Error {
+  return Object.assign(new Error(`${code}: ${operation} '${path}'`), {
+    code,
+  });
+}
+
+export class MemoryFileSystem implements ScanFileSystem {
+  readonly calls = {
+    opendir: [] as string[],
+    stat: [] as string[],
+    readFile: [] as string[],
+  };
+
+  private nodes = new Map<string, Node>();
+  // Paths whose stat and reads never answer, like a hung network mount
+  private stalled = new Set<string>();
+  private clock = FIXTURE_MTIME.getTime();
+
+  /**
+   * @param files - Fixture files by absolute path; a path ending in "/" is
+   * an empty folder
+   */
+  constructor(files: Record<string, MemoryFile> = {}) {
+    this.nodes.set("/", {
+      kind: "dir",
+      mtime: FIXTURE_MTIME,
+      children: new Set(),
+    });
+    for (const [path, file] of Object.entries(files)) {
+      this.write(path, file, FIXTURE_MTIME);
+    }
+  }
+
+  /**
+   * Add or replace a file, updating the modification time of its folder
+   * when it is new, as a real file system does
+   */
+  write(path: string, file: MemoryFile = "", mtime?: Date): void {
+    const when = mtime ?? this.tick();
+    if (path.endsWith("/")) {
+      this.ensureDir(path.slice(0, -1) || "/", when);
+      return;
+    }
+
+    const parent = this.ensureDir(dirname(path), when);
+    if (!parent.children.has(basename(path))) {
+      parent.children.add(basename(path));
+      parent.mtime = when;
+    }
+
+    const spec = typeof file === "string" ? { contents: file } : file;
+    if (spec.link) {
+      this.nodes.set(path, { kind: "link", target: spec.link, mtime: when });
+      return;
+    }
+    const contents = spec.contents ?? "";
+    this.nodes.set(path, {
+      kind: "file",
+      contents,
+      size: spec.size ?? contents.length,
+      mtime: spec.mtime ?? when,
+    });
+  }
+
+  /**
+   * Remove a file, updating the modification time of its folder
+   */
+  remove(path: string): void {
+    this.nodes.delete(path);
+    const parent = this.nodes.get(dirname(path));
+    if (parent?.kind === "dir" && parent.children.delete(basename(path))) {
+      parent.mtime = this.tick();
+    }
+  }
+
+  /**
+   * Make stat and reads of a path hang forever
+   */
+  stall(path: string): void {
+    this.stalled.add(path);
+  }
+
+  async opendir(path: string): Promise<AsyncIterable<ScanDirEntry>> {
+    this.calls.opendir.push(path);
+    const node = this.nodes.get(path);
+    if (!node) throw fsError("ENOENT", "opendir", path);
+    if (node.kind !== "dir") throw fsError("ENOTDIR", "opendir", path);
+
+    const entries = [...node.children].sort().map((name) => {
+      const child = this.nodes.get(join(path, name))!;
+      return {
+        name,
+        isDirectory: () => child.kind === "dir",
+        isFile: () => child.kind === "file",
+      };
+    });
+    return (async function* () {
+      yield* entries;
+    })();
+  }
+
+  async stat(path: string): Promise<ScanStats> {
+    this.calls.stat.push(path);
+    await this.waitIfStalled(path);
+    const node = this.resolve(path, "stat");
+    const size = node.kind === "file" ? node.size : 0;
+    return {
+      size,
+      mtime: node.mtime,
+      mtimeMs: node.mtime.getTime(),
+      isDirectory: () => node.kind === "dir",
+      isFile: () => node.kind === "file",
+    };
+  }
+
+  async readFile(path: string): Promise<string> {
+    this.calls.readFile.push(path);
+    await this.waitIfStalled(path);
+    const node = this.resolve(path, "open");
+    if (node.kind !== "file") throw fsError("EISDIR", "read", path);
+    return node.contents;
+  }
+
+  // Follow symlinks to the file or folder they point at
+  private resolve(path: string, operation: string): FileNode | DirNode {
+    let node = this.nodes.get(path);
+    for (let hops = 0; node?.kind === "link" && hops < 10; hops++) {
+      node = this.nodes.get(node.target);
+    }
+    if (!node || node.kind === "link") throw fsError("ENOENT", operation, path);
+    return node;
+  }
+
+  private ensureDir(path: string, mtime: Date): DirNode {
+    const existing = this.nodes.get(path);
+    if (existing?.kind === "dir") return existing;
+    if (existing) throw fsError("ENOTDIR", "mkdir", path);
+
+    const parent = this.ensureDir(dirname(path), mtime);
+    parent.children.add(basename(path));
+    parent.mtime = mtime;
+    const dir: DirNode = { kind: "dir", mtime, children: new Set() };
+    this.nodes.set(path, dir);
+    return dir;
+  }
+
+  private async waitIfStalled(path: string): Promise<void> {
+    if (this.stalled.has(path)) {
+      await new Promise(() => {});
+    }
+  }
+
+  // Later changes get later modification times
+  private tick(): Date {
+    this.clock += 1000;
+    return new Date(this.clock);
+  }
+}
@@ -0,0 +1,204 @@
+import { before, beforeEach, describe, it } from "node:test";
+import assert from "node:assert/strict";
+import type * as Stats from "../src/domains/stats/stats.services";
+import { installFakePrisma } from "./support/fake-prisma";
+
+// Installed before the service is imported, which happens in before()
+const prisma = installFakePrisma();
+let statsServices: typeof Stats.statsServices;
+
+// eslint-disable-next-line @typescript-eslint/no-explicit-any
+type Row = Record<string, any>;
+
+interface FileRow {
+  resolution: string | null;
+  fileSize: bigint | null;
+  filePath: string | null;
+  missingSince: Date | null;
+  libraryId: string;
+}
+
+const GB = BigInt(1024 ** 3);
+
+function file(
+  libraryId: string,
+  resolution: string | null,
+  gigabytes: number,
+  extra: Partial<FileRow> = {},
+): FileRow {
+  return {
+    resolution,
+    fileSize: BigInt(gigabytes) * GB,
+    filePath: `/media/${libraryId}/${resolution}-${gigabytes}.mkv`,
+    missingSince: null,
+    libraryId,
+    ...extra,
+  };
+}
+
+let movies: FileRow[];
+let episodes: FileRow[];
+
+// Group seeded rows the way the stats query asks the database to
+function groupByResolution(
+  rows: FileRow[],
+  where: { filePath?: { not: null }; missingSince?: null },
+  libraryId: string | undefined,
+) {
+  const groups = new Map<
+    string | null,
+    { resolution: string | null; _count: { _all: number }; _sum: Row }
+  >();
+  for (const row of rows) {
+    if (where.filePath && row.filePath === null) continue;
+    if (where.missingSince === null && row.missingSince !== null) continue;
+    if (libraryId && row.libraryId !== libraryId) continue;
+
+    const group = groups.get(row.resolution) ?? {
+      resolution: row.resolution,
+      _count: { _all: 0 },
+      _sum: { fileSize: null },
+    };
+    group._count._all++;
+    group._sum.fileSize = (group._sum.fileSize ?? BigInt(0)) + row.fileSize!;
+    groups.set(row.resolution, group);
+  }
+  return [...groups.values()];
+}
+
+before(async () => {
+  ({ statsServices } = await import("../src/domains/stats/stats.services"));
+});
+
+beforeEach(() => {
+  movies = [
+    file("lib-a", "2160p", 40),
+    file("lib-a", "1080i", 10),
+    file("lib-b", null, 5),
+    // Marked missing by library verify
+    file("lib-a", "1080p", 8, { missingSince: new Date() }),
+    // A movie known only from its extras
+    file("lib-a", "1080p", 0, { filePath: null, fileSize: null }),
+  ];
+  episodes = [
+    file("lib-b", "720p", 2),
+    file("lib-b", "480p", 1),
+    file("lib-a", null, 1),
+    file("lib-b", "720p", 0, { filePath: null, fileSize: null }),
+  ];
+
+  prisma.library = {
+    findUnique: async ({ where }: { where: { id: string } }) =>
+      ["lib-a", "lib-b"].includes(where.id) ? { id: where.id } : null,
+  };
+  prisma.movie = {
+    groupBy: async ({ where }: Row) =>
+      groupByResolution(movies, where, where.media?.libraries.some.libraryId),
+  };
+  prisma.episode = {
+    groupBy: async ({ where }: Row) =>
+      groupByResolution(
+        episodes,
+        where,
+        where.season.tvShow.media?.libraries.some.libraryId,
+      ),
+  };
+});
+
+describe("getTechnicalStats", () => {
+  it("counts files and bytes by resolution bucket", async () => {
+    const stats = await statsServices.getTechnicalStats();
+
+    assert.equal(stats?.libraryId, null);
+    assert.deepEqual(stats?.byResolution, [
+      {
+        resolution: "2160p",
+        movies: 1,
+        episodes: 0,
+        files: 1,
+        totalBytes: (BigInt(40) * GB).toString(),
+      },
+      {
+        resolution: "1440p",
+        movies: 0,
+        episodes: 0,
+        files: 0,
+        totalBytes: "0",
+      },
+      {
+        resolution: "1080p",
+        movies: 1,
+        episodes: 0,
+        files: 1,
+        totalBytes: (BigInt(10) * GB).toString(),
+      },
+      {
+        resolution: "720p",
+        movies: 0,
+        episodes: 1,
+        files: 1,
+        totalBytes: (BigInt(2) * GB).toString(),
+      },
+      {
+        resolution: "sd",
+        movies: 0,
+        episodes: 1,
+        files: 1,
+        totalBytes: GB.toString(),
+      },
+      {
+        resolution: "unknown",
+        movies: 1,
+        episodes: 1,
+        files: 2,
+        totalBytes: (BigInt(6) * GB).toString(),
+      },
+    ]);
+    // The missing and fileless rows are left out of the totals too
+    assert.deepEqual(stats?.totals, {
+      files: 6,
+      totalBytes: (BigInt(59) * GB).toString(),
+    });
+  });
+
+  it("counts only the files of one library", async () => {
+    const stats = await statsServices.getTechnicalStats("lib-b");
+    const files = Object.fromEntries(
+      stats!.byResolution.map((bucket) => [bucket.resolution, bucket.files]),
+    );
+
+    assert.equal(stats?.libraryId, "lib-b");
+    assert.deepEqual(files, {
+      "2160p": 0,
+      "1440p": 0,
+      "1080p": 0,
+      "720p": 1,
+      sd: 1,
+      unknown: 1,
+    });
+    assert.equal(stats?.totals.files, 3);
+  });
+
+  it("returns null for a library that does not exist", async () => {
+    assert.equal(await statsServices.getTechnicalStats("lib-x"), null);
+  });
+
+  it("serves cached stats for 5 minutes", async () => {
+    const realNow = Date.now;
+    const startedAt = realNow();
+    try {
+      Date.now = () => startedAt;
+      const first = await statsServices.getTechnicalStats("lib-a");
+      movies.push(file("lib-a", "2160p", 50));
+
+      Date.now = () => startedAt + 5 * 60 * 1000 - 1;
+      assert.equal(await statsServices.getTechnicalStats("lib-a"), first);
+
+      Date.now = () => startedAt + 5 * 60 * 1000;
+      const refreshed = await statsServices.getTechnicalStats("lib-a");
+      assert.equal(refreshed?.totals.files, (first?.totals.files ?? 0) + 1);
+    } finally {
+      Date.now = realNow;
+    }
+  });
+});